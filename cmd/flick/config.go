@@ -1,17 +1,203 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"github.com/astrokkidd/flick/pkg/blob"
+	"github.com/astrokkidd/flick/pkg/crypto"
 	"github.com/astrokkidd/flick/pkg/identity"
+	"github.com/astrokkidd/flick/pkg/store"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/joho/godotenv"
 	"github.com/kelseyhightower/envconfig"
 )
 
 type Config struct {
-	JwtSecret            identity.SecretKey `envconfig:"jwt_secret"`
-	PostgresUrl          string             `envconfig:"postgres_url"`
-	ApiBaseUrl           string             `envconfig:"api_base_address"`
-	MessageEncryptionKey string             `envconfig:"message_encryption_key"`
+	JwtSecret              identity.SecretKey `envconfig:"jwt_secret"`
+	DatabaseDriver         string             `envconfig:"database_driver" default:"postgres"` // postgres or sqlite
+	PostgresUrl            string             `envconfig:"postgres_url"`
+	SqlitePath             string             `envconfig:"sqlite_path" default:"flick.db"`
+	DatabaseMaxConns       int32              `envconfig:"database_max_conns"` // zero keeps the pool_* URL setting or pgxpool's default
+	DatabaseMinConns       int32              `envconfig:"database_min_conns"`
+	DatabaseMaxConnLife    time.Duration      `envconfig:"database_max_conn_lifetime"`
+	DatabaseMaxConnIdle    time.Duration      `envconfig:"database_max_conn_idle_time"`
+	DatabaseHealthCheck    time.Duration      `envconfig:"database_health_check_period"`
+	DatabaseAcquireTimeout time.Duration      `envconfig:"database_acquire_timeout" default:"5s"` // sqlite: how long to wait on another writer
+	ApiBaseUrl             string             `envconfig:"api_base_address"`
+	MessageEncryptionKey   string             `envconfig:"message_encryption_key"`  // pre-rotation key, opens rows without a key ID
+	MessageEncryptionKeys  map[string]string  `envconfig:"message_encryption_keys"` // id:base64,id:base64
+	MessageEncryptionKeyID string             `envconfig:"message_encryption_key_id"`
+	MessageRequireAAD      bool               `envconfig:"message_require_aad"`        // set once reencrypt has bound every row
+	KeyProvider            string             `envconfig:"key_provider" default:"env"` // env, file or local-kms
+	MasterKeyFile          string             `envconfig:"master_key_file"`
+	MasterKeyID            string             `envconfig:"master_key_id"`
+	AccessTokenTTL         time.Duration      `envconfig:"access_token_ttl" default:"15m"`
+	RefreshTokenTTL        time.Duration      `envconfig:"refresh_token_ttl" default:"720h"`
+	SessionCacheTTL        time.Duration      `envconfig:"session_cache_ttl" default:"30s"`
+	MessageEditWindow      time.Duration      `envconfig:"message_edit_window" default:"15m"`      // zero allows edits at any time
+	MessageMaxReactions    int64              `envconfig:"message_max_reactions" default:"20"`     // distinct emoji per message
+	AttachmentMaxSize      int64              `envconfig:"attachment_max_size" default:"26214400"` // bytes per file
+	AttachmentQuota        int64              `envconfig:"attachment_quota" default:"1073741824"`  // bytes per user
+	AvatarMaxSize          int64              `envconfig:"avatar_max_size" default:"10485760"`     // bytes per profile picture upload
+	UserLookupRate         float64            `envconfig:"user_lookup_rate" default:"2"`           // user searches and profile views per second, per user
+	UserLookupBurst        int                `envconfig:"user_lookup_burst" default:"20"`         // requests allowed at once before the rate applies
+	BlobDriver             string             `envconfig:"blob_driver" default:"local"`            // local or s3
+	BlobDir                string             `envconfig:"blob_dir" default:"blobs"`
+	S3Endpoint             string             `envconfig:"s3_endpoint"`
+	S3Region               string             `envconfig:"s3_region" default:"us-east-1"`
+	S3Bucket               string             `envconfig:"s3_bucket"`
+	S3AccessKey            string             `envconfig:"s3_access_key"`
+	S3SecretKey            string             `envconfig:"s3_secret_key"`
+	S3PathStyle            bool               `envconfig:"s3_path_style"` // MinIO and most other S3-compatible servers
+	PasswordMinLength      int                `envconfig:"password_min_length" default:"10"`
+	PasswordMaxLength      int                `envconfig:"password_max_length" default:"128"`
+	CommonPasswordsFile    string             `envconfig:"common_passwords_file"`
+	Argon2Memory           uint32             `envconfig:"argon2_memory" default:"65536"` // KiB
+	Argon2Iterations       uint32             `envconfig:"argon2_iterations" default:"3"`
+	Argon2Parallelism      uint8              `envconfig:"argon2_parallelism" default:"2"`
+	LoginRate              float64            `envconfig:"login_rate" default:"0.2"` // sign-in and recovery attempts per second, per address
+	LoginBurst             int                `envconfig:"login_burst" default:"10"` // attempts allowed at once before the rate applies
+	SocketOrigins          []string           `envconfig:"socket_origins"`           // hosts of web clients on other origins, e.g. app.getflick.chat,*.getflick.chat
 }
 
 func (cfg *Config) Load() {
 	envconfig.MustProcess("flick", cfg)
 }
+
+// store opens the database FLICK_DATABASE_DRIVER picks. close releases it
+// on shutdown.
+func (cfg *Config) store(ctx context.Context) (st store.Store, close func(), err error) {
+	switch cfg.DatabaseDriver {
+	case "postgres":
+		pool, err := cfg.pool(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		return store.NewPostgres(pool, cfg.DatabaseAcquireTimeout), pool.Close, nil
+	case "sqlite":
+		lite, err := store.OpenSQLite(ctx, cfg.SqlitePath, cfg.DatabaseAcquireTimeout)
+		if err != nil {
+			return nil, nil, err
+		}
+		return lite, func() { lite.Close() }, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown database driver %q", cfg.DatabaseDriver)
+	}
+}
+
+// blobs opens the store for attachments and avatars FLICK_BLOB_DRIVER picks.
+func (cfg *Config) blobs() (blob.Store, error) {
+	switch cfg.BlobDriver {
+	case "local":
+		return blob.NewLocal(cfg.BlobDir)
+	case "s3":
+		return blob.NewS3(blob.S3Config{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			PathStyle: cfg.S3PathStyle,
+		})
+	default:
+		return nil, fmt.Errorf("unknown blob driver %q", cfg.BlobDriver)
+	}
+}
+
+// pool opens the Postgres connection pool and checks it can reach the
+// server.
+func (cfg *Config) pool(ctx context.Context) (*pgxpool.Pool, error) {
+	poolCfg, err := pgxpool.ParseConfig(cfg.PostgresUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.DatabaseMaxConns > 0 {
+		poolCfg.MaxConns = cfg.DatabaseMaxConns
+	}
+	if cfg.DatabaseMinConns > 0 {
+		poolCfg.MinConns = cfg.DatabaseMinConns
+	}
+	if cfg.DatabaseMaxConnLife > 0 {
+		poolCfg.MaxConnLifetime = cfg.DatabaseMaxConnLife
+	}
+	if cfg.DatabaseMaxConnIdle > 0 {
+		poolCfg.MaxConnIdleTime = cfg.DatabaseMaxConnIdle
+	}
+	if cfg.DatabaseHealthCheck > 0 {
+		poolCfg.HealthCheckPeriod = cfg.DatabaseHealthCheck
+	}
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// The pool connects lazily, so fail at startup rather than on the first request
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, err
+	}
+
+	return pool, nil
+}
+
+// keyring builds the message keyring from the FLICK_MESSAGE_ENCRYPTION_*
+// settings.
+func (cfg *Config) keyring() (*crypto.Keyring, error) {
+	keyring, err := crypto.NewKeyring(cfg.MessageEncryptionKeyID, cfg.MessageEncryptionKeys, cfg.MessageEncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MessageRequireAAD {
+		keyring.RequireAAD()
+	}
+	return keyring, nil
+}
+
+// keyProvider picks where the master key wrapping chat data keys lives.
+// The env provider reuses the message keyring.
+func (cfg *Config) keyProvider(keyring *crypto.Keyring) (crypto.KeyProvider, error) {
+	switch cfg.KeyProvider {
+	case "env":
+		return crypto.NewEnvKeyProvider(keyring), nil
+	case "file":
+		return crypto.NewFileKeyProvider(cfg.MasterKeyFile, cfg.MasterKeyID)
+	case "local-kms":
+		return crypto.NewLocalKMS()
+	default:
+		return nil, fmt.Errorf("unknown key provider %q", cfg.KeyProvider)
+	}
+}
+
+// reloadKeys rereads .env and the environment and swaps the cipher's keys.
+// The local KMS keeps its key, as a new one could not open anything.
+func reloadKeys(cipher *crypto.AESGCM) error {
+	if err := godotenv.Overload(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	var next Config
+	if err := envconfig.Process("flick", &next); err != nil {
+		return err
+	}
+
+	keyring, err := next.keyring()
+	if err != nil {
+		return err
+	}
+
+	var provider crypto.KeyProvider
+	if next.KeyProvider != "local-kms" {
+		if provider, err = next.keyProvider(keyring); err != nil {
+			return err
+		}
+	}
+
+	cipher.Reload(keyring, provider)
+	return nil
+}
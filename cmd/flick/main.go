@@ -10,10 +10,9 @@ import (
 	"time"
 
 	"github.com/astrokkidd/flick/pkg/crypto"
-	"github.com/astrokkidd/flick/pkg/database"
 	"github.com/astrokkidd/flick/pkg/identity"
+	"github.com/astrokkidd/flick/pkg/realtime"
 	"github.com/astrokkidd/flick/pkg/route"
-	"github.com/jackc/pgx/v5"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 
@@ -29,45 +28,106 @@ func init() {
 func main() {
 	ctx := context.Background()
 
-	conn, err := pgx.Connect(ctx, cfg.PostgresUrl)
+	st, closeStore, err := cfg.store(ctx)
 	if err != nil {
-		panic(err)
+		log.Fatal("database init failed:", err)
 	}
-	defer conn.Close(ctx)
+	defer closeStore()
 
-	queries := database.New(conn)
-
-	if err := crypto.Init(cfg.MessageEncryptionKey); err != nil {
+	keyring, err := cfg.keyring()
+	if err != nil {
 		log.Fatal("encryption init failed:", err)
 	}
 
-	tokenHandler := identity.NewTokenHandler(cfg.JwtSecret)
+	provider, err := cfg.keyProvider(keyring)
+	if err != nil {
+		log.Fatal("key provider init failed:", err)
+	}
+
+	cipher := crypto.NewAESGCM(keyring, provider)
+
+	blobs, err := cfg.blobs()
+	if err != nil {
+		log.Fatal("blob store init failed:", err)
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "reencrypt" {
+		reencrypt(ctx, st, cipher, os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "reindex" {
+		reindex(ctx, st, cipher, os.Args[2:])
+		return
+	}
+
+	tokenHandler := identity.NewTokenHandler(cfg.JwtSecret, cfg.AccessTokenTTL, cfg.RefreshTokenTTL)
+
+	passwords, err := identity.NewPasswordPolicy(cfg.PasswordMinLength, cfg.PasswordMaxLength, cfg.CommonPasswordsFile)
+	if err != nil {
+		log.Fatal("password policy init failed:", err)
+	}
+
+	hashing, err := identity.NewArgon2Params(cfg.Argon2Memory, cfg.Argon2Iterations, cfg.Argon2Parallelism)
+	if err != nil {
+		log.Fatal("password hashing init failed:", err)
+	}
+
+	sessions := identity.NewSessionCache(route.NewSessionLookup(st), cfg.SessionCacheTTL)
+	auth := identity.Authenticate(&tokenHandler, sessions)
+
+	hub := realtime.NewHub()
+	sessions.OnRevoke(hub.DisconnectSession)
 
 	e := echo.New()
 
-	e.Use(middleware.Logger())
+	// echo's default format, logging the path where it has the full URI:
+	// query strings carry search terms, and access tokens from clients that
+	// put them in the URL rather than the gateway's subprotocol handshake.
+	e.Use(middleware.LoggerWithConfig(middleware.LoggerConfig{
+		Format: `{"time":"${time_rfc3339_nano}","id":"${id}","remote_ip":"${remote_ip}",` +
+			`"host":"${host}","method":"${method}","path":"${path}","user_agent":"${user_agent}",` +
+			`"status":${status},"error":"${error}","latency":${latency},"latency_human":"${latency_human}"` +
+			`,"bytes_in":${bytes_in},"bytes_out":${bytes_out}}` + "\n",
+	}))
 	e.Use(middleware.Recover())
 	e.Use(middleware.CORS())
 
 	api := e.Group("/v1")
 
 	//-- AUTH --//
-	authHandler := route.NewAuthHandler(queries, &tokenHandler)
+	authHandler := route.NewAuthHandler(st, &tokenHandler, sessions, passwords, hashing, cipher)
+	logins := identity.RateLimit(cfg.LoginRate, cfg.LoginBurst)
 	api.POST("/auth/register", authHandler.Register)
-	api.POST("/auth/login", authHandler.Login)
+	api.POST("/auth/login", authHandler.Login, logins)
+	api.POST("/auth/refresh", authHandler.Refresh)
+	api.POST("/auth/login/totp", authHandler.LoginTOTP, logins)
+	api.POST("/auth/recover", authHandler.Recover, logins)
+	session := api.Group("/auth", auth)
+	session.POST("/logout", authHandler.Logout)
+	session.GET("/sessions", authHandler.ListSessions)
+	session.DELETE("/sessions/:id", authHandler.RevokeSession)
+	session.POST("/totp/enroll", authHandler.EnrollTOTP)
+	session.POST("/totp/confirm", authHandler.ConfirmTOTP)
 
 	//-- USER --//
-	userHandler := route.NewUserHandler(queries, conn, &tokenHandler)
-	users := api.Group("/users", identity.Authenticate(&tokenHandler))
+	userHandler := route.NewUserHandler(st, &tokenHandler, sessions, passwords, hashing, blobs, cfg.AvatarMaxSize)
+	api.GET("/avatars/:avatar_id", userHandler.Avatar)
+	api.GET("/identicons/:hash", userHandler.Identicon)
+	lookups := identity.RateLimit(cfg.UserLookupRate, cfg.UserLookupBurst)
+	users := api.Group("/users", auth)
 	users.PUT("/pfp", userHandler.UpdateProfilePicture)
 	users.PUT("/pfp/delete", userHandler.RemoveProfilePicture)
 	users.PUT("/display-name", userHandler.UpdateDisplayName)
 	users.PUT("/password", userHandler.UpdatePassword)
 	users.GET("/profile", userHandler.GetProfile)
+	users.GET("/blocks", userHandler.ListBlockedUsers)
+	users.GET("/:id", userHandler.GetUserProfile, lookups)
+	users.PUT("/:id/block", userHandler.BlockUser)
+	users.DELETE("/:id/block", userHandler.UnblockUser)
 
 	//-- FRIENDS --//
-	requestHandler := route.NewRequestHandler(queries, conn, &tokenHandler)
-	friends := api.Group("/friends", identity.Authenticate(&tokenHandler))
+	requestHandler := route.NewRequestHandler(st, &tokenHandler)
+	friends := api.Group("/friends", auth)
 	friends.GET("", requestHandler.GetFriends)
 	friends.GET("/requests/received", requestHandler.GetReceivedRequests)
 	friends.GET("/requests/sent", requestHandler.GetSentRequests)
@@ -75,18 +135,51 @@ func main() {
 	friends.POST("/requests/:id/accept", requestHandler.AcceptRequest)
 	friends.POST("/requests/:id/delete", requestHandler.DeleteRequest)
 
+	//-- KEYS --//
+	keyHandler := route.NewKeyHandler(st, &tokenHandler)
+	keys := api.Group("/keys", auth)
+	keys.PUT("", keyHandler.UploadKeys)
+	keys.GET("/:user_id", keyHandler.GetUserKeys)
+
 	//-- CHATS --//
-	chatHandler := route.NewChatHandler(queries, conn, &tokenHandler)
-	chat := api.Group("/chats", identity.Authenticate(&tokenHandler))
+	chatHandler := route.NewChatHandler(st, &tokenHandler, hub, cipher, blobs)
+	chat := api.Group("/chats", auth)
 	chat.POST("", chatHandler.CreateChat)
 	chat.GET("", chatHandler.GetChats)
 	chat.POST("/:id/read", chatHandler.SetLastReadMessage)
 	chat.POST("/:id/typing/:status", chatHandler.SetTypingStatus)
+	chat.PUT("/:id/title", chatHandler.RenameChat)
+	chat.POST("/:id/members", chatHandler.AddMembers)
+	chat.DELETE("/:id/members/:user_id", chatHandler.RemoveMember)
+	chat.PUT("/:id/members/:user_id/role", chatHandler.SetMemberRole)
+	chat.POST("/:id/leave", chatHandler.LeaveChat)
 
 	//-- MESSAGES --//
-	messageHandler := route.NewMessageHandler(queries, conn, &tokenHandler)
+	messageHandler := route.NewMessageHandler(st, &tokenHandler, hub, cipher, blobs, cfg.MessageEditWindow, cfg.MessageMaxReactions)
 	chat.POST("/:id/messages", messageHandler.CreateMessage)
 	chat.GET("/:id/messages", messageHandler.GetMessages)
+	chat.PATCH("/:id/messages/:message_id", messageHandler.EditMessage)
+	chat.DELETE("/:id/messages/:message_id", messageHandler.DeleteMessage)
+	chat.POST("/:id/messages/:message_id/reactions", messageHandler.AddReaction)
+	chat.DELETE("/:id/messages/:message_id/reactions", messageHandler.RemoveReaction)
+	chat.GET("/:id/messages/:message_id/revisions", messageHandler.GetRevisions)
+	chat.GET("/:id/messages/:message_id/thread", messageHandler.GetThread)
+	chat.POST("/:id/messages/:message_id/thread/read", messageHandler.SetThreadLastRead)
+
+	//-- ATTACHMENTS --//
+	attachmentHandler := route.NewAttachmentHandler(st, &tokenHandler, cipher, blobs, cfg.AttachmentMaxSize, cfg.AttachmentQuota)
+	chat.POST("/:id/attachments", attachmentHandler.Upload)
+	chat.GET("/:id/attachments/:attachment_id", attachmentHandler.Download)
+	chat.GET("/:id/attachments/:attachment_id/thumbnail", attachmentHandler.Thumbnail)
+
+	//-- SEARCH --//
+	searches := api.Group("/search", auth)
+	searches.GET("/messages", messageHandler.SearchMessages)
+	searches.GET("/users", userHandler.SearchUsers, lookups)
+
+	//-- REALTIME --//
+	socketHandler := route.NewSocketHandler(hub, &tokenHandler, sessions, cfg.SocketOrigins)
+	api.GET("/ws", socketHandler.Connect)
 
 	go func() {
 		if err := e.Start(":8080"); err != nil && err != http.ErrServerClosed {
@@ -95,6 +188,19 @@ func main() {
 	}()
 	log.Println("Flick API running on http://localhost:8080")
 
+	// SIGHUP picks up rotated keys without dropping connections
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := reloadKeys(cipher); err != nil {
+				log.Println("key reload failed:", err)
+				continue
+			}
+			log.Println("encryption keys reloaded")
+		}
+	}()
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 
@@ -102,6 +208,9 @@ func main() {
 
 	log.Println("Shutting down Flick API...")
 
+	// Hijacked websocket connections are not tracked by Shutdown
+	hub.Close()
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	if err := e.Shutdown(ctx); err != nil {
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"time"
+
+	"github.com/astrokkidd/flick/pkg/crypto"
+	"github.com/astrokkidd/flick/pkg/database"
+	"github.com/jackc/pgx/v5"
+)
+
+// sealedRow is a ciphertext column value along with the key of its row.
+type sealedRow struct {
+	id   int64
+	data []byte
+
+	chatID, senderID, messageID int64 // messages and their revisions
+}
+
+// reseal returns the row's new ciphertext, or false when it is already
+// sealed the way new rows are.
+type reseal func(row sealedRow) ([]byte, bool, error)
+
+// reencrypt moves every sealed column onto the current keys: chat keys are
+// rewrapped under the active master key, messages and their earlier
+// revisions are moved into their chat's data key and TOTP secrets onto the
+// active message key, binding associated data as it goes. Attachment blobs
+// are always sealed under their chat's data key, which rewrapping leaves as
+// it is, so they need no pass of their own. It is safe to run next to the
+// API: rows are walked in key order in small batches and an update only
+// lands if the row wasn't rewritten in the meantime, so it can be stopped
+// and restarted at any point.
+func reencrypt(ctx context.Context, queries database.Querier, cipher crypto.Cipher, args []string) {
+	flags := flag.NewFlagSet("reencrypt", flag.ExitOnError)
+	batchSize := flags.Int("batch", 500, "rows to load per batch")
+	pause := flags.Duration("pause", 100*time.Millisecond, "sleep between batches to limit load")
+	flags.Parse(args)
+
+	log.Printf("re-encrypting with key %q", cipher.ActiveKeyID())
+
+	reencryptColumn("chat keys", int32(*batchSize), *pause,
+		func(after int64, limit int32) ([]sealedRow, error) {
+			rows, err := queries.ListChatKeys(ctx, database.ListChatKeysParams{AfterID: after, BatchSize: limit})
+			sealed := make([]sealedRow, len(rows))
+			for i, r := range rows {
+				sealed[i] = sealedRow{id: r.ChatID, data: r.WrappedKey}
+			}
+			return sealed, err
+		},
+		func(row sealedRow) ([]byte, bool, error) {
+			return cipher.RewrapDataKey(ctx, row.data, crypto.ChatKeyAAD(row.id))
+		},
+		func(id int64, old, updated []byte) (int64, error) {
+			return queries.ReplaceChatKey(ctx, database.ReplaceChatKeyParams{
+				NewWrappedKey: updated,
+				ChatID:        id,
+				OldWrappedKey: old,
+			})
+		},
+	)
+
+	chats := map[int64]crypto.DataKey{}
+
+	resealMessage := func(row sealedRow) ([]byte, bool, error) {
+		if crypto.IsEnvelope(row.data) {
+			return row.data, false, nil
+		}
+
+		key, ok := chats[row.chatID]
+		if !ok {
+			var err error
+			if key, err = loadChatKey(ctx, queries, cipher, row.chatID); err != nil {
+				return nil, false, err
+			}
+			chats[row.chatID] = key
+		}
+
+		aad := crypto.MessageAAD(row.chatID, row.senderID, row.messageID)
+		plaintext, err := key.Decrypt(row.data, aad)
+		if err != nil {
+			return nil, false, err
+		}
+
+		sealed, err := key.Encrypt(plaintext, aad)
+		return sealed, err == nil, err
+	}
+
+	reencryptColumn("messages", int32(*batchSize), *pause,
+		func(after int64, limit int32) ([]sealedRow, error) {
+			rows, err := queries.ListMessageCiphertexts(ctx, database.ListMessageCiphertextsParams{AfterID: after, BatchSize: limit})
+			sealed := make([]sealedRow, len(rows))
+			for i, r := range rows {
+				sealed[i] = sealedRow{id: r.MessageID, data: r.CypherText, chatID: r.ChatID, senderID: r.SenderID, messageID: r.MessageID}
+			}
+			return sealed, err
+		},
+		resealMessage,
+		func(id int64, old, updated []byte) (int64, error) {
+			return queries.ReplaceMessageCiphertext(ctx, database.ReplaceMessageCiphertextParams{
+				NewCypherText: updated,
+				MessageID:     id,
+				OldCypherText: old,
+			})
+		},
+	)
+
+	reencryptColumn("message revisions", int32(*batchSize), *pause,
+		func(after int64, limit int32) ([]sealedRow, error) {
+			rows, err := queries.ListMessageRevisionCiphertexts(ctx, database.ListMessageRevisionCiphertextsParams{AfterID: after, BatchSize: limit})
+			sealed := make([]sealedRow, len(rows))
+			for i, r := range rows {
+				sealed[i] = sealedRow{id: r.RevisionID, data: r.CypherText, chatID: r.ChatID, senderID: r.SenderID, messageID: r.MessageID}
+			}
+			return sealed, err
+		},
+		resealMessage,
+		func(id int64, old, updated []byte) (int64, error) {
+			return queries.ReplaceMessageRevisionCiphertext(ctx, database.ReplaceMessageRevisionCiphertextParams{
+				NewCypherText: updated,
+				RevisionID:    id,
+				OldCypherText: old,
+			})
+		},
+	)
+
+	reencryptColumn("totp secrets", int32(*batchSize), *pause,
+		func(after int64, limit int32) ([]sealedRow, error) {
+			rows, err := queries.ListTOTPSecrets(ctx, database.ListTOTPSecretsParams{AfterID: after, BatchSize: limit})
+			sealed := make([]sealedRow, len(rows))
+			for i, r := range rows {
+				sealed[i] = sealedRow{id: r.UserID, data: r.TotpSecret}
+			}
+			return sealed, err
+		},
+		func(row sealedRow) ([]byte, bool, error) {
+			if !cipher.NeedsReencrypt(row.data) {
+				return row.data, false, nil
+			}
+
+			aad := crypto.UserSecretAAD(row.id)
+			plaintext, err := cipher.Decrypt(row.data, aad)
+			if err != nil {
+				return nil, false, err
+			}
+
+			sealed, err := cipher.Encrypt(plaintext, aad)
+			return sealed, err == nil, err
+		},
+		func(id int64, old, updated []byte) (int64, error) {
+			return queries.ReplaceTOTPSecret(ctx, database.ReplaceTOTPSecretParams{
+				NewTotpSecret: updated,
+				UserID:        id,
+				OldTotpSecret: old,
+			})
+		},
+	)
+}
+
+// loadChatKey unwraps a chat's data key, creating it for chats that predate
+// per-chat keys.
+func loadChatKey(ctx context.Context, queries database.Querier, cipher crypto.Cipher, chatID int64) (crypto.DataKey, error) {
+	aad := crypto.ChatKeyAAD(chatID)
+
+	wrapped, err := queries.GetChatKey(ctx, database.GetChatKeyParams{ChatID: chatID})
+	if errors.Is(err, pgx.ErrNoRows) {
+		_, fresh, err := cipher.NewDataKey(ctx, aad)
+		if err != nil {
+			return nil, err
+		}
+		if err := queries.CreateChatKey(ctx, database.CreateChatKeyParams{ChatID: chatID, WrappedKey: fresh}); err != nil {
+			return nil, err
+		}
+		wrapped, err = queries.GetChatKey(ctx, database.GetChatKeyParams{ChatID: chatID})
+		if err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	return cipher.OpenDataKey(ctx, wrapped, aad)
+}
+
+func reencryptColumn(
+	name string,
+	batchSize int32,
+	pause time.Duration,
+	list func(after int64, limit int32) ([]sealedRow, error),
+	reseal reseal,
+	replace func(id int64, old, updated []byte) (int64, error),
+) {
+	var after, scanned, rewritten, failed int64
+
+	for {
+		rows, err := list(after, batchSize)
+		if err != nil {
+			log.Fatalf("%s: loading batch after %d: %v", name, after, err)
+		}
+		if len(rows) == 0 {
+			break
+		}
+
+		for _, row := range rows {
+			after = row.id
+			scanned++
+
+			sealed, changed, err := reseal(row)
+			if err != nil {
+				log.Printf("%s: row %d (key %q) cannot be resealed: %v", name, row.id, crypto.KeyID(row.data), err)
+				failed++
+				continue
+			}
+			if !changed {
+				continue
+			}
+
+			n, err := replace(row.id, row.data, sealed)
+			if err != nil {
+				log.Fatalf("%s: updating row %d: %v", name, row.id, err)
+			}
+			rewritten += n
+		}
+
+		log.Printf("%s: %d scanned, %d re-encrypted, %d failed", name, scanned, rewritten, failed)
+		time.Sleep(pause)
+	}
+
+	log.Printf("%s: done, %d scanned, %d re-encrypted, %d failed", name, scanned, rewritten, failed)
+}
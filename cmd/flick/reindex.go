@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/astrokkidd/flick/pkg/crypto"
+	"github.com/astrokkidd/flick/pkg/database"
+	"github.com/astrokkidd/flick/pkg/search"
+	"github.com/astrokkidd/flick/pkg/store"
+	"github.com/jackc/pgx/v5"
+)
+
+var errUnreadable = errors.New("message cannot be opened")
+
+// reindex rebuilds the search index of every message the server can read,
+// which fills it in for messages sent before search existed. Each message is
+// reread and reindexed in its own transaction, so an edit landing meanwhile
+// is never overwritten with the old body. Like reencrypt it is safe to run
+// next to the API and to stop and restart at any point.
+func reindex(ctx context.Context, st store.Store, cipher crypto.Cipher, args []string) {
+	flags := flag.NewFlagSet("reindex", flag.ExitOnError)
+	batchSize := flags.Int("batch", 500, "messages to load per batch")
+	pause := flags.Duration("pause", 100*time.Millisecond, "sleep between batches to limit load")
+	flags.Parse(args)
+
+	chats := map[int64]crypto.DataKey{}
+	var after, scanned, indexed, failed int64
+
+	for {
+		rows, err := st.ListMessageCiphertexts(ctx, database.ListMessageCiphertextsParams{AfterID: after, BatchSize: int32(*batchSize)})
+		if err != nil {
+			log.Fatalf("messages: loading batch after %d: %v", after, err)
+		}
+		if len(rows) == 0 {
+			break
+		}
+
+		for _, row := range rows {
+			after = row.MessageID
+			scanned++
+
+			key, ok := chats[row.ChatID]
+			if !ok {
+				if key, err = loadChatKey(ctx, st, cipher, row.ChatID); err != nil {
+					log.Printf("messages: chat %d key cannot be loaded: %v", row.ChatID, err)
+					failed++
+					continue
+				}
+				chats[row.ChatID] = key
+			}
+
+			err := st.Tx(ctx, func(qtx database.Querier) error {
+				m, err := qtx.GetMessageForUpdate(ctx, database.GetMessageForUpdateParams{ChatID: row.ChatID, MessageID: row.MessageID})
+				if errors.Is(err, pgx.ErrNoRows) || (err == nil && m.DeletedAt.Valid) {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+
+				plaintext, err := key.Decrypt(m.CypherText, crypto.MessageAAD(row.ChatID, m.SenderID, row.MessageID))
+				if err != nil {
+					return fmt.Errorf("%w: %w", errUnreadable, err)
+				}
+				tokens, err := search.Index(key, string(plaintext))
+				if err != nil {
+					return fmt.Errorf("%w: %w", errUnreadable, err)
+				}
+
+				if err := qtx.DeleteMessageSearchTokens(ctx, database.DeleteMessageSearchTokensParams{MessageID: row.MessageID}); err != nil {
+					return err
+				}
+				if len(tokens) == 0 {
+					return nil
+				}
+				return qtx.CreateMessageSearchTokens(ctx, database.CreateMessageSearchTokensParams{MessageID: row.MessageID, Tokens: tokens})
+			})
+			if errors.Is(err, errUnreadable) {
+				log.Printf("messages: row %d (key %q) cannot be indexed: %v", row.MessageID, crypto.KeyID(row.CypherText), err)
+				failed++
+				continue
+			}
+			if err != nil {
+				log.Fatalf("messages: indexing row %d: %v", row.MessageID, err)
+			}
+			indexed++
+		}
+
+		log.Printf("messages: %d scanned, %d indexed, %d failed", scanned, indexed, failed)
+		time.Sleep(*pause)
+	}
+
+	log.Printf("messages: done, %d scanned, %d indexed, %d failed", scanned, indexed, failed)
+}
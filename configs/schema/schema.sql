@@ -1,3 +1,6 @@
+-- Trigram indexes for the user directory search
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
 -- =========================
 -- Core identity
 -- =========================
@@ -8,18 +11,115 @@ CREATE TABLE users (
   last_name     TEXT         NOT NULL,
   password_hash TEXT         NOT NULL,
   pfp_url       TEXT,
-  created_at    TIMESTAMPTZ  NOT NULL DEFAULT now()
+  created_at    TIMESTAMPTZ  NOT NULL DEFAULT now(),
+
+  -- TOTP secret sealed with the message key; only enforced once enabled
+  totp_secret     BYTEA,
+  totp_enabled    BOOLEAN    NOT NULL DEFAULT FALSE,
+  totp_last_step  BIGINT,
+
+  -- Wrong second-factor codes in a row; past the limit each further try
+  -- waits out mfa_locked_until
+  mfa_failed_attempts  INTEGER      NOT NULL DEFAULT 0,
+  mfa_locked_until     TIMESTAMPTZ,
+
+  -- Set while pfp_url points at an uploaded avatar, whose renderings are
+  -- stored under avatars/<avatar_id>-<size>
+  avatar_id       TEXT
 );
 
 CREATE UNIQUE INDEX uq_users_display_name_ci ON users ((lower(display_name)));
 
+CREATE INDEX idx_users_display_name_trgm ON users USING gin ((lower(display_name)) gin_trgm_ops);
+CREATE INDEX idx_users_full_name_trgm ON users USING gin ((lower(first_name || ' ' || last_name)) gin_trgm_ops);
+
+-- =========================
+-- Auth
+-- =========================
+CREATE TABLE refresh_tokens (
+  token_id    BIGSERIAL    PRIMARY KEY,
+  user_id     BIGINT       NOT NULL,
+  family_id   UUID         NOT NULL,
+  token_hash  BYTEA        NOT NULL UNIQUE,
+  created_at  TIMESTAMPTZ  NOT NULL DEFAULT now(),
+  expires_at  TIMESTAMPTZ  NOT NULL,
+  used_at     TIMESTAMPTZ,
+  revoked_at  TIMESTAMPTZ,
+
+  FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE ON UPDATE RESTRICT
+);
+
+CREATE INDEX idx_refresh_tokens_family ON refresh_tokens (family_id);
+
+-- One row per signed-in device. The session id doubles as the jti of its
+-- access tokens and the family id of its refresh tokens.
+CREATE TABLE sessions (
+  session_id    UUID         PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id       BIGINT       NOT NULL,
+  user_agent    TEXT         NOT NULL DEFAULT '',
+  ip_address    TEXT         NOT NULL DEFAULT '',
+  created_at    TIMESTAMPTZ  NOT NULL DEFAULT now(),
+  last_seen_at  TIMESTAMPTZ  NOT NULL DEFAULT now(),
+  revoked_at    TIMESTAMPTZ,
+
+  FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE ON UPDATE RESTRICT
+);
+
+CREATE INDEX idx_sessions_user ON sessions (user_id);
+
+CREATE TABLE recovery_codes (
+  code_id     BIGSERIAL    PRIMARY KEY,
+  user_id     BIGINT       NOT NULL,
+  code_hash   BYTEA        NOT NULL UNIQUE,
+  created_at  TIMESTAMPTZ  NOT NULL DEFAULT now(),
+  used_at     TIMESTAMPTZ,
+
+  FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE ON UPDATE RESTRICT
+);
+
+CREATE INDEX idx_recovery_codes_user ON recovery_codes (user_id);
+
+-- Login challenges that have been presented, so each is good for one try.
+-- Rows are only needed until the challenge would have expired anyway.
+CREATE TABLE used_mfa_challenges (
+  challenge_id  TEXT         PRIMARY KEY,
+  user_id       BIGINT       NOT NULL,
+  expires_at    TIMESTAMPTZ  NOT NULL,
+
+  FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE ON UPDATE RESTRICT
+);
+
+CREATE INDEX idx_used_mfa_challenges_expires ON used_mfa_challenges (expires_at);
+
+-- Public half of a user's end-to-end keys. The identity key is Ed25519 and
+-- signs the current prekey; private keys never leave the client.
+CREATE TABLE user_keys (
+  user_id                  BIGINT       PRIMARY KEY,
+  identity_key             BYTEA        NOT NULL,
+  signed_prekey_id         BIGINT,
+  signed_prekey            BYTEA,
+  signed_prekey_signature  BYTEA,
+  updated_at               TIMESTAMPTZ  NOT NULL DEFAULT now(),
+
+  CONSTRAINT signed_prekey_complete CHECK (
+    (signed_prekey_id IS NULL) = (signed_prekey IS NULL)
+    AND (signed_prekey IS NULL) = (signed_prekey_signature IS NULL)
+  ),
+  FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE ON UPDATE RESTRICT
+);
+
 -- =========================
 -- Chats & participants
 -- =========================
 CREATE TABLE chats (
   chat_id          BIGSERIAL   PRIMARY KEY,
+  kind             TEXT        NOT NULL DEFAULT 'direct' CHECK (kind IN ('direct', 'group')),
+  title            TEXT,
   last_message_id  BIGINT,
-  created_at       TIMESTAMPTZ NOT NULL DEFAULT now()
+  created_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+  -- Messages hold client ciphertext the server cannot open
+  end_to_end       BOOLEAN     NOT NULL DEFAULT FALSE
 );
 
 CREATE TABLE chat_participants (
@@ -29,6 +129,8 @@ CREATE TABLE chat_participants (
   typing_updated_at     TIMESTAMPTZ,
   last_read_message_id  BIGINT,
   last_read_at          TIMESTAMPTZ,
+  role                  TEXT         NOT NULL DEFAULT 'member' CHECK (role IN ('owner', 'admin', 'member')),
+  joined_at             TIMESTAMPTZ  NOT NULL DEFAULT now(),
   PRIMARY KEY (chat_id, user_id),
   FOREIGN KEY (chat_id) REFERENCES chats(chat_id) ON DELETE CASCADE ON UPDATE RESTRICT,
   FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE ON UPDATE RESTRICT
@@ -43,9 +145,118 @@ CREATE TABLE messages (
   cypher_text BYTEA        NOT NULL,
   sender_id   BIGINT       NOT NULL,
   created_at  TIMESTAMPTZ  NOT NULL DEFAULT now(),
+  edited_at   TIMESTAMPTZ,
+  deleted_at  TIMESTAMPTZ, -- deleted for everyone: the row stays for ordering, the body is wiped
 
-  FOREIGN KEY (chat_id)   REFERENCES chats(chat_id)   ON DELETE CASCADE ON UPDATE RESTRICT,
-  FOREIGN KEY (sender_id) REFERENCES users(user_id)   ON DELETE CASCADE ON UPDATE RESTRICT
+  reply_to_id     BIGINT, -- message quoted by this one
+  thread_root_id  BIGINT, -- replies in a thread stay out of the chat history
+
+  FOREIGN KEY (chat_id)        REFERENCES chats(chat_id)       ON DELETE CASCADE  ON UPDATE RESTRICT,
+  FOREIGN KEY (sender_id)      REFERENCES users(user_id)       ON DELETE CASCADE  ON UPDATE RESTRICT,
+  FOREIGN KEY (reply_to_id)    REFERENCES messages(message_id) ON DELETE SET NULL ON UPDATE RESTRICT,
+  FOREIGN KEY (thread_root_id) REFERENCES messages(message_id) ON DELETE CASCADE  ON UPDATE RESTRICT
+);
+
+CREATE INDEX idx_messages_chat_created ON messages (chat_id, created_at DESC, message_id DESC);
+CREATE INDEX idx_messages_thread_created ON messages (thread_root_id, created_at DESC, message_id DESC) WHERE thread_root_id IS NOT NULL;
+
+-- Bodies a message had before each edit, sealed exactly as they were stored.
+-- written_at is when that body was sent or last edited.
+CREATE TABLE message_revisions (
+  revision_id  BIGSERIAL    PRIMARY KEY,
+  message_id   BIGINT       NOT NULL,
+  cypher_text  BYTEA        NOT NULL,
+  written_at   TIMESTAMPTZ  NOT NULL,
+  replaced_at  TIMESTAMPTZ  NOT NULL DEFAULT now(),
+
+  FOREIGN KEY (message_id) REFERENCES messages(message_id) ON DELETE CASCADE ON UPDATE RESTRICT
+);
+
+CREATE INDEX idx_message_revisions_message ON message_revisions (message_id, revision_id);
+
+-- One row per emoji a participant put on a message
+CREATE TABLE message_reactions (
+  message_id  BIGINT       NOT NULL,
+  user_id     BIGINT       NOT NULL,
+  emoji       VARCHAR(64)  NOT NULL,
+  created_at  TIMESTAMPTZ  NOT NULL DEFAULT now(),
+  PRIMARY KEY (message_id, user_id, emoji),
+
+  FOREIGN KEY (message_id) REFERENCES messages(message_id) ON DELETE CASCADE ON UPDATE RESTRICT,
+  FOREIGN KEY (user_id)    REFERENCES users(user_id)       ON DELETE CASCADE ON UPDATE RESTRICT
+);
+
+-- Messages a participant deleted for themselves only
+CREATE TABLE hidden_messages (
+  user_id     BIGINT       NOT NULL,
+  message_id  BIGINT       NOT NULL,
+  hidden_at   TIMESTAMPTZ  NOT NULL DEFAULT now(),
+  PRIMARY KEY (user_id, message_id),
+
+  FOREIGN KEY (user_id)    REFERENCES users(user_id)       ON DELETE CASCADE ON UPDATE RESTRICT,
+  FOREIGN KEY (message_id) REFERENCES messages(message_id) ON DELETE CASCADE ON UPDATE RESTRICT
+);
+
+-- How far each participant has read into a thread, apart from their
+-- chat_participants.last_read_message_id
+CREATE TABLE thread_reads (
+  thread_root_id        BIGINT       NOT NULL,
+  user_id               BIGINT       NOT NULL,
+  last_read_message_id  BIGINT       NOT NULL,
+  last_read_at          TIMESTAMPTZ  NOT NULL DEFAULT now(),
+  PRIMARY KEY (thread_root_id, user_id),
+
+  FOREIGN KEY (thread_root_id) REFERENCES messages(message_id) ON DELETE CASCADE ON UPDATE RESTRICT,
+  FOREIGN KEY (user_id)        REFERENCES users(user_id)       ON DELETE CASCADE ON UPDATE RESTRICT
+);
+
+-- Blind index over message bodies: one row per distinct word of a message,
+-- HMAC'd under a key derived from the chat's data key, so words can be
+-- matched without being stored. End-to-end chats are never indexed.
+CREATE TABLE message_search_tokens (
+  token       BYTEA   NOT NULL,
+  message_id  BIGINT  NOT NULL,
+  PRIMARY KEY (token, message_id),
+
+  FOREIGN KEY (message_id) REFERENCES messages(message_id) ON DELETE CASCADE ON UPDATE RESTRICT
+);
+
+CREATE INDEX idx_message_search_tokens_message ON message_search_tokens (message_id);
+
+-- Files uploaded into a chat. The bytes live in the blob store under
+-- blob_key, sealed with the chat's data key; message_id stays NULL until the
+-- uploader sends a message carrying the file.
+CREATE TABLE attachments (
+  attachment_id  BIGSERIAL    PRIMARY KEY,
+  chat_id        BIGINT       NOT NULL,
+  uploader_id    BIGINT       NOT NULL,
+  message_id     BIGINT,
+  blob_key       TEXT         NOT NULL UNIQUE,
+  file_name      TEXT         NOT NULL,
+  mime_type      TEXT         NOT NULL,
+  size_bytes     BIGINT       NOT NULL, -- as stored, before sealing
+  sha256         BYTEA        NOT NULL, -- of the bytes as stored, metadata stripped
+  width          INTEGER,               -- images only
+  height         INTEGER,
+  created_at     TIMESTAMPTZ  NOT NULL DEFAULT now(),
+  thumbnail_key  TEXT         UNIQUE,   -- images only, sealed like the file
+
+  FOREIGN KEY (chat_id)     REFERENCES chats(chat_id)       ON DELETE CASCADE ON UPDATE RESTRICT,
+  FOREIGN KEY (uploader_id) REFERENCES users(user_id)       ON DELETE CASCADE ON UPDATE RESTRICT,
+  FOREIGN KEY (message_id)  REFERENCES messages(message_id) ON DELETE CASCADE ON UPDATE RESTRICT
+);
+
+CREATE INDEX idx_attachments_message ON attachments (message_id) WHERE message_id IS NOT NULL;
+CREATE INDEX idx_attachments_uploader ON attachments (uploader_id);
+
+-- Per-chat data key, wrapped by the master key provider. Dropping the row
+-- (with the chat) crypto-shreds every message sealed under it.
+CREATE TABLE chat_keys (
+  chat_id     BIGINT       PRIMARY KEY,
+  wrapped_key BYTEA        NOT NULL,
+  created_at  TIMESTAMPTZ  NOT NULL DEFAULT now(),
+
+  FOREIGN KEY (chat_id) REFERENCES chats(chat_id) ON DELETE CASCADE ON UPDATE RESTRICT
 );
 
 -- =========================
@@ -71,4 +282,18 @@ CREATE TABLE friend_requests (
   CONSTRAINT no_self_request CHECK (sender_id <> receiver_id),
   FOREIGN KEY (sender_id)   REFERENCES users(user_id) ON DELETE CASCADE ON UPDATE RESTRICT,
   FOREIGN KEY (receiver_id) REFERENCES users(user_id) ON DELETE CASCADE ON UPDATE RESTRICT
-);
\ No newline at end of file
+);
+
+-- Either side of a block drops out of the other's user search and profiles,
+-- and no friend request can pass between them
+CREATE TABLE user_blocks (
+  blocker_id  BIGINT       NOT NULL,
+  blocked_id  BIGINT       NOT NULL,
+  created_at  TIMESTAMPTZ  NOT NULL DEFAULT now(),
+  CONSTRAINT no_self_block CHECK (blocker_id <> blocked_id),
+  PRIMARY KEY (blocker_id, blocked_id),
+  FOREIGN KEY (blocker_id) REFERENCES users(user_id) ON DELETE CASCADE ON UPDATE RESTRICT,
+  FOREIGN KEY (blocked_id) REFERENCES users(user_id) ON DELETE CASCADE ON UPDATE RESTRICT
+);
+
+CREATE INDEX idx_user_blocks_blocked ON user_blocks (blocked_id);
@@ -0,0 +1,302 @@
+-- SQLite version of ../schema.sql for the sqlite database driver. Keep the
+-- two in step: same tables, columns and constraint names, translated.
+--
+--   BIGSERIAL    -> INTEGER PRIMARY KEY AUTOINCREMENT (ids are never reused)
+--   TIMESTAMPTZ  -> DATETIME, written as UTC text that sorts chronologically
+--   BYTEA        -> BLOB
+--   UUID         -> TEXT in the canonical 8-4-4-4-12 form
+--   VARCHAR(n)   -> TEXT with a length check
+
+-- =========================
+-- Core identity
+-- =========================
+CREATE TABLE users (
+  user_id       INTEGER   PRIMARY KEY AUTOINCREMENT,
+  display_name  TEXT      NOT NULL CONSTRAINT users_display_name_key UNIQUE,
+  first_name    TEXT      NOT NULL,
+  last_name     TEXT      NOT NULL,
+  password_hash TEXT      NOT NULL,
+  pfp_url       TEXT,
+  created_at    DATETIME  NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+
+  -- TOTP secret sealed with the message key; only enforced once enabled
+  totp_secret     BLOB,
+  totp_enabled    BOOLEAN   NOT NULL DEFAULT FALSE,
+  totp_last_step  INTEGER,
+
+  -- Wrong second-factor codes in a row; past the limit each further try
+  -- waits out mfa_locked_until
+  mfa_failed_attempts  INTEGER   NOT NULL DEFAULT 0,
+  mfa_locked_until     DATETIME,
+
+  -- Set while pfp_url points at an uploaded avatar, whose renderings are
+  -- stored under avatars/<avatar_id>-<size>
+  avatar_id       TEXT,
+
+  CONSTRAINT display_name_length CHECK (length(display_name) <= 32)
+);
+
+CREATE UNIQUE INDEX uq_users_display_name_ci ON users (lower(display_name));
+
+-- =========================
+-- Auth
+-- =========================
+CREATE TABLE refresh_tokens (
+  token_id    INTEGER   PRIMARY KEY AUTOINCREMENT,
+  user_id     INTEGER   NOT NULL,
+  family_id   TEXT      NOT NULL,
+  token_hash  BLOB      NOT NULL UNIQUE,
+  created_at  DATETIME  NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+  expires_at  DATETIME  NOT NULL,
+  used_at     DATETIME,
+  revoked_at  DATETIME,
+
+  FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE ON UPDATE RESTRICT
+);
+
+CREATE INDEX idx_refresh_tokens_family ON refresh_tokens (family_id);
+
+-- One row per signed-in device. The session id doubles as the jti of its
+-- access tokens and the family id of its refresh tokens.
+CREATE TABLE sessions (
+  session_id    TEXT      PRIMARY KEY NOT NULL DEFAULT (
+    lower(hex(randomblob(4))) || '-' || lower(hex(randomblob(2))) || '-4' ||
+    substr(lower(hex(randomblob(2))), 2) || '-' ||
+    substr('89ab', 1 + (abs(random()) % 4), 1) || substr(lower(hex(randomblob(2))), 2) || '-' ||
+    lower(hex(randomblob(6)))
+  ),
+  user_id       INTEGER   NOT NULL,
+  user_agent    TEXT      NOT NULL DEFAULT '',
+  ip_address    TEXT      NOT NULL DEFAULT '',
+  created_at    DATETIME  NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+  last_seen_at  DATETIME  NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+  revoked_at    DATETIME,
+
+  FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE ON UPDATE RESTRICT
+);
+
+CREATE INDEX idx_sessions_user ON sessions (user_id);
+
+CREATE TABLE recovery_codes (
+  code_id     INTEGER   PRIMARY KEY AUTOINCREMENT,
+  user_id     INTEGER   NOT NULL,
+  code_hash   BLOB      NOT NULL UNIQUE,
+  created_at  DATETIME  NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+  used_at     DATETIME,
+
+  FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE ON UPDATE RESTRICT
+);
+
+CREATE INDEX idx_recovery_codes_user ON recovery_codes (user_id);
+
+-- Login challenges that have been presented, so each is good for one try.
+-- Rows are only needed until the challenge would have expired anyway.
+CREATE TABLE used_mfa_challenges (
+  challenge_id  TEXT      PRIMARY KEY NOT NULL,
+  user_id       INTEGER   NOT NULL,
+  expires_at    DATETIME  NOT NULL,
+
+  FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE ON UPDATE RESTRICT
+);
+
+CREATE INDEX idx_used_mfa_challenges_expires ON used_mfa_challenges (expires_at);
+
+-- Public half of a user's end-to-end keys. The identity key is Ed25519 and
+-- signs the current prekey; private keys never leave the client.
+CREATE TABLE user_keys (
+  user_id                  INTEGER   PRIMARY KEY,
+  identity_key             BLOB      NOT NULL,
+  signed_prekey_id         INTEGER,
+  signed_prekey            BLOB,
+  signed_prekey_signature  BLOB,
+  updated_at               DATETIME  NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+
+  CONSTRAINT signed_prekey_complete CHECK (
+    (signed_prekey_id IS NULL) = (signed_prekey IS NULL)
+    AND (signed_prekey IS NULL) = (signed_prekey_signature IS NULL)
+  ),
+  FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE ON UPDATE RESTRICT
+);
+
+-- =========================
+-- Chats & participants
+-- =========================
+CREATE TABLE chats (
+  chat_id          INTEGER   PRIMARY KEY AUTOINCREMENT,
+  kind             TEXT      NOT NULL DEFAULT 'direct' CHECK (kind IN ('direct', 'group')),
+  title            TEXT,
+  last_message_id  INTEGER,
+  created_at       DATETIME  NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+
+  -- Messages hold client ciphertext the server cannot open
+  end_to_end       BOOLEAN   NOT NULL DEFAULT FALSE
+);
+
+CREATE TABLE chat_participants (
+  chat_id               INTEGER   NOT NULL,
+  user_id               INTEGER   NOT NULL,
+  is_typing             BOOLEAN   NOT NULL DEFAULT FALSE,
+  typing_updated_at     DATETIME,
+  last_read_message_id  INTEGER,
+  last_read_at          DATETIME,
+  role                  TEXT      NOT NULL DEFAULT 'member' CHECK (role IN ('owner', 'admin', 'member')),
+  joined_at             DATETIME  NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+  PRIMARY KEY (chat_id, user_id),
+  FOREIGN KEY (chat_id) REFERENCES chats(chat_id) ON DELETE CASCADE ON UPDATE RESTRICT,
+  FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE ON UPDATE RESTRICT
+);
+
+-- =========================
+-- Messages
+-- =========================
+CREATE TABLE messages (
+  message_id  INTEGER   PRIMARY KEY,
+  chat_id     INTEGER   NOT NULL,
+  cypher_text BLOB      NOT NULL,
+  sender_id   INTEGER   NOT NULL,
+  created_at  DATETIME  NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+  edited_at   DATETIME,
+  deleted_at  DATETIME,
+
+  reply_to_id     INTEGER,
+  thread_root_id  INTEGER,
+
+  FOREIGN KEY (chat_id)        REFERENCES chats(chat_id)       ON DELETE CASCADE  ON UPDATE RESTRICT,
+  FOREIGN KEY (sender_id)      REFERENCES users(user_id)       ON DELETE CASCADE  ON UPDATE RESTRICT,
+  FOREIGN KEY (reply_to_id)    REFERENCES messages(message_id) ON DELETE SET NULL ON UPDATE RESTRICT,
+  FOREIGN KEY (thread_root_id) REFERENCES messages(message_id) ON DELETE CASCADE  ON UPDATE RESTRICT
+);
+
+CREATE INDEX idx_messages_chat_created ON messages (chat_id, created_at DESC, message_id DESC);
+CREATE INDEX idx_messages_thread_created ON messages (thread_root_id, created_at DESC, message_id DESC) WHERE thread_root_id IS NOT NULL;
+
+CREATE TABLE message_revisions (
+  revision_id  INTEGER   PRIMARY KEY AUTOINCREMENT,
+  message_id   INTEGER   NOT NULL,
+  cypher_text  BLOB      NOT NULL,
+  written_at   DATETIME  NOT NULL,
+  replaced_at  DATETIME  NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+
+  FOREIGN KEY (message_id) REFERENCES messages(message_id) ON DELETE CASCADE ON UPDATE RESTRICT
+);
+
+CREATE INDEX idx_message_revisions_message ON message_revisions (message_id, revision_id);
+
+CREATE TABLE message_reactions (
+  message_id  INTEGER   NOT NULL,
+  user_id     INTEGER   NOT NULL,
+  emoji       TEXT      NOT NULL CHECK (length(emoji) <= 64),
+  created_at  DATETIME  NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+  PRIMARY KEY (message_id, user_id, emoji),
+
+  FOREIGN KEY (message_id) REFERENCES messages(message_id) ON DELETE CASCADE ON UPDATE RESTRICT,
+  FOREIGN KEY (user_id)    REFERENCES users(user_id)       ON DELETE CASCADE ON UPDATE RESTRICT
+);
+
+CREATE TABLE hidden_messages (
+  user_id     INTEGER   NOT NULL,
+  message_id  INTEGER   NOT NULL,
+  hidden_at   DATETIME  NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+  PRIMARY KEY (user_id, message_id),
+
+  FOREIGN KEY (user_id)    REFERENCES users(user_id)       ON DELETE CASCADE ON UPDATE RESTRICT,
+  FOREIGN KEY (message_id) REFERENCES messages(message_id) ON DELETE CASCADE ON UPDATE RESTRICT
+);
+
+CREATE TABLE thread_reads (
+  thread_root_id        INTEGER   NOT NULL,
+  user_id               INTEGER   NOT NULL,
+  last_read_message_id  INTEGER   NOT NULL,
+  last_read_at          DATETIME  NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+  PRIMARY KEY (thread_root_id, user_id),
+
+  FOREIGN KEY (thread_root_id) REFERENCES messages(message_id) ON DELETE CASCADE ON UPDATE RESTRICT,
+  FOREIGN KEY (user_id)        REFERENCES users(user_id)       ON DELETE CASCADE ON UPDATE RESTRICT
+);
+
+CREATE TABLE message_search_tokens (
+  token       BLOB     NOT NULL,
+  message_id  INTEGER  NOT NULL,
+  PRIMARY KEY (token, message_id),
+
+  FOREIGN KEY (message_id) REFERENCES messages(message_id) ON DELETE CASCADE ON UPDATE RESTRICT
+);
+
+CREATE INDEX idx_message_search_tokens_message ON message_search_tokens (message_id);
+
+CREATE TABLE attachments (
+  attachment_id  INTEGER   PRIMARY KEY,
+  chat_id        INTEGER   NOT NULL,
+  uploader_id    INTEGER   NOT NULL,
+  message_id     INTEGER,
+  blob_key       TEXT      NOT NULL UNIQUE,
+  file_name      TEXT      NOT NULL,
+  mime_type      TEXT      NOT NULL,
+  size_bytes     INTEGER   NOT NULL,
+  sha256         BLOB      NOT NULL,
+  width          INTEGER,
+  height         INTEGER,
+  created_at     DATETIME  NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+  thumbnail_key  TEXT      UNIQUE,
+
+  FOREIGN KEY (chat_id)     REFERENCES chats(chat_id)       ON DELETE CASCADE ON UPDATE RESTRICT,
+  FOREIGN KEY (uploader_id) REFERENCES users(user_id)       ON DELETE CASCADE ON UPDATE RESTRICT,
+  FOREIGN KEY (message_id)  REFERENCES messages(message_id) ON DELETE CASCADE ON UPDATE RESTRICT
+);
+
+CREATE INDEX idx_attachments_message ON attachments (message_id) WHERE message_id IS NOT NULL;
+CREATE INDEX idx_attachments_uploader ON attachments (uploader_id);
+
+-- Stands in for Postgres sequences where an id is taken before its row is
+-- written (messages and attachments, whose ids are bound into the
+-- ciphertext).
+CREATE TABLE sequences (
+  name   TEXT     PRIMARY KEY,
+  value  INTEGER  NOT NULL
+);
+
+-- Per-chat data key, wrapped by the master key provider. Dropping the row
+-- (with the chat) crypto-shreds every message sealed under it.
+CREATE TABLE chat_keys (
+  chat_id     INTEGER   PRIMARY KEY,
+  wrapped_key BLOB      NOT NULL,
+  created_at  DATETIME  NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+
+  FOREIGN KEY (chat_id) REFERENCES chats(chat_id) ON DELETE CASCADE ON UPDATE RESTRICT
+);
+
+-- =========================
+-- Friendships & requests
+-- =========================
+CREATE TABLE user_friendships (
+  user_id   INTEGER NOT NULL,
+  friend_id INTEGER NOT NULL,
+  friendship_ts DATETIME  NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+  CONSTRAINT no_self_friend CHECK (user_id <> friend_id),
+  PRIMARY KEY (user_id, friend_id),
+  FOREIGN KEY (user_id)   REFERENCES users(user_id) ON DELETE CASCADE,
+  FOREIGN KEY (friend_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE TABLE friend_requests (
+  request_id  INTEGER PRIMARY KEY AUTOINCREMENT,
+  sender_id   INTEGER NOT NULL,
+  receiver_id INTEGER NOT NULL,
+  CONSTRAINT no_self_request CHECK (sender_id <> receiver_id),
+  FOREIGN KEY (sender_id)   REFERENCES users(user_id) ON DELETE CASCADE ON UPDATE RESTRICT,
+  FOREIGN KEY (receiver_id) REFERENCES users(user_id) ON DELETE CASCADE ON UPDATE RESTRICT
+);
+
+-- Either side of a block drops out of the other's user search and profiles,
+-- and no friend request can pass between them
+CREATE TABLE user_blocks (
+  blocker_id  INTEGER   NOT NULL,
+  blocked_id  INTEGER   NOT NULL,
+  created_at  DATETIME  NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+  CONSTRAINT no_self_block CHECK (blocker_id <> blocked_id),
+  PRIMARY KEY (blocker_id, blocked_id),
+  FOREIGN KEY (blocker_id) REFERENCES users(user_id) ON DELETE CASCADE ON UPDATE RESTRICT,
+  FOREIGN KEY (blocked_id) REFERENCES users(user_id) ON DELETE CASCADE ON UPDATE RESTRICT
+);
+
+CREATE INDEX idx_user_blocks_blocked ON user_blocks (blocked_id);
@@ -0,0 +1,2 @@
+-- Create index "idx_messages_chat_created" to table: "messages"
+CREATE INDEX "idx_messages_chat_created" ON "public"."messages" ("chat_id", "created_at" DESC, "message_id" DESC);
@@ -0,0 +1,2 @@
+-- Modify "chats" table
+ALTER TABLE "public"."chats" ADD COLUMN "kind" text NOT NULL DEFAULT 'direct', ADD COLUMN "title" text NULL, ADD CONSTRAINT "chats_kind_check" CHECK (kind = ANY (ARRAY['direct'::text, 'group'::text]));
@@ -0,0 +1,4 @@
+-- Modify "chat_participants" table
+ALTER TABLE "public"."chat_participants" ADD COLUMN "role" text NOT NULL DEFAULT 'member', ADD COLUMN "joined_at" timestamptz NOT NULL DEFAULT now(), ADD CONSTRAINT "chat_participants_role_check" CHECK (role = ANY (ARRAY['owner'::text, 'admin'::text, 'member'::text]));
+-- Hand existing groups to their lowest user id so every group has an owner
+UPDATE "public"."chat_participants" cp SET "role" = 'owner' FROM "public"."chats" c WHERE c.chat_id = cp.chat_id AND c.kind = 'group' AND cp.user_id = (SELECT MIN(cp2.user_id) FROM "public"."chat_participants" cp2 WHERE cp2.chat_id = cp.chat_id);
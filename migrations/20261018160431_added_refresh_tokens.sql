@@ -0,0 +1,16 @@
+-- Create "refresh_tokens" table
+CREATE TABLE "public"."refresh_tokens" (
+  "token_id" bigserial NOT NULL,
+  "user_id" bigint NOT NULL,
+  "family_id" uuid NOT NULL,
+  "token_hash" bytea NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT now(),
+  "expires_at" timestamptz NOT NULL,
+  "used_at" timestamptz NULL,
+  "revoked_at" timestamptz NULL,
+  PRIMARY KEY ("token_id"),
+  CONSTRAINT "refresh_tokens_token_hash_key" UNIQUE ("token_hash"),
+  CONSTRAINT "refresh_tokens_user_id_fkey" FOREIGN KEY ("user_id") REFERENCES "public"."users" ("user_id") ON UPDATE RESTRICT ON DELETE CASCADE
+);
+-- Create index "idx_refresh_tokens_family" to table: "refresh_tokens"
+CREATE INDEX "idx_refresh_tokens_family" ON "public"."refresh_tokens" ("family_id");
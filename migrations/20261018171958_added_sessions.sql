@@ -0,0 +1,14 @@
+-- Create "sessions" table
+CREATE TABLE "public"."sessions" (
+  "session_id" uuid NOT NULL DEFAULT gen_random_uuid(),
+  "user_id" bigint NOT NULL,
+  "user_agent" text NOT NULL DEFAULT '',
+  "ip_address" text NOT NULL DEFAULT '',
+  "created_at" timestamptz NOT NULL DEFAULT now(),
+  "last_seen_at" timestamptz NOT NULL DEFAULT now(),
+  "revoked_at" timestamptz NULL,
+  PRIMARY KEY ("session_id"),
+  CONSTRAINT "sessions_user_id_fkey" FOREIGN KEY ("user_id") REFERENCES "public"."users" ("user_id") ON UPDATE RESTRICT ON DELETE CASCADE
+);
+-- Create index "idx_sessions_user" to table: "sessions"
+CREATE INDEX "idx_sessions_user" ON "public"."sessions" ("user_id");
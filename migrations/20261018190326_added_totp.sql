@@ -0,0 +1,25 @@
+-- Modify "users" table
+ALTER TABLE "public"."users" ADD COLUMN "totp_secret" bytea NULL, ADD COLUMN "totp_enabled" boolean NOT NULL DEFAULT false, ADD COLUMN "totp_last_step" bigint NULL, ADD COLUMN "mfa_failed_attempts" integer NOT NULL DEFAULT 0, ADD COLUMN "mfa_locked_until" timestamptz NULL;
+-- Create "recovery_codes" table
+CREATE TABLE "public"."recovery_codes" (
+  "code_id" bigserial NOT NULL,
+  "user_id" bigint NOT NULL,
+  "code_hash" bytea NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT now(),
+  "used_at" timestamptz NULL,
+  PRIMARY KEY ("code_id"),
+  CONSTRAINT "recovery_codes_code_hash_key" UNIQUE ("code_hash"),
+  CONSTRAINT "recovery_codes_user_id_fkey" FOREIGN KEY ("user_id") REFERENCES "public"."users" ("user_id") ON UPDATE RESTRICT ON DELETE CASCADE
+);
+-- Create index "idx_recovery_codes_user" to table: "recovery_codes"
+CREATE INDEX "idx_recovery_codes_user" ON "public"."recovery_codes" ("user_id");
+-- Create "used_mfa_challenges" table
+CREATE TABLE "public"."used_mfa_challenges" (
+  "challenge_id" text NOT NULL,
+  "user_id" bigint NOT NULL,
+  "expires_at" timestamptz NOT NULL,
+  PRIMARY KEY ("challenge_id"),
+  CONSTRAINT "used_mfa_challenges_user_id_fkey" FOREIGN KEY ("user_id") REFERENCES "public"."users" ("user_id") ON UPDATE RESTRICT ON DELETE CASCADE
+);
+-- Create index "idx_used_mfa_challenges_expires" to table: "used_mfa_challenges"
+CREATE INDEX "idx_used_mfa_challenges_expires" ON "public"."used_mfa_challenges" ("expires_at");
@@ -0,0 +1,8 @@
+-- Create "chat_keys" table
+CREATE TABLE "public"."chat_keys" (
+  "chat_id" bigint NOT NULL,
+  "wrapped_key" bytea NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT now(),
+  PRIMARY KEY ("chat_id"),
+  CONSTRAINT "chat_keys_chat_id_fkey" FOREIGN KEY ("chat_id") REFERENCES "public"."chats" ("chat_id") ON UPDATE RESTRICT ON DELETE CASCADE
+);
@@ -0,0 +1,14 @@
+-- Modify "chats" table
+ALTER TABLE "public"."chats" ADD COLUMN "end_to_end" boolean NOT NULL DEFAULT false;
+-- Create "user_keys" table
+CREATE TABLE "public"."user_keys" (
+  "user_id" bigint NOT NULL,
+  "identity_key" bytea NOT NULL,
+  "signed_prekey_id" bigint NULL,
+  "signed_prekey" bytea NULL,
+  "signed_prekey_signature" bytea NULL,
+  "updated_at" timestamptz NOT NULL DEFAULT now(),
+  PRIMARY KEY ("user_id"),
+  CONSTRAINT "user_keys_user_id_fkey" FOREIGN KEY ("user_id") REFERENCES "public"."users" ("user_id") ON UPDATE RESTRICT ON DELETE CASCADE,
+  CONSTRAINT "signed_prekey_complete" CHECK (((signed_prekey_id IS NULL) = (signed_prekey IS NULL)) AND ((signed_prekey IS NULL) = (signed_prekey_signature IS NULL)))
+);
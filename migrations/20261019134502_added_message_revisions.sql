@@ -0,0 +1,14 @@
+-- Modify "messages" table
+ALTER TABLE "public"."messages" ADD COLUMN "edited_at" timestamptz NULL;
+-- Create "message_revisions" table
+CREATE TABLE "public"."message_revisions" (
+  "revision_id" bigserial NOT NULL,
+  "message_id" bigint NOT NULL,
+  "cypher_text" bytea NOT NULL,
+  "written_at" timestamptz NOT NULL,
+  "replaced_at" timestamptz NOT NULL DEFAULT now(),
+  PRIMARY KEY ("revision_id"),
+  CONSTRAINT "message_revisions_message_id_fkey" FOREIGN KEY ("message_id") REFERENCES "public"."messages" ("message_id") ON UPDATE RESTRICT ON DELETE CASCADE
+);
+-- Create index "idx_message_revisions_message" to table: "message_revisions"
+CREATE INDEX "idx_message_revisions_message" ON "public"."message_revisions" ("message_id", "revision_id");
@@ -0,0 +1,11 @@
+-- Modify "messages" table
+ALTER TABLE "public"."messages" ADD COLUMN "deleted_at" timestamptz NULL;
+-- Create "hidden_messages" table
+CREATE TABLE "public"."hidden_messages" (
+  "user_id" bigint NOT NULL,
+  "message_id" bigint NOT NULL,
+  "hidden_at" timestamptz NOT NULL DEFAULT now(),
+  PRIMARY KEY ("user_id", "message_id"),
+  CONSTRAINT "hidden_messages_message_id_fkey" FOREIGN KEY ("message_id") REFERENCES "public"."messages" ("message_id") ON UPDATE RESTRICT ON DELETE CASCADE,
+  CONSTRAINT "hidden_messages_user_id_fkey" FOREIGN KEY ("user_id") REFERENCES "public"."users" ("user_id") ON UPDATE RESTRICT ON DELETE CASCADE
+);
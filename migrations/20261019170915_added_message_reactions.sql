@@ -0,0 +1,10 @@
+-- Create "message_reactions" table
+CREATE TABLE "public"."message_reactions" (
+  "message_id" bigint NOT NULL,
+  "user_id" bigint NOT NULL,
+  "emoji" character varying(64) NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT now(),
+  PRIMARY KEY ("message_id", "user_id", "emoji"),
+  CONSTRAINT "message_reactions_message_id_fkey" FOREIGN KEY ("message_id") REFERENCES "public"."messages" ("message_id") ON UPDATE RESTRICT ON DELETE CASCADE,
+  CONSTRAINT "message_reactions_user_id_fkey" FOREIGN KEY ("user_id") REFERENCES "public"."users" ("user_id") ON UPDATE RESTRICT ON DELETE CASCADE
+);
@@ -0,0 +1,14 @@
+-- Modify "messages" table
+ALTER TABLE "public"."messages" ADD COLUMN "reply_to_id" bigint NULL, ADD COLUMN "thread_root_id" bigint NULL, ADD CONSTRAINT "messages_reply_to_id_fkey" FOREIGN KEY ("reply_to_id") REFERENCES "public"."messages" ("message_id") ON UPDATE RESTRICT ON DELETE SET NULL, ADD CONSTRAINT "messages_thread_root_id_fkey" FOREIGN KEY ("thread_root_id") REFERENCES "public"."messages" ("message_id") ON UPDATE RESTRICT ON DELETE CASCADE;
+-- Create index "idx_messages_thread_created" to table: "messages"
+CREATE INDEX "idx_messages_thread_created" ON "public"."messages" ("thread_root_id", "created_at" DESC, "message_id" DESC) WHERE (thread_root_id IS NOT NULL);
+-- Create "thread_reads" table
+CREATE TABLE "public"."thread_reads" (
+  "thread_root_id" bigint NOT NULL,
+  "user_id" bigint NOT NULL,
+  "last_read_message_id" bigint NOT NULL,
+  "last_read_at" timestamptz NOT NULL DEFAULT now(),
+  PRIMARY KEY ("thread_root_id", "user_id"),
+  CONSTRAINT "thread_reads_thread_root_id_fkey" FOREIGN KEY ("thread_root_id") REFERENCES "public"."messages" ("message_id") ON UPDATE RESTRICT ON DELETE CASCADE,
+  CONSTRAINT "thread_reads_user_id_fkey" FOREIGN KEY ("user_id") REFERENCES "public"."users" ("user_id") ON UPDATE RESTRICT ON DELETE CASCADE
+);
@@ -0,0 +1,24 @@
+-- Create "attachments" table
+CREATE TABLE "public"."attachments" (
+  "attachment_id" bigserial NOT NULL,
+  "chat_id" bigint NOT NULL,
+  "uploader_id" bigint NOT NULL,
+  "message_id" bigint NULL,
+  "blob_key" text NOT NULL,
+  "file_name" text NOT NULL,
+  "mime_type" text NOT NULL,
+  "size_bytes" bigint NOT NULL,
+  "sha256" bytea NOT NULL,
+  "width" integer NULL,
+  "height" integer NULL,
+  "created_at" timestamptz NOT NULL DEFAULT now(),
+  PRIMARY KEY ("attachment_id"),
+  CONSTRAINT "attachments_blob_key_key" UNIQUE ("blob_key"),
+  CONSTRAINT "attachments_chat_id_fkey" FOREIGN KEY ("chat_id") REFERENCES "public"."chats" ("chat_id") ON UPDATE RESTRICT ON DELETE CASCADE,
+  CONSTRAINT "attachments_message_id_fkey" FOREIGN KEY ("message_id") REFERENCES "public"."messages" ("message_id") ON UPDATE RESTRICT ON DELETE CASCADE,
+  CONSTRAINT "attachments_uploader_id_fkey" FOREIGN KEY ("uploader_id") REFERENCES "public"."users" ("user_id") ON UPDATE RESTRICT ON DELETE CASCADE
+);
+-- Create index "idx_attachments_message" to table: "attachments"
+CREATE INDEX "idx_attachments_message" ON "public"."attachments" ("message_id") WHERE (message_id IS NOT NULL);
+-- Create index "idx_attachments_uploader" to table: "attachments"
+CREATE INDEX "idx_attachments_uploader" ON "public"."attachments" ("uploader_id");
@@ -0,0 +1,6 @@
+-- Modify "attachments" table
+ALTER TABLE "public"."attachments" ADD COLUMN "thumbnail_key" text NULL, ADD CONSTRAINT "attachments_thumbnail_key_key" UNIQUE ("thumbnail_key");
+-- Modify "users" table
+ALTER TABLE "public"."users" ADD COLUMN "avatar_id" text NULL;
+-- Replace hotlinked default avatars with locally drawn identicons
+UPDATE "public"."users" SET "pfp_url" = '/v1/identicons/' || encode(substring(sha256(convert_to("display_name", 'UTF8')) from 1 for 16), 'hex') WHERE "pfp_url" LIKE 'https://api.dicebear.com/%';
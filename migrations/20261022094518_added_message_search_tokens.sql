@@ -0,0 +1,9 @@
+-- Create "message_search_tokens" table
+CREATE TABLE "public"."message_search_tokens" (
+  "token" bytea NOT NULL,
+  "message_id" bigint NOT NULL,
+  PRIMARY KEY ("token", "message_id"),
+  CONSTRAINT "message_search_tokens_message_id_fkey" FOREIGN KEY ("message_id") REFERENCES "public"."messages" ("message_id") ON UPDATE RESTRICT ON DELETE CASCADE
+);
+-- Create index "idx_message_search_tokens_message" to table: "message_search_tokens"
+CREATE INDEX "idx_message_search_tokens_message" ON "public"."message_search_tokens" ("message_id");
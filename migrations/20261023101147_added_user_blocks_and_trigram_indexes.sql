@@ -0,0 +1,18 @@
+-- Add extension "pg_trgm"
+CREATE EXTENSION IF NOT EXISTS "pg_trgm" WITH SCHEMA "public";
+-- Create index "idx_users_display_name_trgm" to table: "users"
+CREATE INDEX "idx_users_display_name_trgm" ON "public"."users" USING gin ((lower((display_name)::text)) gin_trgm_ops);
+-- Create index "idx_users_full_name_trgm" to table: "users"
+CREATE INDEX "idx_users_full_name_trgm" ON "public"."users" USING gin ((lower(((first_name || ' '::text) || last_name))) gin_trgm_ops);
+-- Create "user_blocks" table
+CREATE TABLE "public"."user_blocks" (
+  "blocker_id" bigint NOT NULL,
+  "blocked_id" bigint NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT now(),
+  PRIMARY KEY ("blocker_id", "blocked_id"),
+  CONSTRAINT "user_blocks_blocked_id_fkey" FOREIGN KEY ("blocked_id") REFERENCES "public"."users" ("user_id") ON UPDATE RESTRICT ON DELETE CASCADE,
+  CONSTRAINT "user_blocks_blocker_id_fkey" FOREIGN KEY ("blocker_id") REFERENCES "public"."users" ("user_id") ON UPDATE RESTRICT ON DELETE CASCADE,
+  CONSTRAINT "no_self_block" CHECK (blocker_id <> blocked_id)
+);
+-- Create index "idx_user_blocks_blocked" to table: "user_blocks"
+CREATE INDEX "idx_user_blocks_blocked" ON "public"."user_blocks" ("blocked_id");
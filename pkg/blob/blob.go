@@ -0,0 +1,46 @@
+// Package blob stores opaque byte strings, such as attachment bodies, by
+// key. Callers seal anything sensitive before it gets here.
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNotFound is returned by Get for a key nothing was stored under.
+var ErrNotFound = errors.New("blob not found")
+
+// Store is the BlobStore the API keeps files in. Keys are slash separated
+// paths of lower case letters, digits, '-', '_' and '.', such as
+// "attachments/12/345".
+type Store interface {
+	// Put stores data under key, replacing anything already there.
+	Put(ctx context.Context, key string, data []byte) error
+	// Get returns what was stored under key, or ErrNotFound.
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Delete removes key. Deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// checkKey keeps keys portable across backends and out of parent
+// directories on the filesystem.
+func checkKey(key string) error {
+	if key == "" || len(key) > 512 {
+		return fmt.Errorf("invalid blob key %q", key)
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return fmt.Errorf("invalid blob key %q", key)
+		}
+		for _, r := range segment {
+			switch {
+			case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			default:
+				return fmt.Errorf("invalid blob key %q", key)
+			}
+		}
+	}
+	return nil
+}
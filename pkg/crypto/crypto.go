@@ -1,78 +1,106 @@
 package crypto
 
 import (
-	"crypto/aes"
+	"context"
 	"crypto/cipher"
 	"crypto/rand"
-	"encoding/base64"
-	"errors"
 	"io"
+	"sync"
 )
 
-var masterKey []byte
-
-func Init(keyB64 string) error {
-	if keyB64 == "" {
-		return errors.New("missing FLICK_MESSAGE_ENCRYPTION_KEY")
-	}
-
-	key, err := base64.StdEncoding.DecodeString(keyB64)
-	if err != nil {
-		return err
-	}
+// Cipher seals everything Flick stores encrypted. Small per-row secrets are
+// sealed with the master keyring directly; chat messages go through a
+// per-chat DataKey wrapped by the cipher's key provider.
+type Cipher interface {
+	// Encrypt seals plaintext with the active key, bound to aad. The same aad
+	// must be presented to open it; see MessageAAD and UserSecretAAD.
+	Encrypt(plaintext, aad []byte) ([]byte, error)
+	// Decrypt opens a ciphertext sealed with any key in the keyring.
+	Decrypt(data, aad []byte) ([]byte, error)
+
+	// NewDataKey generates a data key and wraps it for storage.
+	NewDataKey(ctx context.Context, aad []byte) (DataKey, []byte, error)
+	// OpenDataKey unwraps a stored data key. A nil wrapped key gives a key
+	// that only opens rows sealed before the chat had one.
+	OpenDataKey(ctx context.Context, wrapped, aad []byte) (DataKey, error)
+	// RewrapDataKey wraps a data key again if the master key has moved on,
+	// reporting whether it did.
+	RewrapDataKey(ctx context.Context, wrapped, aad []byte) ([]byte, bool, error)
+
+	// ActiveKeyID names the key Encrypt seals with.
+	ActiveKeyID() string
+	// NeedsReencrypt reports whether data was sealed by Encrypt with anything
+	// other than the active key, or without associated data.
+	NeedsReencrypt(data []byte) bool
+}
 
-	if len(key) != 32 {
-		return errors.New("key must be 32 bytes")
-	}
+// DataKey seals the messages of a single chat.
+type DataKey interface {
+	Encrypt(plaintext, aad []byte) ([]byte, error)
+	// Decrypt also opens rows from before the chat had a data key.
+	Decrypt(data, aad []byte) ([]byte, error)
+	// BlindIndex digests a search token under a key derived from this one,
+	// so the same word only matches within the same chat. A chat without a
+	// key has no index.
+	BlindIndex(token string) ([]byte, error)
+}
 
-	masterKey = key
-	return nil
+// AESGCM is the Cipher used in production. Its keys can be swapped with
+// Reload while requests are in flight.
+type AESGCM struct {
+	mu       sync.RWMutex
+	keyring  *Keyring
+	provider KeyProvider
 }
 
-func Encrypt(plaintext []byte) ([]byte, error) {
-	block, err := aes.NewCipher(masterKey)
-	if err != nil {
-		return nil, err
-	}
+func NewAESGCM(keyring *Keyring, provider KeyProvider) *AESGCM {
+	return &AESGCM{keyring: keyring, provider: provider}
+}
 
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return nil, err
-	}
+// Reload replaces the keys. A nil provider keeps the current one, which
+// matters for providers whose master key cannot be loaded twice.
+func (c *AESGCM) Reload(keyring *Keyring, provider KeyProvider) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
-		return nil, err
+	c.keyring = keyring
+	if provider != nil {
+		c.provider = provider
 	}
+}
 
-	ciphertext := gcm.Seal(nil, nonce, plaintext, nil)
-
-	return append(nonce, ciphertext...), nil
+func (c *AESGCM) keys() (*Keyring, KeyProvider) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.keyring, c.provider
 }
 
-func Decrypt(data []byte) ([]byte, error) {
-	block, err := aes.NewCipher(masterKey)
-	if err != nil {
-		return nil, err
-	}
+func (c *AESGCM) Encrypt(plaintext, aad []byte) ([]byte, error) {
+	keyring, _ := c.keys()
+	return keyring.seal(plaintext, aad)
+}
 
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return nil, err
-	}
+// Decrypt opens rows written before associated data was bound without
+// checking aad, unless the keyring requires it.
+func (c *AESGCM) Decrypt(data, aad []byte) ([]byte, error) {
+	keyring, _ := c.keys()
+	return keyring.open(data, aad)
+}
 
-	nonceSize := gcm.NonceSize()
-	if len(data) < nonceSize {
-		return nil, errors.New("invalid ciphertext")
-	}
+func (c *AESGCM) ActiveKeyID() string {
+	keyring, _ := c.keys()
+	return keyring.ActiveKeyID()
+}
 
-	nonce := data[:nonceSize]
-	ciphertext := data[nonceSize:]
+func (c *AESGCM) NeedsReencrypt(data []byte) bool {
+	keyring, _ := c.keys()
+	return keyring.needsReseal(data)
+}
 
-	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
-	if err != nil {
+func newNonce(aead cipher.AEAD) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
 		return nil, err
 	}
-
-	return plaintext, nil
+	return nonce, nil
 }
@@ -0,0 +1,144 @@
+package crypto
+
+import (
+	"bytes"
+	"context"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+)
+
+// Envelope ciphertexts are sealed with a per-chat data key rather than a
+// master key. The data key is implied by the row, so the header carries no
+// key ID:
+//
+//	magic(2) || version(1) || nonce || sealed
+const formatV3 byte = 3
+
+const dataKeySize = 32
+
+// Blind index digests are truncated to 128 bits, which keeps collisions out
+// of reach at half the storage.
+const blindIndexSize = 16
+
+// dataKey is an unwrapped per-chat key. Rows it cannot open are handed to
+// the master keyring, which is where they lived before the chat had a key.
+type dataKey struct {
+	aead     cipher.AEAD // nil when the chat has no key yet
+	index    []byte      // search index key, derived from the data key
+	fallback Cipher
+}
+
+func newDataKey(key []byte, fallback Cipher) (*dataKey, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Kept apart from the sealing key so digests reveal nothing about it
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("flick/search-index/v1"))
+
+	return &dataKey{aead: aead, index: mac.Sum(nil), fallback: fallback}, nil
+}
+
+func (c *AESGCM) NewDataKey(ctx context.Context, aad []byte) (DataKey, []byte, error) {
+	_, provider := c.keys()
+
+	key := make([]byte, dataKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, nil, err
+	}
+
+	wrapped, err := provider.WrapKey(ctx, key, aad)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	k, err := newDataKey(key, c)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return k, wrapped, nil
+}
+
+func (c *AESGCM) OpenDataKey(ctx context.Context, wrapped, aad []byte) (DataKey, error) {
+	if wrapped == nil {
+		return &dataKey{fallback: c}, nil
+	}
+
+	_, provider := c.keys()
+
+	key, err := provider.UnwrapKey(ctx, wrapped, aad)
+	if err != nil {
+		return nil, err
+	}
+
+	return newDataKey(key, c)
+}
+
+func (c *AESGCM) RewrapDataKey(ctx context.Context, wrapped, aad []byte) ([]byte, bool, error) {
+	// The master key comes from the provider, not the message keyring
+	_, provider := c.keys()
+	if !provider.NeedsRewrap(wrapped) {
+		return wrapped, false, nil
+	}
+
+	key, err := provider.UnwrapKey(ctx, wrapped, aad)
+	if err != nil {
+		return nil, false, err
+	}
+
+	rewrapped, err := provider.WrapKey(ctx, key, aad)
+	if err != nil {
+		return nil, false, err
+	}
+	return rewrapped, true, nil
+}
+
+func (k *dataKey) Encrypt(plaintext, aad []byte) ([]byte, error) {
+	if k.aead == nil {
+		return nil, ErrUnknownKey
+	}
+
+	nonce, err := newNonce(k.aead)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, len(headerMagic)+1+len(nonce)+len(plaintext)+k.aead.Overhead())
+	out = append(out, headerMagic...)
+	out = append(out, formatV3)
+	out = append(out, nonce...)
+
+	return k.aead.Seal(out, nonce, plaintext, aad), nil
+}
+
+func (k *dataKey) Decrypt(data, aad []byte) ([]byte, error) {
+	if IsEnvelope(data) && k.aead != nil {
+		if plaintext, err := openWith(k.aead, data[len(headerMagic)+1:], aad); err == nil {
+			return plaintext, nil
+		}
+		// Fall through: a legacy row can happen to start with these bytes.
+	}
+
+	return k.fallback.Decrypt(data, aad)
+}
+
+func (k *dataKey) BlindIndex(token string) ([]byte, error) {
+	if k.index == nil {
+		return nil, ErrUnknownKey
+	}
+
+	mac := hmac.New(sha256.New, k.index)
+	mac.Write([]byte(token))
+	return mac.Sum(nil)[:blindIndexSize], nil
+}
+
+// IsEnvelope reports whether data is sealed with a data key.
+func IsEnvelope(data []byte) bool {
+	n := len(headerMagic)
+	return len(data) > n && bytes.Equal(data[:n], headerMagic) && data[n] == formatV3
+}
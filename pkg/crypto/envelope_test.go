@@ -0,0 +1,164 @@
+package crypto
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestDataKeyEnvelope(t *testing.T) {
+	ctx := context.Background()
+	k := testKeyring(t, "k1", map[string]string{"k1": testKey(t)}, "")
+	c := NewAESGCM(k, NewEnvKeyProvider(k))
+
+	key, wrapped, err := c.NewDataKey(ctx, ChatKeyAAD(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealed, err := key.Encrypt([]byte("hi there"), MessageAAD(10, 1, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEnvelope(sealed) {
+		t.Fatal("data key ciphertext is not an envelope")
+	}
+
+	reopened, err := c.OpenDataKey(ctx, wrapped, ChatKeyAAD(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := reopened.Decrypt(sealed, MessageAAD(10, 1, 1)); err != nil || string(got) != "hi there" {
+		t.Errorf("Decrypt = %q, %v", got, err)
+	}
+	if _, err := reopened.Decrypt(sealed, MessageAAD(10, 1, 2)); err == nil {
+		t.Error("Decrypt with the wrong aad succeeded")
+	}
+
+	// The wrapped key is bound to its chat
+	if _, err := c.OpenDataKey(ctx, wrapped, ChatKeyAAD(11)); err == nil {
+		t.Error("OpenDataKey for another chat succeeded")
+	}
+
+	// Another chat's key cannot open this chat's rows
+	other, _, err := c.NewDataKey(ctx, ChatKeyAAD(11))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Decrypt(sealed, MessageAAD(10, 1, 1)); err == nil {
+		t.Error("another chat's key opened the row")
+	}
+}
+
+func TestDataKeyFallback(t *testing.T) {
+	ctx := context.Background()
+	k := testKeyring(t, "k1", map[string]string{"k1": testKey(t)}, "")
+	c := NewAESGCM(k, NewEnvKeyProvider(k))
+
+	// Rows from before the chat had a key were sealed with the keyring
+	old, err := c.Encrypt([]byte("before keys"), MessageAAD(3, 1, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	empty, err := c.OpenDataKey(ctx, nil, ChatKeyAAD(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := empty.Decrypt(old, MessageAAD(3, 1, 1)); err != nil || string(got) != "before keys" {
+		t.Errorf("Decrypt without a data key = %q, %v", got, err)
+	}
+	if _, err := empty.Encrypt([]byte("x"), nil); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Encrypt without a data key = %v, want ErrUnknownKey", err)
+	}
+	if _, err := empty.BlindIndex("word"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("BlindIndex without a data key = %v, want ErrUnknownKey", err)
+	}
+
+	key, _, err := c.NewDataKey(ctx, ChatKeyAAD(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := key.Decrypt(old, MessageAAD(3, 1, 1)); err != nil || string(got) != "before keys" {
+		t.Errorf("Decrypt of a pre-key row = %q, %v", got, err)
+	}
+}
+
+func TestRewrapDataKey(t *testing.T) {
+	ctx := context.Background()
+	m1, m2 := testKey(t), testKey(t)
+	k := testKeyring(t, "k1", map[string]string{"k1": testKey(t)}, "")
+
+	before := testKeyring(t, "m1", map[string]string{"m1": m1}, "")
+	c := NewAESGCM(k, NewEnvKeyProvider(before))
+
+	key, wrapped, err := c.NewDataKey(ctx, ChatKeyAAD(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := key.Encrypt([]byte("kept"), MessageAAD(5, 1, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	same, rewrapped, err := c.RewrapDataKey(ctx, wrapped, ChatKeyAAD(5))
+	if err != nil || rewrapped || !bytes.Equal(same, wrapped) {
+		t.Fatalf("RewrapDataKey under the same master key = %v, %v", rewrapped, err)
+	}
+
+	// Rotating the master key alone must rewrap, even though the message
+	// keyring has not moved
+	c.Reload(k, NewEnvKeyProvider(testKeyring(t, "m2", map[string]string{"m1": m1, "m2": m2}, "")))
+
+	moved, rewrapped, err := c.RewrapDataKey(ctx, wrapped, ChatKeyAAD(5))
+	if err != nil || !rewrapped {
+		t.Fatalf("RewrapDataKey after rotation = %v, %v", rewrapped, err)
+	}
+	if KeyID(moved) != "m2" {
+		t.Errorf("rewrapped under %q, want m2", KeyID(moved))
+	}
+
+	// The data key itself is unchanged, so its rows still open
+	reopened, err := c.OpenDataKey(ctx, moved, ChatKeyAAD(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := reopened.Decrypt(sealed, MessageAAD(5, 1, 1)); err != nil || string(got) != "kept" {
+		t.Errorf("Decrypt after rewrap = %q, %v", got, err)
+	}
+}
+
+func TestBlindIndex(t *testing.T) {
+	ctx := context.Background()
+	k := testKeyring(t, "k1", map[string]string{"k1": testKey(t)}, "")
+	c := NewAESGCM(k, NewEnvKeyProvider(k))
+
+	key, wrapped, err := c.NewDataKey(ctx, ChatKeyAAD(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := c.OpenDataKey(ctx, wrapped, ChatKeyAAD(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, err := c.NewDataKey(ctx, ChatKeyAAD(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, _ := key.BlindIndex("hello")
+	b, _ := reopened.BlindIndex("hello")
+	d, _ := key.BlindIndex("world")
+	o, _ := other.BlindIndex("hello")
+
+	if len(a) != blindIndexSize {
+		t.Errorf("digest is %d bytes, want %d", len(a), blindIndexSize)
+	}
+	if !bytes.Equal(a, b) {
+		t.Error("the same key gave different digests for one word")
+	}
+	if bytes.Equal(a, d) || bytes.Equal(a, o) {
+		t.Error("digests collide across words or chats")
+	}
+}
@@ -0,0 +1,216 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Ciphertexts written since key rotation start with a header naming the key:
+//
+//	magic(2) || version(1) || len(keyID)(1) || keyID || nonce || sealed
+//
+// Version 1 seals without associated data, version 2 binds the ciphertext to
+// the AAD its caller supplies. Rows from before either carry only
+// nonce || sealed and belong to the legacy key.
+var headerMagic = []byte{'f', 'k'}
+
+const (
+	formatV1     byte = 1
+	formatV2     byte = 2
+	LegacyKeyID       = "legacy"
+	maxKeyIDSize      = 255
+)
+
+var (
+	ErrUnknownKey        = errors.New("ciphertext sealed with unknown key")
+	ErrInvalidCiphertext = errors.New("invalid ciphertext")
+	ErrUnboundCiphertext = errors.New("ciphertext is not bound to its context")
+)
+
+// Keyring holds every key that may still be needed to open old rows and
+// names the one new rows are sealed with.
+type Keyring struct {
+	active     string
+	keys       map[string]cipher.AEAD
+	requireAAD bool
+}
+
+// NewKeyring builds a keyring from base64 keys by ID. legacyKey, if set, is
+// added as LegacyKeyID and opens rows written before key IDs existed. active
+// defaults to LegacyKeyID when only the legacy key is configured.
+func NewKeyring(active string, keys map[string]string, legacyKey string) (*Keyring, error) {
+	k := &Keyring{active: active, keys: map[string]cipher.AEAD{}}
+
+	for id, keyB64 := range keys {
+		if id == "" || len(id) > maxKeyIDSize || id == LegacyKeyID {
+			return nil, fmt.Errorf("invalid key id %q", id)
+		}
+		if err := k.add(id, keyB64); err != nil {
+			return nil, err
+		}
+	}
+
+	if legacyKey != "" {
+		if err := k.add(LegacyKeyID, legacyKey); err != nil {
+			return nil, err
+		}
+		if k.active == "" && len(keys) == 0 {
+			k.active = LegacyKeyID
+		}
+	}
+
+	if len(k.keys) == 0 {
+		return nil, errors.New("missing FLICK_MESSAGE_ENCRYPTION_KEYS")
+	}
+	if _, ok := k.keys[k.active]; !ok {
+		return nil, fmt.Errorf("active key %q is not in the keyring", k.active)
+	}
+
+	return k, nil
+}
+
+func (k *Keyring) add(id, keyB64 string) error {
+	key, err := base64.StdEncoding.DecodeString(keyB64)
+	if err != nil {
+		return fmt.Errorf("key %q: %w", id, err)
+	}
+
+	if len(key) != 32 {
+		return fmt.Errorf("key %q must be 32 bytes", id)
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return err
+	}
+
+	k.keys[id] = aead
+	return nil
+}
+
+// RequireAAD stops the keyring from opening ciphertexts written before
+// associated data was bound. Turn it on once re-encryption has finished, as
+// until then a row can still be moved between contexts undetected.
+func (k *Keyring) RequireAAD() {
+	k.requireAAD = true
+}
+
+// ActiveKeyID names the key new ciphertexts are sealed with.
+func (k *Keyring) ActiveKeyID() string {
+	return k.active
+}
+
+// KeyIDs lists every key the keyring can open, sorted.
+func (k *Keyring) KeyIDs() []string {
+	ids := make([]string, 0, len(k.keys))
+	for id := range k.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (k *Keyring) seal(plaintext, aad []byte) ([]byte, error) {
+	aead := k.keys[k.active]
+
+	nonce, err := newNonce(aead)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, len(headerMagic)+2+len(k.active)+len(nonce)+len(plaintext)+aead.Overhead())
+	out = append(out, headerMagic...)
+	out = append(out, formatV2, byte(len(k.active)))
+	out = append(out, k.active...)
+	out = append(out, nonce...)
+
+	return aead.Seal(out, nonce, plaintext, aad), nil
+}
+
+// needsReseal reports whether data is not sealed the way seal seals it now.
+func (k *Keyring) needsReseal(data []byte) bool {
+	version, id, _, versioned := parseHeader(data)
+	return !versioned || version != formatV2 || id != k.active
+}
+
+func (k *Keyring) open(data, aad []byte) ([]byte, error) {
+	version, id, body, versioned := parseHeader(data)
+	if versioned {
+		if aead, known := k.keys[id]; known {
+			if version == formatV2 {
+				if plaintext, err := openWith(aead, body, aad); err == nil {
+					return plaintext, nil
+				}
+			} else if !k.requireAAD {
+				if plaintext, err := openWith(aead, body, nil); err == nil {
+					return plaintext, nil
+				}
+			}
+		}
+	}
+
+	// Legacy rows have random leading bytes that can happen to look like a
+	// header, so anything that didn't open above gets one more try.
+	legacy, ok := k.keys[LegacyKeyID]
+	switch {
+	case k.requireAAD && !(versioned && version == formatV2):
+		return nil, ErrUnboundCiphertext
+	case !ok && versioned:
+		return nil, ErrUnknownKey
+	case !ok:
+		return nil, ErrInvalidCiphertext
+	}
+
+	return openWith(legacy, data, nil)
+}
+
+// KeyID reports which key a ciphertext claims to be sealed with, treating
+// anything without a header as legacy.
+func KeyID(data []byte) string {
+	if _, id, _, ok := parseHeader(data); ok {
+		return id
+	}
+	return LegacyKeyID
+}
+
+func parseHeader(data []byte) (version byte, keyID string, body []byte, ok bool) {
+	n := len(headerMagic)
+	if len(data) < n+2 || !bytes.Equal(data[:n], headerMagic) {
+		return 0, "", nil, false
+	}
+
+	version = data[n]
+	if version != formatV1 && version != formatV2 {
+		return 0, "", nil, false
+	}
+
+	idLen := int(data[n+1])
+	if idLen == 0 || len(data) < n+2+idLen {
+		return 0, "", nil, false
+	}
+
+	return version, string(data[n+2 : n+2+idLen]), data[n+2+idLen:], true
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func openWith(aead cipher.AEAD, data, aad []byte) ([]byte, error) {
+	nonceSize := aead.NonceSize()
+	if len(data) < nonceSize {
+		return nil, ErrInvalidCiphertext
+	}
+
+	return aead.Open(nil, data[:nonceSize], data[nonceSize:], aad)
+}
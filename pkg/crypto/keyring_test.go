@@ -0,0 +1,116 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func testKey(t *testing.T) string {
+	t.Helper()
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func testKeyring(t *testing.T, active string, keys map[string]string, legacy string) *Keyring {
+	t.Helper()
+	k, err := NewKeyring(active, keys, legacy)
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	return k
+}
+
+func TestKeyringSealOpen(t *testing.T) {
+	k := testKeyring(t, "k1", map[string]string{"k1": testKey(t)}, "")
+	c := NewAESGCM(k, NewEnvKeyProvider(k))
+
+	sealed, err := c.Encrypt([]byte("hello"), MessageAAD(1, 2, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if KeyID(sealed) != "k1" || c.NeedsReencrypt(sealed) {
+		t.Errorf("sealed with %q, needs re-encrypt %v", KeyID(sealed), c.NeedsReencrypt(sealed))
+	}
+
+	got, err := c.Decrypt(sealed, MessageAAD(1, 2, 3))
+	if err != nil || string(got) != "hello" {
+		t.Fatalf("Decrypt = %q, %v", got, err)
+	}
+
+	// A row moved to another message must not open
+	if _, err := c.Decrypt(sealed, MessageAAD(1, 2, 4)); err == nil {
+		t.Error("Decrypt with the wrong aad succeeded")
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	k1, k2 := testKey(t), testKey(t)
+	old := testKeyring(t, "k1", map[string]string{"k1": k1}, "")
+	sealed, err := old.seal([]byte("secret"), UserSecretAAD(7))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated := testKeyring(t, "k2", map[string]string{"k1": k1, "k2": k2}, "")
+	if !rotated.needsReseal(sealed) {
+		t.Error("ciphertext under a retired key does not need resealing")
+	}
+	if got, err := rotated.open(sealed, UserSecretAAD(7)); err != nil || string(got) != "secret" {
+		t.Errorf("open after rotation = %q, %v", got, err)
+	}
+
+	dropped := testKeyring(t, "k2", map[string]string{"k2": k2}, "")
+	if _, err := dropped.open(sealed, UserSecretAAD(7)); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("open with the key removed = %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestKeyringLegacy(t *testing.T) {
+	legacy := testKey(t)
+	raw, _ := base64.StdEncoding.DecodeString(legacy)
+	aead, err := newAEAD(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce, _ := newNonce(aead)
+	row := aead.Seal(append([]byte{}, nonce...), nonce, []byte("old row"), nil)
+
+	k := testKeyring(t, "k1", map[string]string{"k1": testKey(t)}, legacy)
+	if KeyID(row) != LegacyKeyID || !k.needsReseal(row) {
+		t.Errorf("legacy row reports key %q", KeyID(row))
+	}
+	if got, err := k.open(row, []byte("ignored")); err != nil || string(got) != "old row" {
+		t.Errorf("open legacy row = %q, %v", got, err)
+	}
+
+	k.RequireAAD()
+	if _, err := k.open(row, nil); !errors.Is(err, ErrUnboundCiphertext) {
+		t.Errorf("open legacy row with AAD required = %v, want ErrUnboundCiphertext", err)
+	}
+}
+
+func TestNewKeyringErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		active string
+		keys   map[string]string
+		legacy string
+	}{
+		{"no keys", "", nil, ""},
+		{"active missing", "k2", map[string]string{"k1": testKey(t)}, ""},
+		{"reserved id", LegacyKeyID, map[string]string{LegacyKeyID: testKey(t)}, ""},
+		{"short key", "k1", map[string]string{"k1": base64.StdEncoding.EncodeToString([]byte("short"))}, ""},
+		{"not base64", "k1", map[string]string{"k1": "!!"}, ""},
+	}
+
+	for _, tt := range tests {
+		if _, err := NewKeyring(tt.active, tt.keys, tt.legacy); err == nil {
+			t.Errorf("%s: NewKeyring succeeded", tt.name)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package crypto
+
+import (
+	"bytes"
+	"context"
+	"errors"
+)
+
+var errAADMismatch = errors.New("associated data does not match")
+
+// Nop is a Cipher for tests and local tooling. It stores plaintext next to
+// its associated data so handlers behave as they would with AESGCM, mismatched
+// aad included, but it protects nothing and must never be used in production.
+type Nop struct{}
+
+func (Nop) Encrypt(plaintext, aad []byte) ([]byte, error) {
+	return nopSeal(plaintext, aad), nil
+}
+
+func (Nop) Decrypt(data, aad []byte) ([]byte, error) {
+	return nopOpen(data, aad)
+}
+
+func (Nop) NewDataKey(context.Context, []byte) (DataKey, []byte, error) {
+	return Nop{}, []byte("nop"), nil
+}
+
+func (Nop) OpenDataKey(context.Context, []byte, []byte) (DataKey, error) {
+	return Nop{}, nil
+}
+
+func (Nop) RewrapDataKey(_ context.Context, wrapped, _ []byte) ([]byte, bool, error) {
+	return wrapped, false, nil
+}
+
+func (Nop) BlindIndex(token string) ([]byte, error) {
+	return []byte(token), nil
+}
+
+func (Nop) ActiveKeyID() string {
+	return "nop"
+}
+
+func (Nop) NeedsReencrypt([]byte) bool {
+	return false
+}
+
+// nopSeal lays out len(aad)(1) || aad || plaintext. Associated data is
+// truncated to 255 bytes, which covers every AAD this package builds.
+func nopSeal(plaintext, aad []byte) []byte {
+	aad = aad[:min(len(aad), 255)]
+
+	out := make([]byte, 0, 1+len(aad)+len(plaintext))
+	out = append(out, byte(len(aad)))
+	out = append(out, aad...)
+	return append(out, plaintext...)
+}
+
+func nopOpen(data, aad []byte) ([]byte, error) {
+	aad = aad[:min(len(aad), 255)]
+
+	if len(data) < 1 || len(data) < 1+int(data[0]) {
+		return nil, ErrInvalidCiphertext
+	}
+	if !bytes.Equal(data[1:1+int(data[0])], aad) {
+		return nil, errAADMismatch
+	}
+	return data[1+int(data[0]):], nil
+}
+
+var (
+	_ Cipher = (*AESGCM)(nil)
+	_ Cipher = Nop{}
+)
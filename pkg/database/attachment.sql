@@ -0,0 +1,50 @@
+-- name: NextAttachmentID :one
+SELECT nextval(pg_get_serial_sequence('attachments', 'attachment_id'))::bigint;
+
+-- name: CreateAttachment :exec
+INSERT INTO attachments (attachment_id, chat_id, uploader_id, blob_key, file_name, mime_type, size_bytes, sha256, width, height, thumbnail_key)
+VALUES (@attachment_id, @chat_id, @uploader_id, @blob_key, @file_name, @mime_type, @size_bytes, @sha256, @width, @height, @thumbnail_key);
+
+-- name: GetAttachmentUsage :one
+SELECT COALESCE(SUM(a.size_bytes), 0)::bigint
+FROM attachments a
+WHERE a.uploader_id = @uploader_id;
+
+-- name: GetAttachment :one
+SELECT a.*
+FROM attachments a
+WHERE a.chat_id = @chat_id
+  AND a.attachment_id = @attachment_id;
+
+-- Claims the uploader's pending attachments for a message. Ids that are
+-- someone else's, in another chat or already sent are left out of the
+-- result rather than failing.
+
+-- name: AttachToMessage :many
+UPDATE attachments
+SET message_id = @message_id::bigint
+WHERE chat_id = @chat_id
+  AND uploader_id = @uploader_id
+  AND message_id IS NULL
+  AND attachment_id = ANY(@attachment_ids::bigint[])
+RETURNING *;
+
+-- name: ListMessageAttachments :many
+SELECT a.*
+FROM attachments a
+WHERE a.message_id = ANY(@message_ids::bigint[])
+ORDER BY a.attachment_id;
+
+-- Blobs to remove once the chat itself is deleted, which takes the rows
+-- with it.
+
+-- name: ListChatAttachmentBlobs :many
+SELECT a.blob_key, a.thumbnail_key
+FROM attachments a
+WHERE a.chat_id = @chat_id
+ORDER BY a.attachment_id;
+
+-- name: DeleteMessageAttachments :many
+DELETE FROM attachments
+WHERE message_id = @message_id::bigint
+RETURNING blob_key, thumbnail_key;
@@ -0,0 +1,139 @@
+-- name: CreateRefreshToken :one
+INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at)
+VALUES (@user_id, COALESCE(sqlc.narg(family_id)::uuid, gen_random_uuid()), @token_hash, @expires_at)
+RETURNING token_id, family_id;
+
+-- name: GetRefreshTokenForUpdate :one
+SELECT token_id, user_id, family_id, expires_at, used_at, revoked_at
+FROM refresh_tokens
+WHERE token_hash = $1
+FOR UPDATE;
+
+-- name: MarkRefreshTokenUsed :exec
+UPDATE refresh_tokens
+SET used_at = now()
+WHERE token_id = $1;
+
+-- name: RevokeRefreshTokenFamily :execrows
+UPDATE refresh_tokens
+SET revoked_at = now()
+WHERE family_id = $1
+  AND revoked_at IS NULL;
+
+-- name: CreateSession :one
+INSERT INTO sessions (user_id, user_agent, ip_address)
+VALUES ($1, $2, $3)
+RETURNING session_id;
+
+-- name: TouchSession :execrows
+UPDATE sessions
+SET last_seen_at = now(),
+    ip_address = $2
+WHERE session_id = $1
+  AND revoked_at IS NULL;
+
+-- name: ListUserSessions :many
+SELECT session_id, user_agent, ip_address, created_at, last_seen_at
+FROM sessions
+WHERE user_id = $1
+  AND revoked_at IS NULL
+ORDER BY last_seen_at DESC;
+
+-- name: RevokeSession :execrows
+UPDATE sessions
+SET revoked_at = now()
+WHERE session_id = $1
+  AND user_id = $2
+  AND revoked_at IS NULL;
+
+-- name: RevokeAllUserSessions :many
+UPDATE sessions
+SET revoked_at = now()
+WHERE user_id = @user_id
+  AND revoked_at IS NULL
+  AND (sqlc.narg(keep_session_id)::uuid IS NULL OR session_id <> sqlc.narg(keep_session_id)::uuid)
+RETURNING session_id;
+
+-- name: RevokeAllUserRefreshTokens :exec
+UPDATE refresh_tokens
+SET revoked_at = now()
+WHERE user_id = @user_id
+  AND revoked_at IS NULL
+  AND (sqlc.narg(keep_session_id)::uuid IS NULL OR family_id <> sqlc.narg(keep_session_id)::uuid);
+
+-- name: GetUserTOTPForUpdate :one
+SELECT totp_secret, totp_enabled, totp_last_step, mfa_locked_until
+FROM users
+WHERE user_id = $1
+FOR UPDATE;
+
+-- name: SetPendingTOTPSecret :exec
+UPDATE users
+SET totp_secret = $1,
+    totp_enabled = FALSE,
+    totp_last_step = NULL
+WHERE user_id = $2;
+
+-- name: EnableTOTP :exec
+UPDATE users
+SET totp_enabled = TRUE,
+    totp_last_step = $1
+WHERE user_id = $2;
+
+-- name: SetTOTPLastStep :exec
+UPDATE users
+SET totp_last_step = $1
+WHERE user_id = $2;
+
+-- name: RecordMFAFailure :exec
+UPDATE users
+SET mfa_failed_attempts = mfa_failed_attempts + 1,
+    mfa_locked_until = CASE
+      WHEN mfa_failed_attempts + 1 >= @max_attempts::integer THEN @locked_until::timestamptz
+      ELSE mfa_locked_until
+    END
+WHERE user_id = @user_id;
+
+-- name: ResetMFAFailures :exec
+UPDATE users
+SET mfa_failed_attempts = 0,
+    mfa_locked_until = NULL
+WHERE user_id = $1;
+
+-- name: UseMFAChallenge :execrows
+INSERT INTO used_mfa_challenges (challenge_id, user_id, expires_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (challenge_id) DO NOTHING;
+
+-- name: DeleteExpiredMFAChallenges :exec
+DELETE FROM used_mfa_challenges
+WHERE expires_at < now();
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM recovery_codes
+WHERE user_id = $1;
+
+-- name: CreateRecoveryCode :exec
+INSERT INTO recovery_codes (user_id, code_hash)
+VALUES ($1, $2);
+
+-- name: UseRecoveryCode :execrows
+UPDATE recovery_codes
+SET used_at = now()
+WHERE user_id = $1
+  AND code_hash = $2
+  AND used_at IS NULL;
+
+-- name: ListTOTPSecrets :many
+SELECT user_id, totp_secret
+FROM users
+WHERE totp_secret IS NOT NULL
+  AND user_id > @after_id
+ORDER BY user_id
+LIMIT @batch_size;
+
+-- name: ReplaceTOTPSecret :execrows
+UPDATE users
+SET totp_secret = @new_totp_secret
+WHERE user_id = @user_id
+  AND totp_secret = @old_totp_secret;
@@ -1,23 +1,66 @@
 -- name: GetChatByID :one
-SELECT c.chat_id, c.last_message_id
+SELECT c.chat_id, c.kind, c.title, c.last_message_id
 FROM chats c
 WHERE c.chat_id = $1;
 
 -- name: CreateEmptyChat :one
-INSERT INTO chats DEFAULT VALUES
+INSERT INTO chats (end_to_end)
+VALUES ($1)
 RETURNING chat_id, last_message_id;
 
+-- name: CreateGroupChat :one
+INSERT INTO chats (kind, title, end_to_end)
+VALUES ('group', $1, $2)
+RETURNING chat_id;
+
+-- name: IsChatEndToEnd :one
+SELECT end_to_end
+FROM chats
+WHERE chat_id = $1;
+
+-- name: UpdateChatTitle :execrows
+UPDATE chats
+SET title = $1
+WHERE chat_id = $2
+  AND kind = 'group';
+
+-- name: CountChatParticipants :one
+SELECT COUNT(*)::bigint
+FROM chat_participants cp
+WHERE cp.chat_id = $1;
+
 -- name: AddParticipant :exec
-INSERT INTO chat_participants (chat_id, user_id, is_typing)
-VALUES ($1, $2, FALSE)
+INSERT INTO chat_participants (chat_id, user_id, is_typing, role)
+VALUES ($1, $2, FALSE, $3)
 ON CONFLICT (chat_id, user_id) DO NOTHING;
 
+-- name: GetParticipantRole :one
+SELECT cp.role
+FROM chat_participants cp
+WHERE cp.chat_id = $1
+  AND cp.user_id = $2;
+
+-- name: SetParticipantRole :execrows
+UPDATE chat_participants
+SET role = $1
+WHERE chat_id = $2
+  AND user_id = $3;
+
+-- name: FindSuccessorOwner :one
+SELECT cp.user_id
+FROM chat_participants cp
+WHERE cp.chat_id = $1
+ORDER BY (cp.role = 'admin') DESC, cp.joined_at, cp.user_id
+LIMIT 1;
+
 -- name: FindDirectChatBetween :one
 SELECT c.chat_id
 FROM chats c
 JOIN chat_participants cp1 ON cp1.chat_id = c.chat_id AND cp1.user_id = $1
 JOIN chat_participants cp2 ON cp2.chat_id = c.chat_id AND cp2.user_id = $2
-WHERE NOT EXISTS (
+WHERE c.kind = 'direct'
+  AND c.end_to_end = $3
+  AND NOT EXISTS (
   SELECT 1
   FROM chat_participants cp3
   WHERE cp3.chat_id = c.chat_id
@@ -25,11 +68,6 @@ WHERE NOT EXISTS (
 )
 LIMIT 1;
 
--- name: DeleteMessage :execrows
-DELETE FROM messages
-WHERE message_id = $1
-  AND sender_id = $2;  -- optional: only author can delete
-
 -- name: DeleteChat :execrows
 DELETE FROM chats
 WHERE chat_id = $1;
@@ -64,18 +102,40 @@ ORDER BY m.created_at DESC NULLS LAST;
 -- name: ListChatsWithUser :many
 SELECT
   c.chat_id,
+  c.kind,
+  c.title,
+  c.end_to_end,
 
   m.message_id,
   m.sender_id,
   m.created_at,
-  m.cypher_text
+  m.cypher_text,
+
+  k.wrapped_key
   
 FROM chats c
 JOIN chat_participants cp
   ON cp.chat_id = c.chat_id
  AND cp.user_id = $1
+-- the newest message this user can still see, normally c.last_message_id
 LEFT JOIN messages m
-  ON m.message_id = c.last_message_id
+  ON m.message_id = (
+    SELECT v.message_id
+    FROM messages v
+    WHERE v.chat_id = c.chat_id
+      AND v.thread_root_id IS NULL
+      AND v.deleted_at IS NULL
+      AND NOT EXISTS (
+        SELECT 1
+        FROM hidden_messages h
+        WHERE h.message_id = v.message_id
+          AND h.user_id = $1
+      )
+    ORDER BY v.created_at DESC, v.message_id DESC
+    LIMIT 1
+  )
+LEFT JOIN chat_keys k
+  ON k.chat_id = c.chat_id
 ORDER BY
   m.created_at DESC NULLS LAST,
   c.last_message_id DESC,
@@ -85,6 +145,7 @@ ORDER BY
 -- name: ListChatParticipants :many
 SELECT
   cp.chat_id,
+  cp.role,
   u.pfp_url,
   u.user_id,
   u.first_name,
@@ -100,6 +161,19 @@ UPDATE chats
 SET last_message_id = $1
 WHERE chat_id = $2;
 
+-- name: RefreshChatLastMessage :exec
+UPDATE chats
+SET last_message_id = (
+  SELECT m.message_id
+  FROM messages m
+  WHERE m.chat_id = @chat_id
+    AND m.thread_root_id IS NULL
+    AND m.deleted_at IS NULL
+  ORDER BY m.created_at DESC, m.message_id DESC
+  LIMIT 1
+)
+WHERE chat_id = @chat_id;
+
 -- name: SetTypingStatus :execrows
 UPDATE chat_participants cp
 SET is_typing = $1
@@ -132,4 +206,40 @@ WHERE cp.chat_id = $1
   AND (
         cp.last_read_message_id IS NULL
         OR m.message_id > cp.last_read_message_id
-      );
\ No newline at end of file
+      )
+  AND m.thread_root_id IS NULL -- threads keep their own read markers
+  AND m.deleted_at IS NULL
+  AND NOT EXISTS (
+    SELECT 1
+    FROM hidden_messages h
+    WHERE h.message_id = m.message_id
+      AND h.user_id = cp.user_id
+  );
+
+-- name: ListChatParticipantIDs :many
+SELECT cp.user_id
+FROM chat_participants cp
+WHERE cp.chat_id = $1;
+
+-- name: GetChatKey :one
+SELECT wrapped_key
+FROM chat_keys
+WHERE chat_id = $1;
+
+-- name: CreateChatKey :exec
+INSERT INTO chat_keys (chat_id, wrapped_key)
+VALUES ($1, $2)
+ON CONFLICT (chat_id) DO NOTHING;
+
+-- name: ListChatKeys :many
+SELECT chat_id, wrapped_key
+FROM chat_keys
+WHERE chat_id > @after_id
+ORDER BY chat_id
+LIMIT @batch_size;
+
+-- name: ReplaceChatKey :execrows
+UPDATE chat_keys
+SET wrapped_key = @new_wrapped_key
+WHERE chat_id = @chat_id
+  AND wrapped_key = @old_wrapped_key;
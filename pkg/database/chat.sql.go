@@ -13,31 +13,80 @@ import (
 )
 
 const addParticipant = `-- name: AddParticipant :exec
-INSERT INTO chat_participants (chat_id, user_id, is_typing)
-VALUES ($1, $2, FALSE)
+INSERT INTO chat_participants (chat_id, user_id, is_typing, role)
+VALUES ($1, $2, FALSE, $3)
 ON CONFLICT (chat_id, user_id) DO NOTHING
 `
 
 type AddParticipantParams struct {
-	ChatID int64 `json:"chat_id"`
-	UserID int64 `json:"user_id"`
+	ChatID int64  `json:"chat_id"`
+	UserID int64  `json:"user_id"`
+	Role   string `json:"role"`
 }
 
 // AddParticipant
 //
-//	INSERT INTO chat_participants (chat_id, user_id, is_typing)
-//	VALUES ($1, $2, FALSE)
+//	INSERT INTO chat_participants (chat_id, user_id, is_typing, role)
+//	VALUES ($1, $2, FALSE, $3)
 //	ON CONFLICT (chat_id, user_id) DO NOTHING
 func (q *Queries) AddParticipant(ctx context.Context, arg AddParticipantParams) error {
-	_, err := q.db.Exec(ctx, addParticipant, arg.ChatID, arg.UserID)
+	_, err := q.db.Exec(ctx, addParticipant, arg.ChatID, arg.UserID, arg.Role)
+	return err
+}
+
+const countChatParticipants = `-- name: CountChatParticipants :one
+SELECT COUNT(*)::bigint
+FROM chat_participants cp
+WHERE cp.chat_id = $1
+`
+
+type CountChatParticipantsParams struct {
+	ChatID int64 `json:"chat_id"`
+}
+
+// CountChatParticipants
+//
+//	SELECT COUNT(*)::bigint
+//	FROM chat_participants cp
+//	WHERE cp.chat_id = $1
+func (q *Queries) CountChatParticipants(ctx context.Context, arg CountChatParticipantsParams) (int64, error) {
+	row := q.db.QueryRow(ctx, countChatParticipants, arg.ChatID)
+	var column_1 int64
+	err := row.Scan(&column_1)
+	return column_1, err
+}
+
+const createChatKey = `-- name: CreateChatKey :exec
+INSERT INTO chat_keys (chat_id, wrapped_key)
+VALUES ($1, $2)
+ON CONFLICT (chat_id) DO NOTHING
+`
+
+type CreateChatKeyParams struct {
+	ChatID     int64  `json:"chat_id"`
+	WrappedKey []byte `json:"wrapped_key"`
+}
+
+// CreateChatKey
+//
+//	INSERT INTO chat_keys (chat_id, wrapped_key)
+//	VALUES ($1, $2)
+//	ON CONFLICT (chat_id) DO NOTHING
+func (q *Queries) CreateChatKey(ctx context.Context, arg CreateChatKeyParams) error {
+	_, err := q.db.Exec(ctx, createChatKey, arg.ChatID, arg.WrappedKey)
 	return err
 }
 
 const createEmptyChat = `-- name: CreateEmptyChat :one
-INSERT INTO chats DEFAULT VALUES
+INSERT INTO chats (end_to_end)
+VALUES ($1)
 RETURNING chat_id, last_message_id
 `
 
+type CreateEmptyChatParams struct {
+	EndToEnd bool `json:"end_to_end"`
+}
+
 type CreateEmptyChatRow struct {
 	ChatID        int64  `json:"chat_id"`
 	LastMessageID *int64 `json:"last_message_id"`
@@ -45,17 +94,40 @@ type CreateEmptyChatRow struct {
 
 // CreateEmptyChat
 //
-//	INSERT INTO chats DEFAULT VALUES
+//	INSERT INTO chats (end_to_end)
+//	VALUES ($1)
 //	RETURNING chat_id, last_message_id
-func (q *Queries) CreateEmptyChat(ctx context.Context) (CreateEmptyChatRow, error) {
-	row := q.db.QueryRow(ctx, createEmptyChat)
+func (q *Queries) CreateEmptyChat(ctx context.Context, arg CreateEmptyChatParams) (CreateEmptyChatRow, error) {
+	row := q.db.QueryRow(ctx, createEmptyChat, arg.EndToEnd)
 	var i CreateEmptyChatRow
 	err := row.Scan(&i.ChatID, &i.LastMessageID)
 	return i, err
 }
 
-const deleteChat = `-- name: DeleteChat :execrows
+const createGroupChat = `-- name: CreateGroupChat :one
+INSERT INTO chats (kind, title, end_to_end)
+VALUES ('group', $1, $2)
+RETURNING chat_id
+`
 
+type CreateGroupChatParams struct {
+	Title    *string `json:"title"`
+	EndToEnd bool    `json:"end_to_end"`
+}
+
+// CreateGroupChat
+//
+//	INSERT INTO chats (kind, title, end_to_end)
+//	VALUES ('group', $1, $2)
+//	RETURNING chat_id
+func (q *Queries) CreateGroupChat(ctx context.Context, arg CreateGroupChatParams) (int64, error) {
+	row := q.db.QueryRow(ctx, createGroupChat, arg.Title, arg.EndToEnd)
+	var chat_id int64
+	err := row.Scan(&chat_id)
+	return chat_id, err
+}
+
+const deleteChat = `-- name: DeleteChat :execrows
 DELETE FROM chats
 WHERE chat_id = $1
 `
@@ -64,7 +136,7 @@ type DeleteChatParams struct {
 	ChatID int64 `json:"chat_id"`
 }
 
-// optional: only author can delete
+// DeleteChat
 //
 //	DELETE FROM chats
 //	WHERE chat_id = $1
@@ -76,36 +148,14 @@ func (q *Queries) DeleteChat(ctx context.Context, arg DeleteChatParams) (int64,
 	return result.RowsAffected(), nil
 }
 
-const deleteMessage = `-- name: DeleteMessage :execrows
-DELETE FROM messages
-WHERE message_id = $1
-  AND sender_id = $2
-`
-
-type DeleteMessageParams struct {
-	MessageID int64 `json:"message_id"`
-	SenderID  int64 `json:"sender_id"`
-}
-
-// DeleteMessage
-//
-//	DELETE FROM messages
-//	WHERE message_id = $1
-//	  AND sender_id = $2
-func (q *Queries) DeleteMessage(ctx context.Context, arg DeleteMessageParams) (int64, error) {
-	result, err := q.db.Exec(ctx, deleteMessage, arg.MessageID, arg.SenderID)
-	if err != nil {
-		return 0, err
-	}
-	return result.RowsAffected(), nil
-}
-
 const findDirectChatBetween = `-- name: FindDirectChatBetween :one
 SELECT c.chat_id
 FROM chats c
 JOIN chat_participants cp1 ON cp1.chat_id = c.chat_id AND cp1.user_id = $1
 JOIN chat_participants cp2 ON cp2.chat_id = c.chat_id AND cp2.user_id = $2
-WHERE NOT EXISTS (
+WHERE c.kind = 'direct'
+  AND c.end_to_end = $3
+  AND NOT EXISTS (
   SELECT 1
   FROM chat_participants cp3
   WHERE cp3.chat_id = c.chat_id
@@ -117,6 +167,7 @@ LIMIT 1
 type FindDirectChatBetweenParams struct {
 	UserID   int64 `json:"user_id"`
 	UserID_2 int64 `json:"user_id_2"`
+	EndToEnd bool  `json:"end_to_end"`
 }
 
 // FindDirectChatBetween
@@ -125,7 +176,9 @@ type FindDirectChatBetweenParams struct {
 //	FROM chats c
 //	JOIN chat_participants cp1 ON cp1.chat_id = c.chat_id AND cp1.user_id = $1
 //	JOIN chat_participants cp2 ON cp2.chat_id = c.chat_id AND cp2.user_id = $2
-//	WHERE NOT EXISTS (
+//	WHERE c.kind = 'direct'
+//	  AND c.end_to_end = $3
+//	  AND NOT EXISTS (
 //	  SELECT 1
 //	  FROM chat_participants cp3
 //	  WHERE cp3.chat_id = c.chat_id
@@ -133,14 +186,40 @@ type FindDirectChatBetweenParams struct {
 //	)
 //	LIMIT 1
 func (q *Queries) FindDirectChatBetween(ctx context.Context, arg FindDirectChatBetweenParams) (int64, error) {
-	row := q.db.QueryRow(ctx, findDirectChatBetween, arg.UserID, arg.UserID_2)
+	row := q.db.QueryRow(ctx, findDirectChatBetween, arg.UserID, arg.UserID_2, arg.EndToEnd)
 	var chat_id int64
 	err := row.Scan(&chat_id)
 	return chat_id, err
 }
 
+const findSuccessorOwner = `-- name: FindSuccessorOwner :one
+SELECT cp.user_id
+FROM chat_participants cp
+WHERE cp.chat_id = $1
+ORDER BY (cp.role = 'admin') DESC, cp.joined_at, cp.user_id
+LIMIT 1
+`
+
+type FindSuccessorOwnerParams struct {
+	ChatID int64 `json:"chat_id"`
+}
+
+// FindSuccessorOwner
+//
+//	SELECT cp.user_id
+//	FROM chat_participants cp
+//	WHERE cp.chat_id = $1
+//	ORDER BY (cp.role = 'admin') DESC, cp.joined_at, cp.user_id
+//	LIMIT 1
+func (q *Queries) FindSuccessorOwner(ctx context.Context, arg FindSuccessorOwnerParams) (int64, error) {
+	row := q.db.QueryRow(ctx, findSuccessorOwner, arg.ChatID)
+	var user_id int64
+	err := row.Scan(&user_id)
+	return user_id, err
+}
+
 const getChatByID = `-- name: GetChatByID :one
-SELECT c.chat_id, c.last_message_id
+SELECT c.chat_id, c.kind, c.title, c.last_message_id
 FROM chats c
 WHERE c.chat_id = $1
 `
@@ -150,22 +229,51 @@ type GetChatByIDParams struct {
 }
 
 type GetChatByIDRow struct {
-	ChatID        int64  `json:"chat_id"`
-	LastMessageID *int64 `json:"last_message_id"`
+	ChatID        int64   `json:"chat_id"`
+	Kind          string  `json:"kind"`
+	Title         *string `json:"title"`
+	LastMessageID *int64  `json:"last_message_id"`
 }
 
 // GetChatByID
 //
-//	SELECT c.chat_id, c.last_message_id
+//	SELECT c.chat_id, c.kind, c.title, c.last_message_id
 //	FROM chats c
 //	WHERE c.chat_id = $1
 func (q *Queries) GetChatByID(ctx context.Context, arg GetChatByIDParams) (GetChatByIDRow, error) {
 	row := q.db.QueryRow(ctx, getChatByID, arg.ChatID)
 	var i GetChatByIDRow
-	err := row.Scan(&i.ChatID, &i.LastMessageID)
+	err := row.Scan(
+		&i.ChatID,
+		&i.Kind,
+		&i.Title,
+		&i.LastMessageID,
+	)
 	return i, err
 }
 
+const getChatKey = `-- name: GetChatKey :one
+SELECT wrapped_key
+FROM chat_keys
+WHERE chat_id = $1
+`
+
+type GetChatKeyParams struct {
+	ChatID int64 `json:"chat_id"`
+}
+
+// GetChatKey
+//
+//	SELECT wrapped_key
+//	FROM chat_keys
+//	WHERE chat_id = $1
+func (q *Queries) GetChatKey(ctx context.Context, arg GetChatKeyParams) ([]byte, error) {
+	row := q.db.QueryRow(ctx, getChatKey, arg.ChatID)
+	var wrapped_key []byte
+	err := row.Scan(&wrapped_key)
+	return wrapped_key, err
+}
+
 const getNumberUnreadMessages = `-- name: GetNumberUnreadMessages :one
 SELECT COUNT(*)::bigint
 FROM messages m
@@ -177,6 +285,14 @@ WHERE cp.chat_id = $1
         cp.last_read_message_id IS NULL
         OR m.message_id > cp.last_read_message_id
       )
+  AND m.thread_root_id IS NULL -- threads keep their own read markers
+  AND m.deleted_at IS NULL
+  AND NOT EXISTS (
+    SELECT 1
+    FROM hidden_messages h
+    WHERE h.message_id = m.message_id
+      AND h.user_id = cp.user_id
+  )
 `
 
 type GetNumberUnreadMessagesParams struct {
@@ -196,6 +312,14 @@ type GetNumberUnreadMessagesParams struct {
 //	        cp.last_read_message_id IS NULL
 //	        OR m.message_id > cp.last_read_message_id
 //	      )
+//	  AND m.thread_root_id IS NULL -- threads keep their own read markers
+//	  AND m.deleted_at IS NULL
+//	  AND NOT EXISTS (
+//	    SELECT 1
+//	    FROM hidden_messages h
+//	    WHERE h.message_id = m.message_id
+//	      AND h.user_id = cp.user_id
+//	  )
 func (q *Queries) GetNumberUnreadMessages(ctx context.Context, arg GetNumberUnreadMessagesParams) (int64, error) {
 	row := q.db.QueryRow(ctx, getNumberUnreadMessages, arg.ChatID, arg.UserID)
 	var column_1 int64
@@ -203,6 +327,53 @@ func (q *Queries) GetNumberUnreadMessages(ctx context.Context, arg GetNumberUnre
 	return column_1, err
 }
 
+const getParticipantRole = `-- name: GetParticipantRole :one
+SELECT cp.role
+FROM chat_participants cp
+WHERE cp.chat_id = $1
+  AND cp.user_id = $2
+`
+
+type GetParticipantRoleParams struct {
+	ChatID int64 `json:"chat_id"`
+	UserID int64 `json:"user_id"`
+}
+
+// GetParticipantRole
+//
+//	SELECT cp.role
+//	FROM chat_participants cp
+//	WHERE cp.chat_id = $1
+//	  AND cp.user_id = $2
+func (q *Queries) GetParticipantRole(ctx context.Context, arg GetParticipantRoleParams) (string, error) {
+	row := q.db.QueryRow(ctx, getParticipantRole, arg.ChatID, arg.UserID)
+	var role string
+	err := row.Scan(&role)
+	return role, err
+}
+
+const isChatEndToEnd = `-- name: IsChatEndToEnd :one
+SELECT end_to_end
+FROM chats
+WHERE chat_id = $1
+`
+
+type IsChatEndToEndParams struct {
+	ChatID int64 `json:"chat_id"`
+}
+
+// IsChatEndToEnd
+//
+//	SELECT end_to_end
+//	FROM chats
+//	WHERE chat_id = $1
+func (q *Queries) IsChatEndToEnd(ctx context.Context, arg IsChatEndToEndParams) (bool, error) {
+	row := q.db.QueryRow(ctx, isChatEndToEnd, arg.ChatID)
+	var end_to_end bool
+	err := row.Scan(&end_to_end)
+	return end_to_end, err
+}
+
 const isUserInChat = `-- name: IsUserInChat :one
 
 SELECT EXISTS (
@@ -233,9 +404,90 @@ func (q *Queries) IsUserInChat(ctx context.Context, arg IsUserInChatParams) (boo
 	return is_participant, err
 }
 
+const listChatKeys = `-- name: ListChatKeys :many
+SELECT chat_id, wrapped_key
+FROM chat_keys
+WHERE chat_id > $1
+ORDER BY chat_id
+LIMIT $2
+`
+
+type ListChatKeysParams struct {
+	AfterID   int64 `json:"after_id"`
+	BatchSize int32 `json:"batch_size"`
+}
+
+type ListChatKeysRow struct {
+	ChatID     int64  `json:"chat_id"`
+	WrappedKey []byte `json:"wrapped_key"`
+}
+
+// ListChatKeys
+//
+//	SELECT chat_id, wrapped_key
+//	FROM chat_keys
+//	WHERE chat_id > $1
+//	ORDER BY chat_id
+//	LIMIT $2
+func (q *Queries) ListChatKeys(ctx context.Context, arg ListChatKeysParams) ([]ListChatKeysRow, error) {
+	rows, err := q.db.Query(ctx, listChatKeys, arg.AfterID, arg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListChatKeysRow{}
+	for rows.Next() {
+		var i ListChatKeysRow
+		if err := rows.Scan(&i.ChatID, &i.WrappedKey); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChatParticipantIDs = `-- name: ListChatParticipantIDs :many
+SELECT cp.user_id
+FROM chat_participants cp
+WHERE cp.chat_id = $1
+`
+
+type ListChatParticipantIDsParams struct {
+	ChatID int64 `json:"chat_id"`
+}
+
+// ListChatParticipantIDs
+//
+//	SELECT cp.user_id
+//	FROM chat_participants cp
+//	WHERE cp.chat_id = $1
+func (q *Queries) ListChatParticipantIDs(ctx context.Context, arg ListChatParticipantIDsParams) ([]int64, error) {
+	rows, err := q.db.Query(ctx, listChatParticipantIDs, arg.ChatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []int64{}
+	for rows.Next() {
+		var user_id int64
+		if err := rows.Scan(&user_id); err != nil {
+			return nil, err
+		}
+		items = append(items, user_id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listChatParticipants = `-- name: ListChatParticipants :many
 SELECT
   cp.chat_id,
+  cp.role,
   u.pfp_url,
   u.user_id,
   u.first_name,
@@ -252,6 +504,7 @@ type ListChatParticipantsParams struct {
 
 type ListChatParticipantsRow struct {
 	ChatID    int64   `json:"chat_id"`
+	Role      string  `json:"role"`
 	PfpUrl    *string `json:"pfp_url"`
 	UserID    int64   `json:"user_id"`
 	FirstName string  `json:"first_name"`
@@ -262,6 +515,7 @@ type ListChatParticipantsRow struct {
 //
 //	SELECT
 //	  cp.chat_id,
+//	  cp.role,
 //	  u.pfp_url,
 //	  u.user_id,
 //	  u.first_name,
@@ -281,6 +535,7 @@ func (q *Queries) ListChatParticipants(ctx context.Context, arg ListChatParticip
 		var i ListChatParticipantsRow
 		if err := rows.Scan(
 			&i.ChatID,
+			&i.Role,
 			&i.PfpUrl,
 			&i.UserID,
 			&i.FirstName,
@@ -384,18 +639,39 @@ func (q *Queries) ListChatsWithParticipant(ctx context.Context, arg ListChatsWit
 const listChatsWithUser = `-- name: ListChatsWithUser :many
 SELECT
   c.chat_id,
+  c.kind,
+  c.title,
+  c.end_to_end,
 
   m.message_id,
   m.sender_id,
   m.created_at,
-  m.cypher_text
+  m.cypher_text,
+
+  k.wrapped_key
   
 FROM chats c
 JOIN chat_participants cp
   ON cp.chat_id = c.chat_id
  AND cp.user_id = $1
 LEFT JOIN messages m
-  ON m.message_id = c.last_message_id
+  ON m.message_id = (
+    SELECT v.message_id
+    FROM messages v
+    WHERE v.chat_id = c.chat_id
+      AND v.thread_root_id IS NULL
+      AND v.deleted_at IS NULL
+      AND NOT EXISTS (
+        SELECT 1
+        FROM hidden_messages h
+        WHERE h.message_id = v.message_id
+          AND h.user_id = $1
+      )
+    ORDER BY v.created_at DESC, v.message_id DESC
+    LIMIT 1
+  )
+LEFT JOIN chat_keys k
+  ON k.chat_id = c.chat_id
 ORDER BY
   m.created_at DESC NULLS LAST,
   c.last_message_id DESC,
@@ -408,28 +684,53 @@ type ListChatsWithUserParams struct {
 
 type ListChatsWithUserRow struct {
 	ChatID     int64              `json:"chat_id"`
+	Kind       string             `json:"kind"`
+	Title      *string            `json:"title"`
+	EndToEnd   bool               `json:"end_to_end"`
 	MessageID  *int64             `json:"message_id"`
 	SenderID   *int64             `json:"sender_id"`
 	CreatedAt  pgtype.Timestamptz `json:"created_at"`
 	CypherText []byte             `json:"cypher_text"`
+	WrappedKey []byte             `json:"wrapped_key"`
 }
 
-// ListChatsWithUser
+// the newest message this user can still see, normally c.last_message_id
 //
 //	SELECT
 //	  c.chat_id,
+//	  c.kind,
+//	  c.title,
+//	  c.end_to_end,
 //
 //	  m.message_id,
 //	  m.sender_id,
 //	  m.created_at,
-//	  m.cypher_text
+//	  m.cypher_text,
+//
+//	  k.wrapped_key
 //
 //	FROM chats c
 //	JOIN chat_participants cp
 //	  ON cp.chat_id = c.chat_id
 //	 AND cp.user_id = $1
 //	LEFT JOIN messages m
-//	  ON m.message_id = c.last_message_id
+//	  ON m.message_id = (
+//	    SELECT v.message_id
+//	    FROM messages v
+//	    WHERE v.chat_id = c.chat_id
+//	      AND v.thread_root_id IS NULL
+//	      AND v.deleted_at IS NULL
+//	      AND NOT EXISTS (
+//	        SELECT 1
+//	        FROM hidden_messages h
+//	        WHERE h.message_id = v.message_id
+//	          AND h.user_id = $1
+//	      )
+//	    ORDER BY v.created_at DESC, v.message_id DESC
+//	    LIMIT 1
+//	  )
+//	LEFT JOIN chat_keys k
+//	  ON k.chat_id = c.chat_id
 //	ORDER BY
 //	  m.created_at DESC NULLS LAST,
 //	  c.last_message_id DESC,
@@ -445,10 +746,14 @@ func (q *Queries) ListChatsWithUser(ctx context.Context, arg ListChatsWithUserPa
 		var i ListChatsWithUserRow
 		if err := rows.Scan(
 			&i.ChatID,
+			&i.Kind,
+			&i.Title,
+			&i.EndToEnd,
 			&i.MessageID,
 			&i.SenderID,
 			&i.CreatedAt,
 			&i.CypherText,
+			&i.WrappedKey,
 		); err != nil {
 			return nil, err
 		}
@@ -460,6 +765,42 @@ func (q *Queries) ListChatsWithUser(ctx context.Context, arg ListChatsWithUserPa
 	return items, nil
 }
 
+const refreshChatLastMessage = `-- name: RefreshChatLastMessage :exec
+UPDATE chats
+SET last_message_id = (
+  SELECT m.message_id
+  FROM messages m
+  WHERE m.chat_id = $1
+    AND m.thread_root_id IS NULL
+    AND m.deleted_at IS NULL
+  ORDER BY m.created_at DESC, m.message_id DESC
+  LIMIT 1
+)
+WHERE chat_id = $1
+`
+
+type RefreshChatLastMessageParams struct {
+	ChatID int64 `json:"chat_id"`
+}
+
+// RefreshChatLastMessage
+//
+//	UPDATE chats
+//	SET last_message_id = (
+//	  SELECT m.message_id
+//	  FROM messages m
+//	  WHERE m.chat_id = $1
+//	    AND m.thread_root_id IS NULL
+//	    AND m.deleted_at IS NULL
+//	  ORDER BY m.created_at DESC, m.message_id DESC
+//	  LIMIT 1
+//	)
+//	WHERE chat_id = $1
+func (q *Queries) RefreshChatLastMessage(ctx context.Context, arg RefreshChatLastMessageParams) error {
+	_, err := q.db.Exec(ctx, refreshChatLastMessage, arg.ChatID)
+	return err
+}
+
 const removeParticipant = `-- name: RemoveParticipant :execrows
 DELETE FROM chat_participants
 WHERE chat_id = $1 AND user_id = $2
@@ -482,6 +823,33 @@ func (q *Queries) RemoveParticipant(ctx context.Context, arg RemoveParticipantPa
 	return result.RowsAffected(), nil
 }
 
+const replaceChatKey = `-- name: ReplaceChatKey :execrows
+UPDATE chat_keys
+SET wrapped_key = $1
+WHERE chat_id = $2
+  AND wrapped_key = $3
+`
+
+type ReplaceChatKeyParams struct {
+	NewWrappedKey []byte `json:"new_wrapped_key"`
+	ChatID        int64  `json:"chat_id"`
+	OldWrappedKey []byte `json:"old_wrapped_key"`
+}
+
+// ReplaceChatKey
+//
+//	UPDATE chat_keys
+//	SET wrapped_key = $1
+//	WHERE chat_id = $2
+//	  AND wrapped_key = $3
+func (q *Queries) ReplaceChatKey(ctx context.Context, arg ReplaceChatKeyParams) (int64, error) {
+	result, err := q.db.Exec(ctx, replaceChatKey, arg.NewWrappedKey, arg.ChatID, arg.OldWrappedKey)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const setLastReadMessage = `-- name: SetLastReadMessage :execrows
 UPDATE chat_participants cp
 SET last_read_message_id = $1,
@@ -513,6 +881,33 @@ func (q *Queries) SetLastReadMessage(ctx context.Context, arg SetLastReadMessage
 	return result.RowsAffected(), nil
 }
 
+const setParticipantRole = `-- name: SetParticipantRole :execrows
+UPDATE chat_participants
+SET role = $1
+WHERE chat_id = $2
+  AND user_id = $3
+`
+
+type SetParticipantRoleParams struct {
+	Role   string `json:"role"`
+	ChatID int64  `json:"chat_id"`
+	UserID int64  `json:"user_id"`
+}
+
+// SetParticipantRole
+//
+//	UPDATE chat_participants
+//	SET role = $1
+//	WHERE chat_id = $2
+//	  AND user_id = $3
+func (q *Queries) SetParticipantRole(ctx context.Context, arg SetParticipantRoleParams) (int64, error) {
+	result, err := q.db.Exec(ctx, setParticipantRole, arg.Role, arg.ChatID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const setTypingStatus = `-- name: SetTypingStatus :execrows
 UPDATE chat_participants cp
 SET is_typing = $1
@@ -560,3 +955,29 @@ func (q *Queries) UpdateChatLastMessage(ctx context.Context, arg UpdateChatLastM
 	_, err := q.db.Exec(ctx, updateChatLastMessage, arg.LastMessageID, arg.ChatID)
 	return err
 }
+
+const updateChatTitle = `-- name: UpdateChatTitle :execrows
+UPDATE chats
+SET title = $1
+WHERE chat_id = $2
+  AND kind = 'group'
+`
+
+type UpdateChatTitleParams struct {
+	Title  *string `json:"title"`
+	ChatID int64   `json:"chat_id"`
+}
+
+// UpdateChatTitle
+//
+//	UPDATE chats
+//	SET title = $1
+//	WHERE chat_id = $2
+//	  AND kind = 'group'
+func (q *Queries) UpdateChatTitle(ctx context.Context, arg UpdateChatTitleParams) (int64, error) {
+	result, err := q.db.Exec(ctx, updateChatTitle, arg.Title, arg.ChatID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
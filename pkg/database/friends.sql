@@ -86,3 +86,36 @@ WHERE fr.sender_id = $1;
 SELECT sender_id
 FROM friend_requests
 WHERE request_id = $1;
+
+-- name: DeleteFriendship :exec
+DELETE FROM user_friendships
+WHERE (user_id, friend_id) IN ((@user_id, @friend_id), (@friend_id, @user_id));
+
+-- name: DeleteFriendRequestsBetween :exec
+DELETE FROM friend_requests
+WHERE (sender_id = @user_id AND receiver_id = @user_id_2)
+   OR (sender_id = @user_id_2 AND receiver_id = @user_id);
+
+-- name: BlockUser :exec
+INSERT INTO user_blocks (blocker_id, blocked_id)
+VALUES (@blocker_id, @blocked_id)
+ON CONFLICT DO NOTHING;
+
+-- name: UnblockUser :execrows
+DELETE FROM user_blocks
+WHERE blocker_id = @blocker_id
+  AND blocked_id = @blocked_id;
+
+-- name: IsBlockedBetween :one
+SELECT EXISTS (
+  SELECT 1
+  FROM user_blocks
+  WHERE (blocker_id, blocked_id) IN ((@user_id, @user_id_2), (@user_id_2, @user_id))
+) AS blocked;
+
+-- name: ListBlockedUsers :many
+SELECT u.user_id, u.display_name, u.pfp_url, b.created_at
+FROM user_blocks b
+JOIN users u ON u.user_id = b.blocked_id
+WHERE b.blocker_id = @blocker_id
+ORDER BY b.created_at DESC, u.user_id;
@@ -37,6 +37,27 @@ func (q *Queries) AreUsersFriends(ctx context.Context, arg AreUsersFriendsParams
 	return are_friends, err
 }
 
+const blockUser = `-- name: BlockUser :exec
+INSERT INTO user_blocks (blocker_id, blocked_id)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING
+`
+
+type BlockUserParams struct {
+	BlockerID int64 `json:"blocker_id"`
+	BlockedID int64 `json:"blocked_id"`
+}
+
+// BlockUser
+//
+//	INSERT INTO user_blocks (blocker_id, blocked_id)
+//	VALUES ($1, $2)
+//	ON CONFLICT DO NOTHING
+func (q *Queries) BlockUser(ctx context.Context, arg BlockUserParams) error {
+	_, err := q.db.Exec(ctx, blockUser, arg.BlockerID, arg.BlockedID)
+	return err
+}
+
 const createFriendRequest = `-- name: CreateFriendRequest :one
 INSERT INTO friend_requests (sender_id, receiver_id)
 VALUES ($1, $2)
@@ -107,6 +128,46 @@ func (q *Queries) DeleteFriendRequest(ctx context.Context, arg DeleteFriendReque
 	return result.RowsAffected(), nil
 }
 
+const deleteFriendRequestsBetween = `-- name: DeleteFriendRequestsBetween :exec
+DELETE FROM friend_requests
+WHERE (sender_id = $1 AND receiver_id = $2)
+   OR (sender_id = $2 AND receiver_id = $1)
+`
+
+type DeleteFriendRequestsBetweenParams struct {
+	UserID  int64 `json:"user_id"`
+	UserID2 int64 `json:"user_id_2"`
+}
+
+// DeleteFriendRequestsBetween
+//
+//	DELETE FROM friend_requests
+//	WHERE (sender_id = $1 AND receiver_id = $2)
+//	   OR (sender_id = $2 AND receiver_id = $1)
+func (q *Queries) DeleteFriendRequestsBetween(ctx context.Context, arg DeleteFriendRequestsBetweenParams) error {
+	_, err := q.db.Exec(ctx, deleteFriendRequestsBetween, arg.UserID, arg.UserID2)
+	return err
+}
+
+const deleteFriendship = `-- name: DeleteFriendship :exec
+DELETE FROM user_friendships
+WHERE (user_id, friend_id) IN (($1, $2), ($2, $1))
+`
+
+type DeleteFriendshipParams struct {
+	UserID   int64 `json:"user_id"`
+	FriendID int64 `json:"friend_id"`
+}
+
+// DeleteFriendship
+//
+//	DELETE FROM user_friendships
+//	WHERE (user_id, friend_id) IN (($1, $2), ($2, $1))
+func (q *Queries) DeleteFriendship(ctx context.Context, arg DeleteFriendshipParams) error {
+	_, err := q.db.Exec(ctx, deleteFriendship, arg.UserID, arg.FriendID)
+	return err
+}
+
 const doesFriendRequestExist = `-- name: DoesFriendRequestExist :one
 SELECT EXISTS (
   SELECT 1
@@ -198,6 +259,33 @@ func (q *Queries) GetUserByRequestID(ctx context.Context, arg GetUserByRequestID
 	return sender_id, err
 }
 
+const isBlockedBetween = `-- name: IsBlockedBetween :one
+SELECT EXISTS (
+  SELECT 1
+  FROM user_blocks
+  WHERE (blocker_id, blocked_id) IN (($1, $2), ($2, $1))
+) AS blocked
+`
+
+type IsBlockedBetweenParams struct {
+	UserID  int64 `json:"user_id"`
+	UserID2 int64 `json:"user_id_2"`
+}
+
+// IsBlockedBetween
+//
+//	SELECT EXISTS (
+//	  SELECT 1
+//	  FROM user_blocks
+//	  WHERE (blocker_id, blocked_id) IN (($1, $2), ($2, $1))
+//	) AS blocked
+func (q *Queries) IsBlockedBetween(ctx context.Context, arg IsBlockedBetweenParams) (bool, error) {
+	row := q.db.QueryRow(ctx, isBlockedBetween, arg.UserID, arg.UserID2)
+	var blocked bool
+	err := row.Scan(&blocked)
+	return blocked, err
+}
+
 const listAllFriends = `-- name: ListAllFriends :many
 SELECT u.user_id, u.pfp_url, u.display_name, u.first_name, u.last_name, f.friendship_ts
 FROM user_friendships f
@@ -253,6 +341,57 @@ func (q *Queries) ListAllFriends(ctx context.Context, arg ListAllFriendsParams)
 	return items, nil
 }
 
+const listBlockedUsers = `-- name: ListBlockedUsers :many
+SELECT u.user_id, u.display_name, u.pfp_url, b.created_at
+FROM user_blocks b
+JOIN users u ON u.user_id = b.blocked_id
+WHERE b.blocker_id = $1
+ORDER BY b.created_at DESC, u.user_id
+`
+
+type ListBlockedUsersParams struct {
+	BlockerID int64 `json:"blocker_id"`
+}
+
+type ListBlockedUsersRow struct {
+	UserID      int64     `json:"user_id"`
+	DisplayName string    `json:"display_name"`
+	PfpUrl      *string   `json:"pfp_url"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ListBlockedUsers
+//
+//	SELECT u.user_id, u.display_name, u.pfp_url, b.created_at
+//	FROM user_blocks b
+//	JOIN users u ON u.user_id = b.blocked_id
+//	WHERE b.blocker_id = $1
+//	ORDER BY b.created_at DESC, u.user_id
+func (q *Queries) ListBlockedUsers(ctx context.Context, arg ListBlockedUsersParams) ([]ListBlockedUsersRow, error) {
+	rows, err := q.db.Query(ctx, listBlockedUsers, arg.BlockerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListBlockedUsersRow{}
+	for rows.Next() {
+		var i ListBlockedUsersRow
+		if err := rows.Scan(
+			&i.UserID,
+			&i.DisplayName,
+			&i.PfpUrl,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listIncomingFriendRequests = `-- name: ListIncomingFriendRequests :many
 SELECT request_id, sender_id, receiver_id
 FROM friend_requests
@@ -460,3 +599,27 @@ func (q *Queries) ListSentFriendRequestsWithUser(ctx context.Context, arg ListSe
 	}
 	return items, nil
 }
+
+const unblockUser = `-- name: UnblockUser :execrows
+DELETE FROM user_blocks
+WHERE blocker_id = $1
+  AND blocked_id = $2
+`
+
+type UnblockUserParams struct {
+	BlockerID int64 `json:"blocker_id"`
+	BlockedID int64 `json:"blocked_id"`
+}
+
+// UnblockUser
+//
+//	DELETE FROM user_blocks
+//	WHERE blocker_id = $1
+//	  AND blocked_id = $2
+func (q *Queries) UnblockUser(ctx context.Context, arg UnblockUserParams) (int64, error) {
+	result, err := q.db.Exec(ctx, unblockUser, arg.BlockerID, arg.BlockedID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,34 @@
+-- name: UpsertIdentityKey :exec
+INSERT INTO user_keys (user_id, identity_key)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE
+SET identity_key = EXCLUDED.identity_key,
+    signed_prekey_id = NULL,
+    signed_prekey = NULL,
+    signed_prekey_signature = NULL,
+    updated_at = now();
+
+-- name: UpsertUserKeys :exec
+INSERT INTO user_keys (user_id, identity_key, signed_prekey_id, signed_prekey, signed_prekey_signature)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (user_id) DO UPDATE
+SET identity_key = EXCLUDED.identity_key,
+    signed_prekey_id = EXCLUDED.signed_prekey_id,
+    signed_prekey = EXCLUDED.signed_prekey,
+    signed_prekey_signature = EXCLUDED.signed_prekey_signature,
+    updated_at = now();
+
+-- name: GetUserKeys :one
+SELECT user_id, identity_key, signed_prekey_id, signed_prekey, signed_prekey_signature, updated_at
+FROM user_keys
+WHERE user_id = $1;
+
+-- name: ListUsersWithoutKeys :many
+SELECT u.id::bigint
+FROM unnest(@user_ids::bigint[]) AS u(id)
+WHERE NOT EXISTS (
+  SELECT 1
+  FROM user_keys k
+  WHERE k.user_id = u.id
+    AND k.signed_prekey IS NOT NULL
+);
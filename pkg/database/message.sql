@@ -1,11 +1,210 @@
+-- name: NextMessageID :one
+SELECT nextval(pg_get_serial_sequence('messages', 'message_id'))::bigint;
+
 -- name: CreateMessage :one
-INSERT INTO messages (chat_id, sender_id, cypher_text)
-VALUES ($1, $2, $3)
-RETURNING message_id;
+INSERT INTO messages (message_id, chat_id, sender_id, cypher_text, reply_to_id, thread_root_id)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING created_at;
+
+-- name: ListMessagesBefore :many
+SELECT m.message_id, m.sender_id, m.cypher_text, m.created_at, m.edited_at, m.deleted_at, m.reply_to_id
+FROM messages m
+WHERE m.chat_id = @chat_id
+  -- the chat history without a thread root, that thread's replies with one
+  AND (
+    (sqlc.narg(thread_root_id)::bigint IS NULL AND m.thread_root_id IS NULL)
+    OR m.thread_root_id = sqlc.narg(thread_root_id)::bigint
+  )
+  AND (
+    sqlc.narg(before_id)::bigint IS NULL
+    OR (m.created_at, m.message_id) < (
+      SELECT c.created_at, c.message_id
+      FROM messages c
+      WHERE c.chat_id = @chat_id
+        AND c.message_id = sqlc.narg(before_id)::bigint
+    )
+  )
+  AND NOT EXISTS (
+    SELECT 1
+    FROM hidden_messages h
+    WHERE h.message_id = m.message_id
+      AND h.user_id = @user_id
+  )
+ORDER BY m.created_at DESC, m.message_id DESC
+LIMIT @page_size;
+
+-- name: ListMessagesAfter :many
+SELECT m.message_id, m.sender_id, m.cypher_text, m.created_at, m.edited_at, m.deleted_at, m.reply_to_id
+FROM messages m
+WHERE m.chat_id = @chat_id
+  -- the chat history without a thread root, that thread's replies with one
+  AND (
+    (sqlc.narg(thread_root_id)::bigint IS NULL AND m.thread_root_id IS NULL)
+    OR m.thread_root_id = sqlc.narg(thread_root_id)::bigint
+  )
+  AND (
+    sqlc.narg(after_id)::bigint IS NULL
+    OR (m.created_at, m.message_id) > (
+      SELECT c.created_at, c.message_id
+      FROM messages c
+      WHERE c.chat_id = @chat_id
+        AND c.message_id = sqlc.narg(after_id)::bigint
+    )
+  )
+  AND NOT EXISTS (
+    SELECT 1
+    FROM hidden_messages h
+    WHERE h.message_id = m.message_id
+      AND h.user_id = @user_id
+  )
+ORDER BY m.created_at ASC, m.message_id ASC
+LIMIT @page_size;
+
+-- name: GetMessage :one
+SELECT m.sender_id, m.cypher_text, m.created_at, m.edited_at, m.deleted_at, m.reply_to_id, m.thread_root_id
+FROM messages m
+WHERE m.chat_id = @chat_id
+  AND m.message_id = @message_id;
 
--- name: GetMessages :many
-SELECT m.message_id, m.sender_id, m.cypher_text, m.created_at
+-- name: GetMessageForUpdate :one
+SELECT m.sender_id, m.cypher_text, m.created_at, m.edited_at, m.deleted_at, m.reply_to_id, m.thread_root_id
 FROM messages m
-WHERE m.chat_id = $1
-ORDER BY m.created_at DESC
-LIMIT $2;
+WHERE m.chat_id = @chat_id
+  AND m.message_id = @message_id
+FOR UPDATE;
+
+-- name: ListMessagesByID :many
+SELECT m.message_id, m.sender_id, m.cypher_text, m.created_at, m.edited_at, m.deleted_at
+FROM messages m
+WHERE m.chat_id = @chat_id
+  AND m.message_id = ANY(@message_ids::bigint[]);
+
+-- name: EditMessage :one
+UPDATE messages
+SET cypher_text = @cypher_text,
+    edited_at = now()
+WHERE message_id = @message_id
+RETURNING edited_at;
+
+-- name: DeleteMessageForEveryone :one
+UPDATE messages
+SET cypher_text = ''::bytea,
+    deleted_at = now()
+WHERE message_id = @message_id
+RETURNING deleted_at;
+
+-- name: HideMessage :exec
+INSERT INTO hidden_messages (user_id, message_id)
+VALUES (@user_id, @message_id)
+ON CONFLICT (user_id, message_id) DO NOTHING;
+
+-- name: CreateMessageRevision :exec
+INSERT INTO message_revisions (message_id, cypher_text, written_at)
+VALUES (@message_id, @cypher_text, @written_at);
+
+-- name: DeleteMessageRevisions :exec
+DELETE FROM message_revisions
+WHERE message_id = @message_id;
+
+-- name: ListMessageRevisions :many
+SELECT r.revision_id, r.cypher_text, r.written_at, r.replaced_at
+FROM message_revisions r
+WHERE r.message_id = @message_id
+ORDER BY r.revision_id;
+
+-- name: ListMessageCiphertexts :many
+SELECT m.message_id, m.chat_id, m.sender_id, m.cypher_text
+FROM messages m
+JOIN chats c ON c.chat_id = m.chat_id
+WHERE m.message_id > @after_id
+  AND NOT c.end_to_end
+  AND m.deleted_at IS NULL
+ORDER BY m.message_id
+LIMIT @batch_size;
+
+-- name: ReplaceMessageCiphertext :execrows
+UPDATE messages
+SET cypher_text = @new_cypher_text
+WHERE message_id = @message_id
+  AND cypher_text = @old_cypher_text;
+
+
+-- name: ListMessageRevisionCiphertexts :many
+SELECT r.revision_id, r.message_id, m.chat_id, m.sender_id, r.cypher_text
+FROM message_revisions r
+JOIN messages m ON m.message_id = r.message_id
+JOIN chats c ON c.chat_id = m.chat_id
+WHERE r.revision_id > @after_id
+  AND NOT c.end_to_end
+ORDER BY r.revision_id
+LIMIT @batch_size;
+
+-- name: ReplaceMessageRevisionCiphertext :execrows
+UPDATE message_revisions
+SET cypher_text = @new_cypher_text
+WHERE revision_id = @revision_id
+  AND cypher_text = @old_cypher_text;
+
+-- name: AddReaction :execrows
+INSERT INTO message_reactions (message_id, user_id, emoji)
+VALUES (@message_id, @user_id, @emoji)
+ON CONFLICT (message_id, user_id, emoji) DO NOTHING;
+
+-- name: RemoveReaction :execrows
+DELETE FROM message_reactions
+WHERE message_id = @message_id
+  AND user_id = @user_id
+  AND emoji = @emoji;
+
+-- name: CountOtherReactionEmojis :one
+SELECT COUNT(DISTINCT r.emoji)::bigint
+FROM message_reactions r
+WHERE r.message_id = @message_id
+  AND r.emoji <> @emoji;
+
+-- name: DeleteMessageReactions :exec
+DELETE FROM message_reactions
+WHERE message_id = @message_id;
+
+-- name: ListMessageReactions :many
+SELECT
+  r.message_id,
+  r.emoji,
+  COUNT(*)::bigint AS count,
+  BOOL_OR(r.user_id = @user_id) AS reacted_by_me
+FROM message_reactions r
+WHERE r.message_id = ANY(@message_ids::bigint[])
+GROUP BY r.message_id, r.emoji
+ORDER BY r.message_id, MIN(r.created_at), r.emoji;
+
+-- name: ListThreadSummaries :many
+SELECT
+  m.thread_root_id::bigint AS thread_root_id,
+  COUNT(*)::bigint AS reply_count,
+  COUNT(*) FILTER (
+    WHERE tr.last_read_message_id IS NULL
+       OR m.message_id > tr.last_read_message_id
+  )::bigint AS unread_count,
+  (ARRAY_AGG(m.message_id ORDER BY m.created_at DESC, m.message_id DESC))[1]::bigint AS latest_reply_id
+FROM messages m
+LEFT JOIN thread_reads tr
+  ON tr.thread_root_id = m.thread_root_id
+ AND tr.user_id = @user_id
+WHERE m.thread_root_id = ANY(@root_ids::bigint[])
+  AND m.deleted_at IS NULL
+  AND NOT EXISTS (
+    SELECT 1
+    FROM hidden_messages h
+    WHERE h.message_id = m.message_id
+      AND h.user_id = @user_id
+  )
+GROUP BY m.thread_root_id
+ORDER BY m.thread_root_id;
+
+-- name: SetThreadLastRead :execrows
+INSERT INTO thread_reads (thread_root_id, user_id, last_read_message_id)
+VALUES (@thread_root_id, @user_id, @last_read_message_id)
+ON CONFLICT (thread_root_id, user_id) DO UPDATE
+SET last_read_message_id = EXCLUDED.last_read_message_id,
+    last_read_at = now()
+WHERE thread_reads.last_read_message_id < EXCLUDED.last_read_message_id; -- only move forward
@@ -8,73 +8,882 @@ package database
 import (
 	"context"
 	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
-const createMessage = `-- name: CreateMessage :one
-INSERT INTO messages (chat_id, sender_id, cypher_text)
+const addReaction = `-- name: AddReaction :execrows
+INSERT INTO message_reactions (message_id, user_id, emoji)
 VALUES ($1, $2, $3)
-RETURNING message_id
+ON CONFLICT (message_id, user_id, emoji) DO NOTHING
+`
+
+type AddReactionParams struct {
+	MessageID int64  `json:"message_id"`
+	UserID    int64  `json:"user_id"`
+	Emoji     string `json:"emoji"`
+}
+
+// AddReaction
+//
+//	INSERT INTO message_reactions (message_id, user_id, emoji)
+//	VALUES ($1, $2, $3)
+//	ON CONFLICT (message_id, user_id, emoji) DO NOTHING
+func (q *Queries) AddReaction(ctx context.Context, arg AddReactionParams) (int64, error) {
+	result, err := q.db.Exec(ctx, addReaction, arg.MessageID, arg.UserID, arg.Emoji)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const countOtherReactionEmojis = `-- name: CountOtherReactionEmojis :one
+SELECT COUNT(DISTINCT r.emoji)::bigint
+FROM message_reactions r
+WHERE r.message_id = $1
+  AND r.emoji <> $2
+`
+
+type CountOtherReactionEmojisParams struct {
+	MessageID int64  `json:"message_id"`
+	Emoji     string `json:"emoji"`
+}
+
+// CountOtherReactionEmojis
+//
+//	SELECT COUNT(DISTINCT r.emoji)::bigint
+//	FROM message_reactions r
+//	WHERE r.message_id = $1
+//	  AND r.emoji <> $2
+func (q *Queries) CountOtherReactionEmojis(ctx context.Context, arg CountOtherReactionEmojisParams) (int64, error) {
+	row := q.db.QueryRow(ctx, countOtherReactionEmojis, arg.MessageID, arg.Emoji)
+	var column_1 int64
+	err := row.Scan(&column_1)
+	return column_1, err
+}
+
+const createMessage = `-- name: CreateMessage :one
+INSERT INTO messages (message_id, chat_id, sender_id, cypher_text, reply_to_id, thread_root_id)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING created_at
 `
 
 type CreateMessageParams struct {
-	ChatID     int64  `json:"chat_id"`
-	SenderID   int64  `json:"sender_id"`
-	CypherText []byte `json:"cypher_text"`
+	MessageID    int64  `json:"message_id"`
+	ChatID       int64  `json:"chat_id"`
+	SenderID     int64  `json:"sender_id"`
+	CypherText   []byte `json:"cypher_text"`
+	ReplyToID    *int64 `json:"reply_to_id"`
+	ThreadRootID *int64 `json:"thread_root_id"`
 }
 
 // CreateMessage
 //
-//	INSERT INTO messages (chat_id, sender_id, cypher_text)
+//	INSERT INTO messages (message_id, chat_id, sender_id, cypher_text, reply_to_id, thread_root_id)
+//	VALUES ($1, $2, $3, $4, $5, $6)
+//	RETURNING created_at
+func (q *Queries) CreateMessage(ctx context.Context, arg CreateMessageParams) (time.Time, error) {
+	row := q.db.QueryRow(ctx, createMessage,
+		arg.MessageID,
+		arg.ChatID,
+		arg.SenderID,
+		arg.CypherText,
+		arg.ReplyToID,
+		arg.ThreadRootID,
+	)
+	var created_at time.Time
+	err := row.Scan(&created_at)
+	return created_at, err
+}
+
+const createMessageRevision = `-- name: CreateMessageRevision :exec
+INSERT INTO message_revisions (message_id, cypher_text, written_at)
+VALUES ($1, $2, $3)
+`
+
+type CreateMessageRevisionParams struct {
+	MessageID  int64     `json:"message_id"`
+	CypherText []byte    `json:"cypher_text"`
+	WrittenAt  time.Time `json:"written_at"`
+}
+
+// CreateMessageRevision
+//
+//	INSERT INTO message_revisions (message_id, cypher_text, written_at)
 //	VALUES ($1, $2, $3)
-//	RETURNING message_id
-func (q *Queries) CreateMessage(ctx context.Context, arg CreateMessageParams) (int64, error) {
-	row := q.db.QueryRow(ctx, createMessage, arg.ChatID, arg.SenderID, arg.CypherText)
-	var message_id int64
-	err := row.Scan(&message_id)
-	return message_id, err
+func (q *Queries) CreateMessageRevision(ctx context.Context, arg CreateMessageRevisionParams) error {
+	_, err := q.db.Exec(ctx, createMessageRevision, arg.MessageID, arg.CypherText, arg.WrittenAt)
+	return err
+}
+
+const deleteMessageForEveryone = `-- name: DeleteMessageForEveryone :one
+UPDATE messages
+SET cypher_text = ''::bytea,
+    deleted_at = now()
+WHERE message_id = $1
+RETURNING deleted_at
+`
+
+type DeleteMessageForEveryoneParams struct {
+	MessageID int64 `json:"message_id"`
+}
+
+// DeleteMessageForEveryone
+//
+//	UPDATE messages
+//	SET cypher_text = ''::bytea,
+//	    deleted_at = now()
+//	WHERE message_id = $1
+//	RETURNING deleted_at
+func (q *Queries) DeleteMessageForEveryone(ctx context.Context, arg DeleteMessageForEveryoneParams) (pgtype.Timestamptz, error) {
+	row := q.db.QueryRow(ctx, deleteMessageForEveryone, arg.MessageID)
+	var deleted_at pgtype.Timestamptz
+	err := row.Scan(&deleted_at)
+	return deleted_at, err
+}
+
+const deleteMessageReactions = `-- name: DeleteMessageReactions :exec
+DELETE FROM message_reactions
+WHERE message_id = $1
+`
+
+type DeleteMessageReactionsParams struct {
+	MessageID int64 `json:"message_id"`
 }
 
-const getMessages = `-- name: GetMessages :many
-SELECT m.message_id, m.sender_id, m.cypher_text, m.created_at
+// DeleteMessageReactions
+//
+//	DELETE FROM message_reactions
+//	WHERE message_id = $1
+func (q *Queries) DeleteMessageReactions(ctx context.Context, arg DeleteMessageReactionsParams) error {
+	_, err := q.db.Exec(ctx, deleteMessageReactions, arg.MessageID)
+	return err
+}
+
+const deleteMessageRevisions = `-- name: DeleteMessageRevisions :exec
+DELETE FROM message_revisions
+WHERE message_id = $1
+`
+
+type DeleteMessageRevisionsParams struct {
+	MessageID int64 `json:"message_id"`
+}
+
+// DeleteMessageRevisions
+//
+//	DELETE FROM message_revisions
+//	WHERE message_id = $1
+func (q *Queries) DeleteMessageRevisions(ctx context.Context, arg DeleteMessageRevisionsParams) error {
+	_, err := q.db.Exec(ctx, deleteMessageRevisions, arg.MessageID)
+	return err
+}
+
+const editMessage = `-- name: EditMessage :one
+UPDATE messages
+SET cypher_text = $1,
+    edited_at = now()
+WHERE message_id = $2
+RETURNING edited_at
+`
+
+type EditMessageParams struct {
+	CypherText []byte `json:"cypher_text"`
+	MessageID  int64  `json:"message_id"`
+}
+
+// EditMessage
+//
+//	UPDATE messages
+//	SET cypher_text = $1,
+//	    edited_at = now()
+//	WHERE message_id = $2
+//	RETURNING edited_at
+func (q *Queries) EditMessage(ctx context.Context, arg EditMessageParams) (pgtype.Timestamptz, error) {
+	row := q.db.QueryRow(ctx, editMessage, arg.CypherText, arg.MessageID)
+	var edited_at pgtype.Timestamptz
+	err := row.Scan(&edited_at)
+	return edited_at, err
+}
+
+const getMessage = `-- name: GetMessage :one
+SELECT m.sender_id, m.cypher_text, m.created_at, m.edited_at, m.deleted_at, m.reply_to_id, m.thread_root_id
 FROM messages m
 WHERE m.chat_id = $1
-ORDER BY m.created_at DESC
+  AND m.message_id = $2
+`
+
+type GetMessageParams struct {
+	ChatID    int64 `json:"chat_id"`
+	MessageID int64 `json:"message_id"`
+}
+
+type GetMessageRow struct {
+	SenderID     int64              `json:"sender_id"`
+	CypherText   []byte             `json:"cypher_text"`
+	CreatedAt    time.Time          `json:"created_at"`
+	EditedAt     pgtype.Timestamptz `json:"edited_at"`
+	DeletedAt    pgtype.Timestamptz `json:"deleted_at"`
+	ReplyToID    *int64             `json:"reply_to_id"`
+	ThreadRootID *int64             `json:"thread_root_id"`
+}
+
+// GetMessage
+//
+//	SELECT m.sender_id, m.cypher_text, m.created_at, m.edited_at, m.deleted_at, m.reply_to_id, m.thread_root_id
+//	FROM messages m
+//	WHERE m.chat_id = $1
+//	  AND m.message_id = $2
+func (q *Queries) GetMessage(ctx context.Context, arg GetMessageParams) (GetMessageRow, error) {
+	row := q.db.QueryRow(ctx, getMessage, arg.ChatID, arg.MessageID)
+	var i GetMessageRow
+	err := row.Scan(
+		&i.SenderID,
+		&i.CypherText,
+		&i.CreatedAt,
+		&i.EditedAt,
+		&i.DeletedAt,
+		&i.ReplyToID,
+		&i.ThreadRootID,
+	)
+	return i, err
+}
+
+const getMessageForUpdate = `-- name: GetMessageForUpdate :one
+SELECT m.sender_id, m.cypher_text, m.created_at, m.edited_at, m.deleted_at, m.reply_to_id, m.thread_root_id
+FROM messages m
+WHERE m.chat_id = $1
+  AND m.message_id = $2
+FOR UPDATE
+`
+
+type GetMessageForUpdateParams struct {
+	ChatID    int64 `json:"chat_id"`
+	MessageID int64 `json:"message_id"`
+}
+
+type GetMessageForUpdateRow struct {
+	SenderID     int64              `json:"sender_id"`
+	CypherText   []byte             `json:"cypher_text"`
+	CreatedAt    time.Time          `json:"created_at"`
+	EditedAt     pgtype.Timestamptz `json:"edited_at"`
+	DeletedAt    pgtype.Timestamptz `json:"deleted_at"`
+	ReplyToID    *int64             `json:"reply_to_id"`
+	ThreadRootID *int64             `json:"thread_root_id"`
+}
+
+// GetMessageForUpdate
+//
+//	SELECT m.sender_id, m.cypher_text, m.created_at, m.edited_at, m.deleted_at, m.reply_to_id, m.thread_root_id
+//	FROM messages m
+//	WHERE m.chat_id = $1
+//	  AND m.message_id = $2
+//	FOR UPDATE
+func (q *Queries) GetMessageForUpdate(ctx context.Context, arg GetMessageForUpdateParams) (GetMessageForUpdateRow, error) {
+	row := q.db.QueryRow(ctx, getMessageForUpdate, arg.ChatID, arg.MessageID)
+	var i GetMessageForUpdateRow
+	err := row.Scan(
+		&i.SenderID,
+		&i.CypherText,
+		&i.CreatedAt,
+		&i.EditedAt,
+		&i.DeletedAt,
+		&i.ReplyToID,
+		&i.ThreadRootID,
+	)
+	return i, err
+}
+
+const hideMessage = `-- name: HideMessage :exec
+INSERT INTO hidden_messages (user_id, message_id)
+VALUES ($1, $2)
+ON CONFLICT (user_id, message_id) DO NOTHING
+`
+
+type HideMessageParams struct {
+	UserID    int64 `json:"user_id"`
+	MessageID int64 `json:"message_id"`
+}
+
+// HideMessage
+//
+//	INSERT INTO hidden_messages (user_id, message_id)
+//	VALUES ($1, $2)
+//	ON CONFLICT (user_id, message_id) DO NOTHING
+func (q *Queries) HideMessage(ctx context.Context, arg HideMessageParams) error {
+	_, err := q.db.Exec(ctx, hideMessage, arg.UserID, arg.MessageID)
+	return err
+}
+
+const listMessageCiphertexts = `-- name: ListMessageCiphertexts :many
+SELECT m.message_id, m.chat_id, m.sender_id, m.cypher_text
+FROM messages m
+JOIN chats c ON c.chat_id = m.chat_id
+WHERE m.message_id > $1
+  AND NOT c.end_to_end
+  AND m.deleted_at IS NULL
+ORDER BY m.message_id
 LIMIT $2
 `
 
-type GetMessagesParams struct {
-	ChatID int64 `json:"chat_id"`
-	Limit  int32 `json:"limit"`
+type ListMessageCiphertextsParams struct {
+	AfterID   int64 `json:"after_id"`
+	BatchSize int32 `json:"batch_size"`
 }
 
-type GetMessagesRow struct {
-	MessageID  int64     `json:"message_id"`
-	SenderID   int64     `json:"sender_id"`
+type ListMessageCiphertextsRow struct {
+	MessageID  int64  `json:"message_id"`
+	ChatID     int64  `json:"chat_id"`
+	SenderID   int64  `json:"sender_id"`
+	CypherText []byte `json:"cypher_text"`
+}
+
+// ListMessageCiphertexts
+//
+//	SELECT m.message_id, m.chat_id, m.sender_id, m.cypher_text
+//	FROM messages m
+//	JOIN chats c ON c.chat_id = m.chat_id
+//	WHERE m.message_id > $1
+//	  AND NOT c.end_to_end
+//	  AND m.deleted_at IS NULL
+//	ORDER BY m.message_id
+//	LIMIT $2
+func (q *Queries) ListMessageCiphertexts(ctx context.Context, arg ListMessageCiphertextsParams) ([]ListMessageCiphertextsRow, error) {
+	rows, err := q.db.Query(ctx, listMessageCiphertexts, arg.AfterID, arg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListMessageCiphertextsRow{}
+	for rows.Next() {
+		var i ListMessageCiphertextsRow
+		if err := rows.Scan(
+			&i.MessageID,
+			&i.ChatID,
+			&i.SenderID,
+			&i.CypherText,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listMessageReactions = `-- name: ListMessageReactions :many
+SELECT
+  r.message_id,
+  r.emoji,
+  COUNT(*)::bigint AS count,
+  BOOL_OR(r.user_id = $1) AS reacted_by_me
+FROM message_reactions r
+WHERE r.message_id = ANY($2::bigint[])
+GROUP BY r.message_id, r.emoji
+ORDER BY r.message_id, MIN(r.created_at), r.emoji
+`
+
+type ListMessageReactionsParams struct {
+	UserID     int64   `json:"user_id"`
+	MessageIds []int64 `json:"message_ids"`
+}
+
+type ListMessageReactionsRow struct {
+	MessageID   int64  `json:"message_id"`
+	Emoji       string `json:"emoji"`
+	Count       int64  `json:"count"`
+	ReactedByMe bool   `json:"reacted_by_me"`
+}
+
+// ListMessageReactions
+//
+//	SELECT
+//	  r.message_id,
+//	  r.emoji,
+//	  COUNT(*)::bigint AS count,
+//	  BOOL_OR(r.user_id = $1) AS reacted_by_me
+//	FROM message_reactions r
+//	WHERE r.message_id = ANY($2::bigint[])
+//	GROUP BY r.message_id, r.emoji
+//	ORDER BY r.message_id, MIN(r.created_at), r.emoji
+func (q *Queries) ListMessageReactions(ctx context.Context, arg ListMessageReactionsParams) ([]ListMessageReactionsRow, error) {
+	rows, err := q.db.Query(ctx, listMessageReactions, arg.UserID, arg.MessageIds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListMessageReactionsRow{}
+	for rows.Next() {
+		var i ListMessageReactionsRow
+		if err := rows.Scan(
+			&i.MessageID,
+			&i.Emoji,
+			&i.Count,
+			&i.ReactedByMe,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listMessageRevisionCiphertexts = `-- name: ListMessageRevisionCiphertexts :many
+SELECT r.revision_id, r.message_id, m.chat_id, m.sender_id, r.cypher_text
+FROM message_revisions r
+JOIN messages m ON m.message_id = r.message_id
+JOIN chats c ON c.chat_id = m.chat_id
+WHERE r.revision_id > $1
+  AND NOT c.end_to_end
+ORDER BY r.revision_id
+LIMIT $2
+`
+
+type ListMessageRevisionCiphertextsParams struct {
+	AfterID   int64 `json:"after_id"`
+	BatchSize int32 `json:"batch_size"`
+}
+
+type ListMessageRevisionCiphertextsRow struct {
+	RevisionID int64  `json:"revision_id"`
+	MessageID  int64  `json:"message_id"`
+	ChatID     int64  `json:"chat_id"`
+	SenderID   int64  `json:"sender_id"`
+	CypherText []byte `json:"cypher_text"`
+}
+
+// ListMessageRevisionCiphertexts
+//
+//	SELECT r.revision_id, r.message_id, m.chat_id, m.sender_id, r.cypher_text
+//	FROM message_revisions r
+//	JOIN messages m ON m.message_id = r.message_id
+//	JOIN chats c ON c.chat_id = m.chat_id
+//	WHERE r.revision_id > $1
+//	  AND NOT c.end_to_end
+//	ORDER BY r.revision_id
+//	LIMIT $2
+func (q *Queries) ListMessageRevisionCiphertexts(ctx context.Context, arg ListMessageRevisionCiphertextsParams) ([]ListMessageRevisionCiphertextsRow, error) {
+	rows, err := q.db.Query(ctx, listMessageRevisionCiphertexts, arg.AfterID, arg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListMessageRevisionCiphertextsRow{}
+	for rows.Next() {
+		var i ListMessageRevisionCiphertextsRow
+		if err := rows.Scan(
+			&i.RevisionID,
+			&i.MessageID,
+			&i.ChatID,
+			&i.SenderID,
+			&i.CypherText,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listMessageRevisions = `-- name: ListMessageRevisions :many
+SELECT r.revision_id, r.cypher_text, r.written_at, r.replaced_at
+FROM message_revisions r
+WHERE r.message_id = $1
+ORDER BY r.revision_id
+`
+
+type ListMessageRevisionsParams struct {
+	MessageID int64 `json:"message_id"`
+}
+
+type ListMessageRevisionsRow struct {
+	RevisionID int64     `json:"revision_id"`
 	CypherText []byte    `json:"cypher_text"`
-	CreatedAt  time.Time `json:"created_at"`
+	WrittenAt  time.Time `json:"written_at"`
+	ReplacedAt time.Time `json:"replaced_at"`
 }
 
-// GetMessages
+// ListMessageRevisions
 //
-//	SELECT m.message_id, m.sender_id, m.cypher_text, m.created_at
+//	SELECT r.revision_id, r.cypher_text, r.written_at, r.replaced_at
+//	FROM message_revisions r
+//	WHERE r.message_id = $1
+//	ORDER BY r.revision_id
+func (q *Queries) ListMessageRevisions(ctx context.Context, arg ListMessageRevisionsParams) ([]ListMessageRevisionsRow, error) {
+	rows, err := q.db.Query(ctx, listMessageRevisions, arg.MessageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListMessageRevisionsRow{}
+	for rows.Next() {
+		var i ListMessageRevisionsRow
+		if err := rows.Scan(
+			&i.RevisionID,
+			&i.CypherText,
+			&i.WrittenAt,
+			&i.ReplacedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listMessagesAfter = `-- name: ListMessagesAfter :many
+SELECT m.message_id, m.sender_id, m.cypher_text, m.created_at, m.edited_at, m.deleted_at, m.reply_to_id
+FROM messages m
+WHERE m.chat_id = $1
+  -- the chat history without a thread root, that thread's replies with one
+  AND (
+    ($2::bigint IS NULL AND m.thread_root_id IS NULL)
+    OR m.thread_root_id = $2::bigint
+  )
+  AND (
+    $3::bigint IS NULL
+    OR (m.created_at, m.message_id) > (
+      SELECT c.created_at, c.message_id
+      FROM messages c
+      WHERE c.chat_id = $1
+        AND c.message_id = $3::bigint
+    )
+  )
+  AND NOT EXISTS (
+    SELECT 1
+    FROM hidden_messages h
+    WHERE h.message_id = m.message_id
+      AND h.user_id = $4
+  )
+ORDER BY m.created_at ASC, m.message_id ASC
+LIMIT $5
+`
+
+type ListMessagesAfterParams struct {
+	ChatID       int64  `json:"chat_id"`
+	ThreadRootID *int64 `json:"thread_root_id"`
+	AfterID      *int64 `json:"after_id"`
+	UserID       int64  `json:"user_id"`
+	PageSize     int32  `json:"page_size"`
+}
+
+type ListMessagesAfterRow struct {
+	MessageID  int64              `json:"message_id"`
+	SenderID   int64              `json:"sender_id"`
+	CypherText []byte             `json:"cypher_text"`
+	CreatedAt  time.Time          `json:"created_at"`
+	EditedAt   pgtype.Timestamptz `json:"edited_at"`
+	DeletedAt  pgtype.Timestamptz `json:"deleted_at"`
+	ReplyToID  *int64             `json:"reply_to_id"`
+}
+
+// ListMessagesAfter
+//
+//	SELECT m.message_id, m.sender_id, m.cypher_text, m.created_at, m.edited_at, m.deleted_at, m.reply_to_id
 //	FROM messages m
 //	WHERE m.chat_id = $1
-//	ORDER BY m.created_at DESC
-//	LIMIT $2
-func (q *Queries) GetMessages(ctx context.Context, arg GetMessagesParams) ([]GetMessagesRow, error) {
-	rows, err := q.db.Query(ctx, getMessages, arg.ChatID, arg.Limit)
+//	  -- the chat history without a thread root, that thread's replies with one
+//	  AND (
+//	    ($2::bigint IS NULL AND m.thread_root_id IS NULL)
+//	    OR m.thread_root_id = $2::bigint
+//	  )
+//	  AND (
+//	    $3::bigint IS NULL
+//	    OR (m.created_at, m.message_id) > (
+//	      SELECT c.created_at, c.message_id
+//	      FROM messages c
+//	      WHERE c.chat_id = $1
+//	        AND c.message_id = $3::bigint
+//	    )
+//	  )
+//	  AND NOT EXISTS (
+//	    SELECT 1
+//	    FROM hidden_messages h
+//	    WHERE h.message_id = m.message_id
+//	      AND h.user_id = $4
+//	  )
+//	ORDER BY m.created_at ASC, m.message_id ASC
+//	LIMIT $5
+func (q *Queries) ListMessagesAfter(ctx context.Context, arg ListMessagesAfterParams) ([]ListMessagesAfterRow, error) {
+	rows, err := q.db.Query(ctx, listMessagesAfter,
+		arg.ChatID,
+		arg.ThreadRootID,
+		arg.AfterID,
+		arg.UserID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListMessagesAfterRow{}
+	for rows.Next() {
+		var i ListMessagesAfterRow
+		if err := rows.Scan(
+			&i.MessageID,
+			&i.SenderID,
+			&i.CypherText,
+			&i.CreatedAt,
+			&i.EditedAt,
+			&i.DeletedAt,
+			&i.ReplyToID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listMessagesBefore = `-- name: ListMessagesBefore :many
+SELECT m.message_id, m.sender_id, m.cypher_text, m.created_at, m.edited_at, m.deleted_at, m.reply_to_id
+FROM messages m
+WHERE m.chat_id = $1
+  -- the chat history without a thread root, that thread's replies with one
+  AND (
+    ($2::bigint IS NULL AND m.thread_root_id IS NULL)
+    OR m.thread_root_id = $2::bigint
+  )
+  AND (
+    $3::bigint IS NULL
+    OR (m.created_at, m.message_id) < (
+      SELECT c.created_at, c.message_id
+      FROM messages c
+      WHERE c.chat_id = $1
+        AND c.message_id = $3::bigint
+    )
+  )
+  AND NOT EXISTS (
+    SELECT 1
+    FROM hidden_messages h
+    WHERE h.message_id = m.message_id
+      AND h.user_id = $4
+  )
+ORDER BY m.created_at DESC, m.message_id DESC
+LIMIT $5
+`
+
+type ListMessagesBeforeParams struct {
+	ChatID       int64  `json:"chat_id"`
+	ThreadRootID *int64 `json:"thread_root_id"`
+	BeforeID     *int64 `json:"before_id"`
+	UserID       int64  `json:"user_id"`
+	PageSize     int32  `json:"page_size"`
+}
+
+type ListMessagesBeforeRow struct {
+	MessageID  int64              `json:"message_id"`
+	SenderID   int64              `json:"sender_id"`
+	CypherText []byte             `json:"cypher_text"`
+	CreatedAt  time.Time          `json:"created_at"`
+	EditedAt   pgtype.Timestamptz `json:"edited_at"`
+	DeletedAt  pgtype.Timestamptz `json:"deleted_at"`
+	ReplyToID  *int64             `json:"reply_to_id"`
+}
+
+// ListMessagesBefore
+//
+//	SELECT m.message_id, m.sender_id, m.cypher_text, m.created_at, m.edited_at, m.deleted_at, m.reply_to_id
+//	FROM messages m
+//	WHERE m.chat_id = $1
+//	  -- the chat history without a thread root, that thread's replies with one
+//	  AND (
+//	    ($2::bigint IS NULL AND m.thread_root_id IS NULL)
+//	    OR m.thread_root_id = $2::bigint
+//	  )
+//	  AND (
+//	    $3::bigint IS NULL
+//	    OR (m.created_at, m.message_id) < (
+//	      SELECT c.created_at, c.message_id
+//	      FROM messages c
+//	      WHERE c.chat_id = $1
+//	        AND c.message_id = $3::bigint
+//	    )
+//	  )
+//	  AND NOT EXISTS (
+//	    SELECT 1
+//	    FROM hidden_messages h
+//	    WHERE h.message_id = m.message_id
+//	      AND h.user_id = $4
+//	  )
+//	ORDER BY m.created_at DESC, m.message_id DESC
+//	LIMIT $5
+func (q *Queries) ListMessagesBefore(ctx context.Context, arg ListMessagesBeforeParams) ([]ListMessagesBeforeRow, error) {
+	rows, err := q.db.Query(ctx, listMessagesBefore,
+		arg.ChatID,
+		arg.ThreadRootID,
+		arg.BeforeID,
+		arg.UserID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListMessagesBeforeRow{}
+	for rows.Next() {
+		var i ListMessagesBeforeRow
+		if err := rows.Scan(
+			&i.MessageID,
+			&i.SenderID,
+			&i.CypherText,
+			&i.CreatedAt,
+			&i.EditedAt,
+			&i.DeletedAt,
+			&i.ReplyToID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listMessagesByID = `-- name: ListMessagesByID :many
+SELECT m.message_id, m.sender_id, m.cypher_text, m.created_at, m.edited_at, m.deleted_at
+FROM messages m
+WHERE m.chat_id = $1
+  AND m.message_id = ANY($2::bigint[])
+`
+
+type ListMessagesByIDParams struct {
+	ChatID     int64   `json:"chat_id"`
+	MessageIds []int64 `json:"message_ids"`
+}
+
+type ListMessagesByIDRow struct {
+	MessageID  int64              `json:"message_id"`
+	SenderID   int64              `json:"sender_id"`
+	CypherText []byte             `json:"cypher_text"`
+	CreatedAt  time.Time          `json:"created_at"`
+	EditedAt   pgtype.Timestamptz `json:"edited_at"`
+	DeletedAt  pgtype.Timestamptz `json:"deleted_at"`
+}
+
+// ListMessagesByID
+//
+//	SELECT m.message_id, m.sender_id, m.cypher_text, m.created_at, m.edited_at, m.deleted_at
+//	FROM messages m
+//	WHERE m.chat_id = $1
+//	  AND m.message_id = ANY($2::bigint[])
+func (q *Queries) ListMessagesByID(ctx context.Context, arg ListMessagesByIDParams) ([]ListMessagesByIDRow, error) {
+	rows, err := q.db.Query(ctx, listMessagesByID, arg.ChatID, arg.MessageIds)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	items := []GetMessagesRow{}
+	items := []ListMessagesByIDRow{}
 	for rows.Next() {
-		var i GetMessagesRow
+		var i ListMessagesByIDRow
 		if err := rows.Scan(
 			&i.MessageID,
 			&i.SenderID,
 			&i.CypherText,
 			&i.CreatedAt,
+			&i.EditedAt,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listThreadSummaries = `-- name: ListThreadSummaries :many
+SELECT
+  m.thread_root_id::bigint AS thread_root_id,
+  COUNT(*)::bigint AS reply_count,
+  COUNT(*) FILTER (
+    WHERE tr.last_read_message_id IS NULL
+       OR m.message_id > tr.last_read_message_id
+  )::bigint AS unread_count,
+  (ARRAY_AGG(m.message_id ORDER BY m.created_at DESC, m.message_id DESC))[1]::bigint AS latest_reply_id
+FROM messages m
+LEFT JOIN thread_reads tr
+  ON tr.thread_root_id = m.thread_root_id
+ AND tr.user_id = $1
+WHERE m.thread_root_id = ANY($2::bigint[])
+  AND m.deleted_at IS NULL
+  AND NOT EXISTS (
+    SELECT 1
+    FROM hidden_messages h
+    WHERE h.message_id = m.message_id
+      AND h.user_id = $1
+  )
+GROUP BY m.thread_root_id
+ORDER BY m.thread_root_id
+`
+
+type ListThreadSummariesParams struct {
+	UserID  int64   `json:"user_id"`
+	RootIds []int64 `json:"root_ids"`
+}
+
+type ListThreadSummariesRow struct {
+	ThreadRootID  int64 `json:"thread_root_id"`
+	ReplyCount    int64 `json:"reply_count"`
+	UnreadCount   int64 `json:"unread_count"`
+	LatestReplyID int64 `json:"latest_reply_id"`
+}
+
+// ListThreadSummaries
+//
+//	SELECT
+//	  m.thread_root_id::bigint AS thread_root_id,
+//	  COUNT(*)::bigint AS reply_count,
+//	  COUNT(*) FILTER (
+//	    WHERE tr.last_read_message_id IS NULL
+//	       OR m.message_id > tr.last_read_message_id
+//	  )::bigint AS unread_count,
+//	  (ARRAY_AGG(m.message_id ORDER BY m.created_at DESC, m.message_id DESC))[1]::bigint AS latest_reply_id
+//	FROM messages m
+//	LEFT JOIN thread_reads tr
+//	  ON tr.thread_root_id = m.thread_root_id
+//	 AND tr.user_id = $1
+//	WHERE m.thread_root_id = ANY($2::bigint[])
+//	  AND m.deleted_at IS NULL
+//	  AND NOT EXISTS (
+//	    SELECT 1
+//	    FROM hidden_messages h
+//	    WHERE h.message_id = m.message_id
+//	      AND h.user_id = $1
+//	  )
+//	GROUP BY m.thread_root_id
+//	ORDER BY m.thread_root_id
+func (q *Queries) ListThreadSummaries(ctx context.Context, arg ListThreadSummariesParams) ([]ListThreadSummariesRow, error) {
+	rows, err := q.db.Query(ctx, listThreadSummaries, arg.UserID, arg.RootIds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListThreadSummariesRow{}
+	for rows.Next() {
+		var i ListThreadSummariesRow
+		if err := rows.Scan(
+			&i.ThreadRootID,
+			&i.ReplyCount,
+			&i.UnreadCount,
+			&i.LatestReplyID,
 		); err != nil {
 			return nil, err
 		}
@@ -85,3 +894,129 @@ func (q *Queries) GetMessages(ctx context.Context, arg GetMessagesParams) ([]Get
 	}
 	return items, nil
 }
+
+const nextMessageID = `-- name: NextMessageID :one
+SELECT nextval(pg_get_serial_sequence('messages', 'message_id'))::bigint
+`
+
+// NextMessageID
+//
+//	SELECT nextval(pg_get_serial_sequence('messages', 'message_id'))::bigint
+func (q *Queries) NextMessageID(ctx context.Context) (int64, error) {
+	row := q.db.QueryRow(ctx, nextMessageID)
+	var column_1 int64
+	err := row.Scan(&column_1)
+	return column_1, err
+}
+
+const removeReaction = `-- name: RemoveReaction :execrows
+DELETE FROM message_reactions
+WHERE message_id = $1
+  AND user_id = $2
+  AND emoji = $3
+`
+
+type RemoveReactionParams struct {
+	MessageID int64  `json:"message_id"`
+	UserID    int64  `json:"user_id"`
+	Emoji     string `json:"emoji"`
+}
+
+// RemoveReaction
+//
+//	DELETE FROM message_reactions
+//	WHERE message_id = $1
+//	  AND user_id = $2
+//	  AND emoji = $3
+func (q *Queries) RemoveReaction(ctx context.Context, arg RemoveReactionParams) (int64, error) {
+	result, err := q.db.Exec(ctx, removeReaction, arg.MessageID, arg.UserID, arg.Emoji)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const replaceMessageCiphertext = `-- name: ReplaceMessageCiphertext :execrows
+UPDATE messages
+SET cypher_text = $1
+WHERE message_id = $2
+  AND cypher_text = $3
+`
+
+type ReplaceMessageCiphertextParams struct {
+	NewCypherText []byte `json:"new_cypher_text"`
+	MessageID     int64  `json:"message_id"`
+	OldCypherText []byte `json:"old_cypher_text"`
+}
+
+// ReplaceMessageCiphertext
+//
+//	UPDATE messages
+//	SET cypher_text = $1
+//	WHERE message_id = $2
+//	  AND cypher_text = $3
+func (q *Queries) ReplaceMessageCiphertext(ctx context.Context, arg ReplaceMessageCiphertextParams) (int64, error) {
+	result, err := q.db.Exec(ctx, replaceMessageCiphertext, arg.NewCypherText, arg.MessageID, arg.OldCypherText)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const replaceMessageRevisionCiphertext = `-- name: ReplaceMessageRevisionCiphertext :execrows
+UPDATE message_revisions
+SET cypher_text = $1
+WHERE revision_id = $2
+  AND cypher_text = $3
+`
+
+type ReplaceMessageRevisionCiphertextParams struct {
+	NewCypherText []byte `json:"new_cypher_text"`
+	RevisionID    int64  `json:"revision_id"`
+	OldCypherText []byte `json:"old_cypher_text"`
+}
+
+// ReplaceMessageRevisionCiphertext
+//
+//	UPDATE message_revisions
+//	SET cypher_text = $1
+//	WHERE revision_id = $2
+//	  AND cypher_text = $3
+func (q *Queries) ReplaceMessageRevisionCiphertext(ctx context.Context, arg ReplaceMessageRevisionCiphertextParams) (int64, error) {
+	result, err := q.db.Exec(ctx, replaceMessageRevisionCiphertext, arg.NewCypherText, arg.RevisionID, arg.OldCypherText)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const setThreadLastRead = `-- name: SetThreadLastRead :execrows
+INSERT INTO thread_reads (thread_root_id, user_id, last_read_message_id)
+VALUES ($1, $2, $3)
+ON CONFLICT (thread_root_id, user_id) DO UPDATE
+SET last_read_message_id = EXCLUDED.last_read_message_id,
+    last_read_at = now()
+WHERE thread_reads.last_read_message_id < EXCLUDED.last_read_message_id
+`
+
+type SetThreadLastReadParams struct {
+	ThreadRootID      int64 `json:"thread_root_id"`
+	UserID            int64 `json:"user_id"`
+	LastReadMessageID int64 `json:"last_read_message_id"`
+}
+
+// SetThreadLastRead
+//
+//	INSERT INTO thread_reads (thread_root_id, user_id, last_read_message_id)
+//	VALUES ($1, $2, $3)
+//	ON CONFLICT (thread_root_id, user_id) DO UPDATE
+//	SET last_read_message_id = EXCLUDED.last_read_message_id,
+//	    last_read_at = now()
+//	WHERE thread_reads.last_read_message_id < EXCLUDED.last_read_message_id
+func (q *Queries) SetThreadLastRead(ctx context.Context, arg SetThreadLastReadParams) (int64, error) {
+	result, err := q.db.Exec(ctx, setThreadLastRead, arg.ThreadRootID, arg.UserID, arg.LastReadMessageID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
@@ -10,10 +10,35 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+type Attachment struct {
+	AttachmentID int64     `json:"attachment_id"`
+	ChatID       int64     `json:"chat_id"`
+	UploaderID   int64     `json:"uploader_id"`
+	MessageID    *int64    `json:"message_id"`
+	BlobKey      string    `json:"blob_key"`
+	FileName     string    `json:"file_name"`
+	MimeType     string    `json:"mime_type"`
+	SizeBytes    int64     `json:"size_bytes"`
+	Sha256       []byte    `json:"sha256"`
+	Width        *int32    `json:"width"`
+	Height       *int32    `json:"height"`
+	CreatedAt    time.Time `json:"created_at"`
+	ThumbnailKey *string   `json:"thumbnail_key"`
+}
+
 type Chat struct {
 	ChatID        int64     `json:"chat_id"`
 	LastMessageID *int64    `json:"last_message_id"`
 	CreatedAt     time.Time `json:"created_at"`
+	Kind          string    `json:"kind"`
+	Title         *string   `json:"title"`
+	EndToEnd      bool      `json:"end_to_end"`
+}
+
+type ChatKey struct {
+	ChatID     int64     `json:"chat_id"`
+	WrappedKey []byte    `json:"wrapped_key"`
+	CreatedAt  time.Time `json:"created_at"`
 }
 
 type ChatParticipant struct {
@@ -23,6 +48,8 @@ type ChatParticipant struct {
 	TypingUpdatedAt   pgtype.Timestamptz `json:"typing_updated_at"`
 	LastReadMessageID *int64             `json:"last_read_message_id"`
 	LastReadAt        pgtype.Timestamptz `json:"last_read_at"`
+	Role              string             `json:"role"`
+	JoinedAt          time.Time          `json:"joined_at"`
 }
 
 type FriendRequest struct {
@@ -31,22 +58,106 @@ type FriendRequest struct {
 	ReceiverID int64 `json:"receiver_id"`
 }
 
+type HiddenMessage struct {
+	UserID    int64     `json:"user_id"`
+	MessageID int64     `json:"message_id"`
+	HiddenAt  time.Time `json:"hidden_at"`
+}
+
 type Message struct {
+	MessageID    int64              `json:"message_id"`
+	SenderID     int64              `json:"sender_id"`
+	ChatID       int64              `json:"chat_id"`
+	CreatedAt    time.Time          `json:"created_at"`
+	CypherText   []byte             `json:"cypher_text"`
+	EditedAt     pgtype.Timestamptz `json:"edited_at"`
+	DeletedAt    pgtype.Timestamptz `json:"deleted_at"`
+	ReplyToID    *int64             `json:"reply_to_id"`
+	ThreadRootID *int64             `json:"thread_root_id"`
+}
+
+type MessageReaction struct {
+	MessageID int64     `json:"message_id"`
+	UserID    int64     `json:"user_id"`
+	Emoji     string    `json:"emoji"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type MessageRevision struct {
+	RevisionID int64     `json:"revision_id"`
 	MessageID  int64     `json:"message_id"`
-	SenderID   int64     `json:"sender_id"`
-	ChatID     int64     `json:"chat_id"`
-	CreatedAt  time.Time `json:"created_at"`
 	CypherText []byte    `json:"cypher_text"`
+	WrittenAt  time.Time `json:"written_at"`
+	ReplacedAt time.Time `json:"replaced_at"`
+}
+
+type MessageSearchToken struct {
+	Token     []byte `json:"token"`
+	MessageID int64  `json:"message_id"`
+}
+
+type RecoveryCode struct {
+	CodeID    int64              `json:"code_id"`
+	UserID    int64              `json:"user_id"`
+	CodeHash  []byte             `json:"code_hash"`
+	CreatedAt time.Time          `json:"created_at"`
+	UsedAt    pgtype.Timestamptz `json:"used_at"`
+}
+
+type RefreshToken struct {
+	TokenID   int64              `json:"token_id"`
+	UserID    int64              `json:"user_id"`
+	FamilyID  pgtype.UUID        `json:"family_id"`
+	TokenHash []byte             `json:"token_hash"`
+	CreatedAt time.Time          `json:"created_at"`
+	ExpiresAt time.Time          `json:"expires_at"`
+	UsedAt    pgtype.Timestamptz `json:"used_at"`
+	RevokedAt pgtype.Timestamptz `json:"revoked_at"`
+}
+
+type Session struct {
+	SessionID  pgtype.UUID        `json:"session_id"`
+	UserID     int64              `json:"user_id"`
+	UserAgent  string             `json:"user_agent"`
+	IpAddress  string             `json:"ip_address"`
+	CreatedAt  time.Time          `json:"created_at"`
+	LastSeenAt time.Time          `json:"last_seen_at"`
+	RevokedAt  pgtype.Timestamptz `json:"revoked_at"`
+}
+
+type ThreadRead struct {
+	ThreadRootID      int64     `json:"thread_root_id"`
+	UserID            int64     `json:"user_id"`
+	LastReadMessageID int64     `json:"last_read_message_id"`
+	LastReadAt        time.Time `json:"last_read_at"`
+}
+
+type UsedMfaChallenge struct {
+	ChallengeID string    `json:"challenge_id"`
+	UserID      int64     `json:"user_id"`
+	ExpiresAt   time.Time `json:"expires_at"`
 }
 
 type User struct {
-	UserID       int64     `json:"user_id"`
-	DisplayName  string    `json:"display_name"`
-	PasswordHash string    `json:"password_hash"`
-	FirstName    string    `json:"first_name"`
-	PfpUrl       *string   `json:"pfp_url"`
-	LastName     string    `json:"last_name"`
-	CreatedAt    time.Time `json:"created_at"`
+	UserID            int64              `json:"user_id"`
+	DisplayName       string             `json:"display_name"`
+	PasswordHash      string             `json:"password_hash"`
+	FirstName         string             `json:"first_name"`
+	PfpUrl            *string            `json:"pfp_url"`
+	LastName          string             `json:"last_name"`
+	CreatedAt         time.Time          `json:"created_at"`
+	TotpSecret        []byte             `json:"totp_secret"`
+	TotpEnabled       bool               `json:"totp_enabled"`
+	TotpLastStep      *int64             `json:"totp_last_step"`
+	MfaFailedAttempts int32              `json:"mfa_failed_attempts"`
+	MfaLockedUntil    pgtype.Timestamptz `json:"mfa_locked_until"`
+	AvatarID          *string            `json:"avatar_id"`
+}
+
+type UserBlock struct {
+	BlockerID int64     `json:"blocker_id"`
+	BlockedID int64     `json:"blocked_id"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 type UserFriendship struct {
@@ -54,3 +165,12 @@ type UserFriendship struct {
 	FriendID     int64     `json:"friend_id"`
 	FriendshipTs time.Time `json:"friendship_ts"`
 }
+
+type UserKey struct {
+	UserID                int64     `json:"user_id"`
+	IdentityKey           []byte    `json:"identity_key"`
+	SignedPrekeyID        *int64    `json:"signed_prekey_id"`
+	SignedPrekey          []byte    `json:"signed_prekey"`
+	SignedPrekeySignature []byte    `json:"signed_prekey_signature"`
+	UpdatedAt             time.Time `json:"updated_at"`
+}
@@ -0,0 +1,43 @@
+-- name: CreateMessageSearchTokens :exec
+INSERT INTO message_search_tokens (message_id, token)
+SELECT @message_id, t
+FROM unnest(@tokens::bytea[]) AS t
+ON CONFLICT (token, message_id) DO NOTHING;
+
+-- name: DeleteMessageSearchTokens :exec
+DELETE FROM message_search_tokens
+WHERE message_id = @message_id;
+
+-- name: ListSearchableChats :many
+SELECT k.chat_id, k.wrapped_key
+FROM chat_participants cp
+JOIN chats c ON c.chat_id = cp.chat_id
+JOIN chat_keys k ON k.chat_id = cp.chat_id -- nothing is indexed before a chat has a key
+WHERE cp.user_id = @user_id
+  AND NOT c.end_to_end
+  AND (sqlc.narg(chat_id)::bigint IS NULL OR cp.chat_id = sqlc.narg(chat_id)::bigint)
+ORDER BY k.chat_id;
+
+-- name: SearchMessages :many
+-- Each term is digested once per chat, so a message matches when term_count
+-- of the given digests are among its tokens.
+SELECT m.message_id, m.chat_id, m.sender_id, m.cypher_text, m.created_at, m.edited_at, m.thread_root_id
+FROM messages m
+WHERE m.chat_id = ANY(@chat_ids::bigint[])
+  AND m.deleted_at IS NULL
+  AND (sqlc.narg(before_id)::bigint IS NULL OR m.message_id < sqlc.narg(before_id)::bigint)
+  AND m.message_id IN (
+    SELECT t.message_id
+    FROM message_search_tokens t
+    WHERE t.token = ANY(@tokens::bytea[])
+    GROUP BY t.message_id
+    HAVING COUNT(*) = @term_count::bigint
+  )
+  AND NOT EXISTS (
+    SELECT 1
+    FROM hidden_messages h
+    WHERE h.message_id = m.message_id
+      AND h.user_id = @user_id
+  )
+ORDER BY m.message_id DESC
+LIMIT @page_size;
@@ -0,0 +1,205 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: search.sql
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const createMessageSearchTokens = `-- name: CreateMessageSearchTokens :exec
+INSERT INTO message_search_tokens (message_id, token)
+SELECT $1, t
+FROM unnest($2::bytea[]) AS t
+ON CONFLICT (token, message_id) DO NOTHING
+`
+
+type CreateMessageSearchTokensParams struct {
+	MessageID int64    `json:"message_id"`
+	Tokens    [][]byte `json:"tokens"`
+}
+
+// CreateMessageSearchTokens
+//
+//	INSERT INTO message_search_tokens (message_id, token)
+//	SELECT $1, t
+//	FROM unnest($2::bytea[]) AS t
+//	ON CONFLICT (token, message_id) DO NOTHING
+func (q *Queries) CreateMessageSearchTokens(ctx context.Context, arg CreateMessageSearchTokensParams) error {
+	_, err := q.db.Exec(ctx, createMessageSearchTokens, arg.MessageID, arg.Tokens)
+	return err
+}
+
+const deleteMessageSearchTokens = `-- name: DeleteMessageSearchTokens :exec
+DELETE FROM message_search_tokens
+WHERE message_id = $1
+`
+
+type DeleteMessageSearchTokensParams struct {
+	MessageID int64 `json:"message_id"`
+}
+
+// DeleteMessageSearchTokens
+//
+//	DELETE FROM message_search_tokens
+//	WHERE message_id = $1
+func (q *Queries) DeleteMessageSearchTokens(ctx context.Context, arg DeleteMessageSearchTokensParams) error {
+	_, err := q.db.Exec(ctx, deleteMessageSearchTokens, arg.MessageID)
+	return err
+}
+
+const listSearchableChats = `-- name: ListSearchableChats :many
+SELECT k.chat_id, k.wrapped_key
+FROM chat_participants cp
+JOIN chats c ON c.chat_id = cp.chat_id
+JOIN chat_keys k ON k.chat_id = cp.chat_id -- nothing is indexed before a chat has a key
+WHERE cp.user_id = $1
+  AND NOT c.end_to_end
+  AND ($2::bigint IS NULL OR cp.chat_id = $2::bigint)
+ORDER BY k.chat_id
+`
+
+type ListSearchableChatsParams struct {
+	UserID int64  `json:"user_id"`
+	ChatID *int64 `json:"chat_id"`
+}
+
+type ListSearchableChatsRow struct {
+	ChatID     int64  `json:"chat_id"`
+	WrappedKey []byte `json:"wrapped_key"`
+}
+
+// ListSearchableChats
+//
+//	SELECT k.chat_id, k.wrapped_key
+//	FROM chat_participants cp
+//	JOIN chats c ON c.chat_id = cp.chat_id
+//	JOIN chat_keys k ON k.chat_id = cp.chat_id -- nothing is indexed before a chat has a key
+//	WHERE cp.user_id = $1
+//	  AND NOT c.end_to_end
+//	  AND ($2::bigint IS NULL OR cp.chat_id = $2::bigint)
+//	ORDER BY k.chat_id
+func (q *Queries) ListSearchableChats(ctx context.Context, arg ListSearchableChatsParams) ([]ListSearchableChatsRow, error) {
+	rows, err := q.db.Query(ctx, listSearchableChats, arg.UserID, arg.ChatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListSearchableChatsRow{}
+	for rows.Next() {
+		var i ListSearchableChatsRow
+		if err := rows.Scan(&i.ChatID, &i.WrappedKey); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const searchMessages = `-- name: SearchMessages :many
+SELECT m.message_id, m.chat_id, m.sender_id, m.cypher_text, m.created_at, m.edited_at, m.thread_root_id
+FROM messages m
+WHERE m.chat_id = ANY($1::bigint[])
+  AND m.deleted_at IS NULL
+  AND ($2::bigint IS NULL OR m.message_id < $2::bigint)
+  AND m.message_id IN (
+    SELECT t.message_id
+    FROM message_search_tokens t
+    WHERE t.token = ANY($3::bytea[])
+    GROUP BY t.message_id
+    HAVING COUNT(*) = $4::bigint
+  )
+  AND NOT EXISTS (
+    SELECT 1
+    FROM hidden_messages h
+    WHERE h.message_id = m.message_id
+      AND h.user_id = $5
+  )
+ORDER BY m.message_id DESC
+LIMIT $6
+`
+
+type SearchMessagesParams struct {
+	ChatIds   []int64  `json:"chat_ids"`
+	BeforeID  *int64   `json:"before_id"`
+	Tokens    [][]byte `json:"tokens"`
+	TermCount int64    `json:"term_count"`
+	UserID    int64    `json:"user_id"`
+	PageSize  int32    `json:"page_size"`
+}
+
+type SearchMessagesRow struct {
+	MessageID    int64              `json:"message_id"`
+	ChatID       int64              `json:"chat_id"`
+	SenderID     int64              `json:"sender_id"`
+	CypherText   []byte             `json:"cypher_text"`
+	CreatedAt    time.Time          `json:"created_at"`
+	EditedAt     pgtype.Timestamptz `json:"edited_at"`
+	ThreadRootID *int64             `json:"thread_root_id"`
+}
+
+// Each term is digested once per chat, so a message matches when term_count
+// of the given digests are among its tokens.
+//
+//	SELECT m.message_id, m.chat_id, m.sender_id, m.cypher_text, m.created_at, m.edited_at, m.thread_root_id
+//	FROM messages m
+//	WHERE m.chat_id = ANY($1::bigint[])
+//	  AND m.deleted_at IS NULL
+//	  AND ($2::bigint IS NULL OR m.message_id < $2::bigint)
+//	  AND m.message_id IN (
+//	    SELECT t.message_id
+//	    FROM message_search_tokens t
+//	    WHERE t.token = ANY($3::bytea[])
+//	    GROUP BY t.message_id
+//	    HAVING COUNT(*) = $4::bigint
+//	  )
+//	  AND NOT EXISTS (
+//	    SELECT 1
+//	    FROM hidden_messages h
+//	    WHERE h.message_id = m.message_id
+//	      AND h.user_id = $5
+//	  )
+//	ORDER BY m.message_id DESC
+//	LIMIT $6
+func (q *Queries) SearchMessages(ctx context.Context, arg SearchMessagesParams) ([]SearchMessagesRow, error) {
+	rows, err := q.db.Query(ctx, searchMessages,
+		arg.ChatIds,
+		arg.BeforeID,
+		arg.Tokens,
+		arg.TermCount,
+		arg.UserID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []SearchMessagesRow{}
+	for rows.Next() {
+		var i SearchMessagesRow
+		if err := rows.Scan(
+			&i.MessageID,
+			&i.ChatID,
+			&i.SenderID,
+			&i.CypherText,
+			&i.CreatedAt,
+			&i.EditedAt,
+			&i.ThreadRootID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,50 @@
+-- name: NextAttachmentID :one
+INSERT INTO sequences (name, value)
+VALUES ('attachments', 1)
+ON CONFLICT (name) DO UPDATE
+SET value = value + 1
+RETURNING value;
+
+-- name: CreateAttachment :exec
+INSERT INTO attachments (attachment_id, chat_id, uploader_id, blob_key, file_name, mime_type, size_bytes, sha256, width, height, thumbnail_key)
+VALUES (@attachment_id, @chat_id, @uploader_id, @blob_key, @file_name, @mime_type, @size_bytes, @sha256, @width, @height, @thumbnail_key);
+
+-- name: GetAttachmentUsage :one
+SELECT CAST(COALESCE(SUM(a.size_bytes), 0) AS INTEGER)
+FROM attachments a
+WHERE a.uploader_id = @uploader_id;
+
+-- name: GetAttachment :one
+SELECT a.*
+FROM attachments a
+WHERE a.chat_id = @chat_id
+  AND a.attachment_id = @attachment_id;
+
+-- name: AttachToMessage :many
+UPDATE attachments
+SET message_id = CAST(@message_id AS INTEGER)
+WHERE chat_id = @chat_id
+  AND uploader_id = @uploader_id
+  AND message_id IS NULL
+  AND attachment_id IN (sqlc.slice(attachment_ids))
+RETURNING *;
+
+-- name: ListMessageAttachments :many
+SELECT a.*
+FROM attachments a
+WHERE a.message_id IN (sqlc.slice(message_ids))
+ORDER BY a.attachment_id;
+
+-- Blobs to remove once the chat itself is deleted, which takes the rows
+-- with it.
+
+-- name: ListChatAttachmentBlobs :many
+SELECT a.blob_key, a.thumbnail_key
+FROM attachments a
+WHERE a.chat_id = @chat_id
+ORDER BY a.attachment_id;
+
+-- name: DeleteMessageAttachments :many
+DELETE FROM attachments
+WHERE message_id = CAST(@message_id AS INTEGER)
+RETURNING blob_key, thumbnail_key;
@@ -0,0 +1,364 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: attachment.sql
+
+package sqlite
+
+import (
+	"context"
+	"strings"
+)
+
+const attachToMessage = `-- name: AttachToMessage :many
+UPDATE attachments
+SET message_id = CAST(?1 AS INTEGER)
+WHERE chat_id = ?2
+  AND uploader_id = ?3
+  AND message_id IS NULL
+  AND attachment_id IN (/*SLICE:attachment_ids*/?)
+RETURNING attachment_id, chat_id, uploader_id, message_id, blob_key, file_name, mime_type, size_bytes, sha256, width, height, created_at, thumbnail_key
+`
+
+type AttachToMessageParams struct {
+	MessageID     int64   `json:"message_id"`
+	ChatID        int64   `json:"chat_id"`
+	UploaderID    int64   `json:"uploader_id"`
+	AttachmentIds []int64 `json:"attachment_ids"`
+}
+
+// AttachToMessage
+//
+//	UPDATE attachments
+//	SET message_id = CAST(?1 AS INTEGER)
+//	WHERE chat_id = ?2
+//	  AND uploader_id = ?3
+//	  AND message_id IS NULL
+//	  AND attachment_id IN (/*SLICE:attachment_ids*/?)
+//	RETURNING attachment_id, chat_id, uploader_id, message_id, blob_key, file_name, mime_type, size_bytes, sha256, width, height, created_at, thumbnail_key
+func (q *Queries) AttachToMessage(ctx context.Context, arg AttachToMessageParams) ([]Attachment, error) {
+	query := attachToMessage
+	var queryParams []interface{}
+	queryParams = append(queryParams, arg.MessageID)
+	queryParams = append(queryParams, arg.ChatID)
+	queryParams = append(queryParams, arg.UploaderID)
+	if len(arg.AttachmentIds) > 0 {
+		for _, v := range arg.AttachmentIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:attachment_ids*/?", strings.Repeat(",?", len(arg.AttachmentIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:attachment_ids*/?", "NULL", 1)
+	}
+	rows, err := q.db.QueryContext(ctx, query, queryParams...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Attachment{}
+	for rows.Next() {
+		var i Attachment
+		if err := rows.Scan(
+			&i.AttachmentID,
+			&i.ChatID,
+			&i.UploaderID,
+			&i.MessageID,
+			&i.BlobKey,
+			&i.FileName,
+			&i.MimeType,
+			&i.SizeBytes,
+			&i.Sha256,
+			&i.Width,
+			&i.Height,
+			&i.CreatedAt,
+			&i.ThumbnailKey,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const createAttachment = `-- name: CreateAttachment :exec
+INSERT INTO attachments (attachment_id, chat_id, uploader_id, blob_key, file_name, mime_type, size_bytes, sha256, width, height, thumbnail_key)
+VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?11)
+`
+
+type CreateAttachmentParams struct {
+	AttachmentID int64   `json:"attachment_id"`
+	ChatID       int64   `json:"chat_id"`
+	UploaderID   int64   `json:"uploader_id"`
+	BlobKey      string  `json:"blob_key"`
+	FileName     string  `json:"file_name"`
+	MimeType     string  `json:"mime_type"`
+	SizeBytes    int64   `json:"size_bytes"`
+	Sha256       []byte  `json:"sha256"`
+	Width        *int64  `json:"width"`
+	Height       *int64  `json:"height"`
+	ThumbnailKey *string `json:"thumbnail_key"`
+}
+
+// CreateAttachment
+//
+//	INSERT INTO attachments (attachment_id, chat_id, uploader_id, blob_key, file_name, mime_type, size_bytes, sha256, width, height, thumbnail_key)
+//	VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?11)
+func (q *Queries) CreateAttachment(ctx context.Context, arg CreateAttachmentParams) error {
+	_, err := q.db.ExecContext(ctx, createAttachment,
+		arg.AttachmentID,
+		arg.ChatID,
+		arg.UploaderID,
+		arg.BlobKey,
+		arg.FileName,
+		arg.MimeType,
+		arg.SizeBytes,
+		arg.Sha256,
+		arg.Width,
+		arg.Height,
+		arg.ThumbnailKey,
+	)
+	return err
+}
+
+const deleteMessageAttachments = `-- name: DeleteMessageAttachments :many
+DELETE FROM attachments
+WHERE message_id = CAST(?1 AS INTEGER)
+RETURNING blob_key, thumbnail_key
+`
+
+type DeleteMessageAttachmentsParams struct {
+	MessageID int64 `json:"message_id"`
+}
+
+type DeleteMessageAttachmentsRow struct {
+	BlobKey      string  `json:"blob_key"`
+	ThumbnailKey *string `json:"thumbnail_key"`
+}
+
+// DeleteMessageAttachments
+//
+//	DELETE FROM attachments
+//	WHERE message_id = CAST(?1 AS INTEGER)
+//	RETURNING blob_key, thumbnail_key
+func (q *Queries) DeleteMessageAttachments(ctx context.Context, arg DeleteMessageAttachmentsParams) ([]DeleteMessageAttachmentsRow, error) {
+	rows, err := q.db.QueryContext(ctx, deleteMessageAttachments, arg.MessageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []DeleteMessageAttachmentsRow{}
+	for rows.Next() {
+		var i DeleteMessageAttachmentsRow
+		if err := rows.Scan(&i.BlobKey, &i.ThumbnailKey); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getAttachment = `-- name: GetAttachment :one
+SELECT a.attachment_id, a.chat_id, a.uploader_id, a.message_id, a.blob_key, a.file_name, a.mime_type, a.size_bytes, a.sha256, a.width, a.height, a.created_at, a.thumbnail_key
+FROM attachments a
+WHERE a.chat_id = ?1
+  AND a.attachment_id = ?2
+`
+
+type GetAttachmentParams struct {
+	ChatID       int64 `json:"chat_id"`
+	AttachmentID int64 `json:"attachment_id"`
+}
+
+// GetAttachment
+//
+//	SELECT a.attachment_id, a.chat_id, a.uploader_id, a.message_id, a.blob_key, a.file_name, a.mime_type, a.size_bytes, a.sha256, a.width, a.height, a.created_at, a.thumbnail_key
+//	FROM attachments a
+//	WHERE a.chat_id = ?1
+//	  AND a.attachment_id = ?2
+func (q *Queries) GetAttachment(ctx context.Context, arg GetAttachmentParams) (Attachment, error) {
+	row := q.db.QueryRowContext(ctx, getAttachment, arg.ChatID, arg.AttachmentID)
+	var i Attachment
+	err := row.Scan(
+		&i.AttachmentID,
+		&i.ChatID,
+		&i.UploaderID,
+		&i.MessageID,
+		&i.BlobKey,
+		&i.FileName,
+		&i.MimeType,
+		&i.SizeBytes,
+		&i.Sha256,
+		&i.Width,
+		&i.Height,
+		&i.CreatedAt,
+		&i.ThumbnailKey,
+	)
+	return i, err
+}
+
+const getAttachmentUsage = `-- name: GetAttachmentUsage :one
+SELECT CAST(COALESCE(SUM(a.size_bytes), 0) AS INTEGER)
+FROM attachments a
+WHERE a.uploader_id = ?1
+`
+
+type GetAttachmentUsageParams struct {
+	UploaderID int64 `json:"uploader_id"`
+}
+
+// GetAttachmentUsage
+//
+//	SELECT CAST(COALESCE(SUM(a.size_bytes), 0) AS INTEGER)
+//	FROM attachments a
+//	WHERE a.uploader_id = ?1
+func (q *Queries) GetAttachmentUsage(ctx context.Context, arg GetAttachmentUsageParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, getAttachmentUsage, arg.UploaderID)
+	var column_1 int64
+	err := row.Scan(&column_1)
+	return column_1, err
+}
+
+const listChatAttachmentBlobs = `-- name: ListChatAttachmentBlobs :many
+
+SELECT a.blob_key, a.thumbnail_key
+FROM attachments a
+WHERE a.chat_id = ?1
+ORDER BY a.attachment_id
+`
+
+type ListChatAttachmentBlobsParams struct {
+	ChatID int64 `json:"chat_id"`
+}
+
+type ListChatAttachmentBlobsRow struct {
+	BlobKey      string  `json:"blob_key"`
+	ThumbnailKey *string `json:"thumbnail_key"`
+}
+
+// Blobs to remove once the chat itself is deleted, which takes the rows
+// with it.
+//
+//	SELECT a.blob_key, a.thumbnail_key
+//	FROM attachments a
+//	WHERE a.chat_id = ?1
+//	ORDER BY a.attachment_id
+func (q *Queries) ListChatAttachmentBlobs(ctx context.Context, arg ListChatAttachmentBlobsParams) ([]ListChatAttachmentBlobsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listChatAttachmentBlobs, arg.ChatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListChatAttachmentBlobsRow{}
+	for rows.Next() {
+		var i ListChatAttachmentBlobsRow
+		if err := rows.Scan(&i.BlobKey, &i.ThumbnailKey); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listMessageAttachments = `-- name: ListMessageAttachments :many
+SELECT a.attachment_id, a.chat_id, a.uploader_id, a.message_id, a.blob_key, a.file_name, a.mime_type, a.size_bytes, a.sha256, a.width, a.height, a.created_at, a.thumbnail_key
+FROM attachments a
+WHERE a.message_id IN (/*SLICE:message_ids*/?)
+ORDER BY a.attachment_id
+`
+
+type ListMessageAttachmentsParams struct {
+	MessageIds []*int64 `json:"message_ids"`
+}
+
+// ListMessageAttachments
+//
+//	SELECT a.attachment_id, a.chat_id, a.uploader_id, a.message_id, a.blob_key, a.file_name, a.mime_type, a.size_bytes, a.sha256, a.width, a.height, a.created_at, a.thumbnail_key
+//	FROM attachments a
+//	WHERE a.message_id IN (/*SLICE:message_ids*/?)
+//	ORDER BY a.attachment_id
+func (q *Queries) ListMessageAttachments(ctx context.Context, arg ListMessageAttachmentsParams) ([]Attachment, error) {
+	query := listMessageAttachments
+	var queryParams []interface{}
+	if len(arg.MessageIds) > 0 {
+		for _, v := range arg.MessageIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:message_ids*/?", strings.Repeat(",?", len(arg.MessageIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:message_ids*/?", "NULL", 1)
+	}
+	rows, err := q.db.QueryContext(ctx, query, queryParams...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Attachment{}
+	for rows.Next() {
+		var i Attachment
+		if err := rows.Scan(
+			&i.AttachmentID,
+			&i.ChatID,
+			&i.UploaderID,
+			&i.MessageID,
+			&i.BlobKey,
+			&i.FileName,
+			&i.MimeType,
+			&i.SizeBytes,
+			&i.Sha256,
+			&i.Width,
+			&i.Height,
+			&i.CreatedAt,
+			&i.ThumbnailKey,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const nextAttachmentID = `-- name: NextAttachmentID :one
+INSERT INTO sequences (name, value)
+VALUES ('attachments', 1)
+ON CONFLICT (name) DO UPDATE
+SET value = value + 1
+RETURNING value
+`
+
+// NextAttachmentID
+//
+//	INSERT INTO sequences (name, value)
+//	VALUES ('attachments', 1)
+//	ON CONFLICT (name) DO UPDATE
+//	SET value = value + 1
+//	RETURNING value
+func (q *Queries) NextAttachmentID(ctx context.Context) (int64, error) {
+	row := q.db.QueryRowContext(ctx, nextAttachmentID)
+	var value int64
+	err := row.Scan(&value)
+	return value, err
+}
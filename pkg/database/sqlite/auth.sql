@@ -0,0 +1,145 @@
+-- name: CreateRefreshToken :one
+INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at)
+VALUES (
+  @user_id,
+  COALESCE(sqlc.narg(family_id),
+    lower(hex(randomblob(4))) || '-' || lower(hex(randomblob(2))) || '-4' ||
+    substr(lower(hex(randomblob(2))), 2) || '-' ||
+    substr('89ab', 1 + (abs(random()) % 4), 1) || substr(lower(hex(randomblob(2))), 2) || '-' ||
+    lower(hex(randomblob(6)))),
+  @token_hash,
+  @expires_at
+)
+RETURNING token_id, family_id;
+
+-- name: GetRefreshTokenForUpdate :one
+SELECT token_id, user_id, family_id, expires_at, used_at, revoked_at
+FROM refresh_tokens
+WHERE token_hash = @token_hash;
+
+-- name: MarkRefreshTokenUsed :exec
+UPDATE refresh_tokens
+SET used_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+WHERE token_id = @token_id;
+
+-- name: RevokeRefreshTokenFamily :execrows
+UPDATE refresh_tokens
+SET revoked_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+WHERE family_id = @family_id
+  AND revoked_at IS NULL;
+
+-- name: CreateSession :one
+INSERT INTO sessions (user_id, user_agent, ip_address)
+VALUES (@user_id, @user_agent, @ip_address)
+RETURNING session_id;
+
+-- name: TouchSession :execrows
+UPDATE sessions
+SET last_seen_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'),
+    ip_address = @ip_address
+WHERE session_id = @session_id
+  AND revoked_at IS NULL;
+
+-- name: ListUserSessions :many
+SELECT session_id, user_agent, ip_address, created_at, last_seen_at
+FROM sessions
+WHERE user_id = @user_id
+  AND revoked_at IS NULL
+ORDER BY last_seen_at DESC;
+
+-- name: RevokeSession :execrows
+UPDATE sessions
+SET revoked_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+WHERE session_id = @session_id
+  AND user_id = @user_id
+  AND revoked_at IS NULL;
+
+-- name: RevokeAllUserSessions :many
+UPDATE sessions
+SET revoked_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+WHERE user_id = @user_id
+  AND revoked_at IS NULL
+  AND (sqlc.narg(keep_session_id) IS NULL OR session_id <> sqlc.narg(keep_session_id))
+RETURNING session_id;
+
+-- name: RevokeAllUserRefreshTokens :exec
+UPDATE refresh_tokens
+SET revoked_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+WHERE user_id = @user_id
+  AND revoked_at IS NULL
+  AND (sqlc.narg(keep_session_id) IS NULL OR family_id <> sqlc.narg(keep_session_id));
+
+-- name: GetUserTOTPForUpdate :one
+SELECT totp_secret, totp_enabled, totp_last_step, mfa_locked_until
+FROM users
+WHERE user_id = @user_id;
+
+-- name: SetPendingTOTPSecret :exec
+UPDATE users
+SET totp_secret = @totp_secret,
+    totp_enabled = FALSE,
+    totp_last_step = NULL
+WHERE user_id = @user_id;
+
+-- name: EnableTOTP :exec
+UPDATE users
+SET totp_enabled = TRUE,
+    totp_last_step = @totp_last_step
+WHERE user_id = @user_id;
+
+-- name: SetTOTPLastStep :exec
+UPDATE users
+SET totp_last_step = @totp_last_step
+WHERE user_id = @user_id;
+
+-- name: RecordMFAFailure :exec
+UPDATE users
+SET mfa_failed_attempts = mfa_failed_attempts + 1,
+    mfa_locked_until = CASE
+      WHEN mfa_failed_attempts + 1 >= CAST(@max_attempts AS INTEGER) THEN @locked_until
+      ELSE mfa_locked_until
+    END
+WHERE user_id = @user_id;
+
+-- name: ResetMFAFailures :exec
+UPDATE users
+SET mfa_failed_attempts = 0,
+    mfa_locked_until = NULL
+WHERE user_id = @user_id;
+
+-- name: UseMFAChallenge :execrows
+INSERT OR IGNORE INTO used_mfa_challenges (challenge_id, user_id, expires_at)
+VALUES (@challenge_id, @user_id, @expires_at);
+
+-- name: DeleteExpiredMFAChallenges :exec
+DELETE FROM used_mfa_challenges
+WHERE expires_at < strftime('%Y-%m-%d %H:%M:%f+00:00', 'now');
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM recovery_codes
+WHERE user_id = @user_id;
+
+-- name: CreateRecoveryCode :exec
+INSERT INTO recovery_codes (user_id, code_hash)
+VALUES (@user_id, @code_hash);
+
+-- name: UseRecoveryCode :execrows
+UPDATE recovery_codes
+SET used_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+WHERE user_id = @user_id
+  AND code_hash = @code_hash
+  AND used_at IS NULL;
+
+-- name: ListTOTPSecrets :many
+SELECT user_id, totp_secret
+FROM users
+WHERE totp_secret IS NOT NULL
+  AND user_id > @after_id
+ORDER BY user_id
+LIMIT @batch_size;
+
+-- name: ReplaceTOTPSecret :execrows
+UPDATE users
+SET totp_secret = @new_totp_secret
+WHERE user_id = @user_id
+  AND totp_secret = @old_totp_secret;
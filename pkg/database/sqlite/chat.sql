@@ -0,0 +1,246 @@
+-- name: GetChatByID :one
+SELECT c.chat_id, c.kind, c.title, c.last_message_id
+FROM chats c
+WHERE c.chat_id = @chat_id;
+
+-- name: CreateEmptyChat :one
+INSERT INTO chats (end_to_end)
+VALUES (@end_to_end)
+RETURNING chat_id, last_message_id;
+
+-- name: CreateGroupChat :one
+INSERT INTO chats (kind, title, end_to_end)
+VALUES ('group', @title, @end_to_end)
+RETURNING chat_id;
+
+-- name: IsChatEndToEnd :one
+SELECT end_to_end
+FROM chats
+WHERE chat_id = @chat_id;
+
+-- name: UpdateChatTitle :execrows
+UPDATE chats
+SET title = @title
+WHERE chat_id = @chat_id
+  AND kind = 'group';
+
+-- name: CountChatParticipants :one
+SELECT COUNT(*)
+FROM chat_participants cp
+WHERE cp.chat_id = @chat_id;
+
+-- name: AddParticipant :exec
+INSERT INTO chat_participants (chat_id, user_id, is_typing, role)
+VALUES (@chat_id, @user_id, FALSE, @role)
+ON CONFLICT (chat_id, user_id) DO NOTHING;
+
+-- name: GetParticipantRole :one
+SELECT cp.role
+FROM chat_participants cp
+WHERE cp.chat_id = @chat_id
+  AND cp.user_id = @user_id;
+
+-- name: SetParticipantRole :execrows
+UPDATE chat_participants
+SET role = @role
+WHERE chat_id = @chat_id
+  AND user_id = @user_id;
+
+-- name: FindSuccessorOwner :one
+SELECT cp.user_id
+FROM chat_participants cp
+WHERE cp.chat_id = @chat_id
+ORDER BY (cp.role = 'admin') DESC, cp.joined_at, cp.user_id
+LIMIT 1;
+
+-- name: FindDirectChatBetween :one
+SELECT c.chat_id
+FROM chats c
+JOIN chat_participants cp1 ON cp1.chat_id = c.chat_id AND cp1.user_id = @user_id
+JOIN chat_participants cp2 ON cp2.chat_id = c.chat_id AND cp2.user_id = @user_id_2
+WHERE c.kind = 'direct'
+  AND c.end_to_end = @end_to_end
+  AND NOT EXISTS (
+  SELECT 1
+  FROM chat_participants cp3
+  WHERE cp3.chat_id = c.chat_id
+    AND cp3.user_id NOT IN (@user_id, @user_id_2)
+)
+LIMIT 1;
+
+-- name: DeleteChat :execrows
+DELETE FROM chats
+WHERE chat_id = @chat_id;
+
+-- name: RemoveParticipant :execrows
+DELETE FROM chat_participants
+WHERE chat_id = @chat_id AND user_id = @user_id;
+
+-- other_participant_ids comes back as a JSON array rather than ARRAY_AGG.
+
+-- name: ListChatsWithParticipant :many
+SELECT
+  c.chat_id,
+  c.last_message_id,
+  m.message_id,
+  m.sender_id,
+  c.created_at,
+  -- all participants except the requesting user
+  (
+    SELECT json_group_array(o.user_id)
+    FROM (
+      SELECT cp2.user_id
+      FROM chat_participants cp2
+      WHERE cp2.chat_id = c.chat_id
+        AND cp2.user_id <> @user_id
+      ORDER BY cp2.user_id
+    ) o
+  ) AS other_participant_ids
+FROM chats c
+JOIN chat_participants cp
+  ON cp.chat_id = c.chat_id
+ AND cp.user_id = @user_id
+LEFT JOIN messages m
+  ON m.message_id = c.last_message_id
+ORDER BY m.created_at DESC NULLS LAST;
+
+-- name: ListChatsWithUser :many
+SELECT
+  c.chat_id,
+  c.kind,
+  c.title,
+  c.end_to_end,
+
+  m.message_id,
+  m.sender_id,
+  m.created_at,
+  m.cypher_text,
+
+  k.wrapped_key
+
+FROM chats c
+JOIN chat_participants cp
+  ON cp.chat_id = c.chat_id
+ AND cp.user_id = @user_id
+-- the newest message this user can still see, normally c.last_message_id
+LEFT JOIN messages m
+  ON m.message_id = (
+    SELECT v.message_id
+    FROM messages v
+    WHERE v.chat_id = c.chat_id
+      AND v.thread_root_id IS NULL
+      AND v.deleted_at IS NULL
+      AND v.message_id NOT IN (
+        SELECT h.message_id
+        FROM hidden_messages h
+        WHERE h.user_id = @user_id
+      )
+    ORDER BY v.created_at DESC, v.message_id DESC
+    LIMIT 1
+  )
+LEFT JOIN chat_keys k
+  ON k.chat_id = c.chat_id
+ORDER BY
+  m.created_at DESC NULLS LAST,
+  c.last_message_id DESC NULLS FIRST,
+  c.chat_id DESC;
+
+-- name: ListChatParticipants :many
+SELECT
+  cp.chat_id,
+  cp.role,
+  u.pfp_url,
+  u.user_id,
+  u.first_name,
+  u.last_name
+FROM chat_participants cp
+JOIN users u
+  ON u.user_id = cp.user_id
+WHERE cp.chat_id IN (sqlc.slice(chat_ids));
+
+-- name: UpdateChatLastMessage :exec
+UPDATE chats
+SET last_message_id = @last_message_id
+WHERE chat_id = @chat_id;
+
+-- name: RefreshChatLastMessage :exec
+UPDATE chats
+SET last_message_id = (
+  SELECT m.message_id
+  FROM messages m
+  WHERE m.chat_id = @chat_id
+    AND m.thread_root_id IS NULL
+    AND m.deleted_at IS NULL
+  ORDER BY m.created_at DESC, m.message_id DESC
+  LIMIT 1
+)
+WHERE chat_id = @chat_id;
+
+-- name: SetTypingStatus :execrows
+UPDATE chat_participants
+SET is_typing = @is_typing
+WHERE chat_id = @chat_id
+  AND user_id = @user_id;
+
+-- name: SetLastReadMessage :execrows
+UPDATE chat_participants
+SET last_read_message_id = @last_read_message_id,
+    last_read_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+WHERE chat_id = @chat_id
+  AND user_id = @user_id
+  AND (last_read_message_id IS NULL OR last_read_message_id < @last_read_message_id); -- only move forward
+
+-- name: IsUserInChat :one
+SELECT CAST(EXISTS (
+  SELECT 1
+  FROM chat_participants cp
+  WHERE cp.chat_id = @chat_id
+    AND cp.user_id = @user_id
+) AS BOOLEAN) AS is_participant;
+
+-- name: GetNumberUnreadMessages :one
+SELECT COUNT(*)
+FROM messages m
+JOIN chat_participants cp
+  ON cp.chat_id = m.chat_id
+WHERE cp.chat_id = @chat_id
+  AND cp.user_id = @user_id
+  AND (
+        cp.last_read_message_id IS NULL
+        OR m.message_id > cp.last_read_message_id
+      )
+  AND m.thread_root_id IS NULL
+  AND m.deleted_at IS NULL
+  AND m.message_id NOT IN (
+    SELECT h.message_id
+    FROM hidden_messages h
+    WHERE h.user_id = cp.user_id
+  );
+
+-- name: ListChatParticipantIDs :many
+SELECT cp.user_id
+FROM chat_participants cp
+WHERE cp.chat_id = @chat_id;
+
+-- name: GetChatKey :one
+SELECT wrapped_key
+FROM chat_keys
+WHERE chat_id = @chat_id;
+
+-- name: CreateChatKey :exec
+INSERT INTO chat_keys (chat_id, wrapped_key)
+VALUES (@chat_id, @wrapped_key)
+ON CONFLICT (chat_id) DO NOTHING;
+
+-- name: ListChatKeys :many
+SELECT chat_id, wrapped_key
+FROM chat_keys
+WHERE chat_id > @after_id
+ORDER BY chat_id
+LIMIT @batch_size;
+
+-- name: ReplaceChatKey :execrows
+UPDATE chat_keys
+SET wrapped_key = @new_wrapped_key
+WHERE chat_id = @chat_id
+  AND wrapped_key = @old_wrapped_key;
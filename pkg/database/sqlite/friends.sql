@@ -0,0 +1,124 @@
+-- name: CreateFriendRequest :one
+INSERT INTO friend_requests (sender_id, receiver_id)
+VALUES (@sender_id, @receiver_id)
+ON CONFLICT DO NOTHING
+RETURNING request_id, sender_id, receiver_id;
+
+-- name: GetFriendRequestByID :one
+SELECT request_id, sender_id, receiver_id
+FROM friend_requests
+WHERE request_id = @request_id;
+
+-- name: GetPendingRequestBetween :one
+SELECT request_id, sender_id, receiver_id
+FROM friend_requests
+LIMIT 1;
+
+-- name: ListIncomingFriendRequests :many
+SELECT request_id, sender_id, receiver_id
+FROM friend_requests
+WHERE receiver_id = @receiver_id
+ORDER BY request_id DESC
+LIMIT @limit OFFSET @offset;
+
+-- name: ListOutgoingFriendRequests :many
+SELECT request_id, sender_id, receiver_id
+FROM friend_requests
+WHERE sender_id = @sender_id
+ORDER BY request_id DESC
+LIMIT @limit OFFSET @offset;
+
+-- name: DeleteFriendRequest :execrows
+DELETE FROM friend_requests
+WHERE request_id = @request_id
+  AND sender_id = @sender_id;
+
+-- name: AreUsersFriends :one
+SELECT CAST(EXISTS (
+  SELECT 1
+  FROM user_friendships AS uf
+  WHERE (uf.user_id = @user_id AND uf.friend_id = @user_id_2)
+     OR (uf.user_id = @user_id_2 AND uf.friend_id = @user_id)
+) AS BOOLEAN) AS are_friends;
+
+-- name: DoesFriendRequestExist :one
+SELECT CAST(EXISTS (
+  SELECT 1
+  FROM friend_requests
+  WHERE (sender_id = @sender_id AND receiver_id = @receiver_id)
+     OR (sender_id = @receiver_id AND receiver_id = @sender_id)
+) AS BOOLEAN) AS friend_request_exists;
+
+-- name: CreateFriendship :exec
+INSERT INTO user_friendships (user_id, friend_id)
+VALUES (@user_id, @friend_id), (@friend_id, @user_id)
+ON CONFLICT DO NOTHING;
+
+-- name: ListAllFriends :many
+SELECT u.user_id, u.pfp_url, u.display_name, u.first_name, u.last_name, f.friendship_ts
+FROM user_friendships f
+JOIN users u ON u.user_id = f.friend_id
+WHERE f.user_id = @user_id
+ORDER BY f.friendship_ts DESC;
+
+-- name: ListReceivedFriendRequestsWithUser :many
+SELECT fr.request_id,
+       fr.sender_id,
+       fr.receiver_id,
+       u.user_id,
+       u.display_name,
+       u.pfp_url
+FROM friend_requests fr
+JOIN users u ON u.user_id = fr.sender_id
+WHERE fr.receiver_id = @receiver_id;
+
+-- name: ListSentFriendRequestsWithUser :many
+SELECT fr.request_id,
+       fr.sender_id,
+       fr.receiver_id,
+       u.user_id,
+       u.display_name,
+       u.pfp_url
+FROM friend_requests fr
+JOIN users u ON u.user_id = fr.receiver_id
+WHERE fr.sender_id = @sender_id;
+
+-- name: GetUserByRequestID :one
+SELECT sender_id
+FROM friend_requests
+WHERE request_id = @request_id;
+
+-- name: DeleteFriendship :exec
+DELETE FROM user_friendships
+WHERE (user_id = @user_id AND friend_id = @friend_id)
+   OR (user_id = @friend_id AND friend_id = @user_id);
+
+-- name: DeleteFriendRequestsBetween :exec
+DELETE FROM friend_requests
+WHERE (sender_id = @user_id AND receiver_id = @user_id_2)
+   OR (sender_id = @user_id_2 AND receiver_id = @user_id);
+
+-- name: BlockUser :exec
+INSERT INTO user_blocks (blocker_id, blocked_id)
+VALUES (@blocker_id, @blocked_id)
+ON CONFLICT DO NOTHING;
+
+-- name: UnblockUser :execrows
+DELETE FROM user_blocks
+WHERE blocker_id = @blocker_id
+  AND blocked_id = @blocked_id;
+
+-- name: IsBlockedBetween :one
+SELECT CAST(EXISTS (
+  SELECT 1
+  FROM user_blocks
+  WHERE (blocker_id = @user_id AND blocked_id = @user_id_2)
+     OR (blocker_id = @user_id_2 AND blocked_id = @user_id)
+) AS BOOLEAN) AS blocked;
+
+-- name: ListBlockedUsers :many
+SELECT u.user_id, u.display_name, u.pfp_url, b.created_at
+FROM user_blocks b
+JOIN users u ON u.user_id = b.blocked_id
+WHERE b.blocker_id = @blocker_id
+ORDER BY b.created_at DESC, u.user_id;
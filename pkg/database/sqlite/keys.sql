@@ -0,0 +1,33 @@
+-- name: UpsertIdentityKey :exec
+INSERT INTO user_keys (user_id, identity_key)
+VALUES (@user_id, @identity_key)
+ON CONFLICT (user_id) DO UPDATE
+SET identity_key = excluded.identity_key,
+    signed_prekey_id = NULL,
+    signed_prekey = NULL,
+    signed_prekey_signature = NULL,
+    updated_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now');
+
+-- name: UpsertUserKeys :exec
+INSERT INTO user_keys (user_id, identity_key, signed_prekey_id, signed_prekey, signed_prekey_signature)
+VALUES (@user_id, @identity_key, @signed_prekey_id, @signed_prekey, @signed_prekey_signature)
+ON CONFLICT (user_id) DO UPDATE
+SET identity_key = excluded.identity_key,
+    signed_prekey_id = excluded.signed_prekey_id,
+    signed_prekey = excluded.signed_prekey,
+    signed_prekey_signature = excluded.signed_prekey_signature,
+    updated_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now');
+
+-- name: GetUserKeys :one
+SELECT user_id, identity_key, signed_prekey_id, signed_prekey, signed_prekey_signature, updated_at
+FROM user_keys
+WHERE user_id = @user_id;
+
+-- There is no unnest, so this lists the ids that do have keys and the store
+-- works out the rest.
+
+-- name: ListUsersWithKeys :many
+SELECT k.user_id
+FROM user_keys k
+WHERE k.user_id IN (sqlc.slice(user_ids))
+  AND k.signed_prekey IS NOT NULL;
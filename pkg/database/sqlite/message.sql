@@ -0,0 +1,225 @@
+-- name: NextMessageID :one
+INSERT INTO sequences (name, value)
+VALUES ('messages', 1)
+ON CONFLICT (name) DO UPDATE
+SET value = value + 1
+RETURNING value;
+
+-- name: CreateMessage :one
+INSERT INTO messages (message_id, chat_id, sender_id, cypher_text, reply_to_id, thread_root_id)
+VALUES (@message_id, @chat_id, @sender_id, @cypher_text, @reply_to_id, @thread_root_id)
+RETURNING created_at;
+
+-- Row values are spelled out as (created_at, message_id) comparisons, and
+-- hidden messages are left out with NOT IN since sqlc does not bind
+-- parameters inside NOT EXISTS here.
+
+-- name: ListMessagesBefore :many
+SELECT m.message_id, m.sender_id, m.cypher_text, m.created_at, m.edited_at, m.deleted_at, m.reply_to_id
+FROM messages m
+WHERE m.chat_id = @chat_id
+  AND (
+    (CAST(sqlc.narg(thread_root_id) AS INTEGER) IS NULL AND m.thread_root_id IS NULL)
+    OR m.thread_root_id = CAST(sqlc.narg(thread_root_id) AS INTEGER)
+  )
+  AND (
+    CAST(sqlc.narg(before_id) AS INTEGER) IS NULL
+    OR EXISTS (
+      SELECT 1
+      FROM messages c
+      WHERE c.chat_id = @chat_id
+        AND c.message_id = CAST(sqlc.narg(before_id) AS INTEGER)
+        AND (m.created_at < c.created_at
+          OR (m.created_at = c.created_at AND m.message_id < c.message_id))
+    )
+  )
+  AND m.message_id NOT IN (
+    SELECT h.message_id
+    FROM hidden_messages h
+    WHERE h.user_id = @user_id
+  )
+ORDER BY m.created_at DESC, m.message_id DESC
+LIMIT @page_size;
+
+-- name: ListMessagesAfter :many
+SELECT m.message_id, m.sender_id, m.cypher_text, m.created_at, m.edited_at, m.deleted_at, m.reply_to_id
+FROM messages m
+WHERE m.chat_id = @chat_id
+  AND (
+    (CAST(sqlc.narg(thread_root_id) AS INTEGER) IS NULL AND m.thread_root_id IS NULL)
+    OR m.thread_root_id = CAST(sqlc.narg(thread_root_id) AS INTEGER)
+  )
+  AND (
+    CAST(sqlc.narg(after_id) AS INTEGER) IS NULL
+    OR EXISTS (
+      SELECT 1
+      FROM messages c
+      WHERE c.chat_id = @chat_id
+        AND c.message_id = CAST(sqlc.narg(after_id) AS INTEGER)
+        AND (m.created_at > c.created_at
+          OR (m.created_at = c.created_at AND m.message_id > c.message_id))
+    )
+  )
+  AND m.message_id NOT IN (
+    SELECT h.message_id
+    FROM hidden_messages h
+    WHERE h.user_id = @user_id
+  )
+ORDER BY m.created_at ASC, m.message_id ASC
+LIMIT @page_size;
+
+-- name: GetMessage :one
+SELECT m.sender_id, m.cypher_text, m.created_at, m.edited_at, m.deleted_at, m.reply_to_id, m.thread_root_id
+FROM messages m
+WHERE m.chat_id = @chat_id
+  AND m.message_id = @message_id;
+
+-- name: GetMessageForUpdate :one
+SELECT m.sender_id, m.cypher_text, m.created_at, m.edited_at, m.deleted_at, m.reply_to_id, m.thread_root_id
+FROM messages m
+WHERE m.chat_id = @chat_id
+  AND m.message_id = @message_id;
+
+-- name: ListMessagesByID :many
+SELECT m.message_id, m.sender_id, m.cypher_text, m.created_at, m.edited_at, m.deleted_at
+FROM messages m
+WHERE m.chat_id = @chat_id
+  AND m.message_id IN (sqlc.slice(message_ids));
+
+-- name: EditMessage :one
+UPDATE messages
+SET cypher_text = @cypher_text,
+    edited_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+WHERE message_id = @message_id
+RETURNING edited_at;
+
+-- name: DeleteMessageForEveryone :one
+UPDATE messages
+SET cypher_text = X'',
+    deleted_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+WHERE message_id = @message_id
+RETURNING deleted_at;
+
+-- name: HideMessage :exec
+INSERT INTO hidden_messages (user_id, message_id)
+VALUES (@user_id, @message_id)
+ON CONFLICT (user_id, message_id) DO NOTHING;
+
+-- name: CreateMessageRevision :exec
+INSERT INTO message_revisions (message_id, cypher_text, written_at)
+VALUES (@message_id, @cypher_text, @written_at);
+
+-- name: DeleteMessageRevisions :exec
+DELETE FROM message_revisions
+WHERE message_id = @message_id;
+
+-- name: ListMessageRevisions :many
+SELECT r.revision_id, r.cypher_text, r.written_at, r.replaced_at
+FROM message_revisions r
+WHERE r.message_id = @message_id
+ORDER BY r.revision_id;
+
+-- name: ListMessageCiphertexts :many
+SELECT m.message_id, m.chat_id, m.sender_id, m.cypher_text
+FROM messages m
+JOIN chats c ON c.chat_id = m.chat_id
+WHERE m.message_id > @after_id
+  AND NOT c.end_to_end
+  AND m.deleted_at IS NULL
+ORDER BY m.message_id
+LIMIT @batch_size;
+
+-- name: ReplaceMessageCiphertext :execrows
+UPDATE messages
+SET cypher_text = @new_cypher_text
+WHERE message_id = @message_id
+  AND cypher_text = @old_cypher_text;
+
+
+-- name: ListMessageRevisionCiphertexts :many
+SELECT r.revision_id, r.message_id, m.chat_id, m.sender_id, r.cypher_text
+FROM message_revisions r
+JOIN messages m ON m.message_id = r.message_id
+JOIN chats c ON c.chat_id = m.chat_id
+WHERE r.revision_id > @after_id
+  AND NOT c.end_to_end
+ORDER BY r.revision_id
+LIMIT @batch_size;
+
+-- name: ReplaceMessageRevisionCiphertext :execrows
+UPDATE message_revisions
+SET cypher_text = @new_cypher_text
+WHERE revision_id = @revision_id
+  AND cypher_text = @old_cypher_text;
+
+-- name: AddReaction :execrows
+INSERT INTO message_reactions (message_id, user_id, emoji)
+VALUES (@message_id, @user_id, @emoji)
+ON CONFLICT (message_id, user_id, emoji) DO NOTHING;
+
+-- name: RemoveReaction :execrows
+DELETE FROM message_reactions
+WHERE message_id = @message_id
+  AND user_id = @user_id
+  AND emoji = @emoji;
+
+-- name: CountOtherReactionEmojis :one
+SELECT COUNT(DISTINCT r.emoji)
+FROM message_reactions r
+WHERE r.message_id = @message_id
+  AND r.emoji <> @emoji;
+
+-- name: DeleteMessageReactions :exec
+DELETE FROM message_reactions
+WHERE message_id = @message_id;
+
+-- name: ListMessageReactions :many
+SELECT
+  r.message_id,
+  r.emoji,
+  COUNT(*) AS count,
+  CAST(MAX(r.user_id = @user_id) AS BOOLEAN) AS reacted_by_me
+FROM message_reactions r
+WHERE r.message_id IN (sqlc.slice(message_ids))
+GROUP BY r.message_id, r.emoji
+ORDER BY r.message_id, MIN(r.created_at), r.emoji;
+
+-- name: ListThreadSummaries :many
+SELECT
+  CAST(m.thread_root_id AS INTEGER) AS thread_root_id,
+  COUNT(*) AS reply_count,
+  CAST(SUM(tr.last_read_message_id IS NULL OR m.message_id > tr.last_read_message_id) AS INTEGER) AS unread_count,
+  CAST((
+    SELECT l.message_id
+    FROM messages l
+    WHERE l.thread_root_id = m.thread_root_id
+      AND l.deleted_at IS NULL
+      AND l.message_id NOT IN (
+        SELECT h.message_id
+        FROM hidden_messages h
+        WHERE h.user_id = @user_id
+      )
+    ORDER BY l.created_at DESC, l.message_id DESC
+    LIMIT 1
+  ) AS INTEGER) AS latest_reply_id
+FROM messages m
+LEFT JOIN thread_reads tr
+  ON tr.thread_root_id = m.thread_root_id
+ AND tr.user_id = @user_id
+WHERE m.thread_root_id IN (sqlc.slice(root_ids))
+  AND m.deleted_at IS NULL
+  AND m.message_id NOT IN (
+    SELECT h.message_id
+    FROM hidden_messages h
+    WHERE h.user_id = @user_id
+  )
+GROUP BY m.thread_root_id
+ORDER BY m.thread_root_id;
+
+-- name: SetThreadLastRead :execrows
+INSERT INTO thread_reads (thread_root_id, user_id, last_read_message_id)
+VALUES (@thread_root_id, @user_id, @last_read_message_id)
+ON CONFLICT (thread_root_id, user_id) DO UPDATE
+SET last_read_message_id = excluded.last_read_message_id,
+    last_read_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+WHERE thread_reads.last_read_message_id < excluded.last_read_message_id;
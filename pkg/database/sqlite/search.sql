@@ -0,0 +1,47 @@
+-- name: CreateMessageSearchToken :exec
+INSERT INTO message_search_tokens (message_id, token)
+VALUES (@message_id, @token)
+ON CONFLICT (token, message_id) DO NOTHING;
+
+-- name: DeleteMessageSearchTokens :exec
+DELETE FROM message_search_tokens
+WHERE message_id = @message_id;
+
+-- name: ListSearchableChats :many
+SELECT k.chat_id, k.wrapped_key
+FROM chat_participants cp
+JOIN chats c ON c.chat_id = cp.chat_id
+JOIN chat_keys k ON k.chat_id = cp.chat_id
+WHERE cp.user_id = @user_id
+  AND NOT c.end_to_end
+  AND (CAST(sqlc.narg(chat_id) AS INTEGER) IS NULL OR cp.chat_id = CAST(sqlc.narg(chat_id) AS INTEGER))
+ORDER BY k.chat_id;
+
+-- sqlc numbers the elements of a slice after every parameter ahead of it,
+-- so nothing may follow one, LIMIT included. The search is split in two:
+-- the matching ids, newest first, then the rows of one page of them.
+
+-- name: SearchMessageIDs :many
+SELECT m.message_id
+FROM messages m
+WHERE m.deleted_at IS NULL
+  AND (CAST(sqlc.narg(before_id) AS INTEGER) IS NULL OR m.message_id < CAST(sqlc.narg(before_id) AS INTEGER))
+  AND m.message_id NOT IN (
+    SELECT h.message_id
+    FROM hidden_messages h
+    WHERE h.user_id = @user_id
+  )
+  AND CAST(@term_count AS INTEGER) = (
+    SELECT COUNT(*)
+    FROM message_search_tokens t
+    WHERE t.message_id = m.message_id
+      AND t.token IN (sqlc.slice(tokens))
+  )
+  AND m.chat_id IN (sqlc.slice(chat_ids))
+ORDER BY m.message_id DESC;
+
+-- name: ListSearchResults :many
+SELECT m.message_id, m.chat_id, m.sender_id, m.cypher_text, m.created_at, m.edited_at, m.thread_root_id
+FROM messages m
+WHERE m.message_id IN (sqlc.slice(message_ids))
+ORDER BY m.message_id DESC;
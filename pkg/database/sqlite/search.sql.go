@@ -0,0 +1,267 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: search.sql
+
+package sqlite
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+const createMessageSearchToken = `-- name: CreateMessageSearchToken :exec
+INSERT INTO message_search_tokens (message_id, token)
+VALUES (?1, ?2)
+ON CONFLICT (token, message_id) DO NOTHING
+`
+
+type CreateMessageSearchTokenParams struct {
+	MessageID int64  `json:"message_id"`
+	Token     []byte `json:"token"`
+}
+
+// CreateMessageSearchToken
+//
+//	INSERT INTO message_search_tokens (message_id, token)
+//	VALUES (?1, ?2)
+//	ON CONFLICT (token, message_id) DO NOTHING
+func (q *Queries) CreateMessageSearchToken(ctx context.Context, arg CreateMessageSearchTokenParams) error {
+	_, err := q.db.ExecContext(ctx, createMessageSearchToken, arg.MessageID, arg.Token)
+	return err
+}
+
+const deleteMessageSearchTokens = `-- name: DeleteMessageSearchTokens :exec
+DELETE FROM message_search_tokens
+WHERE message_id = ?1
+`
+
+type DeleteMessageSearchTokensParams struct {
+	MessageID int64 `json:"message_id"`
+}
+
+// DeleteMessageSearchTokens
+//
+//	DELETE FROM message_search_tokens
+//	WHERE message_id = ?1
+func (q *Queries) DeleteMessageSearchTokens(ctx context.Context, arg DeleteMessageSearchTokensParams) error {
+	_, err := q.db.ExecContext(ctx, deleteMessageSearchTokens, arg.MessageID)
+	return err
+}
+
+const listSearchResults = `-- name: ListSearchResults :many
+SELECT m.message_id, m.chat_id, m.sender_id, m.cypher_text, m.created_at, m.edited_at, m.thread_root_id
+FROM messages m
+WHERE m.message_id IN (/*SLICE:message_ids*/?)
+ORDER BY m.message_id DESC
+`
+
+type ListSearchResultsParams struct {
+	MessageIds []int64 `json:"message_ids"`
+}
+
+type ListSearchResultsRow struct {
+	MessageID    int64      `json:"message_id"`
+	ChatID       int64      `json:"chat_id"`
+	SenderID     int64      `json:"sender_id"`
+	CypherText   []byte     `json:"cypher_text"`
+	CreatedAt    time.Time  `json:"created_at"`
+	EditedAt     *time.Time `json:"edited_at"`
+	ThreadRootID *int64     `json:"thread_root_id"`
+}
+
+// ListSearchResults
+//
+//	SELECT m.message_id, m.chat_id, m.sender_id, m.cypher_text, m.created_at, m.edited_at, m.thread_root_id
+//	FROM messages m
+//	WHERE m.message_id IN (/*SLICE:message_ids*/?)
+//	ORDER BY m.message_id DESC
+func (q *Queries) ListSearchResults(ctx context.Context, arg ListSearchResultsParams) ([]ListSearchResultsRow, error) {
+	query := listSearchResults
+	var queryParams []interface{}
+	if len(arg.MessageIds) > 0 {
+		for _, v := range arg.MessageIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:message_ids*/?", strings.Repeat(",?", len(arg.MessageIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:message_ids*/?", "NULL", 1)
+	}
+	rows, err := q.db.QueryContext(ctx, query, queryParams...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListSearchResultsRow{}
+	for rows.Next() {
+		var i ListSearchResultsRow
+		if err := rows.Scan(
+			&i.MessageID,
+			&i.ChatID,
+			&i.SenderID,
+			&i.CypherText,
+			&i.CreatedAt,
+			&i.EditedAt,
+			&i.ThreadRootID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listSearchableChats = `-- name: ListSearchableChats :many
+SELECT k.chat_id, k.wrapped_key
+FROM chat_participants cp
+JOIN chats c ON c.chat_id = cp.chat_id
+JOIN chat_keys k ON k.chat_id = cp.chat_id
+WHERE cp.user_id = ?1
+  AND NOT c.end_to_end
+  AND (CAST(?2 AS INTEGER) IS NULL OR cp.chat_id = CAST(?2 AS INTEGER))
+ORDER BY k.chat_id
+`
+
+type ListSearchableChatsParams struct {
+	UserID int64  `json:"user_id"`
+	ChatID *int64 `json:"chat_id"`
+}
+
+type ListSearchableChatsRow struct {
+	ChatID     int64  `json:"chat_id"`
+	WrappedKey []byte `json:"wrapped_key"`
+}
+
+// ListSearchableChats
+//
+//	SELECT k.chat_id, k.wrapped_key
+//	FROM chat_participants cp
+//	JOIN chats c ON c.chat_id = cp.chat_id
+//	JOIN chat_keys k ON k.chat_id = cp.chat_id
+//	WHERE cp.user_id = ?1
+//	  AND NOT c.end_to_end
+//	  AND (CAST(?2 AS INTEGER) IS NULL OR cp.chat_id = CAST(?2 AS INTEGER))
+//	ORDER BY k.chat_id
+func (q *Queries) ListSearchableChats(ctx context.Context, arg ListSearchableChatsParams) ([]ListSearchableChatsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listSearchableChats, arg.UserID, arg.ChatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListSearchableChatsRow{}
+	for rows.Next() {
+		var i ListSearchableChatsRow
+		if err := rows.Scan(&i.ChatID, &i.WrappedKey); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const searchMessageIDs = `-- name: SearchMessageIDs :many
+
+SELECT m.message_id
+FROM messages m
+WHERE m.deleted_at IS NULL
+  AND (CAST(?1 AS INTEGER) IS NULL OR m.message_id < CAST(?1 AS INTEGER))
+  AND m.message_id NOT IN (
+    SELECT h.message_id
+    FROM hidden_messages h
+    WHERE h.user_id = ?2
+  )
+  AND CAST(?3 AS INTEGER) = (
+    SELECT COUNT(*)
+    FROM message_search_tokens t
+    WHERE t.message_id = m.message_id
+      AND t.token IN (/*SLICE:tokens*/?)
+  )
+  AND m.chat_id IN (/*SLICE:chat_ids*/?)
+ORDER BY m.message_id DESC
+`
+
+type SearchMessageIDsParams struct {
+	BeforeID  *int64   `json:"before_id"`
+	UserID    int64    `json:"user_id"`
+	TermCount int64    `json:"term_count"`
+	Tokens    [][]byte `json:"tokens"`
+	ChatIds   []int64  `json:"chat_ids"`
+}
+
+// sqlc numbers the elements of a slice after every parameter ahead of it,
+// so nothing may follow one, LIMIT included. The search is split in two:
+// the matching ids, newest first, then the rows of one page of them.
+//
+//	SELECT m.message_id
+//	FROM messages m
+//	WHERE m.deleted_at IS NULL
+//	  AND (CAST(?1 AS INTEGER) IS NULL OR m.message_id < CAST(?1 AS INTEGER))
+//	  AND m.message_id NOT IN (
+//	    SELECT h.message_id
+//	    FROM hidden_messages h
+//	    WHERE h.user_id = ?2
+//	  )
+//	  AND CAST(?3 AS INTEGER) = (
+//	    SELECT COUNT(*)
+//	    FROM message_search_tokens t
+//	    WHERE t.message_id = m.message_id
+//	      AND t.token IN (/*SLICE:tokens*/?)
+//	  )
+//	  AND m.chat_id IN (/*SLICE:chat_ids*/?)
+//	ORDER BY m.message_id DESC
+func (q *Queries) SearchMessageIDs(ctx context.Context, arg SearchMessageIDsParams) ([]int64, error) {
+	query := searchMessageIDs
+	var queryParams []interface{}
+	queryParams = append(queryParams, arg.BeforeID)
+	queryParams = append(queryParams, arg.UserID)
+	queryParams = append(queryParams, arg.TermCount)
+	if len(arg.Tokens) > 0 {
+		for _, v := range arg.Tokens {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:tokens*/?", strings.Repeat(",?", len(arg.Tokens))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:tokens*/?", "NULL", 1)
+	}
+	if len(arg.ChatIds) > 0 {
+		for _, v := range arg.ChatIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:chat_ids*/?", strings.Repeat(",?", len(arg.ChatIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:chat_ids*/?", "NULL", 1)
+	}
+	rows, err := q.db.QueryContext(ctx, query, queryParams...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []int64{}
+	for rows.Next() {
+		var message_id int64
+		if err := rows.Scan(&message_id); err != nil {
+			return nil, err
+		}
+		items = append(items, message_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,128 @@
+-- name: ListUsers :many
+SELECT * FROM users
+ORDER BY display_name;
+
+-- name: FindUserByDisplayName :one
+SELECT user_id, password_hash, first_name, last_name, pfp_url, totp_enabled FROM users
+WHERE display_name = @display_name;
+
+-- name: FindUserByID :one
+SELECT display_name, first_name, last_name, pfp_url FROM users
+WHERE user_id = @user_id;
+
+-- name: CreateUser :one
+INSERT INTO users (
+    display_name,
+    first_name,
+    last_name,
+    password_hash,
+    pfp_url
+) VALUES (
+    @display_name, @first_name, @last_name, @password_hash, @pfp_url
+)
+RETURNING user_id, display_name, pfp_url;
+
+-- name: UpdateUserPfp :exec
+UPDATE users
+SET pfp_url = @pfp_url,
+    avatar_id = @avatar_id
+WHERE user_id = @user_id;
+
+-- Transactions take the write lock up front, so no FOR UPDATE.
+-- name: GetUserAvatarForUpdate :one
+SELECT display_name, avatar_id
+FROM users
+WHERE user_id = @user_id;
+
+-- name: UpdateUserPassword :exec
+UPDATE users
+SET password_hash = @password_hash
+WHERE user_id = @user_id;
+
+-- Transactions take the write lock up front, so no FOR UPDATE.
+
+-- name: GetUserPasswordForUpdate :one
+SELECT display_name, password_hash
+FROM users
+WHERE user_id = @user_id;
+
+-- name: RehashUserPassword :exec
+UPDATE users
+SET password_hash = @new_hash
+WHERE user_id = @user_id
+  AND password_hash = @old_hash;
+
+-- name: GetOwnProfile :one
+SELECT user_id, display_name, first_name, last_name, pfp_url, created_at, totp_enabled
+FROM users
+WHERE user_id = @user_id;
+
+-- A profile either side of a block has hidden reads as no rows.
+-- name: GetUserProfile :one
+SELECT u.user_id, u.display_name, u.first_name, u.last_name, u.pfp_url, u.created_at,
+       CAST(EXISTS (
+         SELECT 1
+         FROM user_friendships f
+         WHERE f.user_id = @viewer_id AND f.friend_id = u.user_id
+       ) AS BOOLEAN) AS is_friend,
+       CAST(EXISTS (
+         SELECT 1
+         FROM chat_participants mine
+         JOIN chat_participants theirs ON theirs.chat_id = mine.chat_id
+         WHERE mine.user_id = @viewer_id AND theirs.user_id = u.user_id
+       ) AS BOOLEAN) AS shares_chat,
+       CAST(EXISTS (
+         SELECT 1
+         FROM friend_requests fr
+         WHERE fr.sender_id = @viewer_id AND fr.receiver_id = u.user_id
+       ) AS BOOLEAN) AS request_sent,
+       CAST(EXISTS (
+         SELECT 1
+         FROM friend_requests fr
+         WHERE fr.sender_id = u.user_id AND fr.receiver_id = @viewer_id
+       ) AS BOOLEAN) AS request_received
+FROM users u
+WHERE u.user_id = @user_id
+  AND u.user_id NOT IN (SELECT blocked_id FROM user_blocks WHERE blocker_id = @viewer_id)
+  AND u.user_id NOT IN (SELECT blocker_id FROM user_blocks WHERE blocked_id = @viewer_id);
+
+-- There are no trigrams here, so matching is by prefix and substring
+-- alone, and lower() only folds ASCII. Real names only match for friends
+-- and chat partners. sqlc leaves parameters in ORDER BY
+-- unbound, so the rank is a column.
+-- name: SearchUsers :many
+SELECT u.user_id, u.display_name, u.first_name, u.last_name, u.pfp_url,
+       CAST(EXISTS (
+         SELECT 1
+         FROM user_friendships f
+         WHERE f.user_id = @user_id AND f.friend_id = u.user_id
+       ) AS BOOLEAN) AS is_friend,
+       CAST(EXISTS (
+         SELECT 1
+         FROM chat_participants mine
+         JOIN chat_participants theirs ON theirs.chat_id = mine.chat_id
+         WHERE mine.user_id = @user_id AND theirs.user_id = u.user_id
+       ) AS BOOLEAN) AS shares_chat,
+       CASE
+         WHEN lower(u.display_name) = CAST(@query AS TEXT) THEN 0
+         WHEN (lower(u.display_name) LIKE (CAST(@pattern AS TEXT) || '%') ESCAPE '\') THEN 1
+         ELSE 2
+       END AS match_rank
+FROM users u
+WHERE u.user_id <> @user_id
+  AND ((lower(u.display_name) LIKE (CAST(@pattern AS TEXT) || '%') ESCAPE '\')
+       OR ((lower(u.first_name || ' ' || u.last_name) LIKE ('%' || CAST(@pattern AS TEXT) || '%') ESCAPE '\')
+           AND u.user_id IN (
+             SELECT f.friend_id
+             FROM user_friendships f
+             WHERE f.user_id = @user_id
+             UNION
+             SELECT theirs.user_id
+             FROM chat_participants mine
+             JOIN chat_participants theirs ON theirs.chat_id = mine.chat_id
+             WHERE mine.user_id = @user_id
+           )))
+  AND u.user_id NOT IN (SELECT blocked_id FROM user_blocks WHERE blocker_id = @user_id)
+  AND u.user_id NOT IN (SELECT blocker_id FROM user_blocks WHERE blocked_id = @user_id)
+ORDER BY match_rank, u.display_name
+LIMIT @page_size;
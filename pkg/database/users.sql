@@ -3,7 +3,7 @@ SELECT * FROM users
 ORDER BY display_name;
 
 -- name: FindUserByDisplayName :one
-SELECT user_id, password_hash, first_name, last_name, pfp_url FROM users
+SELECT user_id, password_hash, first_name, last_name, pfp_url, totp_enabled FROM users
 WHERE display_name = @display_name;
 
 -- name: FindUserByID :one
@@ -24,5 +24,110 @@ RETURNING user_id, display_name, pfp_url;
 
 -- name: UpdateUserPfp :exec
 UPDATE users
-SET pfp_url = $1
-WHERE user_id = $2;
\ No newline at end of file
+SET pfp_url = @pfp_url,
+    avatar_id = @avatar_id
+WHERE user_id = @user_id;
+
+-- name: GetUserAvatarForUpdate :one
+SELECT display_name, avatar_id
+FROM users
+WHERE user_id = @user_id
+FOR UPDATE;
+
+-- name: UpdateUserPassword :exec
+UPDATE users
+SET password_hash = $1
+WHERE user_id = $2;
+
+-- name: GetUserPasswordForUpdate :one
+SELECT display_name, password_hash
+FROM users
+WHERE user_id = $1
+FOR UPDATE;
+
+-- name: RehashUserPassword :exec
+UPDATE users
+SET password_hash = @new_hash
+WHERE user_id = @user_id
+  AND password_hash = @old_hash;
+
+-- name: GetOwnProfile :one
+SELECT user_id, display_name, first_name, last_name, pfp_url, created_at, totp_enabled
+FROM users
+WHERE user_id = @user_id;
+
+-- A profile either side of a block has hidden reads as no rows.
+-- name: GetUserProfile :one
+SELECT u.user_id, u.display_name, u.first_name, u.last_name, u.pfp_url, u.created_at,
+       EXISTS (
+         SELECT 1
+         FROM user_friendships f
+         WHERE f.user_id = @viewer_id AND f.friend_id = u.user_id
+       ) AS is_friend,
+       EXISTS (
+         SELECT 1
+         FROM chat_participants mine
+         JOIN chat_participants theirs ON theirs.chat_id = mine.chat_id
+         WHERE mine.user_id = @viewer_id AND theirs.user_id = u.user_id
+       ) AS shares_chat,
+       EXISTS (
+         SELECT 1
+         FROM friend_requests fr
+         WHERE fr.sender_id = @viewer_id AND fr.receiver_id = u.user_id
+       ) AS request_sent,
+       EXISTS (
+         SELECT 1
+         FROM friend_requests fr
+         WHERE fr.sender_id = u.user_id AND fr.receiver_id = @viewer_id
+       ) AS request_received
+FROM users u
+WHERE u.user_id = @user_id
+  AND u.user_id NOT IN (SELECT blocked_id FROM user_blocks WHERE blocker_id = @viewer_id)
+  AND u.user_id NOT IN (SELECT blocker_id FROM user_blocks WHERE blocked_id = @viewer_id);
+
+-- Display names match on a prefix and, for the friends and chat partners
+-- who can see them, real names anywhere; both also fuzzily through the
+-- trigram indexes. Nobody else can find a user by real name. @query is
+-- lowercased and @pattern is it with LIKE wildcards escaped. Exact display
+-- names rank first, then prefixes, then the closest names.
+-- name: SearchUsers :many
+WITH known AS (
+  SELECT f.friend_id AS user_id
+  FROM user_friendships f
+  WHERE f.user_id = @user_id
+  UNION
+  SELECT theirs.user_id
+  FROM chat_participants mine
+  JOIN chat_participants theirs ON theirs.chat_id = mine.chat_id
+  WHERE mine.user_id = @user_id
+)
+SELECT u.user_id, u.display_name, u.first_name, u.last_name, u.pfp_url,
+       EXISTS (
+         SELECT 1
+         FROM user_friendships f
+         WHERE f.user_id = @user_id AND f.friend_id = u.user_id
+       ) AS is_friend,
+       EXISTS (
+         SELECT 1
+         FROM chat_participants mine
+         JOIN chat_participants theirs ON theirs.chat_id = mine.chat_id
+         WHERE mine.user_id = @user_id AND theirs.user_id = u.user_id
+       ) AS shares_chat
+FROM users u
+LEFT JOIN known k ON k.user_id = u.user_id
+WHERE u.user_id <> @user_id
+  AND (lower(u.display_name) LIKE @pattern::text || '%' ESCAPE '\'
+       OR lower(u.display_name) % @query::text
+       OR (k.user_id IS NOT NULL
+           AND (lower(u.first_name || ' ' || u.last_name) LIKE '%' || @pattern::text || '%' ESCAPE '\'
+                OR lower(u.first_name || ' ' || u.last_name) % @query::text)))
+  AND u.user_id NOT IN (SELECT blocked_id FROM user_blocks WHERE blocker_id = @user_id)
+  AND u.user_id NOT IN (SELECT blocker_id FROM user_blocks WHERE blocked_id = @user_id)
+ORDER BY lower(u.display_name) = @query::text DESC,
+         lower(u.display_name) LIKE @pattern::text || '%' ESCAPE '\' DESC,
+         greatest(similarity(lower(u.display_name), @query::text),
+                  CASE WHEN k.user_id IS NOT NULL
+                       THEN similarity(lower(u.first_name || ' ' || u.last_name), @query::text)
+                       ELSE 0 END) DESC,
+         u.display_name
+LIMIT @page_size;
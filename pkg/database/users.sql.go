@@ -7,6 +7,7 @@ package database
 
 import (
 	"context"
+	"time"
 )
 
 const createUser = `-- name: CreateUser :one
@@ -62,7 +63,7 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (CreateU
 }
 
 const findUserByDisplayName = `-- name: FindUserByDisplayName :one
-SELECT user_id, password_hash, first_name, last_name, pfp_url FROM users
+SELECT user_id, password_hash, first_name, last_name, pfp_url, totp_enabled FROM users
 WHERE display_name = $1
 `
 
@@ -76,11 +77,12 @@ type FindUserByDisplayNameRow struct {
 	FirstName    string  `json:"first_name"`
 	LastName     string  `json:"last_name"`
 	PfpUrl       *string `json:"pfp_url"`
+	TotpEnabled  bool    `json:"totp_enabled"`
 }
 
 // FindUserByDisplayName
 //
-//	SELECT user_id, password_hash, first_name, last_name, pfp_url FROM users
+//	SELECT user_id, password_hash, first_name, last_name, pfp_url, totp_enabled FROM users
 //	WHERE display_name = $1
 func (q *Queries) FindUserByDisplayName(ctx context.Context, arg FindUserByDisplayNameParams) (FindUserByDisplayNameRow, error) {
 	row := q.db.QueryRow(ctx, findUserByDisplayName, arg.DisplayName)
@@ -91,6 +93,7 @@ func (q *Queries) FindUserByDisplayName(ctx context.Context, arg FindUserByDispl
 		&i.FirstName,
 		&i.LastName,
 		&i.PfpUrl,
+		&i.TotpEnabled,
 	)
 	return i, err
 }
@@ -127,14 +130,205 @@ func (q *Queries) FindUserByID(ctx context.Context, arg FindUserByIDParams) (Fin
 	return i, err
 }
 
+const getOwnProfile = `-- name: GetOwnProfile :one
+SELECT user_id, display_name, first_name, last_name, pfp_url, created_at, totp_enabled
+FROM users
+WHERE user_id = $1
+`
+
+type GetOwnProfileParams struct {
+	UserID int64 `json:"user_id"`
+}
+
+type GetOwnProfileRow struct {
+	UserID      int64     `json:"user_id"`
+	DisplayName string    `json:"display_name"`
+	FirstName   string    `json:"first_name"`
+	LastName    string    `json:"last_name"`
+	PfpUrl      *string   `json:"pfp_url"`
+	CreatedAt   time.Time `json:"created_at"`
+	TotpEnabled bool      `json:"totp_enabled"`
+}
+
+// GetOwnProfile
+//
+//	SELECT user_id, display_name, first_name, last_name, pfp_url, created_at, totp_enabled
+//	FROM users
+//	WHERE user_id = $1
+func (q *Queries) GetOwnProfile(ctx context.Context, arg GetOwnProfileParams) (GetOwnProfileRow, error) {
+	row := q.db.QueryRow(ctx, getOwnProfile, arg.UserID)
+	var i GetOwnProfileRow
+	err := row.Scan(
+		&i.UserID,
+		&i.DisplayName,
+		&i.FirstName,
+		&i.LastName,
+		&i.PfpUrl,
+		&i.CreatedAt,
+		&i.TotpEnabled,
+	)
+	return i, err
+}
+
+const getUserAvatarForUpdate = `-- name: GetUserAvatarForUpdate :one
+SELECT display_name, avatar_id
+FROM users
+WHERE user_id = $1
+FOR UPDATE
+`
+
+type GetUserAvatarForUpdateParams struct {
+	UserID int64 `json:"user_id"`
+}
+
+type GetUserAvatarForUpdateRow struct {
+	DisplayName string  `json:"display_name"`
+	AvatarID    *string `json:"avatar_id"`
+}
+
+// GetUserAvatarForUpdate
+//
+//	SELECT display_name, avatar_id
+//	FROM users
+//	WHERE user_id = $1
+//	FOR UPDATE
+func (q *Queries) GetUserAvatarForUpdate(ctx context.Context, arg GetUserAvatarForUpdateParams) (GetUserAvatarForUpdateRow, error) {
+	row := q.db.QueryRow(ctx, getUserAvatarForUpdate, arg.UserID)
+	var i GetUserAvatarForUpdateRow
+	err := row.Scan(&i.DisplayName, &i.AvatarID)
+	return i, err
+}
+
+const getUserPasswordForUpdate = `-- name: GetUserPasswordForUpdate :one
+SELECT display_name, password_hash
+FROM users
+WHERE user_id = $1
+FOR UPDATE
+`
+
+type GetUserPasswordForUpdateParams struct {
+	UserID int64 `json:"user_id"`
+}
+
+type GetUserPasswordForUpdateRow struct {
+	DisplayName  string `json:"display_name"`
+	PasswordHash string `json:"password_hash"`
+}
+
+// GetUserPasswordForUpdate
+//
+//	SELECT display_name, password_hash
+//	FROM users
+//	WHERE user_id = $1
+//	FOR UPDATE
+func (q *Queries) GetUserPasswordForUpdate(ctx context.Context, arg GetUserPasswordForUpdateParams) (GetUserPasswordForUpdateRow, error) {
+	row := q.db.QueryRow(ctx, getUserPasswordForUpdate, arg.UserID)
+	var i GetUserPasswordForUpdateRow
+	err := row.Scan(&i.DisplayName, &i.PasswordHash)
+	return i, err
+}
+
+const getUserProfile = `-- name: GetUserProfile :one
+SELECT u.user_id, u.display_name, u.first_name, u.last_name, u.pfp_url, u.created_at,
+       EXISTS (
+         SELECT 1
+         FROM user_friendships f
+         WHERE f.user_id = $1 AND f.friend_id = u.user_id
+       ) AS is_friend,
+       EXISTS (
+         SELECT 1
+         FROM chat_participants mine
+         JOIN chat_participants theirs ON theirs.chat_id = mine.chat_id
+         WHERE mine.user_id = $1 AND theirs.user_id = u.user_id
+       ) AS shares_chat,
+       EXISTS (
+         SELECT 1
+         FROM friend_requests fr
+         WHERE fr.sender_id = $1 AND fr.receiver_id = u.user_id
+       ) AS request_sent,
+       EXISTS (
+         SELECT 1
+         FROM friend_requests fr
+         WHERE fr.sender_id = u.user_id AND fr.receiver_id = $1
+       ) AS request_received
+FROM users u
+WHERE u.user_id = $2
+  AND u.user_id NOT IN (SELECT blocked_id FROM user_blocks WHERE blocker_id = $1)
+  AND u.user_id NOT IN (SELECT blocker_id FROM user_blocks WHERE blocked_id = $1)
+`
+
+type GetUserProfileParams struct {
+	ViewerID int64 `json:"viewer_id"`
+	UserID   int64 `json:"user_id"`
+}
+
+type GetUserProfileRow struct {
+	UserID          int64     `json:"user_id"`
+	DisplayName     string    `json:"display_name"`
+	FirstName       string    `json:"first_name"`
+	LastName        string    `json:"last_name"`
+	PfpUrl          *string   `json:"pfp_url"`
+	CreatedAt       time.Time `json:"created_at"`
+	IsFriend        bool      `json:"is_friend"`
+	SharesChat      bool      `json:"shares_chat"`
+	RequestSent     bool      `json:"request_sent"`
+	RequestReceived bool      `json:"request_received"`
+}
+
+// A profile either side of a block has hidden reads as no rows.
+//
+//	SELECT u.user_id, u.display_name, u.first_name, u.last_name, u.pfp_url, u.created_at,
+//	       EXISTS (
+//	         SELECT 1
+//	         FROM user_friendships f
+//	         WHERE f.user_id = $1 AND f.friend_id = u.user_id
+//	       ) AS is_friend,
+//	       EXISTS (
+//	         SELECT 1
+//	         FROM chat_participants mine
+//	         JOIN chat_participants theirs ON theirs.chat_id = mine.chat_id
+//	         WHERE mine.user_id = $1 AND theirs.user_id = u.user_id
+//	       ) AS shares_chat,
+//	       EXISTS (
+//	         SELECT 1
+//	         FROM friend_requests fr
+//	         WHERE fr.sender_id = $1 AND fr.receiver_id = u.user_id
+//	       ) AS request_sent,
+//	       EXISTS (
+//	         SELECT 1
+//	         FROM friend_requests fr
+//	         WHERE fr.sender_id = u.user_id AND fr.receiver_id = $1
+//	       ) AS request_received
+//	FROM users u
+//	WHERE u.user_id = $2
+//	  AND u.user_id NOT IN (SELECT blocked_id FROM user_blocks WHERE blocker_id = $1)
+//	  AND u.user_id NOT IN (SELECT blocker_id FROM user_blocks WHERE blocked_id = $1)
+func (q *Queries) GetUserProfile(ctx context.Context, arg GetUserProfileParams) (GetUserProfileRow, error) {
+	row := q.db.QueryRow(ctx, getUserProfile, arg.ViewerID, arg.UserID)
+	var i GetUserProfileRow
+	err := row.Scan(
+		&i.UserID,
+		&i.DisplayName,
+		&i.FirstName,
+		&i.LastName,
+		&i.PfpUrl,
+		&i.CreatedAt,
+		&i.IsFriend,
+		&i.SharesChat,
+		&i.RequestSent,
+		&i.RequestReceived,
+	)
+	return i, err
+}
+
 const listUsers = `-- name: ListUsers :many
-SELECT user_id, display_name, password_hash, first_name, pfp_url, last_name, created_at FROM users
+SELECT user_id, display_name, password_hash, first_name, pfp_url, last_name, created_at, totp_secret, totp_enabled, totp_last_step, mfa_failed_attempts, mfa_locked_until, avatar_id FROM users
 ORDER BY display_name
 `
 
 // ListUsers
 //
-//	SELECT user_id, display_name, password_hash, first_name, pfp_url, last_name, created_at FROM users
+//	SELECT user_id, display_name, password_hash, first_name, pfp_url, last_name, created_at, totp_secret, totp_enabled, totp_last_step, mfa_failed_attempts, mfa_locked_until, avatar_id FROM users
 //	ORDER BY display_name
 func (q *Queries) ListUsers(ctx context.Context) ([]User, error) {
 	rows, err := q.db.Query(ctx, listUsers)
@@ -153,6 +347,12 @@ func (q *Queries) ListUsers(ctx context.Context) ([]User, error) {
 			&i.PfpUrl,
 			&i.LastName,
 			&i.CreatedAt,
+			&i.TotpSecret,
+			&i.TotpEnabled,
+			&i.TotpLastStep,
+			&i.MfaFailedAttempts,
+			&i.MfaLockedUntil,
+			&i.AvatarID,
 		); err != nil {
 			return nil, err
 		}
@@ -164,23 +364,210 @@ func (q *Queries) ListUsers(ctx context.Context) ([]User, error) {
 	return items, nil
 }
 
-const updateUserPfp = `-- name: UpdateUserPfp :exec
+const rehashUserPassword = `-- name: RehashUserPassword :exec
 UPDATE users
-SET pfp_url = $1
+SET password_hash = $1
 WHERE user_id = $2
+  AND password_hash = $3
+`
+
+type RehashUserPasswordParams struct {
+	NewHash string `json:"new_hash"`
+	UserID  int64  `json:"user_id"`
+	OldHash string `json:"old_hash"`
+}
+
+// RehashUserPassword
+//
+//	UPDATE users
+//	SET password_hash = $1
+//	WHERE user_id = $2
+//	  AND password_hash = $3
+func (q *Queries) RehashUserPassword(ctx context.Context, arg RehashUserPasswordParams) error {
+	_, err := q.db.Exec(ctx, rehashUserPassword, arg.NewHash, arg.UserID, arg.OldHash)
+	return err
+}
+
+const searchUsers = `-- name: SearchUsers :many
+WITH known AS (
+  SELECT f.friend_id AS user_id
+  FROM user_friendships f
+  WHERE f.user_id = $1
+  UNION
+  SELECT theirs.user_id
+  FROM chat_participants mine
+  JOIN chat_participants theirs ON theirs.chat_id = mine.chat_id
+  WHERE mine.user_id = $1
+)
+SELECT u.user_id, u.display_name, u.first_name, u.last_name, u.pfp_url,
+       EXISTS (
+         SELECT 1
+         FROM user_friendships f
+         WHERE f.user_id = $1 AND f.friend_id = u.user_id
+       ) AS is_friend,
+       EXISTS (
+         SELECT 1
+         FROM chat_participants mine
+         JOIN chat_participants theirs ON theirs.chat_id = mine.chat_id
+         WHERE mine.user_id = $1 AND theirs.user_id = u.user_id
+       ) AS shares_chat
+FROM users u
+LEFT JOIN known k ON k.user_id = u.user_id
+WHERE u.user_id <> $1
+  AND (lower(u.display_name) LIKE $2::text || '%' ESCAPE '\'
+       OR lower(u.display_name) % $3::text
+       OR (k.user_id IS NOT NULL
+           AND (lower(u.first_name || ' ' || u.last_name) LIKE '%' || $2::text || '%' ESCAPE '\'
+                OR lower(u.first_name || ' ' || u.last_name) % $3::text)))
+  AND u.user_id NOT IN (SELECT blocked_id FROM user_blocks WHERE blocker_id = $1)
+  AND u.user_id NOT IN (SELECT blocker_id FROM user_blocks WHERE blocked_id = $1)
+ORDER BY lower(u.display_name) = $3::text DESC,
+         lower(u.display_name) LIKE $2::text || '%' ESCAPE '\' DESC,
+         greatest(similarity(lower(u.display_name), $3::text),
+                  CASE WHEN k.user_id IS NOT NULL
+                       THEN similarity(lower(u.first_name || ' ' || u.last_name), $3::text)
+                       ELSE 0 END) DESC,
+         u.display_name
+LIMIT $4
+`
+
+type SearchUsersParams struct {
+	UserID   int64  `json:"user_id"`
+	Pattern  string `json:"pattern"`
+	Query    string `json:"query"`
+	PageSize int32  `json:"page_size"`
+}
+
+type SearchUsersRow struct {
+	UserID      int64   `json:"user_id"`
+	DisplayName string  `json:"display_name"`
+	FirstName   string  `json:"first_name"`
+	LastName    string  `json:"last_name"`
+	PfpUrl      *string `json:"pfp_url"`
+	IsFriend    bool    `json:"is_friend"`
+	SharesChat  bool    `json:"shares_chat"`
+}
+
+// Display names match on a prefix and, for the friends and chat partners
+// who can see them, real names anywhere; both also fuzzily through the
+// trigram indexes. Nobody else can find a user by real name. @query is
+// lowercased and @pattern is it with LIKE wildcards escaped. Exact display
+// names rank first, then prefixes, then the closest names.
+//
+//	WITH known AS (
+//	  SELECT f.friend_id AS user_id
+//	  FROM user_friendships f
+//	  WHERE f.user_id = $1
+//	  UNION
+//	  SELECT theirs.user_id
+//	  FROM chat_participants mine
+//	  JOIN chat_participants theirs ON theirs.chat_id = mine.chat_id
+//	  WHERE mine.user_id = $1
+//	)
+//	SELECT u.user_id, u.display_name, u.first_name, u.last_name, u.pfp_url,
+//	       EXISTS (
+//	         SELECT 1
+//	         FROM user_friendships f
+//	         WHERE f.user_id = $1 AND f.friend_id = u.user_id
+//	       ) AS is_friend,
+//	       EXISTS (
+//	         SELECT 1
+//	         FROM chat_participants mine
+//	         JOIN chat_participants theirs ON theirs.chat_id = mine.chat_id
+//	         WHERE mine.user_id = $1 AND theirs.user_id = u.user_id
+//	       ) AS shares_chat
+//	FROM users u
+//	LEFT JOIN known k ON k.user_id = u.user_id
+//	WHERE u.user_id <> $1
+//	  AND (lower(u.display_name) LIKE $2::text || '%' ESCAPE '\'
+//	       OR lower(u.display_name) % $3::text
+//	       OR (k.user_id IS NOT NULL
+//	           AND (lower(u.first_name || ' ' || u.last_name) LIKE '%' || $2::text || '%' ESCAPE '\'
+//	                OR lower(u.first_name || ' ' || u.last_name) % $3::text)))
+//	  AND u.user_id NOT IN (SELECT blocked_id FROM user_blocks WHERE blocker_id = $1)
+//	  AND u.user_id NOT IN (SELECT blocker_id FROM user_blocks WHERE blocked_id = $1)
+//	ORDER BY lower(u.display_name) = $3::text DESC,
+//	         lower(u.display_name) LIKE $2::text || '%' ESCAPE '\' DESC,
+//	         greatest(similarity(lower(u.display_name), $3::text),
+//	                  CASE WHEN k.user_id IS NOT NULL
+//	                       THEN similarity(lower(u.first_name || ' ' || u.last_name), $3::text)
+//	                       ELSE 0 END) DESC,
+//	         u.display_name
+//	LIMIT $4
+func (q *Queries) SearchUsers(ctx context.Context, arg SearchUsersParams) ([]SearchUsersRow, error) {
+	rows, err := q.db.Query(ctx, searchUsers,
+		arg.UserID,
+		arg.Pattern,
+		arg.Query,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []SearchUsersRow{}
+	for rows.Next() {
+		var i SearchUsersRow
+		if err := rows.Scan(
+			&i.UserID,
+			&i.DisplayName,
+			&i.FirstName,
+			&i.LastName,
+			&i.PfpUrl,
+			&i.IsFriend,
+			&i.SharesChat,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateUserPassword = `-- name: UpdateUserPassword :exec
+UPDATE users
+SET password_hash = $1
+WHERE user_id = $2
+`
+
+type UpdateUserPasswordParams struct {
+	PasswordHash string `json:"password_hash"`
+	UserID       int64  `json:"user_id"`
+}
+
+// UpdateUserPassword
+//
+//	UPDATE users
+//	SET password_hash = $1
+//	WHERE user_id = $2
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.Exec(ctx, updateUserPassword, arg.PasswordHash, arg.UserID)
+	return err
+}
+
+const updateUserPfp = `-- name: UpdateUserPfp :exec
+UPDATE users
+SET pfp_url = $1,
+    avatar_id = $2
+WHERE user_id = $3
 `
 
 type UpdateUserPfpParams struct {
-	PfpUrl *string `json:"pfp_url"`
-	UserID int64   `json:"user_id"`
+	PfpUrl   *string `json:"pfp_url"`
+	AvatarID *string `json:"avatar_id"`
+	UserID   int64   `json:"user_id"`
 }
 
 // UpdateUserPfp
 //
 //	UPDATE users
-//	SET pfp_url = $1
-//	WHERE user_id = $2
+//	SET pfp_url = $1,
+//	    avatar_id = $2
+//	WHERE user_id = $3
 func (q *Queries) UpdateUserPfp(ctx context.Context, arg UpdateUserPfpParams) error {
-	_, err := q.db.Exec(ctx, updateUserPfp, arg.PfpUrl, arg.UserID)
+	_, err := q.db.Exec(ctx, updateUserPfp, arg.PfpUrl, arg.AvatarID, arg.UserID)
 	return err
 }
@@ -22,7 +22,7 @@ func GetUserClaims(c echo.Context) (*UserClaims, error) {
 	return claims, nil
 }
 
-func Authenticate(handler *TokenHandler) echo.MiddlewareFunc {
+func Authenticate(handler *TokenHandler, sessions *SessionCache) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			r := c.Request()
@@ -43,6 +43,16 @@ func Authenticate(handler *TokenHandler) echo.MiddlewareFunc {
 				return echo.ErrUnauthorized.WithInternal(err)
 			}
 
+			active, err := sessions.Active(r.Context(), claims.SessionID(), c.RealIP())
+			if err != nil {
+				slog.Error("session lookup failed", "error", err)
+				return echo.ErrInternalServerError.WithInternal(err)
+			}
+			if !active {
+				slog.Warn("session revoked or unknown", "subject", claims.Subject, "session", claims.SessionID())
+				return echo.ErrUnauthorized
+			}
+
 			slog.Info("token verified", "subject", claims.Subject, "firstName", claims.FirstName)
 			c.Set(userClaimsKey, claims)
 			return next(c)
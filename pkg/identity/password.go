@@ -6,60 +6,99 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
-
 	"strings"
+	"sync"
 
+	"github.com/go-crypt/crypt"
 	"golang.org/x/crypto/argon2"
 )
 
 type Password []byte
 
-type argon2Params struct {
-	memory      uint32
-	iterations  uint32
-	parallelism uint8
-	saltLength  uint32
-	keyLength   uint32
+// Argon2Params are the argon2id cost settings new hashes are made with.
+// Memory is in KiB.
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2Params are the settings every hash was made with before they
+// became configurable.
+var DefaultArgon2Params = Argon2Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
 }
 
 var (
 	ErrInvalidHash         = errors.New("the encoded hash is not in the correct format")
 	ErrIncompatibleVersion = errors.New("incompatible version of argon2")
+	ErrInvalidArgon2Params = errors.New("argon2 parameters are too weak")
 )
 
-func (password Password) GenerateHash() (encodedHash string, err error) {
-	p := &argon2Params{
-		memory:      64 * 1024,
-		iterations:  3,
-		parallelism: 2,
-		saltLength:  16,
-		keyLength:   32,
+// NewArgon2Params builds cost settings on top of the default salt and key
+// lengths, refusing values that would make hashes trivial to brute force.
+func NewArgon2Params(memory, iterations uint32, parallelism uint8) (Argon2Params, error) {
+	if memory < 8*1024 || iterations < 1 || parallelism < 1 {
+		return Argon2Params{}, ErrInvalidArgon2Params
 	}
 
-	salt, err := newSalt(p.saltLength)
+	p := DefaultArgon2Params
+	p.Memory = memory
+	p.Iterations = iterations
+	p.Parallelism = parallelism
+	return p, nil
+}
+
+func (password Password) GenerateHash(p Argon2Params) (encodedHash string, err error) {
+	salt, err := newSalt(p.SaltLength)
 	if err != nil {
 		return "", err
 	}
 
-	hash := argon2.IDKey(password, salt, p.iterations, p.memory, p.parallelism, p.keyLength)
+	hash := argon2.IDKey(password, salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
 
 	// Base64 encode the salt and hashed password.
 	b64Salt := base64.RawStdEncoding.EncodeToString(salt)
 	b64Hash := base64.RawStdEncoding.EncodeToString(hash)
 
 	// Return a string using the standard encoded hash representation.
-	encodedHash = fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, p.memory, p.iterations, p.parallelism, b64Salt, b64Hash)
+	encodedHash = fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, p.Memory, p.Iterations, p.Parallelism, b64Salt, b64Hash)
 
 	return encodedHash, nil
 }
 
+// legacyDecoder reads the digests accounts may carry from before the switch
+// to argon2id: bcrypt, pbkdf2, scrypt and sha-crypt.
+var legacyDecoder = sync.OnceValues(crypt.NewDefaultDecoder)
+
+// ValidatePassword checks the password against an argon2id hash, or any
+// legacy digest go-crypt knows how to read.
 func (password Password) ValidatePassword(encodedHash string) (match bool, err error) {
+	if !strings.HasPrefix(encodedHash, "$argon2id$") {
+		decoder, err := legacyDecoder()
+		if err != nil {
+			return false, err
+		}
+
+		digest, err := decoder.Decode(encodedHash)
+		if err != nil {
+			return false, fmt.Errorf("%w: %w", ErrInvalidHash, err)
+		}
+		return digest.MatchBytesAdvanced(password)
+	}
+
 	p, salt, hash, err := decodeHash(encodedHash)
 	if err != nil {
 		return false, err
 	}
 
-	otherHash := argon2.IDKey([]byte(password), salt, p.iterations, p.memory, p.parallelism, p.keyLength)
+	otherHash := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
 
 	if subtle.ConstantTimeCompare(hash, otherHash) == 1 {
 		return true, nil
@@ -67,9 +106,25 @@ func (password Password) ValidatePassword(encodedHash string) (match bool, err e
 	return false, nil
 }
 
-func decodeHash(encodedHash string) (p *argon2Params, salt, hash []byte, err error) {
+// NeedsRehash reports whether a hash that just validated should be replaced:
+// it uses another algorithm, argon2id with weaker settings than p, or a
+// different parallelism.
+func NeedsRehash(encodedHash string, p Argon2Params) bool {
+	current, _, _, err := decodeHash(encodedHash)
+	if err != nil {
+		return true
+	}
+
+	return current.Memory < p.Memory ||
+		current.Iterations < p.Iterations ||
+		current.Parallelism != p.Parallelism ||
+		current.SaltLength < p.SaltLength ||
+		current.KeyLength < p.KeyLength
+}
+
+func decodeHash(encodedHash string) (p *Argon2Params, salt, hash []byte, err error) {
 	vals := strings.Split(encodedHash, "$")
-	if len(vals) != 6 {
+	if len(vals) != 6 || vals[1] != "argon2id" {
 		return nil, nil, nil, ErrInvalidHash
 	}
 
@@ -82,8 +137,8 @@ func decodeHash(encodedHash string) (p *argon2Params, salt, hash []byte, err err
 		return nil, nil, nil, ErrIncompatibleVersion
 	}
 
-	p = &argon2Params{}
-	_, err = fmt.Sscanf(vals[3], "m=%d,t=%d,p=%d", &p.memory, &p.iterations, &p.parallelism)
+	p = &Argon2Params{}
+	_, err = fmt.Sscanf(vals[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism)
 	if err != nil {
 		return nil, nil, nil, err
 	}
@@ -92,13 +147,13 @@ func decodeHash(encodedHash string) (p *argon2Params, salt, hash []byte, err err
 	if err != nil {
 		return nil, nil, nil, err
 	}
-	p.saltLength = uint32(len(salt))
+	p.SaltLength = uint32(len(salt))
 
 	hash, err = base64.RawStdEncoding.Strict().DecodeString(vals[5])
 	if err != nil {
 		return nil, nil, nil, err
 	}
-	p.keyLength = uint32(len(hash))
+	p.KeyLength = uint32(len(hash))
 
 	return p, salt, hash, nil
 }
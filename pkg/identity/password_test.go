@@ -0,0 +1,108 @@
+package identity
+
+import (
+	"errors"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testArgon2Params keep hashing fast; production settings are far higher.
+var testArgon2Params = Argon2Params{
+	Memory:      8 * 1024,
+	Iterations:  1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+func TestPasswordHash(t *testing.T) {
+	hash, err := Password("correct horse").GenerateHash(testArgon2Params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := Password("correct horse").ValidatePassword(hash); err != nil || !ok {
+		t.Errorf("ValidatePassword(right) = %v, %v", ok, err)
+	}
+	if ok, err := Password("correct horses").ValidatePassword(hash); err != nil || ok {
+		t.Errorf("ValidatePassword(wrong) = %v, %v", ok, err)
+	}
+
+	// Salted, so the same password never hashes the same twice
+	again, err := Password("correct horse").GenerateHash(testArgon2Params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again == hash {
+		t.Error("two hashes of one password are equal")
+	}
+}
+
+func TestValidateLegacyHash(t *testing.T) {
+	legacy, err := bcrypt.GenerateFromPassword([]byte("old password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := Password("old password").ValidatePassword(string(legacy)); err != nil || !ok {
+		t.Errorf("ValidatePassword(bcrypt, right) = %v, %v", ok, err)
+	}
+	if ok, err := Password("new password").ValidatePassword(string(legacy)); err != nil || ok {
+		t.Errorf("ValidatePassword(bcrypt, wrong) = %v, %v", ok, err)
+	}
+	if !NeedsRehash(string(legacy), testArgon2Params) {
+		t.Error("a bcrypt digest does not need rehashing")
+	}
+
+	for _, hash := range []string{"", "plaintext", "$unknown$abc", "$argon2id$v=19$m=1"} {
+		if _, err := Password("x").ValidatePassword(hash); !errors.Is(err, ErrInvalidHash) {
+			t.Errorf("ValidatePassword(%q) = %v, want ErrInvalidHash", hash, err)
+		}
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	hash, err := Password("pw").GenerateHash(testArgon2Params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stronger := func(change func(*Argon2Params)) Argon2Params {
+		p := testArgon2Params
+		change(&p)
+		return p
+	}
+
+	tests := []struct {
+		name string
+		p    Argon2Params
+		want bool
+	}{
+		{"same", testArgon2Params, false},
+		{"weaker", stronger(func(p *Argon2Params) { p.Iterations = 0 }), false},
+		{"more memory", stronger(func(p *Argon2Params) { p.Memory *= 2 }), true},
+		{"more iterations", stronger(func(p *Argon2Params) { p.Iterations++ }), true},
+		{"other parallelism", stronger(func(p *Argon2Params) { p.Parallelism++ }), true},
+		{"longer key", stronger(func(p *Argon2Params) { p.KeyLength = 64 }), true},
+	}
+
+	for _, tt := range tests {
+		if got := NeedsRehash(hash, tt.p); got != tt.want {
+			t.Errorf("%s: NeedsRehash = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNewArgon2Params(t *testing.T) {
+	if _, err := NewArgon2Params(4*1024, 3, 2); !errors.Is(err, ErrInvalidArgon2Params) {
+		t.Errorf("too little memory: %v", err)
+	}
+	if _, err := NewArgon2Params(64*1024, 0, 2); !errors.Is(err, ErrInvalidArgon2Params) {
+		t.Errorf("no iterations: %v", err)
+	}
+	p, err := NewArgon2Params(32*1024, 2, 4)
+	if err != nil || p.Memory != 32*1024 || p.SaltLength != DefaultArgon2Params.SaltLength {
+		t.Errorf("NewArgon2Params = %+v, %v", p, err)
+	}
+}
@@ -0,0 +1,108 @@
+package identity
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+//go:embed common_passwords.txt
+var commonPasswords []byte
+
+var (
+	ErrPasswordTooShort = errors.New("password is too short")
+	ErrPasswordTooLong  = errors.New("password is too long")
+	ErrPasswordCommon   = errors.New("password is too common")
+	ErrPasswordPersonal = errors.New("password must not contain your display name")
+)
+
+// minPasswordLength is the lowest minimum a policy accepts. The bundled
+// common-password list leaves out entries shorter than this, so a lower
+// minimum would let those through.
+const minPasswordLength = 8
+
+// PasswordPolicy decides whether a new password is acceptable. Length is
+// counted in characters, and passwords on the common list are refused
+// regardless of case.
+type PasswordPolicy struct {
+	minLength int
+	maxLength int
+	common    map[string]struct{}
+}
+
+// NewPasswordPolicy builds a policy from the bundled common-password list,
+// extended with the newline separated list at extraListPath when set. The
+// bundled list is a baseline of a couple of thousand entries; deployments
+// should supply a fuller one. Lines starting with # are comments. minLength
+// must be at least 8.
+func NewPasswordPolicy(minLength, maxLength int, extraListPath string) (*PasswordPolicy, error) {
+	if minLength < minPasswordLength || maxLength < minLength {
+		return nil, fmt.Errorf("invalid password length bounds %d..%d, the minimum must be at least %d", minLength, maxLength, minPasswordLength)
+	}
+
+	policy := &PasswordPolicy{minLength, maxLength, map[string]struct{}{}}
+	if err := policy.addCommon(bytes.NewReader(commonPasswords)); err != nil {
+		return nil, err
+	}
+
+	if extraListPath != "" {
+		f, err := os.Open(extraListPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open common password list: %w", err)
+		}
+		defer f.Close()
+
+		if err := policy.addCommon(f); err != nil {
+			return nil, fmt.Errorf("failed to read common password list: %w", err)
+		}
+	}
+
+	return policy, nil
+}
+
+// addCommon loads a list, skipping entries too short to pass the length
+// check anyway so large lists cost no more memory than they need to.
+func (p *PasswordPolicy) addCommon(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || utf8.RuneCountInString(line) < p.minLength {
+			continue
+		}
+		p.common[strings.ToLower(line)] = struct{}{}
+	}
+	return scanner.Err()
+}
+
+// Check returns why password is unacceptable for the named user, or nil.
+func (p *PasswordPolicy) Check(password Password, displayName string) error {
+	length := utf8.RuneCount(password)
+	if length < p.minLength {
+		return ErrPasswordTooShort
+	}
+	if length > p.maxLength {
+		return ErrPasswordTooLong
+	}
+
+	lower := strings.ToLower(string(password))
+	if _, ok := p.common[lower]; ok {
+		return ErrPasswordCommon
+	}
+
+	if name := strings.ToLower(strings.TrimSpace(displayName)); len(name) >= 3 && strings.Contains(lower, name) {
+		return ErrPasswordPersonal
+	}
+
+	return nil
+}
+
+// MinLength is the shortest password the policy accepts.
+func (p *PasswordPolicy) MinLength() int {
+	return p.minLength
+}
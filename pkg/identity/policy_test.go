@@ -0,0 +1,61 @@
+package identity
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPasswordPolicy(t *testing.T) {
+	extra := filepath.Join(t.TempDir(), "common.txt")
+	if err := os.WriteFile(extra, []byte("# comment\nshort\nHunter2Hunter2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	policy, err := NewPasswordPolicy(8, 20, extra)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		password string
+		name     string
+		want     error
+	}{
+		{"seven77", "", ErrPasswordTooShort},
+		{"ééééééé", "", ErrPasswordTooShort}, // counted in characters, not bytes
+		{"éééééééé", "", nil},
+		{"a very long passphrase indeed", "", ErrPasswordTooLong},
+		{"password", "", ErrPasswordCommon}, // bundled list
+		{"PASSWORD", "", ErrPasswordCommon},
+		{"hunter2hunter2", "", ErrPasswordCommon}, // extra list
+		{"# comment", "", nil},
+		{"i am ada lovelace", "Ada Lovelace", ErrPasswordPersonal},
+		{"ada is fine here", "Ad", nil}, // names under three letters are ignored
+		{"plum-river-lantern", "Ada", nil},
+	}
+
+	for _, tt := range tests {
+		if err := policy.Check(Password(tt.password), tt.name); !errors.Is(err, tt.want) {
+			t.Errorf("Check(%q, %q) = %v, want %v", tt.password, tt.name, err, tt.want)
+		}
+	}
+
+	// Entries shorter than the minimum are never loaded
+	if _, ok := policy.common["short"]; ok {
+		t.Error("an entry below the minimum length was loaded")
+	}
+}
+
+func TestNewPasswordPolicyErrors(t *testing.T) {
+	if _, err := NewPasswordPolicy(6, 10, ""); err == nil {
+		t.Error("minimum below the bundled list's cut-off accepted")
+	}
+	if _, err := NewPasswordPolicy(10, 8, ""); err == nil {
+		t.Error("maximum below minimum accepted")
+	}
+	if _, err := NewPasswordPolicy(8, 64, filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("missing list accepted")
+	}
+}
@@ -0,0 +1,43 @@
+package identity
+
+import (
+	"crypto/ed25519"
+	"errors"
+)
+
+// End-to-end keys are generated and kept on the client; the server only
+// checks that what it publishes is well formed. An identity key is an
+// Ed25519 public key and signs the user's current X25519 prekey.
+const (
+	IdentityKeySize = ed25519.PublicKeySize
+	PrekeySize      = 32
+)
+
+var (
+	ErrInvalidIdentityKey     = errors.New("identity key must be a 32 byte Ed25519 public key")
+	ErrInvalidPrekey          = errors.New("signed prekey must be a 32 byte public key")
+	ErrInvalidPrekeySignature = errors.New("signed prekey signature does not verify against the identity key")
+)
+
+// ValidateIdentityKey checks the shape of an uploaded identity key.
+func ValidateIdentityKey(identityKey []byte) error {
+	if len(identityKey) != IdentityKeySize {
+		return ErrInvalidIdentityKey
+	}
+	return nil
+}
+
+// VerifySignedPrekey checks that the prekey was signed by the identity key.
+// Clients verify the same signature when fetching keys from the directory.
+func VerifySignedPrekey(identityKey, prekey, signature []byte) error {
+	if err := ValidateIdentityKey(identityKey); err != nil {
+		return err
+	}
+	if len(prekey) != PrekeySize {
+		return ErrInvalidPrekey
+	}
+	if len(signature) != ed25519.SignatureSize || !ed25519.Verify(identityKey, prekey, signature) {
+		return ErrInvalidPrekeySignature
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package identity
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"testing"
+)
+
+func TestVerifySignedPrekey(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prekey := make([]byte, PrekeySize)
+	if _, err := rand.Read(prekey); err != nil {
+		t.Fatal(err)
+	}
+	signature := ed25519.Sign(private, prekey)
+
+	if err := VerifySignedPrekey(public, prekey, signature); err != nil {
+		t.Fatalf("VerifySignedPrekey = %v", err)
+	}
+
+	other := append([]byte{}, prekey...)
+	other[0] ^= 1
+
+	tests := []struct {
+		name        string
+		identityKey []byte
+		prekey      []byte
+		signature   []byte
+		want        error
+	}{
+		{"short identity key", public[:16], prekey, signature, ErrInvalidIdentityKey},
+		{"short prekey", public, prekey[:16], signature, ErrInvalidPrekey},
+		{"other prekey", public, other, signature, ErrInvalidPrekeySignature},
+		{"short signature", public, prekey, signature[:32], ErrInvalidPrekeySignature},
+	}
+
+	for _, tt := range tests {
+		if err := VerifySignedPrekey(tt.identityKey, tt.prekey, tt.signature); !errors.Is(err, tt.want) {
+			t.Errorf("%s: VerifySignedPrekey = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package identity
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"golang.org/x/time/rate"
+)
+
+// RateLimit allows each signed-in user perSecond requests on average, in
+// bursts of up to burst, and answers the rest with 429. It goes after
+// Authenticate; requests without claims are limited by address instead.
+// Counts are kept in memory, so every instance limits on its own.
+func RateLimit(perSecond float64, burst int) echo.MiddlewareFunc {
+	return middleware.RateLimiterWithConfig(middleware.RateLimiterConfig{
+		Store: middleware.NewRateLimiterMemoryStoreWithConfig(middleware.RateLimiterMemoryStoreConfig{
+			Rate:      rate.Limit(perSecond),
+			Burst:     burst,
+			ExpiresIn: 10 * time.Minute,
+		}),
+		IdentifierExtractor: func(c echo.Context) (string, error) {
+			if claims, err := GetUserClaims(c); err == nil {
+				return "user:" + claims.Subject, nil
+			}
+			return "ip:" + c.RealIP(), nil
+		},
+		DenyHandler: func(c echo.Context, identifier string, err error) error {
+			return echo.NewHTTPError(http.StatusTooManyRequests, "too many requests, slow down")
+		},
+	})
+}
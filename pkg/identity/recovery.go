@@ -0,0 +1,51 @@
+package identity
+
+import (
+	c_rand "crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"strings"
+)
+
+const (
+	RecoveryCodeCount  = 10
+	recoveryCodeLength = 12 // base32 characters, 60 bits
+	recoveryGroupSize  = 4
+)
+
+// NewRecoveryCodes returns single-use codes for the user along with the
+// hashes to persist. The codes are shown once and never stored.
+func NewRecoveryCodes() (codes []string, hashes [][]byte, err error) {
+	for range RecoveryCodeCount {
+		raw := make([]byte, recoveryCodeLength*5/8+1)
+		if _, err := c_rand.Read(raw); err != nil {
+			return nil, nil, err
+		}
+
+		code := base32.StdEncoding.EncodeToString(raw)[:recoveryCodeLength]
+		codes = append(codes, formatRecoveryCode(code))
+		hashes = append(hashes, HashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+// HashRecoveryCode maps a code as typed by a user to its stored hash.
+// Grouping dashes, spaces and case are ignored.
+func HashRecoveryCode(code string) []byte {
+	code = strings.ToUpper(code)
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+
+	sum := sha256.Sum256([]byte(code))
+	return sum[:]
+}
+
+func formatRecoveryCode(code string) string {
+	var b strings.Builder
+	for i := 0; i < len(code); i += recoveryGroupSize {
+		if i > 0 {
+			b.WriteByte('-')
+		}
+		b.WriteString(code[i:min(i+recoveryGroupSize, len(code))])
+	}
+	return b.String()
+}
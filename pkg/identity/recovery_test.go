@@ -0,0 +1,45 @@
+package identity
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+)
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, hashes, err := NewRecoveryCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != RecoveryCodeCount || len(hashes) != RecoveryCodeCount {
+		t.Fatalf("got %d codes, %d hashes", len(codes), len(hashes))
+	}
+
+	format := regexp.MustCompile(`^[A-Z2-7]{4}-[A-Z2-7]{4}-[A-Z2-7]{4}$`)
+	seen := map[string]bool{}
+	for i, code := range codes {
+		if !format.MatchString(code) {
+			t.Errorf("code %q is not in groups of four", code)
+		}
+		if seen[code] {
+			t.Errorf("code %q repeated", code)
+		}
+		seen[code] = true
+
+		if !bytes.Equal(HashRecoveryCode(code), hashes[i]) {
+			t.Errorf("hash of %q does not match", code)
+		}
+	}
+}
+
+func TestHashRecoveryCodeNormalises(t *testing.T) {
+	want := HashRecoveryCode("ABCD-EFGH-IJKL")
+	for _, typed := range []string{"abcd-efgh-ijkl", "ABCDEFGHIJKL", " abcd efgh ijkl "} {
+		if !bytes.Equal(HashRecoveryCode(typed), want) {
+			t.Errorf("%q hashes differently", typed)
+		}
+	}
+	if bytes.Equal(HashRecoveryCode("ABCD-EFGH-IJKM"), want) {
+		t.Error("different codes hash the same")
+	}
+}
@@ -0,0 +1,36 @@
+package identity
+
+import (
+	c_rand "crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+)
+
+const refreshTokenLength = 32
+
+var ErrInvalidRefreshToken = errors.New("malformed refresh token")
+
+// NewRefreshToken returns an opaque token for the client along with the
+// hash to persist. Only the hash is ever stored.
+func NewRefreshToken() (token string, hash []byte, err error) {
+	raw := make([]byte, refreshTokenLength)
+	if _, err := c_rand.Read(raw); err != nil {
+		return "", nil, err
+	}
+
+	sum := sha256.Sum256(raw)
+	return base64.RawURLEncoding.EncodeToString(raw), sum[:], nil
+}
+
+// HashRefreshToken maps a token presented by a client to its stored hash.
+// The tokens are random, so an unsalted hash is enough.
+func HashRefreshToken(token string) ([]byte, error) {
+	raw, err := base64.RawURLEncoding.Strict().DecodeString(token)
+	if err != nil || len(raw) != refreshTokenLength {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	sum := sha256.Sum256(raw)
+	return sum[:], nil
+}
@@ -0,0 +1,100 @@
+package identity
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// SessionLookup reports whether a session is still active. Implementations
+// are expected to bump the session's last-seen data while they are at it.
+type SessionLookup func(ctx context.Context, sessionID, ip string) (bool, error)
+
+// maxSessionCacheEntries bounds memory use; past it expired entries are swept.
+const maxSessionCacheEntries = 10_000
+
+type sessionEntry struct {
+	active bool
+	until  time.Time
+}
+
+// SessionCache remembers session lookups for a short while so Authenticate
+// does not hit the database on every request. Revocations made through
+// MarkRevoked apply at once, those made by other instances within ttl.
+type SessionCache struct {
+	lookup SessionLookup
+	ttl    time.Duration
+
+	mu       sync.Mutex
+	entries  map[string]sessionEntry
+	onRevoke func(sessionID string)
+}
+
+func NewSessionCache(lookup SessionLookup, ttl time.Duration) *SessionCache {
+	return &SessionCache{
+		lookup:  lookup,
+		ttl:     ttl,
+		entries: make(map[string]sessionEntry),
+	}
+}
+
+func (s *SessionCache) Active(ctx context.Context, sessionID, ip string) (bool, error) {
+	if sessionID == "" {
+		return false, nil
+	}
+
+	now := time.Now()
+
+	s.mu.Lock()
+	entry, ok := s.entries[sessionID]
+	s.mu.Unlock()
+	if ok && now.Before(entry.until) {
+		return entry.active, nil
+	}
+
+	active, err := s.lookup(ctx, sessionID, ip)
+	if err != nil {
+		return false, err
+	}
+
+	s.store(sessionID, active, now)
+	return active, nil
+}
+
+// MarkRevoked makes this instance reject the session immediately.
+func (s *SessionCache) MarkRevoked(sessionID string) {
+	s.store(sessionID, false, time.Now())
+
+	s.mu.Lock()
+	onRevoke := s.onRevoke
+	s.mu.Unlock()
+	if onRevoke != nil {
+		onRevoke(sessionID)
+	}
+}
+
+// OnRevoke sets a function MarkRevoked calls with the session, so whatever
+// holds connections open for it can drop them.
+func (s *SessionCache) OnRevoke(fn func(sessionID string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onRevoke = fn
+}
+
+func (s *SessionCache) store(sessionID string, active bool, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.entries) >= maxSessionCacheEntries {
+		for id, e := range s.entries {
+			if !now.Before(e.until) {
+				delete(s.entries, id)
+			}
+		}
+	}
+	if len(s.entries) >= maxSessionCacheEntries {
+		clear(s.entries)
+	}
+
+	s.entries[sessionID] = sessionEntry{active: active, until: now.Add(s.ttl)}
+}
@@ -1,13 +1,27 @@
 package identity
 
 import (
+	c_rand "crypto/rand"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// Access tokens and login challenges share a signing key, so they are told
+// apart by audience.
+const (
+	AccessAudience    = "api.getflick.chat"
+	challengeAudience = "api.getflick.chat/mfa"
+	challengeTTL      = 5 * time.Minute
+	challengeIDLength = 16
+)
+
+var ErrInvalidChallenge = errors.New("invalid login challenge")
+
 type SecretKey []byte
 
 func (s *SecretKey) UnmarshalText(text []byte) error {
@@ -19,11 +33,23 @@ func (s *SecretKey) UnmarshalText(text []byte) error {
 }
 
 type TokenHandler struct {
-	secret SecretKey
+	secret     SecretKey
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+}
+
+func NewTokenHandler(secret []byte, accessTTL, refreshTTL time.Duration) TokenHandler {
+	return TokenHandler{secret, accessTTL, refreshTTL}
+}
+
+// AccessTTL is how long a signed access token stays valid.
+func (h *TokenHandler) AccessTTL() time.Duration {
+	return h.accessTTL
 }
 
-func NewTokenHandler(secret []byte) TokenHandler {
-	return TokenHandler{secret}
+// RefreshExpiry is the expiry for a refresh token issued now.
+func (h *TokenHandler) RefreshExpiry() time.Time {
+	return time.Now().Add(h.refreshTTL)
 }
 
 type UserClaims struct {
@@ -42,7 +68,20 @@ func (c *UserClaims) ID() int64 {
 	return id
 }
 
+// SessionID is the jti claim, naming the session the token belongs to.
+func (c *UserClaims) SessionID() string {
+	return c.RegisteredClaims.ID
+}
+
+// Sign stamps the claims with the issue time and access token expiry and
+// signs them.
 func (h *TokenHandler) Sign(claims UserClaims) (string, error) {
+	now := time.Now()
+	if claims.IssuedAt == nil {
+		claims.IssuedAt = jwt.NewNumericDate(now)
+	}
+	claims.ExpiresAt = jwt.NewNumericDate(now.Add(h.accessTTL))
+
 	token := jwt.NewWithClaims(jwt.SigningMethodHS512, claims)
 	signed, err := token.SignedString([]byte(h.secret))
 	if err != nil {
@@ -53,9 +92,11 @@ func (h *TokenHandler) Sign(claims UserClaims) (string, error) {
 }
 
 func (h *TokenHandler) Verify(token string) (*UserClaims, error) {
+	// Tokens issued before expiry was introduced carry no exp and must not
+	// live forever.
 	tok, err := jwt.ParseWithClaims(token, new(UserClaims), func(t *jwt.Token) (any, error) {
 		return []byte(h.secret), nil
-	})
+	}, jwt.WithExpirationRequired(), jwt.WithAudience(AccessAudience))
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to verify token: %w", err)
@@ -68,3 +109,56 @@ func (h *TokenHandler) Verify(token string) (*UserClaims, error) {
 
 	return claims, nil
 }
+
+// Challenge is a verified login challenge. Its ID is the jti, which the
+// caller records so the challenge can only be presented once.
+type Challenge struct {
+	ID        string
+	UserID    int64
+	ExpiresAt time.Time
+}
+
+// SignChallenge issues a short-lived token proving the password step of a
+// login succeeded. It is only good for completing the second factor.
+func (h *TokenHandler) SignChallenge(userID int64) (string, error) {
+	raw := make([]byte, challengeIDLength)
+	if _, err := c_rand.Read(raw); err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := jwt.RegisteredClaims{
+		ID:        base64.RawURLEncoding.EncodeToString(raw),
+		Subject:   strconv.FormatInt(userID, 10),
+		Issuer:    AccessAudience,
+		Audience:  jwt.ClaimStrings{challengeAudience},
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(challengeTTL)),
+	}
+
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS512, claims).SignedString([]byte(h.secret))
+	if err != nil {
+		return "", fmt.Errorf("failed to sign challenge: %w", err)
+	}
+
+	return signed, nil
+}
+
+// VerifyChallenge checks a challenge token and returns what it was issued
+// for. Challenges from before they carried a jti are refused.
+func (h *TokenHandler) VerifyChallenge(token string) (Challenge, error) {
+	var claims jwt.RegisteredClaims
+	_, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (any, error) {
+		return []byte(h.secret), nil
+	}, jwt.WithExpirationRequired(), jwt.WithAudience(challengeAudience))
+	if err != nil || claims.ID == "" {
+		return Challenge{}, ErrInvalidChallenge
+	}
+
+	id, err := strconv.ParseInt(claims.Subject, 10, 64)
+	if err != nil {
+		return Challenge{}, ErrInvalidChallenge
+	}
+
+	return Challenge{ID: claims.ID, UserID: id, ExpiresAt: claims.ExpiresAt.Time}, nil
+}
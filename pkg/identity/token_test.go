@@ -0,0 +1,126 @@
+package identity
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func testClaims(subject string) UserClaims {
+	return UserClaims{RegisteredClaims: jwt.RegisteredClaims{
+		ID:       "session",
+		Subject:  subject,
+		Audience: jwt.ClaimStrings{AccessAudience},
+	}}
+}
+
+func TestAccessToken(t *testing.T) {
+	h := NewTokenHandler([]byte("secret"), time.Minute, time.Hour)
+
+	token, err := h.Sign(testClaims("42"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := h.Verify(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.ID() != 42 || claims.SessionID() != "session" {
+		t.Errorf("claims = %d, %q", claims.ID(), claims.SessionID())
+	}
+
+	other := NewTokenHandler([]byte("other"), time.Minute, time.Hour)
+	if _, err := other.Verify(token); err == nil {
+		t.Error("token verified under another secret")
+	}
+
+	expired := NewTokenHandler([]byte("secret"), -time.Minute, time.Hour)
+	stale, err := expired.Sign(testClaims("42"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Verify(stale); err == nil {
+		t.Error("expired token verified")
+	}
+}
+
+func TestRefreshToken(t *testing.T) {
+	token, hash, err := NewRefreshToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := HashRefreshToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, hash) {
+		t.Error("presented token hashes differently from the stored hash")
+	}
+
+	again, _, err := NewRefreshToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again == token {
+		t.Error("two refresh tokens are the same")
+	}
+
+	for _, bad := range []string{"", "not base64!", token[:len(token)-2], token + "AA", token + "="} {
+		if _, err := HashRefreshToken(bad); !errors.Is(err, ErrInvalidRefreshToken) {
+			t.Errorf("HashRefreshToken(%q) = %v, want ErrInvalidRefreshToken", bad, err)
+		}
+	}
+}
+
+func TestChallenge(t *testing.T) {
+	h := NewTokenHandler([]byte("secret"), time.Minute, time.Hour)
+
+	token, err := h.SignChallenge(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	challenge, err := h.VerifyChallenge(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if challenge.UserID != 7 || challenge.ID == "" || !challenge.ExpiresAt.After(time.Now()) {
+		t.Errorf("challenge = %+v", challenge)
+	}
+
+	// Every challenge is distinct, so each can be spent once
+	again, err := h.SignChallenge(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second, _ := h.VerifyChallenge(again); second.ID == challenge.ID {
+		t.Error("two challenges share an ID")
+	}
+
+	// Neither token stands in for the other
+	if _, err := h.Verify(token); err == nil {
+		t.Error("challenge accepted as an access token")
+	}
+	access, err := h.Sign(testClaims("7"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.VerifyChallenge(access); !errors.Is(err, ErrInvalidChallenge) {
+		t.Errorf("access token as a challenge = %v, want ErrInvalidChallenge", err)
+	}
+
+	// Challenges from before they carried a jti
+	legacy, err := jwt.NewWithClaims(jwt.SigningMethodHS512, jwt.RegisteredClaims{
+		Subject:   "7",
+		Audience:  jwt.ClaimStrings{challengeAudience},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}).SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.VerifyChallenge(legacy); !errors.Is(err, ErrInvalidChallenge) {
+		t.Errorf("challenge without jti = %v, want ErrInvalidChallenge", err)
+	}
+}
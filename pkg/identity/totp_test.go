@@ -0,0 +1,81 @@
+package identity
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 seed of the RFC 6238 test vectors.
+var rfc6238Secret = []byte("12345678901234567890")
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 appendix B, cut to six digits
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		if got := totpCode(rfc6238Secret, tt.unix/totpPeriod); got != tt.want {
+			t.Errorf("code at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	current := now.Unix() / totpPeriod
+
+	tests := []struct {
+		name     string
+		code     string
+		lastStep int64
+		want     int64
+		ok       bool
+	}{
+		{"current", totpCode(rfc6238Secret, current), 0, current, true},
+		{"one step behind", totpCode(rfc6238Secret, current-1), 0, current - 1, true},
+		{"one step ahead", totpCode(rfc6238Secret, current+1), 0, current + 1, true},
+		{"two steps behind", totpCode(rfc6238Secret, current-2), 0, 0, false},
+		{"two steps ahead", totpCode(rfc6238Secret, current+2), 0, 0, false},
+		{"replayed", totpCode(rfc6238Secret, current), current, 0, false},
+		{"older than last used", totpCode(rfc6238Secret, current-1), current, 0, false},
+		{"newer than last used", totpCode(rfc6238Secret, current+1), current, current + 1, true},
+		{"short", "12345", 0, 0, false},
+		{"long", "1234567", 0, 0, false},
+		{"wrong", "000000", 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		step, ok := VerifyTOTP(rfc6238Secret, tt.code, now, tt.lastStep)
+		if step != tt.want || ok != tt.ok {
+			t.Errorf("%s: VerifyTOTP = %d, %v, want %d, %v", tt.name, step, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri, err := url.Parse(TOTPURI(rfc6238Secret, "Flick", "ada"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || uri.Path != "/Flick:ada" {
+		t.Errorf("TOTPURI = %s", uri)
+	}
+
+	query := uri.Query()
+	if got := query.Get("secret"); got != "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ" {
+		t.Errorf("secret = %s", got)
+	}
+	if query.Get("issuer") != "Flick" || query.Get("digits") != "6" || query.Get("period") != "30" {
+		t.Errorf("query = %s", uri.RawQuery)
+	}
+}
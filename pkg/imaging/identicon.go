@@ -0,0 +1,72 @@
+package imaging
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"math"
+)
+
+// Identicon draws the default avatar for a seed: a five by five grid,
+// mirrored left to right, in a colour taken from the seed's hash. The same
+// seed always draws the same picture.
+func Identicon(seed []byte, size int) image.Image {
+	sum := sha256.Sum256(seed)
+
+	// 15 bits pick the cells of the left three columns, the middle shared
+	var cells [5][5]bool
+	for i := range 15 {
+		on := sum[i/8]>>(i%8)&1 == 1
+		row, col := i/3, i%3
+		cells[row][col] = on
+		cells[row][4-col] = on
+	}
+
+	fg := hsl(float64(binary.BigEndian.Uint16(sum[2:])%360), 0.55, 0.55)
+	bg := color.RGBA{0xf0, 0xf0, 0xf0, 0xff}
+
+	// Half a cell of margin on each side makes the grid six cells wide
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := range size {
+		row := y*12/size - 1
+		for x := range size {
+			col := x*12/size - 1
+			c := bg
+			if row >= 0 && row < 10 && col >= 0 && col < 10 && cells[row/2][col/2] {
+				c = fg
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// hsl converts a hue in degrees and a saturation and lightness in [0, 1].
+func hsl(h, s, l float64) color.RGBA {
+	c := (1 - math.Abs(2*l-1)) * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := l - c/2
+
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g, b = c, x, 0
+	case h < 120:
+		r, g, b = x, c, 0
+	case h < 180:
+		r, g, b = 0, c, x
+	case h < 240:
+		r, g, b = 0, x, c
+	case h < 300:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+	return color.RGBA{
+		R: uint8(math.Round((r + m) * 255)),
+		G: uint8(math.Round((g + m) * 255)),
+		B: uint8(math.Round((b + m) * 255)),
+		A: 0xff,
+	}
+}
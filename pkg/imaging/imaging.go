@@ -0,0 +1,228 @@
+// Package imaging turns uploaded pictures into what Flick serves: it decodes
+// JPEG, PNG and WebP within a size limit, renders thumbnails the right way
+// up, strips metadata and draws the identicons new accounts start with.
+//
+// Anything re-encoded here carries pixels only, so thumbnails never keep the
+// EXIF (camera, location, time) the original had.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+)
+
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+	FormatWebP = "webp"
+)
+
+// MaxPixels bounds how large an image is decoded, since a few kilobytes of
+// compressed input can expand to gigabytes of pixels.
+const MaxPixels = 40_000_000
+
+var (
+	ErrUnsupported = errors.New("image format not supported")
+	ErrTooLarge    = errors.New("image dimensions too large")
+)
+
+// decoders are the formats uploads may use. GIF is left out on purpose:
+// animation would be flattened to its first frame.
+var decoders = map[string]struct {
+	config func(data []byte) (image.Config, error)
+	decode func(data []byte) (image.Image, error)
+}{
+	FormatJPEG: {
+		config: func(data []byte) (image.Config, error) { return jpeg.DecodeConfig(bytes.NewReader(data)) },
+		decode: func(data []byte) (image.Image, error) { return jpeg.Decode(bytes.NewReader(data)) },
+	},
+	FormatPNG: {
+		config: func(data []byte) (image.Config, error) { return png.DecodeConfig(bytes.NewReader(data)) },
+		decode: func(data []byte) (image.Image, error) { return png.Decode(bytes.NewReader(data)) },
+	},
+	FormatWebP: {
+		config: func(data []byte) (image.Config, error) { return webp.DecodeConfig(bytes.NewReader(data)) },
+		decode: func(data []byte) (image.Image, error) { return webp.Decode(bytes.NewReader(data)) },
+	},
+}
+
+// FormatOf maps a sniffed MIME type to the format that decodes it.
+func FormatOf(mimeType string) (string, bool) {
+	switch mimeType {
+	case "image/jpeg":
+		return FormatJPEG, true
+	case "image/png":
+		return FormatPNG, true
+	case "image/webp":
+		return FormatWebP, true
+	}
+	return "", false
+}
+
+// Image is a decoded picture along with the turn its pixels need to show
+// upright. The turn is applied to thumbnails, which are small, rather than
+// to the full image.
+type Image struct {
+	image.Image
+	Format      string
+	orientation int // EXIF orientation, 1 when the pixels are already upright
+}
+
+// Decode reads a JPEG, PNG or WebP image, checking its dimensions before
+// committing memory to the pixels.
+func Decode(data []byte) (*Image, error) {
+	format := sniff(data)
+	dec, ok := decoders[format]
+	if !ok {
+		return nil, ErrUnsupported
+	}
+
+	cfg, err := dec.config(data)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > MaxPixels {
+		return nil, ErrTooLarge
+	}
+
+	img, err := dec.decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	orientation := 1
+	if format == FormatJPEG {
+		orientation = jpegOrientation(data)
+	}
+	return &Image{Image: img, Format: format, orientation: orientation}, nil
+}
+
+func sniff(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte("\xff\xd8\xff")):
+		return FormatJPEG
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return FormatPNG
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return FormatWebP
+	}
+	return ""
+}
+
+// Size is the width and height the image shows at once turned upright.
+func (img *Image) Size() (width, height int) {
+	b := img.Bounds()
+	if img.orientation >= 5 {
+		return b.Dy(), b.Dx()
+	}
+	return b.Dx(), b.Dy()
+}
+
+// Thumbnail scales the image down to fit in a bound by bound square, keeping
+// its proportions. Images already that small are only turned upright.
+func (img *Image) Thumbnail(bound int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w > bound || h > bound {
+		if w >= h {
+			w, h = bound, max(1, h*bound/w)
+		} else {
+			w, h = max(1, w*bound/h), bound
+		}
+	}
+	return orient(scale(img.Image, b, w, h), img.orientation)
+}
+
+// Square crops the middle of the image to a square and scales it to size by
+// size, as avatars are shown.
+func (img *Image) Square(size int) image.Image {
+	b := img.Bounds()
+	side := min(b.Dx(), b.Dy())
+	x := b.Min.X + (b.Dx()-side)/2
+	y := b.Min.Y + (b.Dy()-side)/2
+	return orient(scale(img.Image, image.Rect(x, y, x+side, y+side), size, size), img.orientation)
+}
+
+func scale(src image.Image, from image.Rectangle, w, h int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, from, draw.Src, nil)
+	return dst
+}
+
+// orient applies an EXIF orientation: 2-4 mirror or turn the image half
+// way, 5-8 also swap its axes.
+func orient(src *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirrored
+				dx, dy = w-1-x, y
+			case 3: // upside down
+				dx, dy = w-1-x, h-1-y
+			case 4: // upside down, mirrored
+				dx, dy = x, h-1-y
+			case 5: // on its side, mirrored
+				dx, dy = y, x
+			case 6: // turned left, needs turning right
+				dx, dy = h-1-y, x
+			case 7: // on its side the other way, mirrored
+				dx, dy = h-1-y, w-1-x
+			case 8: // turned right, needs turning left
+				dx, dy = y, w-1-x
+			}
+			dst.SetRGBA(dx, dy, src.RGBAAt(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return dst
+}
+
+// Encode writes a rendered image as JPEG when it is fully opaque and PNG
+// when it has transparency to keep, returning the bytes and their MIME type.
+func Encode(img image.Image) ([]byte, string, error) {
+	var buf bytes.Buffer
+	if opaque(img) {
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85}); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "image/jpeg", nil
+	}
+
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), "image/png", nil
+}
+
+func opaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a != 0xffff {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,80 @@
+package imaging
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"testing"
+)
+
+func TestThumbnailOrientation(t *testing.T) {
+	// The red quarter starts top left and should end up in the corner the
+	// orientation turns it to.
+	tests := []struct {
+		orientation int
+		w, h        int
+		corner      func(b image.Rectangle) image.Point
+	}{
+		{1, 16, 8, func(b image.Rectangle) image.Point { return b.Min }},
+		{2, 16, 8, func(b image.Rectangle) image.Point { return image.Pt(b.Max.X-1, b.Min.Y) }},
+		{3, 16, 8, func(b image.Rectangle) image.Point { return image.Pt(b.Max.X-1, b.Max.Y-1) }},
+		{4, 16, 8, func(b image.Rectangle) image.Point { return image.Pt(b.Min.X, b.Max.Y-1) }},
+		{5, 8, 16, func(b image.Rectangle) image.Point { return b.Min }},
+		{6, 8, 16, func(b image.Rectangle) image.Point { return image.Pt(b.Max.X-1, b.Min.Y) }},
+		{7, 8, 16, func(b image.Rectangle) image.Point { return image.Pt(b.Max.X-1, b.Max.Y-1) }},
+		{8, 8, 16, func(b image.Rectangle) image.Point { return image.Pt(b.Min.X, b.Max.Y-1) }},
+	}
+
+	for _, tt := range tests {
+		img, err := Decode(withSegments(testJPEG(t, 32, 16), exifSegment(tt.orientation)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if w, h := img.Size(); w != tt.w*2 || h != tt.h*2 {
+			t.Errorf("orientation %d: Size = %dx%d, want %dx%d", tt.orientation, w, h, tt.w*2, tt.h*2)
+		}
+
+		thumb := img.Thumbnail(16)
+		b := thumb.Bounds()
+		if b.Dx() != tt.w || b.Dy() != tt.h {
+			t.Errorf("orientation %d: thumbnail is %dx%d, want %dx%d", tt.orientation, b.Dx(), b.Dy(), tt.w, tt.h)
+			continue
+		}
+
+		p := tt.corner(b)
+		if r, g, _, _ := thumb.At(p.X, p.Y).RGBA(); r < 0xc000 || g > 0x4000 {
+			t.Errorf("orientation %d: corner %v is not red", tt.orientation, p)
+		}
+	}
+}
+
+func TestDecodeRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"gif", []byte("GIF89a\x01\x00\x01\x00"), ErrUnsupported},
+		{"empty", nil, ErrUnsupported},
+		{"too large", pngHeader(100_000, 100_000), ErrTooLarge},
+	}
+
+	for _, tt := range tests {
+		if _, err := Decode(tt.data); !errors.Is(err, tt.want) {
+			t.Errorf("%s: Decode = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+// pngHeader is the start of a PNG claiming the given dimensions, enough for
+// Decode to read its size.
+func pngHeader(w, h uint32) []byte {
+	ihdr := []byte("IHDR\x00\x00\x00\x00\x00\x00\x00\x00\x08\x02\x00\x00\x00")
+	binary.BigEndian.PutUint32(ihdr[4:], w)
+	binary.BigEndian.PutUint32(ihdr[8:], h)
+
+	out := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0d")
+	out = append(out, ihdr...)
+	return binary.BigEndian.AppendUint32(out, crc32.ChecksumIEEE(ihdr))
+}
@@ -0,0 +1,234 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+var errMalformed = errors.New("malformed image")
+
+// StripMetadata drops the metadata an image file carries beside its pixels:
+// EXIF, XMP and comments in JPEG, text and time chunks in PNG, EXIF and XMP
+// in WebP. Colour profiles stay, and so does a JPEG's orientation, written
+// back as an EXIF block holding nothing else. The pixels are not re-encoded.
+func StripMetadata(data []byte, format string) ([]byte, error) {
+	switch format {
+	case FormatJPEG:
+		return stripJPEG(data)
+	case FormatPNG:
+		return stripPNG(data)
+	case FormatWebP:
+		return stripWebP(data)
+	}
+	return nil, ErrUnsupported
+}
+
+// jpegSegments calls fn with the marker and payload of each segment before
+// the image data starts, returning the offset of the start of scan segment.
+func jpegSegments(data []byte, fn func(marker byte, segment, payload []byte)) (int, error) {
+	if len(data) < 2 || data[0] != 0xff || data[1] != 0xd8 {
+		return 0, errMalformed
+	}
+
+	i := 2
+	for {
+		if i+4 > len(data) || data[i] != 0xff {
+			return 0, errMalformed
+		}
+		marker := data[i+1]
+		if marker == 0xff { // fill byte
+			i++
+			continue
+		}
+		if marker == 0xda { // start of scan, entropy-coded data follows
+			return i, nil
+		}
+
+		n := int(binary.BigEndian.Uint16(data[i+2:]))
+		if n < 2 || i+2+n > len(data) {
+			return 0, errMalformed
+		}
+		fn(marker, data[i:i+2+n], data[i+4:i+2+n])
+		i += 2 + n
+	}
+}
+
+var exifHeader = []byte("Exif\x00\x00")
+
+// jpegOrientation reads the EXIF orientation of a JPEG, 1 when it has none.
+func jpegOrientation(data []byte) int {
+	orientation := 1
+	_, _ = jpegSegments(data, func(marker byte, _, payload []byte) {
+		if marker == 0xe1 && bytes.HasPrefix(payload, exifHeader) {
+			if o := exifOrientation(payload[len(exifHeader):]); o != 0 {
+				orientation = o
+			}
+		}
+	})
+	return orientation
+}
+
+// exifOrientation finds the orientation tag in the first IFD of a TIFF
+// structure, returning 0 when it is missing or out of range.
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 0
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 0
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := range count {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 0
+		}
+		// Tag 0x0112 is a single SHORT held in the value field
+		if order.Uint16(tiff[entry:]) == 0x0112 && order.Uint16(tiff[entry+2:]) == 3 {
+			if o := int(order.Uint16(tiff[entry+8:])); o >= 1 && o <= 8 {
+				return o
+			}
+			return 0
+		}
+	}
+	return 0
+}
+
+// orientationSegment is an APP1 segment whose EXIF holds only the
+// orientation tag.
+func orientationSegment(orientation int) []byte {
+	tiff := []byte{
+		'M', 'M', 0, 42, 0, 0, 0, 8, // big endian, first IFD at offset 8
+		0, 1, // one entry
+		0x01, 0x12, 0, 3, 0, 0, 0, 1, 0, byte(orientation), 0, 0, // orientation, SHORT, count 1
+		0, 0, 0, 0, // no next IFD
+	}
+	payload := append(append([]byte{}, exifHeader...), tiff...)
+
+	segment := []byte{0xff, 0xe1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+func stripJPEG(data []byte) ([]byte, error) {
+	orientation := jpegOrientation(data)
+
+	out := make([]byte, 0, len(data))
+	out = append(out, 0xff, 0xd8)
+	wroteOrientation := orientation == 1
+
+	sos, err := jpegSegments(data, func(marker byte, segment, payload []byte) {
+		keep := true
+		switch {
+		case marker == 0xfe: // comment
+			keep = false
+		case marker >= 0xe0 && marker <= 0xef:
+			// JFIF/JFXX, ICC profiles and Adobe colour transform stay
+			keep = marker == 0xe0 ||
+				marker == 0xe2 && bytes.HasPrefix(payload, []byte("ICC_PROFILE\x00")) ||
+				marker == 0xee && bytes.HasPrefix(payload, []byte("Adobe"))
+		}
+
+		// The orientation goes after JFIF, which has to come first
+		if !wroteOrientation && marker != 0xe0 {
+			out = append(out, orientationSegment(orientation)...)
+			wroteOrientation = true
+		}
+		if keep {
+			out = append(out, segment...)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return append(out, data[sos:]...), nil
+}
+
+var pngMetadataChunks = map[string]bool{
+	"tEXt": true,
+	"zTXt": true,
+	"iTXt": true,
+	"eXIf": true,
+	"tIME": true,
+}
+
+func stripPNG(data []byte) ([]byte, error) {
+	const signature = "\x89PNG\r\n\x1a\n"
+	if !bytes.HasPrefix(data, []byte(signature)) {
+		return nil, errMalformed
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, signature...)
+
+	for i := len(signature); i < len(data); {
+		if i+12 > len(data) {
+			return nil, errMalformed
+		}
+		n := int(binary.BigEndian.Uint32(data[i:]))
+		end := i + 12 + n // length, type, data, CRC
+		if n < 0 || end > len(data) {
+			return nil, errMalformed
+		}
+
+		chunk := string(data[i+4 : i+8])
+		if !pngMetadataChunks[chunk] {
+			out = append(out, data[i:end]...)
+		}
+		i = end
+
+		if chunk == "IEND" {
+			break
+		}
+	}
+	return out, nil
+}
+
+func stripWebP(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, errMalformed
+	}
+
+	out := make([]byte, 12, len(data))
+	copy(out, data[:12])
+
+	for i := 12; i < len(data); {
+		if i+8 > len(data) {
+			return nil, errMalformed
+		}
+		n := int(binary.LittleEndian.Uint32(data[i+4:]))
+		end := i + 8 + n + n%2 // chunks are padded to an even length
+		if n < 0 || end > len(data) {
+			return nil, errMalformed
+		}
+
+		switch fourcc := string(data[i : i+4]); fourcc {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			chunk := append([]byte{}, data[i:end]...)
+			if n > 0 {
+				chunk[8] &^= 0x08 | 0x04 // EXIF and XMP present flags
+			}
+			out = append(out, chunk...)
+		default:
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	return out, nil
+}
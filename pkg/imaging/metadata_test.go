@@ -0,0 +1,216 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// testJPEG encodes a w by h picture whose top left pixel is red, so tests can
+// tell which way up a thumbnail came out.
+func testJPEG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.Set(x, y, color.White)
+		}
+	}
+	for y := range h / 2 {
+		for x := range w / 2 {
+			img.Set(x, y, color.RGBA{255, 0, 0, 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// exifSegment is an APP1 segment with a little-endian IFD holding the
+// orientation and a camera make, as phones write them.
+func exifSegment(orientation int) []byte {
+	tiff := []byte{'I', 'I', 42, 0, 8, 0, 0, 0, 2, 0}
+	entry := make([]byte, 12)
+	binary.LittleEndian.PutUint16(entry, 0x010f) // make, ASCII at offset 38
+	binary.LittleEndian.PutUint16(entry[2:], 2)
+	binary.LittleEndian.PutUint32(entry[4:], 6)
+	binary.LittleEndian.PutUint32(entry[8:], 38)
+	tiff = append(tiff, entry...)
+	entry = make([]byte, 12)
+	binary.LittleEndian.PutUint16(entry, 0x0112)
+	binary.LittleEndian.PutUint16(entry[2:], 3)
+	binary.LittleEndian.PutUint32(entry[4:], 1)
+	binary.LittleEndian.PutUint16(entry[8:], uint16(orientation))
+	tiff = append(tiff, entry...)
+	tiff = append(tiff, 0, 0, 0, 0)
+	tiff = append(tiff, "Phone\x00"...)
+
+	return appSegment(0xe1, append(append([]byte{}, exifHeader...), tiff...))
+}
+
+func appSegment(marker byte, payload []byte) []byte {
+	segment := []byte{0xff, marker, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+// withSegments inserts segments straight after the start of image marker.
+func withSegments(data []byte, segments ...[]byte) []byte {
+	out := append([]byte{}, data[:2]...)
+	for _, s := range segments {
+		out = append(out, s...)
+	}
+	return append(out, data[2:]...)
+}
+
+func TestExifOrientation(t *testing.T) {
+	for o := 1; o <= 8; o++ {
+		data := withSegments(testJPEG(t, 8, 4), exifSegment(o))
+		if got := jpegOrientation(data); got != o {
+			t.Errorf("orientation %d read as %d", o, got)
+		}
+	}
+
+	// Big endian, as orientationSegment writes it
+	data := withSegments(testJPEG(t, 8, 4), orientationSegment(6))
+	if got := jpegOrientation(data); got != 6 {
+		t.Errorf("big endian orientation read as %d", got)
+	}
+
+	tests := []struct {
+		name string
+		tiff []byte
+	}{
+		{"empty", nil},
+		{"bad byte order", []byte("XX\x00\x2a\x00\x00\x00\x08\x00\x00")},
+		{"ifd out of range", []byte("MM\x00\x2a\x00\x00\xff\xff\x00\x00")},
+		{"truncated entry", []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x01\x01\x12\x00")},
+		{"out of range value", orientationSegment(9)[4+len(exifHeader):]},
+		{"wrong type", []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x01\x01\x12\x00\x04\x00\x00\x00\x01\x00\x06\x00\x00")},
+	}
+	for _, tt := range tests {
+		if got := exifOrientation(tt.tiff); got != 0 {
+			t.Errorf("%s: exifOrientation = %d, want 0", tt.name, got)
+		}
+	}
+
+	if got := jpegOrientation(testJPEG(t, 8, 4)); got != 1 {
+		t.Errorf("JPEG without EXIF read as %d, want 1", got)
+	}
+}
+
+func TestStripJPEG(t *testing.T) {
+	icc := appSegment(0xe2, []byte("ICC_PROFILE\x00\x01\x01profile"))
+	xmp := appSegment(0xe1, []byte("http://ns.adobe.com/xap/1.0/\x00<x:xmpmeta/>"))
+	comment := appSegment(0xfe, []byte("taken at home"))
+	jfif := appSegment(0xe0, []byte("JFIF\x00\x01\x01\x00\x00\x01\x00\x01\x00\x00"))
+	src := withSegments(testJPEG(t, 8, 4), jfif, exifSegment(6), icc, xmp, comment)
+
+	out, err := StripMetadata(src, FormatJPEG)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, leaked := range []string{"Phone", "xmpmeta", "taken at home"} {
+		if bytes.Contains(out, []byte(leaked)) {
+			t.Errorf("stripped JPEG still holds %q", leaked)
+		}
+	}
+	if !bytes.Contains(out, []byte("ICC_PROFILE")) {
+		t.Error("colour profile was dropped")
+	}
+	if got := jpegOrientation(out); got != 6 {
+		t.Errorf("orientation after stripping = %d, want 6", got)
+	}
+
+	// JFIF must stay the first segment
+	if !bytes.HasPrefix(out[2:], jfif) {
+		t.Errorf("first segment is %x, want JFIF", out[2:4])
+	}
+
+	img, err := Decode(out)
+	if err != nil {
+		t.Fatalf("stripped JPEG does not decode: %v", err)
+	}
+	if w, h := img.Size(); w != 4 || h != 8 {
+		t.Errorf("Size = %dx%d, want 4x8 once turned", w, h)
+	}
+}
+
+func TestStripJPEGUpright(t *testing.T) {
+	src := withSegments(testJPEG(t, 8, 4), exifSegment(1))
+	out, err := StripMetadata(src, FormatJPEG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(out, exifHeader) {
+		t.Error("an upright JPEG kept an EXIF block")
+	}
+}
+
+func TestStripJPEGMalformed(t *testing.T) {
+	src := testJPEG(t, 8, 4)
+	for _, data := range [][]byte{nil, []byte("not a jpeg"), src[:10]} {
+		if _, err := StripMetadata(data, FormatJPEG); err == nil {
+			t.Errorf("StripMetadata(%q) succeeded", data)
+		}
+	}
+}
+
+func riffChunk(fourcc string, payload []byte) []byte {
+	chunk := append([]byte(fourcc), 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(chunk[4:], uint32(len(payload)))
+	chunk = append(chunk, payload...)
+	if len(payload)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+	return chunk
+}
+
+func testWebP(chunks ...[]byte) []byte {
+	out := []byte("RIFF\x00\x00\x00\x00WEBP")
+	for _, c := range chunks {
+		out = append(out, c...)
+	}
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	return out
+}
+
+func TestStripWebP(t *testing.T) {
+	// VP8X flags: ICC (0x20), EXIF (0x08) and XMP (0x04)
+	vp8x := riffChunk("VP8X", []byte{0x20 | 0x08 | 0x04, 0, 0, 0, 7, 0, 0, 3, 0, 0})
+	iccp := riffChunk("ICCP", []byte("profile"))
+	frame := riffChunk("VP8 ", []byte("pixels"))
+	exif := riffChunk("EXIF", []byte("Phone"))
+	xmp := riffChunk("XMP ", []byte("<x:xmpmeta/>"))
+
+	out, err := StripMetadata(testWebP(vp8x, iccp, frame, exif, xmp), FormatWebP)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantVP8X := riffChunk("VP8X", []byte{0x20, 0, 0, 0, 7, 0, 0, 3, 0, 0})
+	if want := testWebP(wantVP8X, iccp, frame); !bytes.Equal(out, want) {
+		t.Errorf("StripMetadata = %q, want %q", out, want)
+	}
+}
+
+func TestStripWebPMalformed(t *testing.T) {
+	tests := [][]byte{
+		nil,
+		[]byte("RIFF\x00\x00\x00\x00WEBQ"),
+		append(testWebP(), "VP8 \xff\x00\x00\x00"...),
+		append(testWebP(), "VP8"...),
+	}
+	for _, data := range tests {
+		if _, err := StripMetadata(data, FormatWebP); err == nil {
+			t.Errorf("StripMetadata(%q) succeeded", data)
+		}
+	}
+}
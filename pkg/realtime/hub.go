@@ -0,0 +1,168 @@
+package realtime
+
+import (
+	"errors"
+	"sync"
+)
+
+const (
+	EventMessageCreated    = "message.created"
+	EventMessageEdited     = "message.edited"
+	EventMessageDeleted    = "message.deleted"
+	EventReactionAdded     = "reaction.added"
+	EventReactionRemoved   = "reaction.removed"
+	EventTypingChanged     = "typing.changed"
+	EventReadUpdated       = "read.updated"
+	EventThreadReadUpdated = "thread.read.updated"
+)
+
+// Reasons a client's events stop, reported by Client.Err.
+var (
+	ErrClientTooSlow  = errors.New("client fell too far behind")
+	ErrSessionRevoked = errors.New("session revoked")
+	ErrHubClosed      = errors.New("hub closed")
+)
+
+// clientBuffer is how many events a connection may fall behind before the
+// hub gives up on it.
+const clientBuffer = 32
+
+type Event struct {
+	Type   string `json:"type"`
+	ChatID int64  `json:"chat_id"`
+	Data   any    `json:"data"`
+}
+
+// Client is a single live connection. A user may hold several at once,
+// one per open device or tab, each opened by one of their sessions.
+type Client struct {
+	UserID    int64
+	SessionID string
+	send      chan Event
+	once      sync.Once
+	err       error
+}
+
+// Events yields everything published to the client. The channel is closed
+// when the client is unregistered or dropped by the hub.
+func (cl *Client) Events() <-chan Event {
+	return cl.send
+}
+
+// Err reports why the hub dropped the client once Events is closed, or nil
+// if it was unregistered.
+func (cl *Client) Err() error {
+	return cl.err
+}
+
+func (cl *Client) close(err error) {
+	cl.once.Do(func() {
+		cl.err = err
+		close(cl.send)
+	})
+}
+
+// Hub fans events out to the connected clients of each user. It lives in
+// process, so events only reach clients connected to the same instance.
+type Hub struct {
+	mu      sync.RWMutex
+	clients map[int64]map[*Client]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{clients: make(map[int64]map[*Client]struct{})}
+}
+
+func (h *Hub) Register(userID int64, sessionID string) *Client {
+	cl := &Client{UserID: userID, SessionID: sessionID, send: make(chan Event, clientBuffer)}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.clients[userID] == nil {
+		h.clients[userID] = make(map[*Client]struct{})
+	}
+	h.clients[userID][cl] = struct{}{}
+
+	return cl
+}
+
+func (h *Hub) Unregister(cl *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.remove(cl, nil)
+}
+
+// DisconnectSession drops every connection opened with the session, so a
+// revoked device stops receiving events along with losing API access.
+func (h *Hub) DisconnectSession(sessionID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, set := range h.clients {
+		for cl := range set {
+			if cl.SessionID == sessionID {
+				h.remove(cl, ErrSessionRevoked)
+			}
+		}
+	}
+}
+
+// Publish delivers ev to every connection of the given users. Clients whose
+// buffer is full are dropped rather than blocking the publisher.
+func (h *Hub) Publish(ev Event, userIDs ...int64) {
+	var slow []*Client
+
+	h.mu.RLock()
+	for _, uid := range userIDs {
+		for cl := range h.clients[uid] {
+			select {
+			case cl.send <- ev:
+			default:
+				slow = append(slow, cl)
+			}
+		}
+	}
+	h.mu.RUnlock()
+
+	if len(slow) == 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, cl := range slow {
+		h.remove(cl, ErrClientTooSlow)
+	}
+}
+
+// Close disconnects every client, used on server shutdown.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, set := range h.clients {
+		for cl := range set {
+			h.remove(cl, ErrHubClosed)
+		}
+	}
+}
+
+// remove must be called with mu held. err is what the client's Err will
+// report.
+func (h *Hub) remove(cl *Client, err error) {
+	set, ok := h.clients[cl.UserID]
+	if !ok {
+		return
+	}
+	if _, ok := set[cl]; !ok {
+		return
+	}
+
+	delete(set, cl)
+	if len(set) == 0 {
+		delete(h.clients, cl.UserID)
+	}
+	cl.close(err)
+}
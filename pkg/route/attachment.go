@@ -0,0 +1,522 @@
+package route
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // registered for image.DecodeConfig
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/astrokkidd/flick/pkg/blob"
+	"github.com/astrokkidd/flick/pkg/crypto"
+	"github.com/astrokkidd/flick/pkg/database"
+	"github.com/astrokkidd/flick/pkg/identity"
+	"github.com/astrokkidd/flick/pkg/imaging"
+	"github.com/astrokkidd/flick/pkg/store"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+type Attachment struct {
+	store        store.Store
+	tokenHandler *identity.TokenHandler
+	cipher       crypto.Cipher
+	blobs        blob.Store
+	maxSize      int64 // bytes per file
+	quota        int64 // bytes per uploader, across every chat
+}
+
+// AttachmentResponse describes an uploaded file. URL downloads it for
+// participants of the chat, and ThumbnailURL a small rendering of images.
+type AttachmentResponse struct {
+	AttachmentID int64  `json:"attachment_id"`
+	FileName     string `json:"file_name"`
+	MimeType     string `json:"mime_type"`
+	Size         int64  `json:"size"`
+	SHA256       string `json:"sha256"`
+	Width        *int32 `json:"width,omitempty"` // images only
+	Height       *int32 `json:"height,omitempty"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url,omitempty"`
+}
+
+const (
+	maxMessageAttachments = 10
+	maxFileNameBytes      = 255
+
+	// Longer side of an image attachment's thumbnail
+	thumbnailSize = 320
+
+	// End-to-end chats upload files the client already sealed, so there is
+	// nothing to sniff
+	endToEndMimeType = "application/octet-stream"
+)
+
+// inlineMimeTypes are shown in the browser; everything else downloads.
+var inlineMimeTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+func NewAttachmentHandler(store store.Store, tokenHandler *identity.TokenHandler, cipher crypto.Cipher, blobs blob.Store, maxSize, quota int64) Attachment {
+	return Attachment{store, tokenHandler, cipher, blobs, maxSize, quota}
+}
+
+func newAttachmentResponse(a database.Attachment) AttachmentResponse {
+	url := fmt.Sprintf("/v1/chats/%d/attachments/%d", a.ChatID, a.AttachmentID)
+	res := AttachmentResponse{
+		AttachmentID: a.AttachmentID,
+		FileName:     a.FileName,
+		MimeType:     a.MimeType,
+		Size:         a.SizeBytes,
+		SHA256:       hex.EncodeToString(a.Sha256),
+		Width:        a.Width,
+		Height:       a.Height,
+		URL:          url,
+	}
+	if a.ThumbnailKey != nil {
+		res.ThumbnailURL = url + "/thumbnail"
+	}
+	return res
+}
+
+func attachmentBlobKey(chatID, attachmentID int64) string {
+	return fmt.Sprintf("attachments/%d/%d", chatID, attachmentID)
+}
+
+func attachmentThumbnailKey(chatID, attachmentID int64) string {
+	return fmt.Sprintf("attachments/%d/%d-thumbnail", chatID, attachmentID)
+}
+
+// cleanFileName keeps the name a client gave a file presentable: no
+// directories or control characters, and short enough to store.
+func cleanFileName(name string) string {
+	name = strings.ToValidUTF8(name, "")
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name))
+
+	for len(name) > maxFileNameBytes {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+
+	if name == "" || name == "." || name == ".." {
+		return "file"
+	}
+	return name
+}
+
+// preparedFile is an upload ready to seal and store.
+type preparedFile struct {
+	data          []byte
+	mimeType      string
+	width, height *int32 // images only
+	thumbnail     []byte // JPEG, PNG and WebP images only
+}
+
+// prepareFile sniffs what a file is from its contents rather than trusting
+// the client. JPEG, PNG and WebP images also lose their metadata, so what is
+// stored and shared carries no camera details or location, and get a
+// thumbnail. Other images are only measured.
+func prepareFile(data []byte) (preparedFile, error) {
+	mimeType, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+	file := preparedFile{data: data, mimeType: mimeType}
+
+	format, ok := imaging.FormatOf(mimeType)
+	if !ok {
+		if strings.HasPrefix(mimeType, "image/") {
+			file.width, file.height = measureImage(data)
+		}
+		return file, nil
+	}
+
+	stripped, err := imaging.StripMetadata(data, format)
+	if err != nil {
+		return file, echo.NewHTTPError(http.StatusBadRequest, "image could not be read").SetInternal(err)
+	}
+	file.data = stripped
+
+	img, err := imaging.Decode(stripped)
+	if errors.Is(err, imaging.ErrTooLarge) {
+		// Still shared, just too many pixels to render a thumbnail from
+		file.width, file.height = measureImage(stripped)
+		return file, nil
+	}
+	if err != nil {
+		return file, echo.NewHTTPError(http.StatusBadRequest, "image could not be read").SetInternal(err)
+	}
+
+	w, h := img.Size()
+	width, height := int32(w), int32(h)
+	file.width, file.height = &width, &height
+
+	file.thumbnail, _, err = imaging.Encode(img.Thumbnail(thumbnailSize))
+	if err != nil {
+		return file, echo.NewHTTPError(http.StatusInternalServerError, "could not render thumbnail").SetInternal(err)
+	}
+	return file, nil
+}
+
+func measureImage(data []byte) (width, height *int32) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil
+	}
+	w, h := int32(cfg.Width), int32(cfg.Height)
+	return &w, &h
+}
+
+// readUpload reads the multipart "file" field, turning away anything over
+// the size limit without reading the rest of it.
+func readUpload(c echo.Context, maxSize int64) (string, []byte, error) {
+	reader, err := c.Request().MultipartReader()
+	if err != nil {
+		return "", nil, echo.NewHTTPError(http.StatusBadRequest, "expected a multipart form").SetInternal(err)
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return "", nil, echo.NewHTTPError(http.StatusBadRequest, "missing file")
+		}
+		if err != nil {
+			return "", nil, echo.NewHTTPError(http.StatusBadRequest, "invalid multipart form").SetInternal(err)
+		}
+		if part.FormName() != "file" {
+			part.Close()
+			continue
+		}
+		defer part.Close()
+
+		data, err := io.ReadAll(io.LimitReader(part, maxSize+1))
+		if err != nil {
+			return "", nil, echo.NewHTTPError(http.StatusBadRequest, "could not read file").SetInternal(err)
+		}
+		if int64(len(data)) > maxSize {
+			return "", nil, echo.NewHTTPError(http.StatusRequestEntityTooLarge, fmt.Sprintf("files are limited to %d bytes", maxSize))
+		}
+		if len(data) == 0 {
+			return "", nil, echo.NewHTTPError(http.StatusBadRequest, "file is empty")
+		}
+
+		return cleanFileName(part.FileName()), data, nil
+	}
+}
+
+// discard deletes the blobs of an upload that was not recorded.
+func (attachment *Attachment) discard(c echo.Context, keys ...string) {
+	for _, key := range keys {
+		if err := attachment.blobs.Delete(c.Request().Context(), key); err != nil {
+			c.Logger().Errorf("orphaned attachment blob %s: %v", key, err)
+		}
+	}
+}
+
+// Upload stores a file in the chat's blob space, sealed with the chat's data
+// key, and returns it pending: only the uploader sees it until a message
+// they send lists it in attachment_ids. In end-to-end chats the client seals
+// the file itself and it is kept as uploaded.
+func (attachment *Attachment) Upload(c echo.Context) error {
+	claims, err := identity.GetUserClaims(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+	uid := claims.ID()
+
+	var body struct {
+		ChatID int64 `param:"id"`
+	}
+	// Path only: Bind would parse the whole multipart body into memory
+	if err := (&echo.DefaultBinder{}).BindPathParams(c, &body); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid input").SetInternal(err)
+	}
+
+	fileName, data, err := readUpload(c, attachment.maxSize)
+	if err != nil {
+		return err
+	}
+
+	var (
+		attachmentID int64
+		key          crypto.DataKey // nil in end-to-end chats
+	)
+
+	//-- Begin tx: take an id and the key to seal under it --//
+	ctx := c.Request().Context()
+	if err := withTx(ctx, attachment.store, func(qtx database.Querier) error {
+		if _, err := requireChatPermission(ctx, qtx, body.ChatID, uid, permSendMessages); err != nil {
+			return err
+		}
+
+		endToEnd, err := qtx.IsChatEndToEnd(ctx, database.IsChatEndToEndParams{ChatID: body.ChatID})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "chat query failed")
+		}
+
+		// The id is part of the associated data, so it is taken before sealing
+		attachmentID, err = qtx.NextAttachmentID(ctx)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "attachment creation failed").SetInternal(err)
+		}
+
+		if endToEnd {
+			return nil
+		}
+
+		key, err = chatDataKey(ctx, qtx, attachment.cipher, body.ChatID, true)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "chat key unavailable").SetInternal(err)
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	//-- Process and seal the file outside any transaction, it can take a while --//
+	file := preparedFile{data: data, mimeType: endToEndMimeType}
+	sealed := data
+	var thumbnail []byte
+
+	if key != nil {
+		file, err = prepareFile(data)
+		if err != nil {
+			return err
+		}
+
+		sealed, err = key.Encrypt(file.data, crypto.AttachmentAAD(body.ChatID, uid, attachmentID))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "encryption failed")
+		}
+		if file.thumbnail != nil {
+			thumbnail, err = key.Encrypt(file.thumbnail, crypto.AttachmentThumbnailAAD(body.ChatID, uid, attachmentID))
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "encryption failed")
+			}
+		}
+	}
+
+	sum := sha256.Sum256(file.data)
+	size := int64(len(file.data))
+
+	blobKey := attachmentBlobKey(body.ChatID, attachmentID)
+	if err := attachment.blobs.Put(ctx, blobKey, sealed); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "could not store file").SetInternal(err)
+	}
+	stored := []string{blobKey}
+
+	var thumbnailKey *string
+	if thumbnail != nil {
+		k := attachmentThumbnailKey(body.ChatID, attachmentID)
+		thumbnailKey = &k
+		if err := attachment.blobs.Put(ctx, *thumbnailKey, thumbnail); err != nil {
+			attachment.discard(c, stored...)
+			return echo.NewHTTPError(http.StatusInternalServerError, "could not store file").SetInternal(err)
+		}
+		stored = append(stored, *thumbnailKey)
+	}
+
+	var created database.Attachment
+
+	//-- Begin tx: record it against the uploader's quota --//
+	if err := withTx(ctx, attachment.store, func(qtx database.Querier) error {
+		// They may have left the chat while the file was uploading
+		if _, err := requireChatPermission(ctx, qtx, body.ChatID, uid, permSendMessages); err != nil {
+			return err
+		}
+
+		// Two uploads racing on Postgres can both pass this, overshooting the
+		// quota by at most one file each
+		used, err := qtx.GetAttachmentUsage(ctx, database.GetAttachmentUsageParams{UploaderID: uid})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "quota query failed").SetInternal(err)
+		}
+		if used+size > attachment.quota {
+			return echo.NewHTTPError(http.StatusForbidden, "attachment storage quota exceeded")
+		}
+
+		err = qtx.CreateAttachment(ctx, database.CreateAttachmentParams{
+			AttachmentID: attachmentID,
+			ChatID:       body.ChatID,
+			UploaderID:   uid,
+			BlobKey:      blobKey,
+			FileName:     fileName,
+			MimeType:     file.mimeType,
+			SizeBytes:    size,
+			Sha256:       sum[:],
+			Width:        file.width,
+			Height:       file.height,
+			ThumbnailKey: thumbnailKey,
+		})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "attachment creation failed").SetInternal(err)
+		}
+
+		created, err = qtx.GetAttachment(ctx, database.GetAttachmentParams{ChatID: body.ChatID, AttachmentID: attachmentID})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "attachment query failed").SetInternal(err)
+		}
+
+		return nil
+	}); err != nil {
+		attachment.discard(c, stored...)
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, newAttachmentResponse(created))
+}
+
+// openAttachment loads an attachment the caller may see, along with the key
+// its blobs are sealed under: nil in end-to-end chats. Until it is sent with
+// a message only its uploader can see it.
+func (attachment *Attachment) openAttachment(c echo.Context) (database.Attachment, crypto.DataKey, error) {
+	claims, err := identity.GetUserClaims(c)
+	if err != nil {
+		return database.Attachment{}, nil, echo.NewHTTPError(http.StatusUnauthorized)
+	}
+	uid := claims.ID()
+
+	var body struct {
+		ChatID       int64 `param:"id"`
+		AttachmentID int64 `param:"attachment_id"`
+	}
+	if err := c.Bind(&body); err != nil {
+		return database.Attachment{}, nil, echo.NewHTTPError(http.StatusBadRequest, "invalid input").SetInternal(err)
+	}
+
+	var (
+		file database.Attachment
+		key  crypto.DataKey
+	)
+
+	//-- Begin tx --//
+	ctx := c.Request().Context()
+	err = withTx(ctx, attachment.store, func(qtx database.Querier) error {
+		isParticipant, err := qtx.IsUserInChat(ctx, database.IsUserInChatParams{ChatID: body.ChatID, UserID: uid})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "could not verify participant").SetInternal(err)
+		}
+		if !isParticipant {
+			return echo.NewHTTPError(http.StatusForbidden, "not a participant in this chat")
+		}
+
+		file, err = qtx.GetAttachment(ctx, database.GetAttachmentParams{ChatID: body.ChatID, AttachmentID: body.AttachmentID})
+		if errors.Is(err, pgx.ErrNoRows) || (err == nil && file.MessageID == nil && file.UploaderID != uid) {
+			return echo.NewHTTPError(http.StatusNotFound, "attachment not found")
+		}
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "attachment query failed").SetInternal(err)
+		}
+
+		endToEnd, err := qtx.IsChatEndToEnd(ctx, database.IsChatEndToEndParams{ChatID: body.ChatID})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "chat query failed")
+		}
+		if endToEnd {
+			return nil
+		}
+
+		key, err = chatDataKey(ctx, qtx, attachment.cipher, body.ChatID, false)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "chat key unavailable").SetInternal(err)
+		}
+
+		return nil
+	})
+	return file, key, err
+}
+
+// readBlob answers 304 when the client's copy is current, otherwise loads
+// and opens one of an attachment's blobs. It returns nil data once the
+// response is written.
+func (attachment *Attachment) readBlob(c echo.Context, etag, blobKey string, key crypto.DataKey, aad []byte) ([]byte, error) {
+	// Contents never change under an id, so the digest is a strong validator
+	header := c.Response().Header()
+	header.Set("ETag", etag)
+	header.Set("Cache-Control", "private, max-age=31536000, immutable")
+	if c.Request().Header.Get("If-None-Match") == etag {
+		return nil, c.NoContent(http.StatusNotModified)
+	}
+
+	data, err := attachment.blobs.Get(c.Request().Context(), blobKey)
+	if errors.Is(err, blob.ErrNotFound) {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "attachment not found")
+	}
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "could not load file").SetInternal(err)
+	}
+
+	if key != nil {
+		data, err = key.Decrypt(data, aad)
+		if err != nil {
+			return nil, echo.NewHTTPError(http.StatusInternalServerError, "decryption failed")
+		}
+	}
+
+	// Uploads are user content served from the API's own origin: never let
+	// the browser guess a type or run anything in them
+	header.Set(echo.HeaderXContentTypeOptions, "nosniff")
+	header.Set(echo.HeaderContentSecurityPolicy, "default-src 'none'; sandbox")
+
+	return data, nil
+}
+
+// Download serves an attachment to participants of its chat.
+func (attachment *Attachment) Download(c echo.Context) error {
+	file, key, err := attachment.openAttachment(c)
+	if err != nil {
+		return err
+	}
+
+	etag := `"` + hex.EncodeToString(file.Sha256) + `"`
+	data, err := attachment.readBlob(c, etag, file.BlobKey, key, crypto.AttachmentAAD(file.ChatID, file.UploaderID, file.AttachmentID))
+	if err != nil || data == nil {
+		return err
+	}
+
+	disposition := "attachment"
+	if inlineMimeTypes[file.MimeType] {
+		disposition = "inline"
+	}
+	c.Response().Header().Set(echo.HeaderContentDisposition, mime.FormatMediaType(disposition, map[string]string{"filename": file.FileName}))
+
+	return c.Blob(http.StatusOK, file.MimeType, data)
+}
+
+// Thumbnail serves the small rendering of an image attachment, for showing
+// it in the chat history before anyone opens the full file.
+func (attachment *Attachment) Thumbnail(c echo.Context) error {
+	file, key, err := attachment.openAttachment(c)
+	if err != nil {
+		return err
+	}
+	if file.ThumbnailKey == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "attachment has no thumbnail")
+	}
+
+	etag := `"` + hex.EncodeToString(file.Sha256) + `-thumbnail"`
+	data, err := attachment.readBlob(c, etag, *file.ThumbnailKey, key, crypto.AttachmentThumbnailAAD(file.ChatID, file.UploaderID, file.AttachmentID))
+	if err != nil || data == nil {
+		return err
+	}
+
+	// Rendered here, so sniffing tells JPEG from PNG reliably
+	return c.Blob(http.StatusOK, http.DetectContentType(data), data)
+}
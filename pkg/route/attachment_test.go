@@ -0,0 +1,224 @@
+package route
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io/fs"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+// multipartBody is a request body holding a file in the "file" field.
+type multipartBody struct {
+	buf         *bytes.Buffer
+	contentType string
+}
+
+func fileUpload(t *testing.T, name string, data []byte) *multipartBody {
+	t.Helper()
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	part, err := w.CreateFormFile("file", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(data)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &multipartBody{&buf, w.FormDataContentType()}
+}
+
+// photo is a JPEG carrying a comment, as cameras leave behind.
+func photo(t *testing.T) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 640, 480))
+	for y := range 480 {
+		for x := range 640 {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	comment := []byte("taken at 51.5N 0.1W")
+	segment := append([]byte{0xff, 0xfe, 0, byte(len(comment) + 2)}, comment...)
+	data := buf.Bytes()
+	return append(append(append([]byte{}, data[:2]...), segment...), data[2:]...)
+}
+
+// blobCount counts the files the blob store holds.
+func (s *testServer) blobCount() int {
+	s.t.Helper()
+	n := 0
+	err := filepath.WalkDir(s.blobDir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			n++
+		}
+		return err
+	})
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return n
+}
+
+func TestAttachments(t *testing.T) {
+	s := newTestServer(t)
+	ada, bob, cy := s.register("ada"), s.register("bob"), s.register("cy")
+	s.befriend(ada, bob)
+	chatID := s.group(ada, bob)
+	base := fmt.Sprintf("/v1/chats/%d/attachments", chatID)
+
+	var uploaded AttachmentResponse
+	s.expect(http.StatusCreated, http.MethodPost, base, ada.AccessToken, fileUpload(t, "../holiday.jpg", photo(t)), &uploaded)
+	if uploaded.FileName != "holiday.jpg" || uploaded.MimeType != "image/jpeg" {
+		t.Errorf("uploaded = %+v", uploaded)
+	}
+	if uploaded.Width == nil || *uploaded.Width != 640 || uploaded.Height == nil || *uploaded.Height != 480 {
+		t.Errorf("dimensions = %v x %v, want 640 x 480", uploaded.Width, uploaded.Height)
+	}
+	file := fmt.Sprintf("%s/%d", base, uploaded.AttachmentID)
+
+	// Pending uploads are the uploader's alone
+	rec := s.do(http.MethodGet, file, ada.AccessToken, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("download = %d %s", rec.Code, rec.Body)
+	}
+	if bytes.Contains(rec.Body.Bytes(), []byte("51.5N")) {
+		t.Error("downloaded photo still carries its comment")
+	}
+	sum := sha256.Sum256(rec.Body.Bytes())
+	if got := hex.EncodeToString(sum[:]); got != uploaded.SHA256 {
+		t.Errorf("downloaded digest %s, want %s", got, uploaded.SHA256)
+	}
+	if rec := s.do(http.MethodGet, file, bob.AccessToken, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("another member's pending upload = %d, want 404", rec.Code)
+	}
+
+	var sent int64
+	s.expect(http.StatusCreated, http.MethodPost, fmt.Sprintf("/v1/chats/%d/messages", chatID), ada.AccessToken, map[string]any{
+		"content":        "look",
+		"attachment_ids": []int64{uploaded.AttachmentID},
+	}, &sent)
+
+	// Once sent, every member can fetch it, and nobody else
+	s.expect(http.StatusOK, http.MethodGet, file, bob.AccessToken, nil, nil)
+	if rec := s.do(http.MethodGet, file, cy.AccessToken, nil); rec.Code != http.StatusForbidden {
+		t.Errorf("download by a non-member = %d, want 403", rec.Code)
+	}
+
+	thumb := s.do(http.MethodGet, file+"/thumbnail", bob.AccessToken, nil)
+	if thumb.Code != http.StatusOK {
+		t.Fatalf("thumbnail = %d %s", thumb.Code, thumb.Body)
+	}
+	cfg, _, err := image.DecodeConfig(thumb.Body)
+	if err != nil || cfg.Width > thumbnailSize || cfg.Height > thumbnailSize {
+		t.Errorf("thumbnail is %dx%d, %v", cfg.Width, cfg.Height, err)
+	}
+
+	// Contents never change, so a client's cached copy stays good
+	req := httptest.NewRequest(http.MethodGet, file, nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+bob.AccessToken)
+	req.Header.Set("If-None-Match", rec.Header().Get("ETag"))
+	cached := httptest.NewRecorder()
+	s.e.ServeHTTP(cached, req)
+	if cached.Code != http.StatusNotModified {
+		t.Errorf("conditional download = %d, want 304", cached.Code)
+	}
+
+	// Deleting the message for everyone takes its files and thumbnails with it
+	s.expect(http.StatusNoContent, http.MethodDelete, fmt.Sprintf("/v1/chats/%d/messages/%d?scope=everyone", chatID, sent), ada.AccessToken, nil, nil)
+	if rec := s.do(http.MethodGet, file, ada.AccessToken, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("download after deletion = %d, want 404", rec.Code)
+	}
+	if n := s.blobCount(); n != 0 {
+		t.Errorf("%d blobs left after deletion, want 0", n)
+	}
+}
+
+func TestAttachmentLimits(t *testing.T) {
+	s := newTestServer(t)
+	ada, bob := s.register("ada"), s.register("bob")
+	s.befriend(ada, bob)
+	chatID := s.group(ada, bob)
+	base := fmt.Sprintf("/v1/chats/%d/attachments", chatID)
+
+	var doc AttachmentResponse
+	s.expect(http.StatusCreated, http.MethodPost, base, ada.AccessToken, fileUpload(t, "notes.txt", []byte("plain notes\n")), &doc)
+	if doc.MimeType != "text/plain" || doc.ThumbnailURL != "" {
+		t.Errorf("text upload = %+v", doc)
+	}
+	if rec := s.do(http.MethodGet, fmt.Sprintf("%s/%d/thumbnail", base, doc.AttachmentID), ada.AccessToken, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("thumbnail of a text file = %d, want 404", rec.Code)
+	}
+
+	tests := []struct {
+		name   string
+		body   *multipartBody
+		status int
+	}{
+		{"too large", fileUpload(t, "big.bin", make([]byte, 1<<20+1)), http.StatusRequestEntityTooLarge},
+		{"empty", fileUpload(t, "empty.txt", nil), http.StatusBadRequest},
+		{"broken image", fileUpload(t, "broken.jpg", []byte("\xff\xd8\xff\xe0broken")), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if rec := s.do(http.MethodPost, base, ada.AccessToken, tt.body); rec.Code != tt.status {
+			t.Errorf("%s: upload = %d %s, want %d", tt.name, rec.Code, rec.Body, tt.status)
+		}
+	}
+
+	// 4 MiB quota, 1 MiB files
+	chunk := bytes.Repeat([]byte("x"), 1<<20)
+	for i := range 3 {
+		s.expect(http.StatusCreated, http.MethodPost, base, ada.AccessToken, fileUpload(t, fmt.Sprintf("%d.bin", i), chunk), nil)
+	}
+	if rec := s.do(http.MethodPost, base, ada.AccessToken, fileUpload(t, "over.bin", chunk)); rec.Code != http.StatusForbidden {
+		t.Errorf("upload over quota = %d, want 403", rec.Code)
+	}
+}
+
+func TestLeaveChatRemovesAttachments(t *testing.T) {
+	s := newTestServer(t)
+	ada, bob := s.register("ada"), s.register("bob")
+	s.befriend(ada, bob)
+	chatID := s.group(ada, bob)
+	base := fmt.Sprintf("/v1/chats/%d/attachments", chatID)
+
+	var sent, pending AttachmentResponse
+	s.expect(http.StatusCreated, http.MethodPost, base, ada.AccessToken, fileUpload(t, "holiday.jpg", photo(t)), &sent)
+	s.expect(http.StatusCreated, http.MethodPost, fmt.Sprintf("/v1/chats/%d/messages", chatID), ada.AccessToken, map[string]any{
+		"content":        "look",
+		"attachment_ids": []int64{sent.AttachmentID},
+	}, nil)
+	s.expect(http.StatusCreated, http.MethodPost, base, bob.AccessToken, fileUpload(t, "notes.txt", []byte("never sent\n")), &pending)
+	// The photo, its thumbnail and the notes
+	stored := s.blobCount()
+	if stored != 3 {
+		t.Fatalf("%d blobs after uploading, want 3", stored)
+	}
+
+	// The chat outlives all but its last member
+	s.expect(http.StatusNoContent, http.MethodPost, fmt.Sprintf("/v1/chats/%d/leave", chatID), ada.AccessToken, nil, nil)
+	if n := s.blobCount(); n != stored {
+		t.Errorf("%d blobs after the first member left, want %d", n, stored)
+	}
+
+	// Sent or still pending, every file goes with the chat
+	s.expect(http.StatusNoContent, http.MethodPost, fmt.Sprintf("/v1/chats/%d/leave", chatID), bob.AccessToken, nil, nil)
+	if n := s.blobCount(); n != 0 {
+		t.Errorf("%d blobs after the last member left, want 0", n)
+	}
+}
@@ -3,27 +3,41 @@ package route
 import (
 	"context"
 	"database/sql"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"net/http"
-	"net/url"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/astrokkidd/flick/pkg/crypto"
 	"github.com/astrokkidd/flick/pkg/database"
 	"github.com/astrokkidd/flick/pkg/identity"
+	"github.com/astrokkidd/flick/pkg/store"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/labstack/echo/v4"
 )
 
 type Auth struct {
-	queries      *database.Queries
+	store        store.Store
 	tokenHandler *identity.TokenHandler
+	sessions     *identity.SessionCache
+	passwords    *identity.PasswordPolicy
+	hashing      identity.Argon2Params
+	cipher       crypto.Cipher
 }
 
-func NewAuthHandler(queries *database.Queries, tokenHandler *identity.TokenHandler) Auth {
-	return Auth{queries, tokenHandler}
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"` // seconds until access_token expires
+}
+
+func NewAuthHandler(store store.Store, tokenHandler *identity.TokenHandler, sessions *identity.SessionCache, passwords *identity.PasswordPolicy, hashing identity.Argon2Params, cipher crypto.Cipher) Auth {
+	return Auth{store, tokenHandler, sessions, passwords, hashing, cipher}
 }
 
 func (auth *Auth) Login(c echo.Context) error {
@@ -48,7 +62,7 @@ func (auth *Auth) Login(c echo.Context) error {
 	defer cancel()
 
 	// Look up user by display name
-	user, err := auth.queries.FindUserByDisplayName(ctx, database.FindUserByDisplayNameParams{
+	user, err := auth.store.FindUserByDisplayName(ctx, database.FindUserByDisplayNameParams{
 		DisplayName: form.DisplayName,
 	})
 	if err != nil {
@@ -68,27 +82,41 @@ func (auth *Auth) Login(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusUnauthorized, "invalid credentials")
 	}
 
-	token, err := auth.tokenHandler.Sign(identity.UserClaims{
-		FirstName:       user.FirstName,
-		LastName:        user.LastName,
-		ProfileImageURL: derefString(user.PfpUrl),
-		RegisteredClaims: jwt.RegisteredClaims{
-			Subject:  fmt.Sprint(user.UserID),
-			Issuer:   "api.getflick.chat",
-			Audience: jwt.ClaimStrings{"api.getflick.chat"},
-		},
-	})
-	if err != nil {
-		c.Logger().Errorf("token sign error: %v", err)
-		return echo.NewHTTPError(http.StatusInternalServerError, "internal server error")
+	// The plaintext is only in hand now, so this is the moment to upgrade
+	// hashes made with older settings or a legacy algorithm.
+	if identity.NeedsRehash(user.PasswordHash, auth.hashing) {
+		auth.rehashPassword(ctx, c, user.UserID, form.Password, user.PasswordHash)
 	}
 
-	return c.JSON(http.StatusOK, map[string]any{
-		"access_token": token,
-		"user_id":      user.UserID,
-		"display_name": form.DisplayName,
-		"pfp_url":      user.PfpUrl,
-	})
+	// With two-factor on, the password only earns a challenge to present
+	// alongside a code at /auth/login/totp.
+	if user.TotpEnabled {
+		challenge, err := auth.tokenHandler.SignChallenge(user.UserID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "internal server error").SetInternal(err)
+		}
+		return c.JSON(http.StatusOK, map[string]any{
+			"mfa_required": true,
+			"mfa_token":    challenge,
+		})
+	}
+
+	var tokens TokenResponse
+
+	//-- Begin tx --//
+	if err := withTx(ctx, auth.store, func(qtx database.Querier) error {
+		tokens, err = auth.openSession(ctx, qtx, c, newUserClaims(user.UserID, user.FirstName, user.LastName, derefString(user.PfpUrl)))
+		if err != nil {
+			c.Logger().Errorf("login session error: %v", err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "internal server error")
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, loginResponse(tokens, user.UserID, form.DisplayName, user.PfpUrl))
 }
 
 func (auth *Auth) Register(c echo.Context) error {
@@ -111,54 +139,354 @@ func (auth *Auth) Register(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "all required fields must be provided")
 	}
 
-	defaultPfp := fmt.Sprintf("https://api.dicebear.com/7.x/notionists-neutral/png?seed=%s", url.QueryEscape(form.DisplayName))
+	if err := checkPassword(auth.passwords, form.Password, form.DisplayName); err != nil {
+		return err
+	}
+
+	// user_key optionally publishes the identity key for end-to-end chats up
+	// front; the signed prekey follows through PUT /keys.
+	var identityKey []byte
+	if form.UserKey != "" {
+		key, err := base64.StdEncoding.DecodeString(form.UserKey)
+		if err != nil || identity.ValidateIdentityKey(key) != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "user_key must be a base64 Ed25519 public key")
+		}
+		identityKey = key
+	}
+
+	defaultPfp := identiconURL(form.DisplayName)
 
 	password := identity.Password(form.Password)
-	hash, err := password.GenerateHash()
+	hash, err := password.GenerateHash(auth.hashing)
 	if err != nil {
 		panic(err)
 	}
 
-	user, err := auth.queries.CreateUser(c.Request().Context(), database.CreateUserParams{
-		DisplayName:  form.DisplayName,
-		FirstName:    form.FirstName,
-		LastName:     form.LastName,
-		PasswordHash: hash,
-		PfpUrl:       &defaultPfp,
-	})
+	var (
+		user   database.CreateUserRow
+		tokens TokenResponse
+	)
+
+	//-- Begin tx --//
+	ctx := c.Request().Context()
+	if err := withTx(ctx, auth.store, func(qtx database.Querier) error {
+		user, err = qtx.CreateUser(ctx, database.CreateUserParams{
+			DisplayName:  form.DisplayName,
+			FirstName:    form.FirstName,
+			LastName:     form.LastName,
+			PasswordHash: hash,
+			PfpUrl:       &defaultPfp,
+		})
+		if err != nil {
+			if strings.Contains(err.Error(), "duplicate key") {
+				return echo.NewHTTPError(http.StatusConflict, "username already exists")
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "could not create user").SetInternal(err)
+		}
+
+		if identityKey != nil {
+			err := qtx.UpsertIdentityKey(ctx, database.UpsertIdentityKeyParams{UserID: user.UserID, IdentityKey: identityKey})
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "could not store user key").SetInternal(err)
+			}
+		}
+
+		tokens, err = auth.openSession(ctx, qtx, c, newUserClaims(user.UserID, form.FirstName, form.LastName, defaultPfp))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "could not create session").SetInternal(err)
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, loginResponse(tokens, user.UserID, user.DisplayName, user.PfpUrl))
+}
+
+// Refresh trades a refresh token for a new access token and rotates the
+// refresh token. Presenting a token that was already rotated means it
+// leaked, so the whole family is revoked.
+func (auth *Auth) Refresh(c echo.Context) error {
+	var body struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := c.Bind(&body); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	hash, err := identity.HashRefreshToken(body.RefreshToken)
 	if err != nil {
-		if strings.Contains(err.Error(), "duplicate key") {
-			return echo.NewHTTPError(http.StatusConflict, "username already exists")
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid refresh token")
+	}
+
+	var (
+		tokens TokenResponse
+		stored database.GetRefreshTokenForUpdateRow
+		reused bool
+	)
+
+	//-- Begin tx --//
+	ctx := c.Request().Context()
+	if err := withTx(ctx, auth.store, func(qtx database.Querier) error {
+		stored, err = qtx.GetRefreshTokenForUpdate(ctx, database.GetRefreshTokenForUpdateParams{TokenHash: hash})
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusUnauthorized, "invalid refresh token")
 		}
-		return echo.NewHTTPError(http.StatusInternalServerError, "could not create user").SetInternal(err)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "refresh token lookup failed").SetInternal(err)
+		}
+
+		if stored.RevokedAt.Valid {
+			return echo.NewHTTPError(http.StatusUnauthorized, "refresh token revoked")
+		}
+
+		//-- Reuse detection --//
+		if stored.UsedAt.Valid {
+			// Families minted before sessions existed have no session row to revoke
+			if err := revokeSession(ctx, qtx, stored.FamilyID, stored.UserID); err != nil && !errors.Is(err, errSessionNotFound) {
+				return echo.NewHTTPError(http.StatusInternalServerError, "could not revoke tokens").SetInternal(err)
+			}
+			// The revocation has to commit, so the rejection waits until after
+			reused = true
+			return nil
+		}
+
+		if time.Now().After(stored.ExpiresAt) {
+			return echo.NewHTTPError(http.StatusUnauthorized, "refresh token expired")
+		}
+
+		// The refresh token family is the session, so a logged out or revoked
+		// session cannot be refreshed back to life.
+		active, err := qtx.TouchSession(ctx, database.TouchSessionParams{SessionID: stored.FamilyID, IpAddress: c.RealIP()})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "session lookup failed").SetInternal(err)
+		}
+		if active == 0 {
+			return echo.NewHTTPError(http.StatusUnauthorized, "session revoked")
+		}
+
+		//-- Rotate --//
+		if err := qtx.MarkRefreshTokenUsed(ctx, database.MarkRefreshTokenUsedParams{TokenID: stored.TokenID}); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "could not rotate token").SetInternal(err)
+		}
+
+		user, err := qtx.FindUserByID(ctx, database.FindUserByIDParams{UserID: stored.UserID})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusUnauthorized, "user no longer exists")
+		}
+
+		tokens, err = auth.issueTokens(ctx, qtx, newUserClaims(stored.UserID, user.FirstName, user.LastName, derefString(user.PfpUrl)), stored.FamilyID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "could not generate token").SetInternal(err)
+		}
+
+		return nil
+	}); err != nil {
+		return err
 	}
 
-	token, err := auth.tokenHandler.Sign(identity.UserClaims{
-		FirstName:       form.FirstName,
-		LastName:        form.LastName,
-		ProfileImageURL: defaultPfp,
-		RegisteredClaims: jwt.RegisteredClaims{
-			Subject:  strconv.FormatInt(user.UserID, 10),
-			Issuer:   "api.getflick.chat",
-			Audience: jwt.ClaimStrings{"api.getflick.chat"},
-			IssuedAt: jwt.NewNumericDate(time.Now()),
-		},
+	if reused {
+		auth.sessions.MarkRevoked(stored.FamilyID.String())
+		c.Logger().Warnf("refresh token reuse detected for user %d, session revoked", stored.UserID)
+		return echo.NewHTTPError(http.StatusUnauthorized, "refresh token reuse detected")
+	}
+
+	return c.JSON(http.StatusOK, tokens)
+}
+
+// rehashPassword replaces a stale hash. Failure only costs the upgrade, so it
+// is logged rather than failing the login, and a password changed in the
+// meantime is left alone.
+func (auth *Auth) rehashPassword(ctx context.Context, c echo.Context, uid int64, password, oldHash string) {
+	hash, err := identity.Password(password).GenerateHash(auth.hashing)
+	if err != nil {
+		c.Logger().Errorf("password rehash error: %v", err)
+		return
+	}
+
+	err = auth.store.RehashUserPassword(ctx, database.RehashUserPasswordParams{
+		NewHash: hash,
+		UserID:  uid,
+		OldHash: oldHash,
 	})
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "could not generate token").SetInternal(err)
+		c.Logger().Errorf("password rehash error: %v", err)
+	}
+}
+
+// openSession starts a session for the user and issues its first token pair.
+func (auth *Auth) openSession(ctx context.Context, q database.Querier, c echo.Context, claims identity.UserClaims) (TokenResponse, error) {
+	session, err := startSession(ctx, q, c, claims.ID())
+	if err != nil {
+		return TokenResponse{}, err
+	}
+
+	return auth.issueTokens(ctx, q, claims, session)
+}
+
+func loginResponse(tokens TokenResponse, userID int64, displayName string, pfpURL *string) map[string]any {
+	return map[string]any{
+		"access_token":  tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
+		"user_id":       userID,
+		"display_name":  displayName,
+		"pfp_url":       pfpURL,
+	}
+}
+
+// issueTokens signs an access token for the session and stores a fresh
+// refresh token in the session's family.
+func (auth *Auth) issueTokens(ctx context.Context, q database.Querier, claims identity.UserClaims, session pgtype.UUID) (TokenResponse, error) {
+	claims.RegisteredClaims.ID = session.String()
+
+	access, err := auth.tokenHandler.Sign(claims)
+	if err != nil {
+		return TokenResponse{}, err
 	}
 
-	return c.JSON(http.StatusCreated, map[string]any{
-		"access_token": token,
-		"user_id":      user.UserID,
-		"display_name": user.DisplayName,
-		"pfp_url":      user.PfpUrl,
+	refresh, hash, err := identity.NewRefreshToken()
+	if err != nil {
+		return TokenResponse{}, err
+	}
+
+	_, err = q.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+		UserID:    claims.ID(),
+		FamilyID:  session,
+		TokenHash: hash,
+		ExpiresAt: auth.tokenHandler.RefreshExpiry(),
 	})
+	if err != nil {
+		return TokenResponse{}, fmt.Errorf("failed to store refresh token: %w", err)
+	}
+
+	return TokenResponse{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		ExpiresIn:    int64(auth.tokenHandler.AccessTTL().Seconds()),
+	}, nil
+}
+
+func newUserClaims(userID int64, firstName, lastName, pfpURL string) identity.UserClaims {
+	return identity.UserClaims{
+		FirstName:       firstName,
+		LastName:        lastName,
+		ProfileImageURL: pfpURL,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:  strconv.FormatInt(userID, 10),
+			Issuer:   identity.AccessAudience,
+			Audience: jwt.ClaimStrings{identity.AccessAudience},
+		},
+	}
 }
 
+// Recover resets a forgotten password with one of the user's recovery codes.
+// The code is spent and every session is signed out, since whoever held the
+// old password may still be holding them. Wrong codes count towards the same
+// lockout as wrong codes at LoginTOTP.
 func (auth *Auth) Recover(c echo.Context) error {
-	// Perform TOTP recovery flow
-	return nil
+	var body struct {
+		DisplayName  string `json:"display_name"`
+		RecoveryCode string `json:"recovery_code"`
+		NewPassword  string `json:"new_password"`
+	}
+	if err := c.Bind(&body); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	body.DisplayName = strings.TrimSpace(body.DisplayName)
+	if body.DisplayName == "" || body.RecoveryCode == "" || strings.TrimSpace(body.NewPassword) == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "display_name, recovery_code and new_password are required")
+	}
+
+	ctx := c.Request().Context()
+
+	user, err := auth.store.FindUserByDisplayName(ctx, database.FindUserByDisplayNameParams{DisplayName: body.DisplayName})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid recovery code")
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "user lookup failed").SetInternal(err)
+	}
+
+	if err := checkPassword(auth.passwords, body.NewPassword, body.DisplayName); err != nil {
+		return err
+	}
+
+	var (
+		revoked []pgtype.UUID
+		failed  error
+	)
+
+	//-- Begin tx --//
+	if err := withTx(ctx, auth.store, func(qtx database.Querier) error {
+		state, err := qtx.GetUserTOTPForUpdate(ctx, database.GetUserTOTPForUpdateParams{UserID: user.UserID})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "could not load two-factor state").SetInternal(err)
+		}
+		if mfaLocked(state) {
+			return errMFALocked
+		}
+
+		used, err := qtx.UseRecoveryCode(ctx, database.UseRecoveryCodeParams{
+			UserID:   user.UserID,
+			CodeHash: identity.HashRecoveryCode(body.RecoveryCode),
+		})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "recovery code lookup failed").SetInternal(err)
+		}
+		if used == 0 {
+			failed = echo.NewHTTPError(http.StatusUnauthorized, "invalid recovery code")
+			return recordMFAFailure(ctx, qtx, user.UserID)
+		}
+
+		// Hashing is the expensive part, so wrong guesses never get this far.
+		hash, err := identity.Password(body.NewPassword).GenerateHash(auth.hashing)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "could not hash password").SetInternal(err)
+		}
+
+		if err := qtx.UpdateUserPassword(ctx, database.UpdateUserPasswordParams{PasswordHash: hash, UserID: user.UserID}); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "could not update password").SetInternal(err)
+		}
+
+		if err := qtx.ResetMFAFailures(ctx, database.ResetMFAFailuresParams{UserID: user.UserID}); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "could not update password").SetInternal(err)
+		}
+
+		revoked, err = revokeAllSessions(ctx, qtx, user.UserID, pgtype.UUID{})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "could not revoke sessions").SetInternal(err)
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+	if failed != nil {
+		return failed
+	}
+
+	for _, id := range revoked {
+		auth.sessions.MarkRevoked(id.String())
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// checkPassword applies the password policy, describing any failure in a
+// way the client can show to the user.
+func checkPassword(policy *identity.PasswordPolicy, password, displayName string) error {
+	err := policy.Check(identity.Password(password), displayName)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, identity.ErrPasswordTooShort):
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("password must be at least %d characters", policy.MinLength()))
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
 }
 
 func derefString(s *string) string {
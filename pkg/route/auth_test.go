@@ -0,0 +1,258 @@
+package route
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegister(t *testing.T) {
+	s := newTestServer(t)
+	ada := s.register("ada")
+	if ada.ID == 0 || ada.AccessToken == "" || ada.RefreshToken == "" {
+		t.Fatalf("register = %+v", ada)
+	}
+
+	tests := []struct {
+		name   string
+		body   map[string]string
+		status int
+	}{
+		{"taken", map[string]string{"display_name": "ada", "password": testPassword, "first_name": "A", "last_name": "B"}, http.StatusConflict},
+		{"taken in another case", map[string]string{"display_name": "ADA", "password": testPassword, "first_name": "A", "last_name": "B"}, http.StatusConflict},
+		{"missing names", map[string]string{"display_name": "bob", "password": testPassword}, http.StatusBadRequest},
+		{"short password", map[string]string{"display_name": "bob", "password": "short", "first_name": "B", "last_name": "B"}, http.StatusBadRequest},
+		{"common password", map[string]string{"display_name": "bob", "password": "password1234", "first_name": "B", "last_name": "B"}, http.StatusBadRequest},
+		{"bad user key", map[string]string{"display_name": "bob", "password": testPassword, "first_name": "B", "last_name": "B", "user_key": "nope"}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if rec := s.do(http.MethodPost, "/v1/auth/register", "", tt.body); rec.Code != tt.status {
+			t.Errorf("%s: register = %d %s, want %d", tt.name, rec.Code, rec.Body, tt.status)
+		}
+	}
+}
+
+func TestLogin(t *testing.T) {
+	s := newTestServer(t)
+	ada := s.register("ada")
+
+	var login testUser
+	s.expect(http.StatusOK, http.MethodPost, "/v1/auth/login", "", map[string]string{"display_name": "ada", "password": testPassword}, &login)
+	if login.ID != ada.ID || login.AccessToken == "" {
+		t.Errorf("login = %+v", login)
+	}
+
+	// Wrong password and unknown user look the same
+	for _, body := range []map[string]string{
+		{"display_name": "ada", "password": "wrong-password-here"},
+		{"display_name": "nobody", "password": testPassword},
+	} {
+		if rec := s.do(http.MethodPost, "/v1/auth/login", "", body); rec.Code != http.StatusUnauthorized {
+			t.Errorf("login as %s = %d %s, want 401", body["display_name"], rec.Code, rec.Body)
+		}
+	}
+
+	if rec := s.do(http.MethodPost, "/v1/auth/login", "", map[string]string{"display_name": "ada"}); rec.Code != http.StatusBadRequest {
+		t.Errorf("login without password = %d, want 400", rec.Code)
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	s := newTestServer(t)
+	ada := s.register("ada")
+
+	var rotated TokenResponse
+	s.expect(http.StatusOK, http.MethodPost, "/v1/auth/refresh", "", map[string]string{"refresh_token": ada.RefreshToken}, &rotated)
+	if rotated.RefreshToken == ada.RefreshToken || rotated.AccessToken == "" {
+		t.Fatalf("refresh did not rotate: %+v", rotated)
+	}
+	s.expect(http.StatusOK, http.MethodGet, "/v1/auth/sessions", rotated.AccessToken, nil, nil)
+
+	// Presenting the old token again means it leaked: the session ends
+	if rec := s.do(http.MethodPost, "/v1/auth/refresh", "", map[string]string{"refresh_token": ada.RefreshToken}); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("reused refresh token = %d, want 401", rec.Code)
+	}
+	if rec := s.do(http.MethodPost, "/v1/auth/refresh", "", map[string]string{"refresh_token": rotated.RefreshToken}); rec.Code != http.StatusUnauthorized {
+		t.Errorf("refresh after reuse = %d, want 401", rec.Code)
+	}
+	if rec := s.do(http.MethodGet, "/v1/auth/sessions", rotated.AccessToken, nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("access token after reuse = %d, want 401", rec.Code)
+	}
+
+	if rec := s.do(http.MethodPost, "/v1/auth/refresh", "", map[string]string{"refresh_token": "garbage"}); rec.Code != http.StatusUnauthorized {
+		t.Errorf("malformed refresh token = %d, want 401", rec.Code)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	s := newTestServer(t)
+	ada := s.register("ada")
+
+	var other testUser
+	s.expect(http.StatusOK, http.MethodPost, "/v1/auth/login", "", map[string]string{"display_name": "ada", "password": testPassword}, &other)
+
+	var sessions []SessionResponse
+	s.expect(http.StatusOK, http.MethodGet, "/v1/auth/sessions", ada.AccessToken, nil, &sessions)
+	if len(sessions) != 2 {
+		t.Fatalf("got %d sessions, want 2", len(sessions))
+	}
+
+	s.expect(http.StatusNoContent, http.MethodPost, "/v1/auth/logout", ada.AccessToken, nil, nil)
+
+	if rec := s.do(http.MethodGet, "/v1/auth/sessions", ada.AccessToken, nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("access token after logout = %d, want 401", rec.Code)
+	}
+	if rec := s.do(http.MethodPost, "/v1/auth/refresh", "", map[string]string{"refresh_token": ada.RefreshToken}); rec.Code != http.StatusUnauthorized {
+		t.Errorf("refresh after logout = %d, want 401", rec.Code)
+	}
+
+	// The other device stays signed in
+	s.expect(http.StatusOK, http.MethodGet, "/v1/auth/sessions", other.AccessToken, nil, &sessions)
+	if len(sessions) != 1 || !sessions[0].Current {
+		t.Errorf("sessions after logout = %+v", sessions)
+	}
+
+	if rec := s.do(http.MethodGet, "/v1/auth/sessions", "", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("no token = %d, want 401", rec.Code)
+	}
+}
+
+// testTOTPCode computes the code an authenticator shows for the base32
+// secret, steps periods from now.
+func testTOTPCode(t *testing.T, secret string, steps int64) string {
+	t.Helper()
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(time.Now().Unix()/30+steps))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	return fmt.Sprintf("%06d", binary.BigEndian.Uint32(sum[offset:])&0x7fffffff%1_000_000)
+}
+
+// enableTOTP turns on two-factor for u, returning the secret and recovery
+// codes.
+func (s *testServer) enableTOTP(u testUser) (string, []string) {
+	s.t.Helper()
+	var enrolled struct {
+		Secret string `json:"secret"`
+		URI    string `json:"otpauth_uri"`
+	}
+	s.expect(http.StatusOK, http.MethodPost, "/v1/auth/totp/enroll", u.AccessToken, nil, &enrolled)
+	if !strings.HasPrefix(enrolled.URI, "otpauth://totp/") {
+		s.t.Errorf("otpauth_uri = %q", enrolled.URI)
+	}
+
+	if rec := s.do(http.MethodPost, "/v1/auth/totp/confirm", u.AccessToken, map[string]string{"code": "000000"}); rec.Code != http.StatusBadRequest {
+		s.t.Errorf("confirm with a wrong code = %d, want 400", rec.Code)
+	}
+
+	var confirmed struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	s.expect(http.StatusOK, http.MethodPost, "/v1/auth/totp/confirm", u.AccessToken, map[string]string{"code": testTOTPCode(s.t, enrolled.Secret, 0)}, &confirmed)
+	return enrolled.Secret, confirmed.RecoveryCodes
+}
+
+func (s *testServer) challenge(name string) string {
+	s.t.Helper()
+	var resp struct {
+		MFARequired bool   `json:"mfa_required"`
+		MFAToken    string `json:"mfa_token"`
+		AccessToken string `json:"access_token"`
+	}
+	s.expect(http.StatusOK, http.MethodPost, "/v1/auth/login", "", map[string]string{"display_name": name, "password": testPassword}, &resp)
+	if !resp.MFARequired || resp.MFAToken == "" || resp.AccessToken != "" {
+		s.t.Fatalf("login with two-factor on = %+v", resp)
+	}
+	return resp.MFAToken
+}
+
+func TestLoginTOTP(t *testing.T) {
+	s := newTestServer(t)
+	ada := s.register("ada")
+	secret, recovery := s.enableTOTP(ada)
+	if len(recovery) == 0 {
+		t.Fatal("no recovery codes")
+	}
+
+	// The confirming code's step is spent, so the next login uses the next
+	mfa := s.challenge("ada")
+	var login testUser
+	s.expect(http.StatusOK, http.MethodPost, "/v1/auth/login/totp", "", map[string]string{"mfa_token": mfa, "code": testTOTPCode(t, secret, 1)}, &login)
+	if login.ID != ada.ID || login.AccessToken == "" {
+		t.Errorf("login/totp = %+v", login)
+	}
+
+	// A challenge is single use
+	if rec := s.do(http.MethodPost, "/v1/auth/login/totp", "", map[string]string{"mfa_token": mfa, "recovery_code": recovery[0]}); rec.Code != http.StatusUnauthorized {
+		t.Errorf("replayed challenge = %d, want 401", rec.Code)
+	}
+
+	// Recovery codes work once each
+	s.expect(http.StatusOK, http.MethodPost, "/v1/auth/login/totp", "", map[string]string{"mfa_token": s.challenge("ada"), "recovery_code": recovery[0]}, nil)
+	if rec := s.do(http.MethodPost, "/v1/auth/login/totp", "", map[string]string{"mfa_token": s.challenge("ada"), "recovery_code": recovery[0]}); rec.Code != http.StatusUnauthorized {
+		t.Errorf("reused recovery code = %d, want 401", rec.Code)
+	}
+
+	if rec := s.do(http.MethodPost, "/v1/auth/login/totp", "", map[string]string{"mfa_token": "forged", "code": "123456"}); rec.Code != http.StatusUnauthorized {
+		t.Errorf("forged challenge = %d, want 401", rec.Code)
+	}
+}
+
+func TestLoginTOTPLockout(t *testing.T) {
+	s := newTestServer(t)
+	ada := s.register("ada")
+	secret, recovery := s.enableTOTP(ada)
+
+	// A wrong code while confirming enrollment is not counted
+	for i := range maxMFAAttempts {
+		rec := s.do(http.MethodPost, "/v1/auth/login/totp", "", map[string]string{"mfa_token": s.challenge("ada"), "code": "000000"})
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("wrong code %d = %d, want 401", i+1, rec.Code)
+		}
+	}
+
+	// Locked: even the right code waits, and so does recovery
+	rec := s.do(http.MethodPost, "/v1/auth/login/totp", "", map[string]string{"mfa_token": s.challenge("ada"), "code": testTOTPCode(t, secret, 1)})
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("right code while locked = %d, want 429", rec.Code)
+	}
+	rec = s.do(http.MethodPost, "/v1/auth/recover", "", map[string]string{"display_name": "ada", "recovery_code": recovery[0], "new_password": "new-plum-river-lantern"})
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("recover while locked = %d, want 429", rec.Code)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	s := newTestServer(t)
+	ada := s.register("ada")
+	_, recovery := s.enableTOTP(ada)
+
+	if rec := s.do(http.MethodPost, "/v1/auth/recover", "", map[string]string{"display_name": "ada", "recovery_code": "AAAA-BBBB-CCCC", "new_password": "new-plum-river-lantern"}); rec.Code != http.StatusUnauthorized {
+		t.Errorf("wrong recovery code = %d, want 401", rec.Code)
+	}
+
+	s.expect(http.StatusNoContent, http.MethodPost, "/v1/auth/recover", "", map[string]string{"display_name": "ada", "recovery_code": recovery[0], "new_password": "new-plum-river-lantern"}, nil)
+
+	// Every session ends with the password change
+	if rec := s.do(http.MethodGet, "/v1/auth/sessions", ada.AccessToken, nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("access token after recovery = %d, want 401", rec.Code)
+	}
+	if rec := s.do(http.MethodPost, "/v1/auth/login", "", map[string]string{"display_name": "ada", "password": testPassword}); rec.Code != http.StatusUnauthorized {
+		t.Errorf("old password after recovery = %d, want 401", rec.Code)
+	}
+	s.expect(http.StatusOK, http.MethodPost, "/v1/auth/login", "", map[string]string{"display_name": "ada", "password": "new-plum-river-lantern"}, nil)
+}
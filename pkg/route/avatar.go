@@ -0,0 +1,245 @@
+package route
+
+import (
+	"bytes"
+	c_rand "crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image/png"
+	"net/http"
+	"strconv"
+
+	"github.com/astrokkidd/flick/pkg/blob"
+	"github.com/astrokkidd/flick/pkg/database"
+	"github.com/astrokkidd/flick/pkg/identity"
+	"github.com/astrokkidd/flick/pkg/imaging"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	avatarIDBytes      = 16
+	identiconHashBytes = 16
+)
+
+// avatarSizes are the squares avatars are rendered at, largest first.
+// Clients pick one with ?size= and get the smallest at least that big.
+var avatarSizes = []int{256, 128, 48}
+
+func avatarURL(avatarID string) string {
+	return "/v1/avatars/" + avatarID
+}
+
+func avatarBlobKey(avatarID string, size int) string {
+	return fmt.Sprintf("avatars/%s-%d", avatarID, size)
+}
+
+// identiconURL is the default avatar for a display name. The path holds a
+// digest of the name, which is all the picture is drawn from.
+func identiconURL(displayName string) string {
+	sum := sha256.Sum256([]byte(displayName))
+	return "/v1/identicons/" + hex.EncodeToString(sum[:identiconHashBytes])
+}
+
+func newAvatarID() (string, error) {
+	raw := make([]byte, avatarIDBytes)
+	if _, err := c_rand.Read(raw); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+func validAvatarID(id string) bool {
+	raw, err := hex.DecodeString(id)
+	return err == nil && len(raw) == avatarIDBytes && hex.EncodeToString(raw) == id
+}
+
+func avatarSize(c echo.Context) (int, error) {
+	size := avatarSizes[0]
+	if s := c.QueryParam("size"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return 0, echo.NewHTTPError(http.StatusBadRequest, "size must be a positive number")
+		}
+		for _, candidate := range avatarSizes {
+			if candidate >= n {
+				size = candidate
+			}
+		}
+	}
+	return size, nil
+}
+
+// deleteAvatar removes every rendering of an avatar once nothing points at
+// it any more.
+func (user *User) deleteAvatar(c echo.Context, avatarID string) {
+	for _, size := range avatarSizes {
+		key := avatarBlobKey(avatarID, size)
+		if err := user.blobs.Delete(c.Request().Context(), key); err != nil {
+			c.Logger().Errorf("orphaned avatar blob %s: %v", key, err)
+		}
+	}
+}
+
+// UpdateProfilePicture replaces the caller's avatar with a JPEG, PNG or
+// WebP image sent as the multipart "file" field. It is cropped to a square
+// and rendered at each of avatarSizes; the upload itself, and whatever
+// metadata it carried, is not kept.
+func (user *User) UpdateProfilePicture(c echo.Context) error {
+	claims, err := identity.GetUserClaims(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "unauthenticated")
+	}
+	uid := claims.ID()
+
+	_, data, err := readUpload(c, user.avatarMaxSize)
+	if err != nil {
+		return err
+	}
+
+	img, err := imaging.Decode(data)
+	switch {
+	case errors.Is(err, imaging.ErrUnsupported):
+		return echo.NewHTTPError(http.StatusUnsupportedMediaType, "profile pictures must be JPEG, PNG or WebP images")
+	case errors.Is(err, imaging.ErrTooLarge):
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("profile pictures are limited to %d pixels", imaging.MaxPixels))
+	case err != nil:
+		return echo.NewHTTPError(http.StatusBadRequest, "image could not be read").SetInternal(err)
+	}
+
+	avatarID, err := newAvatarID()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update profile picture").SetInternal(err)
+	}
+
+	//-- Render and store outside any transaction, it can take a while --//
+	ctx := c.Request().Context()
+	for _, size := range avatarSizes {
+		rendered, _, err := imaging.Encode(img.Square(size))
+		if err == nil {
+			err = user.blobs.Put(ctx, avatarBlobKey(avatarID, size), rendered)
+		}
+		if err != nil {
+			user.deleteAvatar(c, avatarID)
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to store profile picture").SetInternal(err)
+		}
+	}
+
+	pfpURL := avatarURL(avatarID)
+	var previous *string
+
+	//-- Begin tx --//
+	if err := withTx(ctx, user.store, func(qtx database.Querier) error {
+		u, err := qtx.GetUserAvatarForUpdate(ctx, database.GetUserAvatarForUpdateParams{UserID: uid})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch user").SetInternal(err)
+		}
+		previous = u.AvatarID
+
+		err = qtx.UpdateUserPfp(ctx, database.UpdateUserPfpParams{UserID: uid, PfpUrl: &pfpURL, AvatarID: &avatarID})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to update profile picture").SetInternal(err)
+		}
+
+		return nil
+	}); err != nil {
+		user.deleteAvatar(c, avatarID)
+		return err
+	}
+
+	if previous != nil {
+		user.deleteAvatar(c, *previous)
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{"pfp_url": pfpURL})
+}
+
+// RemoveProfilePicture puts the caller back on their identicon.
+func (user *User) RemoveProfilePicture(c echo.Context) error {
+	claims, err := identity.GetUserClaims(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "unauthenticated")
+	}
+	uid := claims.ID()
+
+	var (
+		pfpURL   string
+		previous *string
+	)
+
+	//-- Begin tx --//
+	ctx := c.Request().Context()
+	if err := withTx(ctx, user.store, func(qtx database.Querier) error {
+		u, err := qtx.GetUserAvatarForUpdate(ctx, database.GetUserAvatarForUpdateParams{UserID: uid})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch user").SetInternal(err)
+		}
+		previous = u.AvatarID
+		pfpURL = identiconURL(u.DisplayName)
+
+		err = qtx.UpdateUserPfp(ctx, database.UpdateUserPfpParams{UserID: uid, PfpUrl: &pfpURL})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to update profile picture").SetInternal(err)
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if previous != nil {
+		user.deleteAvatar(c, *previous)
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{"pfp_url": pfpURL})
+}
+
+// Avatar serves an uploaded profile picture. Like any profile picture it is
+// public to whoever has the URL. Ids are random and never reused, so the
+// response can be cached for good.
+func (user *User) Avatar(c echo.Context) error {
+	avatarID := c.Param("avatar_id")
+	if !validAvatarID(avatarID) {
+		return echo.NewHTTPError(http.StatusNotFound, "avatar not found")
+	}
+	size, err := avatarSize(c)
+	if err != nil {
+		return err
+	}
+
+	data, err := user.blobs.Get(c.Request().Context(), avatarBlobKey(avatarID, size))
+	if errors.Is(err, blob.ErrNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, "avatar not found")
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "could not load avatar").SetInternal(err)
+	}
+
+	header := c.Response().Header()
+	header.Set("Cache-Control", "public, max-age=31536000, immutable")
+	header.Set(echo.HeaderXContentTypeOptions, "nosniff")
+
+	// Rendered here, so sniffing tells JPEG from PNG reliably
+	return c.Blob(http.StatusOK, http.DetectContentType(data), data)
+}
+
+// Identicon draws the default avatar identiconURL points at.
+func (user *User) Identicon(c echo.Context) error {
+	hash, err := hex.DecodeString(c.Param("hash"))
+	if err != nil || len(hash) != identiconHashBytes {
+		return echo.NewHTTPError(http.StatusNotFound, "identicon not found")
+	}
+	size, err := avatarSize(c)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, imaging.Identicon(hash, size)); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "could not draw identicon").SetInternal(err)
+	}
+
+	c.Response().Header().Set("Cache-Control", "public, max-age=86400")
+	return c.Blob(http.StatusOK, "image/png", buf.Bytes())
+}
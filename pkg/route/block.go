@@ -0,0 +1,121 @@
+package route
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/astrokkidd/flick/pkg/database"
+	"github.com/astrokkidd/flick/pkg/identity"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// BlockedUser is an entry in the caller's block list.
+type BlockedUser struct {
+	UserID      int64   `json:"user_id"`
+	DisplayName string  `json:"display_name"`
+	PfpURL      *string `json:"pfp_url"`
+	BlockedAt   string  `json:"blocked_at"`
+}
+
+// BlockUser blocks another user. It ends any friendship and drops pending
+// requests both ways. From then on neither finds the other in search or
+// sees their profile, and neither can send a friend request. Chats they
+// already share are left alone.
+func (user *User) BlockUser(c echo.Context) error {
+	claims, err := identity.GetUserClaims(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "unauthenticated")
+	}
+	uid := claims.ID()
+
+	var body struct {
+		UserID int64 `param:"id"`
+	}
+	if err := c.Bind(&body); err != nil || body.UserID <= 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid user id")
+	}
+	if body.UserID == uid {
+		return echo.NewHTTPError(http.StatusBadRequest, "cannot block yourself")
+	}
+
+	//-- Begin tx --//
+	ctx := c.Request().Context()
+	if err := withTx(ctx, user.store, func(qtx database.Querier) error {
+		if _, err := qtx.FindUserByID(ctx, database.FindUserByIDParams{UserID: body.UserID}); errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusNotFound, "user not found")
+		} else if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch user").SetInternal(err)
+		}
+
+		if err := qtx.BlockUser(ctx, database.BlockUserParams{BlockerID: uid, BlockedID: body.UserID}); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "block failed").SetInternal(err)
+		}
+		if err := qtx.DeleteFriendship(ctx, database.DeleteFriendshipParams{UserID: uid, FriendID: body.UserID}); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to remove friendship").SetInternal(err)
+		}
+		if err := qtx.DeleteFriendRequestsBetween(ctx, database.DeleteFriendRequestsBetweenParams{UserID: uid, UserID2: body.UserID}); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to remove friend requests").SetInternal(err)
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// UnblockUser lifts the caller's block. A friendship it ended stays ended.
+func (user *User) UnblockUser(c echo.Context) error {
+	claims, err := identity.GetUserClaims(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "unauthenticated")
+	}
+
+	var body struct {
+		UserID int64 `param:"id"`
+	}
+	if err := c.Bind(&body); err != nil || body.UserID <= 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid user id")
+	}
+
+	n, err := user.store.UnblockUser(c.Request().Context(), database.UnblockUserParams{BlockerID: claims.ID(), BlockedID: body.UserID})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "unblock failed").SetInternal(err)
+	}
+	if n == 0 {
+		return echo.NewHTTPError(http.StatusNotFound, "user not blocked")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ListBlockedUsers returns the users the caller has blocked, most recent
+// first.
+func (user *User) ListBlockedUsers(c echo.Context) error {
+	claims, err := identity.GetUserClaims(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "unauthenticated")
+	}
+
+	rows, err := user.store.ListBlockedUsers(c.Request().Context(), database.ListBlockedUsersParams{BlockerID: claims.ID()})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list blocked users").SetInternal(err)
+	}
+
+	blocked := make([]BlockedUser, 0, len(rows))
+	for _, r := range rows {
+		blocked = append(blocked, BlockedUser{
+			UserID:      r.UserID,
+			DisplayName: r.DisplayName,
+			PfpURL:      r.PfpUrl,
+			BlockedAt:   r.CreatedAt.Format(time.RFC3339),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"users": blocked,
+	})
+}
@@ -2,30 +2,37 @@ package route
 
 import (
 	"net/http"
+	"slices"
 	"time"
 
+	"github.com/astrokkidd/flick/pkg/blob"
 	"github.com/astrokkidd/flick/pkg/crypto"
 	"github.com/astrokkidd/flick/pkg/database"
 	"github.com/astrokkidd/flick/pkg/identity"
-	"github.com/jackc/pgx/v5"
+	"github.com/astrokkidd/flick/pkg/realtime"
+	"github.com/astrokkidd/flick/pkg/store"
 	"github.com/labstack/echo/v4"
 )
 
 type Chat struct {
-	queries      *database.Queries
-	conn         *pgx.Conn
+	store        store.Store
 	tokenHandler *identity.TokenHandler
+	hub          *realtime.Hub
+	cipher       crypto.Cipher
+	blobs        blob.Store // attachment files, removed with their chat
 }
 
 type MessageStructure struct {
-	MessageID int64     `json:"message_id"`
-	SenderID  int64     `json:"sender_id"`
-	CreatedAt time.Time `json:"created_at"`
-	Plaintext string    `json:"plaintext"`
+	MessageID  int64     `json:"message_id"`
+	SenderID   int64     `json:"sender_id"`
+	CreatedAt  time.Time `json:"created_at"`
+	Plaintext  string    `json:"plaintext"`
+	Ciphertext []byte    `json:"ciphertext,omitempty"` // end-to-end chats only
 }
 
 type ParticipantStructure struct {
 	UserID    int64  `json:"user_id"`
+	Role      string `json:"role"`
 	PfpUrl    string `json:"pfp_url"`
 	FirstName string `json:"first_name"`
 	LastName  string `json:"last_name"`
@@ -33,6 +40,9 @@ type ParticipantStructure struct {
 
 type ChatStructure struct {
 	ChatID         int64                  `json:"chat_id"`
+	Kind           string                 `json:"kind"`
+	Title          *string                `json:"title,omitempty"`
+	EndToEnd       bool                   `json:"end_to_end"`
 	LastMessage    *MessageStructure      `json:"last_message,omitempty"`
 	Participants   []ParticipantStructure `json:"participants"`
 	UnreadMessages int                    `json:"unread_messages"`
@@ -42,8 +52,18 @@ type ResponseStructure struct {
 	Chats []ChatStructure `json:"chats"`
 }
 
-func NewChatHandler(queries *database.Queries, conn *pgx.Conn, tokenHandler *identity.TokenHandler) Chat {
-	return Chat{queries, conn, tokenHandler}
+type TypingEvent struct {
+	UserID   int64 `json:"user_id"`
+	IsTyping bool  `json:"is_typing"`
+}
+
+type ReadEvent struct {
+	UserID    int64 `json:"user_id"`
+	MessageID int64 `json:"message_id"`
+}
+
+func NewChatHandler(store store.Store, tokenHandler *identity.TokenHandler, hub *realtime.Hub, cipher crypto.Cipher, blobs blob.Store) Chat {
+	return Chat{store, tokenHandler, hub, cipher, blobs}
 }
 
 func (chat *Chat) CreateChat(c echo.Context) error {
@@ -54,71 +74,94 @@ func (chat *Chat) CreateChat(c echo.Context) error {
 	}
 	uid := claims.ID()
 
-	//-- Read participant_id from JSON --//
+	//-- Read participants from JSON --//
 	var body struct {
-		ParticipantID int64 `json:"participant_id"`
+		Kind           string  `json:"kind"`
+		ParticipantID  int64   `json:"participant_id"`
+		ParticipantIDs []int64 `json:"participant_ids"`
+		Title          string  `json:"title"`
+		EndToEnd       bool    `json:"end_to_end"`
+	}
+	if err := c.Bind(&body); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid body")
+	}
+
+	switch body.Kind {
+	case "", chatKindDirect:
+	case chatKindGroup:
+		return chat.createGroupChat(c, uid, body.ParticipantIDs, body.Title, body.EndToEnd)
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "kind must be direct or group")
 	}
-	if err := c.Bind(&body); err != nil || body.ParticipantID <= 0 {
+
+	if body.ParticipantID <= 0 {
 		return echo.NewHTTPError(http.StatusBadRequest, "missing or invalid participant_id")
 	}
 	if body.ParticipantID == uid {
 		return echo.NewHTTPError(http.StatusBadRequest, "cannot start chat with self")
 	}
 
+	var cid int64
+	status := http.StatusCreated
+
 	//-- Begin transaction --//
 	ctx := c.Request().Context()
-	tx, err := chat.conn.Begin(ctx)
-	if err != nil {
-		return echo.ErrInternalServerError
-	}
-	defer tx.Rollback(ctx)
-
-	qtx := chat.queries.WithTx(tx)
-
-	//-- Check if chat already exists between these two --//
-	found, err := qtx.FindDirectChatBetween(ctx, database.FindDirectChatBetweenParams{
-		UserID:   uid,
-		UserID_2: body.ParticipantID,
-	})
-	if err == nil {
-		// Chat already exists
-		return c.JSON(http.StatusOK, echo.Map{"chat_id": found})
-	}
+	if err := withTx(ctx, chat.store, func(qtx database.Querier) error {
+		//-- Check if chat already exists between these two --//
+		// An end-to-end chat lives alongside the regular one rather than replacing it.
+		found, err := qtx.FindDirectChatBetween(ctx, database.FindDirectChatBetweenParams{
+			UserID:   uid,
+			UserID_2: body.ParticipantID,
+			EndToEnd: body.EndToEnd,
+		})
+		if err == nil {
+			// Chat already exists
+			cid, status = found, http.StatusOK
+			return nil
+		}
 
-	areFriends, err := qtx.AreUsersFriends(ctx, database.AreUsersFriendsParams{
-		UserID:   uid,
-		UserID_2: body.ParticipantID,
-	})
-	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "user doesn't exist")
-	}
-	if !areFriends {
-		return echo.NewHTTPError(http.StatusForbidden, "you must be friends to start a direct chat")
-	}
+		areFriends, err := qtx.AreUsersFriends(ctx, database.AreUsersFriendsParams{
+			UserID:   uid,
+			UserID_2: body.ParticipantID,
+		})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "user doesn't exist")
+		}
+		if !areFriends {
+			return echo.NewHTTPError(http.StatusForbidden, "you must be friends to start a direct chat")
+		}
 
-	//-- Create new chat --//
-	created, err := qtx.CreateEmptyChat(ctx)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "could not create chat")
-	}
-	cid := created.ChatID
+		if body.EndToEnd {
+			if err := requirePublishedKeys(ctx, qtx, []int64{uid, body.ParticipantID}); err != nil {
+				return err
+			}
+		}
 
-	//-- Add both participants --//
-	for _, pid := range []int64{uid, body.ParticipantID} {
-		err := qtx.AddParticipant(ctx, database.AddParticipantParams{
-			ChatID: cid,
-			UserID: pid,
-		})
+		//-- Create new chat --//
+		created, err := qtx.CreateEmptyChat(ctx, database.CreateEmptyChatParams{EndToEnd: body.EndToEnd})
 		if err != nil {
-			return echo.NewHTTPError(http.StatusInternalServerError, "could not add participant")
+			return echo.NewHTTPError(http.StatusInternalServerError, "could not create chat")
+		}
+		cid = created.ChatID
+
+		//-- Add both participants --//
+		for _, pid := range []int64{uid, body.ParticipantID} {
+			err := qtx.AddParticipant(ctx, database.AddParticipantParams{
+				ChatID: cid,
+				UserID: pid,
+				Role:   roleMember,
+			})
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "could not add participant")
+			}
 		}
-	}
 
-	if err := tx.Commit(ctx); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "commit failed")
+		return nil
+	}); err != nil {
+		return err
 	}
 
-	return c.JSON(http.StatusCreated, echo.Map{"chat_id": cid})
+	return c.JSON(status, echo.Map{"chat_id": cid})
 }
 
 func (chat *Chat) GetChats(c echo.Context) error {
@@ -128,91 +171,104 @@ func (chat *Chat) GetChats(c echo.Context) error {
 	}
 	uid := claims.ID()
 
+	var result []ChatStructure
+
 	//-- Begin tx --//
 	ctx := c.Request().Context()
-	tx, err := chat.conn.Begin(ctx)
-	if err != nil {
-		return echo.ErrInternalServerError
-	}
-	defer tx.Rollback(ctx)
-
-	qtx := chat.queries.WithTx(tx)
-
-	listChatsWithUserParams := database.ListChatsWithUserParams{
-		UserID: uid,
-	}
-
-	chats, err := qtx.ListChatsWithUser(ctx, listChatsWithUserParams)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "chats query failed")
-	}
-
-	//if chats len == 0 -> return empty json success
-
-	chatMap := make(map[int64]*ChatStructure, len(chats))
-	chatIDs := make([]int64, 0, len(chats))
-
-	for _, r := range chats {
-		numUnread, err := qtx.GetNumberUnreadMessages(ctx, database.GetNumberUnreadMessagesParams{
-			ChatID: r.ChatID,
+	if err := withTx(ctx, chat.store, func(qtx database.Querier) error {
+		listChatsWithUserParams := database.ListChatsWithUserParams{
 			UserID: uid,
-		})
-		if err != nil {
-			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get number unread")
 		}
 
-		cs := &ChatStructure{
-			ChatID:         r.ChatID,
-			Participants:   []ParticipantStructure{},
-			UnreadMessages: int(numUnread),
+		chats, err := qtx.ListChatsWithUser(ctx, listChatsWithUserParams)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "chats query failed")
 		}
 
-		if r.MessageID != nil && len(r.CypherText) > 0 {
-			plaintext, err := crypto.Decrypt(r.CypherText)
+		//if chats len == 0 -> return empty json success
+
+		chatMap := make(map[int64]*ChatStructure, len(chats))
+		chatIDs := make([]int64, 0, len(chats))
+
+		for _, r := range chats {
+			numUnread, err := qtx.GetNumberUnreadMessages(ctx, database.GetNumberUnreadMessagesParams{
+				ChatID: r.ChatID,
+				UserID: uid,
+			})
 			if err != nil {
-				return echo.NewHTTPError(http.StatusInternalServerError, "decryption failed")
+				return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get number unread")
 			}
 
-			cs.LastMessage = &MessageStructure{
-				MessageID: *r.MessageID,
-				SenderID:  *r.SenderID,
-				CreatedAt: r.CreatedAt.Time,
-				Plaintext: string(plaintext),
+			cs := &ChatStructure{
+				ChatID:         r.ChatID,
+				Kind:           r.Kind,
+				Title:          r.Title,
+				EndToEnd:       r.EndToEnd,
+				Participants:   []ParticipantStructure{},
+				UnreadMessages: int(numUnread),
 			}
-		}
 
-		chatMap[r.ChatID] = cs
-		chatIDs = append(chatIDs, r.ChatID)
-	}
+			// End-to-end ciphertext is passed through for the client to open
+			if r.MessageID != nil && r.EndToEnd {
+				cs.LastMessage = &MessageStructure{
+					MessageID:  *r.MessageID,
+					SenderID:   *r.SenderID,
+					CreatedAt:  r.CreatedAt.Time,
+					Ciphertext: r.CypherText,
+				}
+			} else if r.MessageID != nil && len(r.CypherText) > 0 {
+				key, err := chat.cipher.OpenDataKey(ctx, r.WrappedKey, crypto.ChatKeyAAD(r.ChatID))
+				if err != nil {
+					return echo.NewHTTPError(http.StatusInternalServerError, "chat key unavailable")
+				}
+
+				plaintext, err := key.Decrypt(r.CypherText, crypto.MessageAAD(r.ChatID, *r.SenderID, *r.MessageID))
+				if err != nil {
+					return echo.NewHTTPError(http.StatusInternalServerError, "decryption failed")
+				}
+
+				cs.LastMessage = &MessageStructure{
+					MessageID: *r.MessageID,
+					SenderID:  *r.SenderID,
+					CreatedAt: r.CreatedAt.Time,
+					Plaintext: string(plaintext),
+				}
+			}
 
-	listChatParticipants := database.ListChatParticipantsParams{
-		Column1: chatIDs,
-	}
+			chatMap[r.ChatID] = cs
+			chatIDs = append(chatIDs, r.ChatID)
+		}
 
-	participants, err := qtx.ListChatParticipants(ctx, listChatParticipants)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "participants query failed")
-	}
+		listChatParticipants := database.ListChatParticipantsParams{
+			Column1: chatIDs,
+		}
 
-	for _, p := range participants {
-		chatMap[p.ChatID].Participants = append(
-			chatMap[p.ChatID].Participants,
-			ParticipantStructure{
-				PfpUrl:    *p.PfpUrl,
-				UserID:    p.UserID,
-				FirstName: p.FirstName,
-				LastName:  p.LastName,
-			},
-		)
-	}
+		participants, err := qtx.ListChatParticipants(ctx, listChatParticipants)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "participants query failed")
+		}
 
-	result := make([]ChatStructure, 0, len(chatMap))
-	for _, c := range chatMap {
-		result = append(result, *c)
-	}
+		for _, p := range participants {
+			chatMap[p.ChatID].Participants = append(
+				chatMap[p.ChatID].Participants,
+				ParticipantStructure{
+					PfpUrl:    *p.PfpUrl,
+					UserID:    p.UserID,
+					Role:      p.Role,
+					FirstName: p.FirstName,
+					LastName:  p.LastName,
+				},
+			)
+		}
 
-	if err := tx.Commit(ctx); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "commit failed")
+		result = make([]ChatStructure, 0, len(chatMap))
+		for _, c := range chatMap {
+			result = append(result, *c)
+		}
+
+		return nil
+	}); err != nil {
+		return err
 	}
 
 	return c.JSON(http.StatusOK, ResponseStructure{
@@ -228,39 +284,44 @@ func (chat *Chat) SetTypingStatus(c echo.Context) error {
 	uid := claims.ID()
 
 	var body struct {
-		ChatID   int64 `param:"chat_id"`
-		IsTyping bool  `param:"is_typing"`
+		ChatID   int64 `param:"id"`
+		IsTyping bool  `param:"status"`
 	}
 	if err := c.Bind(&body); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "invalid body")
 	}
 
+	var participants []int64
+
 	//-- Begin tx --//
 	ctx := c.Request().Context()
-	tx, err := chat.conn.Begin(ctx)
-	if err != nil {
-		return echo.ErrInternalServerError
-	}
-	defer tx.Rollback(ctx)
+	if err := withTx(ctx, chat.store, func(qtx database.Querier) error {
+		if _, err := requireChatPermission(ctx, qtx, body.ChatID, uid, permSendMessages); err != nil {
+			return err
+		}
 
-	qtx := chat.queries.WithTx(tx)
+		_, err = qtx.SetTypingStatus(ctx, database.SetTypingStatusParams{IsTyping: body.IsTyping, ChatID: body.ChatID, UserID: uid})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "could not set typing status")
+		}
 
-	isParticipant, err := qtx.IsUserInChat(ctx, database.IsUserInChatParams{ChatID: body.ChatID, UserID: uid})
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "could not verify participant")
-	}
-	if !isParticipant {
-		return echo.NewHTTPError(http.StatusForbidden, "not a participant in this chat")
-	}
+		participants, err = qtx.ListChatParticipantIDs(ctx, database.ListChatParticipantIDsParams{ChatID: body.ChatID})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "participants query failed")
+		}
 
-	_, err = qtx.SetTypingStatus(ctx, database.SetTypingStatusParams{IsTyping: body.IsTyping, ChatID: body.ChatID, UserID: uid})
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "could not set typing status")
+		return nil
+	}); err != nil {
+		return err
 	}
 
-	if err := tx.Commit(ctx); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "commit failed")
-	}
+	//-- Notify everyone but the typist --//
+	others := slices.DeleteFunc(participants, func(id int64) bool { return id == uid })
+	chat.hub.Publish(realtime.Event{
+		Type:   realtime.EventTypingChanged,
+		ChatID: body.ChatID,
+		Data:   TypingEvent{UserID: uid, IsTyping: body.IsTyping},
+	}, others...)
 
 	return c.NoContent(http.StatusNoContent)
 }
@@ -273,18 +334,50 @@ func (chat *Chat) SetLastReadMessage(c echo.Context) error {
 	uid := claims.ID()
 
 	var body struct {
-		ChatID    int64 `param:"chat_id"`
+		ChatID    int64 `param:"id"`
 		MessageID int64 `json:"message_id"`
 	}
 	if err := c.Bind(&body); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "invalid body")
 	}
 
+	var (
+		moved        int64
+		participants []int64
+	)
+
+	//-- Begin tx --//
 	ctx := c.Request().Context()
-	_, err = chat.queries.SetLastReadMessage(ctx, database.SetLastReadMessageParams{LastReadMessageID: &body.MessageID, ChatID: body.ChatID, UserID: uid})
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "could not set last read message")
+	if err := withTx(ctx, chat.store, func(qtx database.Querier) error {
+		moved, err = qtx.SetLastReadMessage(ctx, database.SetLastReadMessageParams{LastReadMessageID: &body.MessageID, ChatID: body.ChatID, UserID: uid})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "could not set last read message")
+		}
+
+		// Marker only moves forward, nothing to announce if it stayed put
+		if moved == 0 {
+			return nil
+		}
+
+		participants, err = qtx.ListChatParticipantIDs(ctx, database.ListChatParticipantIDsParams{ChatID: body.ChatID})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "participants query failed")
+		}
+
+		return nil
+	}); err != nil {
+		return err
 	}
 
+	if moved == 0 {
+		return c.NoContent(http.StatusNoContent)
+	}
+
+	chat.hub.Publish(realtime.Event{
+		Type:   realtime.EventReadUpdated,
+		ChatID: body.ChatID,
+		Data:   ReadEvent{UserID: uid, MessageID: body.MessageID},
+	}, participants...)
+
 	return c.NoContent(http.StatusNoContent)
 }
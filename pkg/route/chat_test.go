@@ -0,0 +1,118 @@
+package route
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestCreateDirectChat(t *testing.T) {
+	s := newTestServer(t)
+	ada, bob := s.register("ada"), s.register("bob")
+
+	direct := map[string]any{"participant_id": bob.ID}
+	s.expect(http.StatusForbidden, http.MethodPost, "/v1/chats", ada.AccessToken, direct, nil)
+	s.expect(http.StatusBadRequest, http.MethodPost, "/v1/chats", ada.AccessToken, map[string]any{"participant_id": ada.ID}, nil)
+
+	s.befriend(ada, bob)
+	var created, again struct {
+		ChatID int64 `json:"chat_id"`
+	}
+	s.expect(http.StatusCreated, http.MethodPost, "/v1/chats", ada.AccessToken, direct, &created)
+
+	// Either side asking again gets the same chat back
+	s.expect(http.StatusOK, http.MethodPost, "/v1/chats", bob.AccessToken, map[string]any{"participant_id": ada.ID}, &again)
+	if again.ChatID != created.ChatID {
+		t.Errorf("second request made chat %d, want %d", again.ChatID, created.ChatID)
+	}
+
+	for _, u := range []testUser{ada, bob} {
+		var listed ResponseStructure
+		s.expect(http.StatusOK, http.MethodGet, "/v1/chats", u.AccessToken, nil, &listed)
+		if len(listed.Chats) != 1 || listed.Chats[0].ChatID != created.ChatID || len(listed.Chats[0].Participants) != 2 {
+			t.Errorf("%s lists %+v", u.DisplayName, listed.Chats)
+		}
+	}
+}
+
+func TestCreateGroupChat(t *testing.T) {
+	s := newTestServer(t)
+	ada, bob, cy := s.register("ada"), s.register("bob"), s.register("cy")
+	s.befriend(ada, bob)
+
+	tests := []struct {
+		name   string
+		body   map[string]any
+		status int
+	}{
+		{"stranger", map[string]any{"kind": "group", "title": "Plans", "participant_ids": []int64{bob.ID, cy.ID}}, http.StatusForbidden},
+		{"alone", map[string]any{"kind": "group", "title": "Plans", "participant_ids": []int64{ada.ID}}, http.StatusBadRequest},
+		{"bad kind", map[string]any{"kind": "channel", "participant_ids": []int64{bob.ID}}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if rec := s.do(http.MethodPost, "/v1/chats", ada.AccessToken, tt.body); rec.Code != tt.status {
+			t.Errorf("%s: create = %d %s, want %d", tt.name, rec.Code, rec.Body, tt.status)
+		}
+	}
+
+	// A failed create leaves nothing behind
+	var listed ResponseStructure
+	s.expect(http.StatusOK, http.MethodGet, "/v1/chats", bob.AccessToken, nil, &listed)
+	if len(listed.Chats) != 0 {
+		t.Fatalf("bob lists %+v after failed creates", listed.Chats)
+	}
+
+	chatID := s.group(ada, bob)
+	s.expect(http.StatusOK, http.MethodGet, "/v1/chats", bob.AccessToken, nil, &listed)
+	if len(listed.Chats) != 1 || listed.Chats[0].ChatID != chatID || listed.Chats[0].Kind != "group" {
+		t.Fatalf("bob lists %+v", listed.Chats)
+	}
+	if title := listed.Chats[0].Title; title == nil || *title != "Test group" {
+		t.Errorf("title = %v", title)
+	}
+}
+
+func TestGroupRoles(t *testing.T) {
+	s := newTestServer(t)
+	ada, bob, cy := s.register("ada"), s.register("bob"), s.register("cy")
+	s.befriend(ada, bob)
+	s.befriend(ada, cy)
+	chatID := s.group(ada, bob, cy)
+
+	base := fmt.Sprintf("/v1/chats/%d", chatID)
+	title := map[string]string{"title": "Renamed"}
+	role := func(u testUser) string { return fmt.Sprintf("%s/members/%d/role", base, u.ID) }
+	member := func(u testUser) string { return fmt.Sprintf("%s/members/%d", base, u.ID) }
+
+	// Members can read and write, nothing more
+	s.expect(http.StatusForbidden, http.MethodPut, base+"/title", bob.AccessToken, title, nil)
+	s.expect(http.StatusForbidden, http.MethodDelete, member(cy), bob.AccessToken, nil, nil)
+	s.expect(http.StatusForbidden, http.MethodPut, role(cy), bob.AccessToken, map[string]string{"role": roleAdmin}, nil)
+
+	s.expect(http.StatusBadRequest, http.MethodPut, role(bob), ada.AccessToken, map[string]string{"role": "moderator"}, nil)
+	s.expect(http.StatusBadRequest, http.MethodPut, role(ada), ada.AccessToken, map[string]string{"role": roleMember}, nil)
+	s.expect(http.StatusOK, http.MethodPut, role(bob), ada.AccessToken, map[string]string{"role": roleAdmin}, nil)
+
+	// Admins rename and remove members, but not the owner, and only the
+	// owner hands out roles
+	var renamed struct {
+		Title string `json:"title"`
+	}
+	s.expect(http.StatusOK, http.MethodPut, base+"/title", bob.AccessToken, title, &renamed)
+	if renamed.Title != "Renamed" {
+		t.Errorf("title = %q", renamed.Title)
+	}
+	s.expect(http.StatusForbidden, http.MethodDelete, member(ada), bob.AccessToken, nil, nil)
+	s.expect(http.StatusForbidden, http.MethodPut, role(cy), bob.AccessToken, map[string]string{"role": roleAdmin}, nil)
+	s.expect(http.StatusNoContent, http.MethodDelete, member(cy), bob.AccessToken, nil, nil)
+	s.expect(http.StatusNotFound, http.MethodPut, role(cy), ada.AccessToken, map[string]string{"role": roleAdmin}, nil)
+	s.expect(http.StatusForbidden, http.MethodGet, base+"/messages", cy.AccessToken, nil, nil)
+
+	// Handing over ownership leaves the old owner an admin, who no longer
+	// outranks the new owner
+	s.expect(http.StatusOK, http.MethodPut, role(bob), ada.AccessToken, map[string]string{"role": roleOwner}, nil)
+	s.expect(http.StatusForbidden, http.MethodPut, role(bob), ada.AccessToken, map[string]string{"role": roleMember}, nil)
+	s.expect(http.StatusForbidden, http.MethodDelete, member(bob), ada.AccessToken, nil, nil)
+	s.expect(http.StatusOK, http.MethodPut, base+"/title", ada.AccessToken, title, nil)
+	s.expect(http.StatusNoContent, http.MethodDelete, member(ada), bob.AccessToken, nil, nil)
+}
@@ -0,0 +1,44 @@
+package route
+
+import (
+	"context"
+	"errors"
+
+	"github.com/astrokkidd/flick/pkg/crypto"
+	"github.com/astrokkidd/flick/pkg/database"
+	"github.com/jackc/pgx/v5"
+)
+
+// chatDataKey unwraps the chat's data key, generating one first when create
+// is set. Without create, a chat that never had a key yields one that still
+// opens messages sealed before per-chat keys existed.
+func chatDataKey(ctx context.Context, q database.Querier, cipher crypto.Cipher, chatID int64, create bool) (crypto.DataKey, error) {
+	aad := crypto.ChatKeyAAD(chatID)
+
+	wrapped, err := q.GetChatKey(ctx, database.GetChatKeyParams{ChatID: chatID})
+	if errors.Is(err, pgx.ErrNoRows) {
+		if !create {
+			return cipher.OpenDataKey(ctx, nil, aad)
+		}
+
+		_, fresh, err := cipher.NewDataKey(ctx, aad)
+		if err != nil {
+			return nil, err
+		}
+
+		// Two first messages can race here; the loser's key is discarded and
+		// both read back whichever landed.
+		if err := q.CreateChatKey(ctx, database.CreateChatKeyParams{ChatID: chatID, WrappedKey: fresh}); err != nil {
+			return nil, err
+		}
+
+		wrapped, err = q.GetChatKey(ctx, database.GetChatKeyParams{ChatID: chatID})
+		if err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	return cipher.OpenDataKey(ctx, wrapped, aad)
+}
@@ -0,0 +1,447 @@
+package route
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/astrokkidd/flick/pkg/database"
+	"github.com/astrokkidd/flick/pkg/identity"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	chatKindDirect = "direct"
+	chatKindGroup  = "group"
+
+	maxGroupParticipants = 256
+	maxGroupTitleLength  = 64
+)
+
+func (chat *Chat) createGroupChat(c echo.Context, uid int64, participantIDs []int64, title string, endToEnd bool) error {
+	title, err := normalizeGroupTitle(title)
+	if err != nil {
+		return err
+	}
+
+	//-- Deduplicate members, the creator is always one of them --//
+	members := []int64{uid}
+	for _, pid := range participantIDs {
+		if pid <= 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid participant id")
+		}
+		if !slices.Contains(members, pid) {
+			members = append(members, pid)
+		}
+	}
+	if len(members) < 2 {
+		return echo.NewHTTPError(http.StatusBadRequest, "a group needs at least one other participant")
+	}
+	if len(members) > maxGroupParticipants {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("a group can have at most %d participants", maxGroupParticipants))
+	}
+
+	var cid int64
+
+	//-- Begin transaction --//
+	ctx := c.Request().Context()
+	if err := withTx(ctx, chat.store, func(qtx database.Querier) error {
+		if err := requireFriends(ctx, qtx, uid, members[1:]); err != nil {
+			return err
+		}
+
+		if endToEnd {
+			if err := requirePublishedKeys(ctx, qtx, members); err != nil {
+				return err
+			}
+		}
+
+		cid, err = qtx.CreateGroupChat(ctx, database.CreateGroupChatParams{Title: &title, EndToEnd: endToEnd})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "could not create chat")
+		}
+
+		for _, pid := range members {
+			role := roleMember
+			if pid == uid {
+				role = roleOwner
+			}
+			err := qtx.AddParticipant(ctx, database.AddParticipantParams{
+				ChatID: cid,
+				UserID: pid,
+				Role:   role,
+			})
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "could not add participant")
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, echo.Map{"chat_id": cid})
+}
+
+func (chat *Chat) AddMembers(c echo.Context) error {
+	claims, err := identity.GetUserClaims(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "unauthenticated")
+	}
+	uid := claims.ID()
+
+	var body struct {
+		ChatID  int64   `param:"id"`
+		UserIDs []int64 `json:"user_ids"`
+	}
+	if err := c.Bind(&body); err != nil || len(body.UserIDs) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "missing or invalid user_ids")
+	}
+
+	//-- Deduplicate new members --//
+	var members []int64
+	for _, pid := range body.UserIDs {
+		if pid <= 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid participant id")
+		}
+		if pid == uid {
+			return echo.NewHTTPError(http.StatusBadRequest, "cannot add yourself")
+		}
+		if !slices.Contains(members, pid) {
+			members = append(members, pid)
+		}
+	}
+	if len(members) >= maxGroupParticipants {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("a group can have at most %d participants", maxGroupParticipants))
+	}
+	body.UserIDs = members
+
+	//-- Begin tx --//
+	ctx := c.Request().Context()
+	if err := withTx(ctx, chat.store, func(qtx database.Querier) error {
+		if _, err := loadGroupChat(ctx, qtx, body.ChatID, uid, permAddMembers); err != nil {
+			return err
+		}
+
+		if err := requireFriends(ctx, qtx, uid, body.UserIDs); err != nil {
+			return err
+		}
+
+		endToEnd, err := qtx.IsChatEndToEnd(ctx, database.IsChatEndToEndParams{ChatID: body.ChatID})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "chat query failed")
+		}
+		if endToEnd {
+			if err := requirePublishedKeys(ctx, qtx, body.UserIDs); err != nil {
+				return err
+			}
+		}
+
+		for _, pid := range body.UserIDs {
+			err := qtx.AddParticipant(ctx, database.AddParticipantParams{
+				ChatID: body.ChatID,
+				UserID: pid,
+				Role:   roleMember,
+			})
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "could not add participant")
+			}
+		}
+
+		count, err := qtx.CountChatParticipants(ctx, database.CountChatParticipantsParams{ChatID: body.ChatID})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "participant count failed")
+		}
+		if count > maxGroupParticipants {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("a group can have at most %d participants", maxGroupParticipants))
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (chat *Chat) RemoveMember(c echo.Context) error {
+	claims, err := identity.GetUserClaims(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "unauthenticated")
+	}
+	uid := claims.ID()
+
+	var body struct {
+		ChatID int64 `param:"id"`
+		UserID int64 `param:"user_id"`
+	}
+	if err := c.Bind(&body); err != nil || body.UserID <= 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "missing or invalid user_id")
+	}
+	if body.UserID == uid {
+		return echo.NewHTTPError(http.StatusBadRequest, "use leave to remove yourself")
+	}
+
+	//-- Begin tx --//
+	ctx := c.Request().Context()
+	if err := withTx(ctx, chat.store, func(qtx database.Querier) error {
+		actorRole, err := loadGroupChat(ctx, qtx, body.ChatID, uid, permRemoveMembers)
+		if err != nil {
+			return err
+		}
+
+		targetRole, err := getMemberRole(ctx, qtx, body.ChatID, body.UserID)
+		if err != nil {
+			return err
+		}
+		if !outranks(actorRole, targetRole) {
+			return echo.NewHTTPError(http.StatusForbidden, "cannot remove a member of equal or higher role")
+		}
+
+		if _, err := qtx.RemoveParticipant(ctx, database.RemoveParticipantParams{ChatID: body.ChatID, UserID: body.UserID}); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "could not remove participant")
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (chat *Chat) LeaveChat(c echo.Context) error {
+	claims, err := identity.GetUserClaims(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "unauthenticated")
+	}
+	uid := claims.ID()
+
+	var body struct {
+		ChatID int64 `param:"id"`
+	}
+	if err := c.Bind(&body); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid chat id")
+	}
+
+	var blobKeys []string // files and thumbnails of a chat nobody is left in
+
+	//-- Begin tx --//
+	ctx := c.Request().Context()
+	if err := withTx(ctx, chat.store, func(qtx database.Querier) error {
+		role, err := loadGroupChat(ctx, qtx, body.ChatID, uid, permReadMessages)
+		if err != nil {
+			return err
+		}
+
+		if _, err := qtx.RemoveParticipant(ctx, database.RemoveParticipantParams{ChatID: body.ChatID, UserID: uid}); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "could not leave chat")
+		}
+
+		//-- Drop the chat once nobody is left in it --//
+		remaining, err := qtx.CountChatParticipants(ctx, database.CountChatParticipantsParams{ChatID: body.ChatID})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "participant count failed")
+		}
+		// The chat's data key goes with it, so any copies of its messages left
+		// in backups can no longer be opened. Its attachment rows go too, but
+		// their blobs have to be removed by hand.
+		if remaining == 0 {
+			files, err := qtx.ListChatAttachmentBlobs(ctx, database.ListChatAttachmentBlobsParams{ChatID: body.ChatID})
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "attachments query failed").SetInternal(err)
+			}
+			for _, f := range files {
+				blobKeys = append(blobKeys, f.BlobKey)
+				if f.ThumbnailKey != nil {
+					blobKeys = append(blobKeys, *f.ThumbnailKey)
+				}
+			}
+			if _, err := qtx.DeleteChat(ctx, database.DeleteChatParams{ChatID: body.ChatID}); err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "could not delete chat")
+			}
+		}
+
+		//-- Hand ownership to the longest-serving admin, or member if there is none --//
+		if remaining > 0 && role == roleOwner {
+			successor, err := qtx.FindSuccessorOwner(ctx, database.FindSuccessorOwnerParams{ChatID: body.ChatID})
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "successor query failed")
+			}
+			if _, err := qtx.SetParticipantRole(ctx, database.SetParticipantRoleParams{Role: roleOwner, ChatID: body.ChatID, UserID: successor}); err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "could not transfer ownership")
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	// Only once the rows are gone, so a failed leave never leaves an
+	// attachment pointing at a missing file
+	for _, key := range blobKeys {
+		if err := chat.blobs.Delete(ctx, key); err != nil {
+			c.Logger().Errorf("orphaned attachment blob %s: %v", key, err)
+		}
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (chat *Chat) RenameChat(c echo.Context) error {
+	claims, err := identity.GetUserClaims(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "unauthenticated")
+	}
+	uid := claims.ID()
+
+	var body struct {
+		ChatID int64  `param:"id"`
+		Title  string `json:"title"`
+	}
+	if err := c.Bind(&body); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid body")
+	}
+
+	title, err := normalizeGroupTitle(body.Title)
+	if err != nil {
+		return err
+	}
+
+	//-- Begin tx --//
+	ctx := c.Request().Context()
+	if err := withTx(ctx, chat.store, func(qtx database.Querier) error {
+		if _, err := loadGroupChat(ctx, qtx, body.ChatID, uid, permRenameChat); err != nil {
+			return err
+		}
+
+		if _, err := qtx.UpdateChatTitle(ctx, database.UpdateChatTitleParams{ChatID: body.ChatID, Title: &title}); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "could not rename chat")
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"chat_id": body.ChatID, "title": title})
+}
+
+func (chat *Chat) SetMemberRole(c echo.Context) error {
+	claims, err := identity.GetUserClaims(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "unauthenticated")
+	}
+	uid := claims.ID()
+
+	var body struct {
+		ChatID int64  `param:"id"`
+		UserID int64  `param:"user_id"`
+		Role   string `json:"role"`
+	}
+	if err := c.Bind(&body); err != nil || body.UserID <= 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "missing or invalid user_id")
+	}
+	if roleRank(body.Role) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "role must be owner, admin or member")
+	}
+	if body.UserID == uid {
+		return echo.NewHTTPError(http.StatusBadRequest, "cannot change your own role")
+	}
+
+	//-- Begin tx --//
+	ctx := c.Request().Context()
+	if err := withTx(ctx, chat.store, func(qtx database.Querier) error {
+		if _, err := loadGroupChat(ctx, qtx, body.ChatID, uid, permManageRoles); err != nil {
+			return err
+		}
+
+		if _, err := getMemberRole(ctx, qtx, body.ChatID, body.UserID); err != nil {
+			return err
+		}
+
+		if _, err := qtx.SetParticipantRole(ctx, database.SetParticipantRoleParams{Role: body.Role, ChatID: body.ChatID, UserID: body.UserID}); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "could not set role")
+		}
+
+		//-- There is only one owner, promoting someone else steps us down --//
+		if body.Role == roleOwner {
+			if _, err := qtx.SetParticipantRole(ctx, database.SetParticipantRoleParams{Role: roleAdmin, ChatID: body.ChatID, UserID: uid}); err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "could not transfer ownership")
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"user_id": body.UserID, "role": body.Role})
+}
+
+// loadGroupChat makes sure the chat is a group and the user's role in it
+// grants perm, returning that role.
+func loadGroupChat(ctx context.Context, qtx database.Querier, chatID, uid int64, perm chatPermission) (string, error) {
+	found, err := qtx.GetChatByID(ctx, database.GetChatByIDParams{ChatID: chatID})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", echo.NewHTTPError(http.StatusNotFound, "chat not found")
+	}
+	if err != nil {
+		return "", echo.NewHTTPError(http.StatusInternalServerError, "chat query failed")
+	}
+	if found.Kind != chatKindGroup {
+		return "", echo.NewHTTPError(http.StatusBadRequest, "not a group chat")
+	}
+
+	return requireChatPermission(ctx, qtx, chatID, uid, perm)
+}
+
+// getMemberRole looks up another participant's role, 404 if they are not in the chat.
+func getMemberRole(ctx context.Context, qtx database.Querier, chatID, uid int64) (string, error) {
+	role, err := qtx.GetParticipantRole(ctx, database.GetParticipantRoleParams{ChatID: chatID, UserID: uid})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", echo.NewHTTPError(http.StatusNotFound, "user is not in this chat")
+	}
+	if err != nil {
+		return "", echo.NewHTTPError(http.StatusInternalServerError, "role query failed")
+	}
+	return role, nil
+}
+
+// requireFriends rejects any user in ids that uid is not friends with.
+func requireFriends(ctx context.Context, qtx database.Querier, uid int64, ids []int64) error {
+	for _, pid := range ids {
+		if pid == uid {
+			continue
+		}
+		areFriends, err := qtx.AreUsersFriends(ctx, database.AreUsersFriendsParams{
+			UserID:   uid,
+			UserID_2: pid,
+		})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "friend check failed")
+		}
+		if !areFriends {
+			return echo.NewHTTPError(http.StatusForbidden, fmt.Sprintf("user %d is not your friend", pid))
+		}
+	}
+	return nil
+}
+
+func normalizeGroupTitle(title string) (string, error) {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return "", echo.NewHTTPError(http.StatusBadRequest, "title is required")
+	}
+	if utf8.RuneCountInString(title) > maxGroupTitleLength {
+		return "", echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("title must be at most %d characters", maxGroupTitleLength))
+	}
+	return title, nil
+}
@@ -0,0 +1,121 @@
+package route
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/astrokkidd/flick/pkg/database"
+	"github.com/astrokkidd/flick/pkg/identity"
+	"github.com/astrokkidd/flick/pkg/store"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// Keys is the public key directory for end-to-end chats.
+type Keys struct {
+	store        store.Store
+	tokenHandler *identity.TokenHandler
+}
+
+type SignedPrekey struct {
+	KeyID     int64  `json:"key_id"`
+	PublicKey []byte `json:"public_key"` // base64 in JSON
+	Signature []byte `json:"signature"`
+}
+
+type KeyBundle struct {
+	UserID       int64         `json:"user_id"`
+	IdentityKey  []byte        `json:"identity_key"`
+	SignedPrekey *SignedPrekey `json:"signed_prekey"`
+	UpdatedAt    time.Time     `json:"updated_at"`
+}
+
+func NewKeyHandler(store store.Store, tokenHandler *identity.TokenHandler) Keys {
+	return Keys{store, tokenHandler}
+}
+
+// UploadKeys publishes the caller's identity key and signed prekey,
+// replacing whatever was there before.
+func (keys *Keys) UploadKeys(c echo.Context) error {
+	claims, err := identity.GetUserClaims(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "unauthenticated")
+	}
+	uid := claims.ID()
+
+	var body struct {
+		IdentityKey  []byte       `json:"identity_key"`
+		SignedPrekey SignedPrekey `json:"signed_prekey"`
+	}
+	if err := c.Bind(&body); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	prekey := body.SignedPrekey
+	if err := identity.VerifySignedPrekey(body.IdentityKey, prekey.PublicKey, prekey.Signature); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	err = keys.store.UpsertUserKeys(c.Request().Context(), database.UpsertUserKeysParams{
+		UserID:                uid,
+		IdentityKey:           body.IdentityKey,
+		SignedPrekeyID:        &prekey.KeyID,
+		SignedPrekey:          prekey.PublicKey,
+		SignedPrekeySignature: prekey.Signature,
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "could not store keys").SetInternal(err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// GetUserKeys serves another user's public keys so a client can start an
+// end-to-end session with them.
+func (keys *Keys) GetUserKeys(c echo.Context) error {
+	var body struct {
+		UserID int64 `param:"user_id"`
+	}
+	if err := c.Bind(&body); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid user id")
+	}
+
+	found, err := keys.store.GetUserKeys(c.Request().Context(), database.GetUserKeysParams{UserID: body.UserID})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return echo.NewHTTPError(http.StatusNotFound, "user has not published keys")
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "key lookup failed").SetInternal(err)
+	}
+
+	bundle := KeyBundle{
+		UserID:      found.UserID,
+		IdentityKey: found.IdentityKey,
+		UpdatedAt:   found.UpdatedAt,
+	}
+	if found.SignedPrekeyID != nil {
+		bundle.SignedPrekey = &SignedPrekey{
+			KeyID:     *found.SignedPrekeyID,
+			PublicKey: found.SignedPrekey,
+			Signature: found.SignedPrekeySignature,
+		}
+	}
+
+	return c.JSON(http.StatusOK, bundle)
+}
+
+// requirePublishedKeys rejects an end-to-end chat with anyone in ids who has
+// no signed prekey yet, since nobody could encrypt to them.
+func requirePublishedKeys(ctx context.Context, qtx database.Querier, ids []int64) error {
+	missing, err := qtx.ListUsersWithoutKeys(ctx, database.ListUsersWithoutKeysParams{UserIds: ids})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "key check failed")
+	}
+	if len(missing) > 0 {
+		return echo.NewHTTPError(http.StatusConflict, fmt.Sprintf("user %d has not published encryption keys", missing[0]))
+	}
+	return nil
+}
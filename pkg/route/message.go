@@ -1,34 +1,149 @@
 package route
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"slices"
 	"time"
 
+	"github.com/astrokkidd/flick/pkg/blob"
 	"github.com/astrokkidd/flick/pkg/crypto"
 	"github.com/astrokkidd/flick/pkg/database"
 	"github.com/astrokkidd/flick/pkg/identity"
+	"github.com/astrokkidd/flick/pkg/realtime"
+	"github.com/astrokkidd/flick/pkg/store"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/labstack/echo/v4"
 )
 
 type Message struct {
-	queries      *database.Queries
-	conn         *pgx.Conn
+	store        store.Store
 	tokenHandler *identity.TokenHandler
+	hub          *realtime.Hub
+	cipher       crypto.Cipher
+	blobs        blob.Store    // attachment files, removed with their message
+	editWindow   time.Duration // zero allows edits at any time
+	maxReactions int64         // distinct emoji per message
 }
 
 type MessageResponse struct {
+	MessageID  int64   `json:"message_id"`
+	SenderID   int64   `json:"sender_id"`
+	Content    string  `json:"content"`
+	Ciphertext []byte  `json:"ciphertext,omitempty"` // end-to-end chats only
+	CreatedAt  string  `json:"created_at"`
+	EditedAt   *string `json:"edited_at"`
+	DeletedAt  *string `json:"deleted_at"` // deleted for everyone; content and ciphertext are empty
+
+	ReplyToID    *int64 `json:"reply_to_id"`
+	ThreadRootID *int64 `json:"thread_root_id,omitempty"` // replies in a thread only
+
+	Attachments []AttachmentResponse `json:"attachments,omitempty"`
+	Reactions   []ReactionSummary    `json:"reactions,omitempty"` // message history only
+	ReplyTo     *MessagePreview      `json:"reply_to,omitempty"`  // message history only
+	Thread      *ThreadSummary       `json:"thread,omitempty"`    // thread roots in the chat history only
+}
+
+// MessageDeletedEvent tells clients to drop a message, or to show it as
+// deleted when it went for everyone.
+type MessageDeletedEvent struct {
 	MessageID int64  `json:"message_id"`
-	SenderID  int64  `json:"sender_id"`
-	Content   string `json:"content"`
-	CreatedAt string `json:"created_at"`
+	Scope     string `json:"scope"`
+}
+
+// MessageRevision is a body a message had before it was edited. WrittenAt
+// is when that body was sent or last edited, ReplacedAt when it was edited
+// away.
+type MessageRevision struct {
+	RevisionID int64  `json:"revision_id"`
+	Content    string `json:"content"`
+	Ciphertext []byte `json:"ciphertext,omitempty"` // end-to-end chats only
+	WrittenAt  string `json:"written_at"`
+	ReplacedAt string `json:"replaced_at"`
+}
+
+// MessagePage is one page of chat history. With order=desc pass next_cursor
+// back as before and prev_cursor as after; with order=asc it is the reverse.
+type MessagePage struct {
+	Messages   []MessageResponse `json:"messages"`
+	NextCursor *int64            `json:"next_cursor"`
+	PrevCursor *int64            `json:"prev_cursor"`
+}
+
+const (
+	defaultMessagePageSize int32 = 50
+	maxMessagePageSize     int32 = 100
+
+	orderNewestFirst = "desc"
+	orderOldestFirst = "asc"
+
+	maxCiphertextSize = 64 << 10
+
+	deleteForMe       = "me"
+	deleteForEveryone = "everyone"
+)
+
+func NewMessageHandler(store store.Store, tokenHandler *identity.TokenHandler, hub *realtime.Hub, cipher crypto.Cipher, blobs blob.Store, editWindow time.Duration, maxReactions int64) Message {
+	return Message{store, tokenHandler, hub, cipher, blobs, editWindow, maxReactions}
 }
 
-func NewMessageHandler(queries *database.Queries, conn *pgx.Conn, tokenHandler *identity.TokenHandler) Message {
-	return Message{queries, conn, tokenHandler}
+// optionalTime formats a timestamp column that stays NULL until something
+// happens to the message, such as edited_at.
+func optionalTime(t pgtype.Timestamptz) *string {
+	if !t.Valid {
+		return nil
+	}
+	formatted := t.Time.Format(time.RFC3339)
+	return &formatted
+}
+
+// sealMessage produces what is stored for a message body. End-to-end chats
+// take the client's ciphertext as is; everywhere else the content is sealed
+// with the chat's data key, bound to the message, and the key is returned
+// for indexing.
+func (message *Message) sealMessage(ctx context.Context, qtx database.Querier, chatID, senderID, messageID int64, content string, ciphertext []byte) ([]byte, crypto.DataKey, error) {
+	endToEnd, err := qtx.IsChatEndToEnd(ctx, database.IsChatEndToEndParams{ChatID: chatID})
+	if err != nil {
+		return nil, nil, echo.NewHTTPError(http.StatusInternalServerError, "chat query failed")
+	}
+
+	if endToEnd {
+		// Stored exactly as the client sealed it; the server has no key
+		if content != "" || len(ciphertext) == 0 {
+			return nil, nil, echo.NewHTTPError(http.StatusBadRequest, "end-to-end chats take ciphertext, not content")
+		}
+		if len(ciphertext) > maxCiphertextSize {
+			return nil, nil, echo.NewHTTPError(http.StatusRequestEntityTooLarge, "ciphertext too large")
+		}
+		return ciphertext, nil, nil
+	}
+
+	if len(ciphertext) > 0 {
+		return nil, nil, echo.NewHTTPError(http.StatusBadRequest, "ciphertext is only accepted in end-to-end chats")
+	}
+
+	key, err := chatDataKey(ctx, qtx, message.cipher, chatID, true)
+	if err != nil {
+		return nil, nil, echo.NewHTTPError(http.StatusInternalServerError, "chat key unavailable").SetInternal(err)
+	}
+
+	sealed, err := key.Encrypt([]byte(content), crypto.MessageAAD(chatID, senderID, messageID))
+	if err != nil {
+		return nil, nil, echo.NewHTTPError(http.StatusInternalServerError, "encryption failed")
+	}
+	return sealed, key, nil
 }
 
 func (message *Message) GetMessages(c echo.Context) error {
+	return message.listMessages(c, false)
+}
+
+// listMessages pages through the chat history or, with inThread, through
+// the replies to the message_id thread root. Both take the same cursors.
+func (message *Message) listMessages(c echo.Context, inThread bool) error {
 	claims, err := identity.GetUserClaims(c)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusUnauthorized)
@@ -36,66 +151,344 @@ func (message *Message) GetMessages(c echo.Context) error {
 	senderID := claims.ID()
 
 	var body struct {
-		ChatID int64 `param:"id"`
+		ChatID   int64  `param:"id"`
+		RootID   int64  `param:"message_id"` // threads only
+		BeforeID *int64 `query:"before"`
+		AfterID  *int64 `query:"after"`
+		Limit    int32  `query:"limit"`
+		Order    string `query:"order"`
 	}
 
 	if err := c.Bind(&body); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "invalid input").SetInternal(err)
 	}
 
+	if body.BeforeID != nil && body.AfterID != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "before and after are mutually exclusive")
+	}
+
+	if body.Limit == 0 {
+		body.Limit = defaultMessagePageSize
+	}
+	if body.Limit < 0 || body.Limit > maxMessagePageSize {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxMessagePageSize))
+	}
+
+	switch body.Order {
+	case "":
+		body.Order = orderNewestFirst
+	case orderNewestFirst, orderOldestFirst:
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "order must be desc or asc")
+	}
+
+	page := MessagePage{Messages: []MessageResponse{}}
+
 	//-- Begin transaction --//
 	ctx := c.Request().Context()
-	tx, err := message.conn.Begin(ctx)
-	if err != nil {
-		return echo.ErrInternalServerError
+	if err := withTx(ctx, message.store, func(qtx database.Querier) error {
+		if _, err := requireChatPermission(ctx, qtx, body.ChatID, senderID, permReadMessages); err != nil {
+			return err
+		}
+
+		var threadRootID *int64
+		if inThread {
+			if _, err := requireThreadRoot(ctx, qtx, body.ChatID, body.RootID); err != nil {
+				return err
+			}
+			threadRootID = &body.RootID
+		}
+
+		endToEnd, err := qtx.IsChatEndToEnd(ctx, database.IsChatEndToEndParams{ChatID: body.ChatID})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "chat query failed")
+		}
+
+		//-- Walk away from the cursor, fetching one extra row to detect more pages --//
+		// Without a cursor, desc starts at the newest message and asc at the oldest.
+		backwards := body.BeforeID != nil || (body.AfterID == nil && body.Order == orderNewestFirst)
+
+		var messages []database.ListMessagesBeforeRow
+		if backwards {
+			messages, err = qtx.ListMessagesBefore(ctx, database.ListMessagesBeforeParams{
+				ChatID:       body.ChatID,
+				ThreadRootID: threadRootID,
+				BeforeID:     body.BeforeID,
+				UserID:       senderID,
+				PageSize:     body.Limit + 1,
+			})
+		} else {
+			var rows []database.ListMessagesAfterRow
+			rows, err = qtx.ListMessagesAfter(ctx, database.ListMessagesAfterParams{
+				ChatID:       body.ChatID,
+				ThreadRootID: threadRootID,
+				AfterID:      body.AfterID,
+				UserID:       senderID,
+				PageSize:     body.Limit + 1,
+			})
+			for _, r := range rows {
+				messages = append(messages, database.ListMessagesBeforeRow(r))
+			}
+		}
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "get messages failed")
+		}
+
+		// More rows away from the cursor show in the extra row. There is
+		// only something on the cursor's side if we started from one.
+		moreAway := len(messages) > int(body.Limit)
+		if moreAway {
+			messages = messages[:body.Limit]
+		}
+		moreToward := body.BeforeID != nil || body.AfterID != nil
+
+		// Rows come back ordered away from the cursor; flip them when that
+		// disagrees with the requested order, and with them which side of
+		// the page each cursor leads to.
+		moreAfterLast, moreBeforeFirst := moreAway, moreToward
+		if backwards != (body.Order == orderNewestFirst) {
+			slices.Reverse(messages)
+			moreAfterLast, moreBeforeFirst = moreToward, moreAway
+		}
+
+		key, err := chatDataKey(ctx, qtx, message.cipher, body.ChatID, false)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "chat key unavailable").SetInternal(err)
+		}
+
+		for _, m := range messages {
+			if m.DeletedAt.Valid {
+				page.Messages = append(page.Messages, MessageResponse{
+					MessageID:    m.MessageID,
+					SenderID:     m.SenderID,
+					CreatedAt:    m.CreatedAt.Format(time.RFC3339),
+					DeletedAt:    optionalTime(m.DeletedAt),
+					ThreadRootID: threadRootID,
+				})
+				continue
+			}
+
+			if endToEnd {
+				page.Messages = append(page.Messages, MessageResponse{
+					MessageID:    m.MessageID,
+					SenderID:     m.SenderID,
+					Ciphertext:   m.CypherText,
+					CreatedAt:    m.CreatedAt.Format(time.RFC3339),
+					EditedAt:     optionalTime(m.EditedAt),
+					ReplyToID:    m.ReplyToID,
+					ThreadRootID: threadRootID,
+				})
+				continue
+			}
+
+			plaintext, err := key.Decrypt(m.CypherText, crypto.MessageAAD(body.ChatID, m.SenderID, m.MessageID))
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "decryption failed")
+			}
+
+			page.Messages = append(page.Messages, MessageResponse{
+				MessageID:    m.MessageID,
+				SenderID:     m.SenderID,
+				Content:      string(plaintext),
+				CreatedAt:    m.CreatedAt.Format(time.RFC3339),
+				EditedAt:     optionalTime(m.EditedAt),
+				ReplyToID:    m.ReplyToID,
+				ThreadRootID: threadRootID,
+			})
+		}
+
+		//-- Attach reactions --//
+		ids := make([]int64, len(page.Messages))
+		for i, m := range page.Messages {
+			ids[i] = m.MessageID
+		}
+		reactions, err := qtx.ListMessageReactions(ctx, database.ListMessageReactionsParams{UserID: senderID, MessageIds: ids})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "reactions query failed").SetInternal(err)
+		}
+		byMessage := make(map[int64][]ReactionSummary, len(reactions))
+		for _, r := range reactions {
+			byMessage[r.MessageID] = append(byMessage[r.MessageID], ReactionSummary{Emoji: r.Emoji, Count: r.Count, ReactedByMe: r.ReactedByMe})
+		}
+		for i := range page.Messages {
+			page.Messages[i].Reactions = byMessage[page.Messages[i].MessageID]
+			if page.Messages[i].Reactions == nil {
+				page.Messages[i].Reactions = []ReactionSummary{}
+			}
+		}
+
+		//-- Attach files --//
+		files, err := qtx.ListMessageAttachments(ctx, database.ListMessageAttachmentsParams{MessageIds: ids})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "attachments query failed").SetInternal(err)
+		}
+		filesByMessage := make(map[int64][]AttachmentResponse, len(files))
+		for _, f := range files {
+			filesByMessage[*f.MessageID] = append(filesByMessage[*f.MessageID], newAttachmentResponse(f))
+		}
+		for i := range page.Messages {
+			page.Messages[i].Attachments = filesByMessage[page.Messages[i].MessageID]
+		}
+
+		//-- Attach quoted messages and, in the chat history, threads --//
+		if err := message.attachReplies(ctx, qtx, key, endToEnd, body.ChatID, senderID, page.Messages, !inThread); err != nil {
+			return err
+		}
+
+		//-- Build cursors relative to the returned order --//
+		// next_cursor continues past the last message, prev_cursor goes back
+		// past the first one.
+		if n := len(page.Messages); n > 0 {
+			first, last := page.Messages[0].MessageID, page.Messages[n-1].MessageID
+			if moreAfterLast {
+				page.NextCursor = &last
+			}
+			if moreBeforeFirst {
+				page.PrevCursor = &first
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return err
 	}
-	defer tx.Rollback(ctx)
 
-	qtx := message.queries.WithTx(tx)
+	return c.JSON(http.StatusOK, page)
+}
 
-	isInChat, err := qtx.IsUserInChat(ctx, database.IsUserInChatParams{
-		UserID: senderID,
-		ChatID: body.ChatID,
-	})
+func (message *Message) CreateMessage(c echo.Context) error {
+	claims, err := identity.GetUserClaims(c)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "user doesn't exist")
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+	senderID := claims.ID()
+
+	var body struct {
+		Content       string  `json:"content"`
+		Ciphertext    []byte  `json:"ciphertext"`     // base64, end-to-end chats only
+		ReplyToID     *int64  `json:"reply_to_id"`    // message to quote
+		ThreadRootID  *int64  `json:"thread_root_id"` // post as a reply in this message's thread
+		AttachmentIDs []int64 `json:"attachment_ids"` // the caller's pending uploads to this chat
+		ChatID        int64   `param:"id"`
 	}
-	if !isInChat {
-		return echo.NewHTTPError(http.StatusForbidden, "User not in chat")
+	if err := c.Bind(&body); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid json").SetInternal(err)
 	}
 
-	messages, err := qtx.GetMessages(ctx, database.GetMessagesParams{
-		ChatID: body.ChatID,
-		Limit:  6,
-	})
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "get messages failed")
+	slices.Sort(body.AttachmentIDs)
+	body.AttachmentIDs = slices.Compact(body.AttachmentIDs)
+	if len(body.AttachmentIDs) > maxMessageAttachments {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("a message can carry at most %d attachments", maxMessageAttachments))
 	}
 
-	var response []MessageResponse
+	var (
+		messageId    int64
+		createdAt    time.Time
+		attachments  []AttachmentResponse
+		participants []int64
+	)
 
-	for _, m := range messages {
-		plaintext, err := crypto.Decrypt(m.CypherText)
+	//-- Begin tx --//
+	ctx := c.Request().Context()
+	if err := withTx(ctx, message.store, func(qtx database.Querier) error {
+		if _, err := requireChatPermission(ctx, qtx, body.ChatID, senderID, permSendMessages); err != nil {
+			return err
+		}
+
+		if err := checkReplyTargets(ctx, qtx, body.ChatID, body.ReplyToID, body.ThreadRootID); err != nil {
+			return err
+		}
+
+		// The id is part of the associated data, so it is taken before sealing
+		messageId, err = qtx.NextMessageID(ctx)
 		if err != nil {
-			return echo.NewHTTPError(http.StatusInternalServerError, "decryption failed")
+			return echo.NewHTTPError(http.StatusInternalServerError, "message creation failed").SetInternal(err)
 		}
 
-		response = append(response, MessageResponse{
-			MessageID: m.MessageID,
-			SenderID:  m.SenderID,
-			Content:   string(plaintext),
-			CreatedAt: m.CreatedAt.Format(time.RFC3339),
-		})
-	}
+		encrypted, key, err := message.sealMessage(ctx, qtx, body.ChatID, senderID, messageId, body.Content, body.Ciphertext)
+		if err != nil {
+			return err
+		}
+
+		createMessageParams := database.CreateMessageParams{
+			MessageID:    messageId,
+			ChatID:       body.ChatID,
+			SenderID:     senderID,
+			CypherText:   encrypted,
+			ReplyToID:    body.ReplyToID,
+			ThreadRootID: body.ThreadRootID,
+		}
+
+		createdAt, err = qtx.CreateMessage(ctx, createMessageParams)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "message creation failed").SetInternal(err)
+		}
 
-	if err := tx.Commit(ctx); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "commit failed")
+		if key != nil {
+			if err := indexMessage(ctx, qtx, key, messageId, body.Content); err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "message creation failed").SetInternal(err)
+			}
+		}
+
+		if len(body.AttachmentIDs) > 0 {
+			files, err := qtx.AttachToMessage(ctx, database.AttachToMessageParams{
+				MessageID:     messageId,
+				ChatID:        body.ChatID,
+				UploaderID:    senderID,
+				AttachmentIds: body.AttachmentIDs,
+			})
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "message creation failed").SetInternal(err)
+			}
+			if len(files) != len(body.AttachmentIDs) {
+				return echo.NewHTTPError(http.StatusBadRequest, "attachments must be your own unsent uploads to this chat")
+			}
+			for _, f := range files {
+				attachments = append(attachments, newAttachmentResponse(f))
+			}
+		}
+
+		// Thread replies do not surface as the chat's last message
+		if body.ThreadRootID == nil {
+			err = qtx.UpdateChatLastMessage(ctx, database.UpdateChatLastMessageParams{ChatID: body.ChatID, LastMessageID: &messageId})
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "update last message failed").SetInternal(err)
+			}
+		}
+
+		participants, err = qtx.ListChatParticipantIDs(ctx, database.ListChatParticipantIDsParams{ChatID: body.ChatID})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "participants query failed").SetInternal(err)
+		}
+
+		return nil
+	}); err != nil {
+		return err
 	}
 
-	return c.JSON(http.StatusOK, response)
+	//-- Notify participants --//
+	message.hub.Publish(realtime.Event{
+		Type:   realtime.EventMessageCreated,
+		ChatID: body.ChatID,
+		Data: MessageResponse{
+			MessageID:    messageId,
+			SenderID:     senderID,
+			Content:      body.Content,
+			Ciphertext:   body.Ciphertext,
+			CreatedAt:    createdAt.Format(time.RFC3339),
+			ReplyToID:    body.ReplyToID,
+			ThreadRootID: body.ThreadRootID,
+			Attachments:  attachments,
+		},
+	}, participants...)
+
+	return c.JSON(http.StatusCreated, messageId)
 }
 
-func (message *Message) CreateMessage(c echo.Context) error {
+// EditMessage replaces the body of one of the caller's own messages. The
+// old body is kept, still sealed, as a revision participants can look back
+// through.
+func (message *Message) EditMessage(c echo.Context) error {
 	claims, err := identity.GetUserClaims(c)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusUnauthorized)
@@ -103,58 +496,322 @@ func (message *Message) CreateMessage(c echo.Context) error {
 	senderID := claims.ID()
 
 	var body struct {
-		Content string `json:"content"`
-		ChatID  int64  `param:"id"`
+		Content    string `json:"content"`
+		Ciphertext []byte `json:"ciphertext"` // base64, end-to-end chats only
+		ChatID     int64  `param:"id"`
+		MessageID  int64  `param:"message_id"`
 	}
 	if err := c.Bind(&body); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "invalid json").SetInternal(err)
 	}
 
+	var (
+		edited       MessageResponse
+		participants []int64
+	)
+
 	//-- Begin tx --//
 	ctx := c.Request().Context()
-	tx, err := message.conn.Begin(ctx)
-	if err != nil {
-		return echo.ErrInternalServerError
-	}
-	defer tx.Rollback(ctx)
+	if err := withTx(ctx, message.store, func(qtx database.Querier) error {
+		if _, err := requireChatPermission(ctx, qtx, body.ChatID, senderID, permSendMessages); err != nil {
+			return err
+		}
+
+		current, err := qtx.GetMessageForUpdate(ctx, database.GetMessageForUpdateParams{ChatID: body.ChatID, MessageID: body.MessageID})
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusNotFound, "message not found")
+		}
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "message query failed").SetInternal(err)
+		}
+
+		if current.DeletedAt.Valid {
+			return echo.NewHTTPError(http.StatusConflict, "message was deleted")
+		}
+		if current.SenderID != senderID {
+			return echo.NewHTTPError(http.StatusForbidden, "only the author can edit a message")
+		}
+		if message.editWindow > 0 && time.Since(current.CreatedAt) > message.editWindow {
+			return echo.NewHTTPError(http.StatusForbidden, "message can no longer be edited")
+		}
+
+		encrypted, key, err := message.sealMessage(ctx, qtx, body.ChatID, senderID, body.MessageID, body.Content, body.Ciphertext)
+		if err != nil {
+			return err
+		}
+
+		//-- Keep the body being replaced --//
+		writtenAt := current.CreatedAt
+		if current.EditedAt.Valid {
+			writtenAt = current.EditedAt.Time
+		}
+		err = qtx.CreateMessageRevision(ctx, database.CreateMessageRevisionParams{
+			MessageID:  body.MessageID,
+			CypherText: current.CypherText,
+			WrittenAt:  writtenAt,
+		})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "message edit failed").SetInternal(err)
+		}
+
+		editTime, err := qtx.EditMessage(ctx, database.EditMessageParams{CypherText: encrypted, MessageID: body.MessageID})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "message edit failed").SetInternal(err)
+		}
 
-	qtx := message.queries.WithTx(tx)
+		// Earlier bodies stay out of search, like they stay out of the history
+		if key != nil {
+			err = qtx.DeleteMessageSearchTokens(ctx, database.DeleteMessageSearchTokensParams{MessageID: body.MessageID})
+			if err == nil {
+				err = indexMessage(ctx, qtx, key, body.MessageID, body.Content)
+			}
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "message edit failed").SetInternal(err)
+			}
+		}
+
+		participants, err = qtx.ListChatParticipantIDs(ctx, database.ListChatParticipantIDsParams{ChatID: body.ChatID})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "participants query failed").SetInternal(err)
+		}
+
+		edited = MessageResponse{
+			MessageID:    body.MessageID,
+			SenderID:     senderID,
+			Content:      body.Content,
+			Ciphertext:   body.Ciphertext,
+			CreatedAt:    current.CreatedAt.Format(time.RFC3339),
+			EditedAt:     optionalTime(editTime),
+			ReplyToID:    current.ReplyToID,
+			ThreadRootID: current.ThreadRootID,
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
 
-	isInChat, err := qtx.IsUserInChat(ctx, database.IsUserInChatParams{
-		UserID: senderID,
+	//-- Notify participants --//
+	message.hub.Publish(realtime.Event{
+		Type:   realtime.EventMessageEdited,
 		ChatID: body.ChatID,
-	})
+		Data:   edited,
+	}, participants...)
+
+	return c.JSON(http.StatusOK, edited)
+}
+
+// GetRevisions lists the earlier bodies of a message, oldest first.
+func (message *Message) GetRevisions(c echo.Context) error {
+	claims, err := identity.GetUserClaims(c)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "user doesn't exist")
+		return echo.NewHTTPError(http.StatusUnauthorized)
 	}
-	if !isInChat {
-		return echo.NewHTTPError(http.StatusForbidden, "User not in chat")
+	userID := claims.ID()
+
+	var body struct {
+		ChatID    int64 `param:"id"`
+		MessageID int64 `param:"message_id"`
+	}
+	if err := c.Bind(&body); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid input").SetInternal(err)
+	}
+
+	revisions := []MessageRevision{}
+
+	//-- Begin tx --//
+	ctx := c.Request().Context()
+	if err := withTx(ctx, message.store, func(qtx database.Querier) error {
+		if _, err := requireChatPermission(ctx, qtx, body.ChatID, userID, permReadMessages); err != nil {
+			return err
+		}
+
+		m, err := qtx.GetMessage(ctx, database.GetMessageParams{ChatID: body.ChatID, MessageID: body.MessageID})
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusNotFound, "message not found")
+		}
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "message query failed").SetInternal(err)
+		}
+
+		endToEnd, err := qtx.IsChatEndToEnd(ctx, database.IsChatEndToEndParams{ChatID: body.ChatID})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "chat query failed")
+		}
+
+		rows, err := qtx.ListMessageRevisions(ctx, database.ListMessageRevisionsParams{MessageID: body.MessageID})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "get revisions failed").SetInternal(err)
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+
+		key, err := chatDataKey(ctx, qtx, message.cipher, body.ChatID, false)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "chat key unavailable").SetInternal(err)
+		}
+
+		for _, r := range rows {
+			revision := MessageRevision{
+				RevisionID: r.RevisionID,
+				WrittenAt:  r.WrittenAt.Format(time.RFC3339),
+				ReplacedAt: r.ReplacedAt.Format(time.RFC3339),
+			}
+
+			// Revisions are sealed like the message they came from
+			if endToEnd {
+				revision.Ciphertext = r.CypherText
+			} else {
+				plaintext, err := key.Decrypt(r.CypherText, crypto.MessageAAD(body.ChatID, m.SenderID, body.MessageID))
+				if err != nil {
+					return echo.NewHTTPError(http.StatusInternalServerError, "decryption failed")
+				}
+				revision.Content = string(plaintext)
+			}
+
+			revisions = append(revisions, revision)
+		}
+
+		return nil
+	}); err != nil {
+		return err
 	}
 
-	encrypted, err := crypto.Encrypt([]byte(body.Content))
+	return c.JSON(http.StatusOK, revisions)
+}
+
+// DeleteMessage deletes a message for the caller alone (scope=me, the
+// default) or for everyone in the chat (scope=everyone). Deleting for
+// everyone keeps the message's place in the history but wipes its body,
+// revisions, reactions and attachments; authors may do it to their own
+// messages, admins to anyone's.
+func (message *Message) DeleteMessage(c echo.Context) error {
+	claims, err := identity.GetUserClaims(c)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "encryption failed")
+		return echo.NewHTTPError(http.StatusUnauthorized)
 	}
+	uid := claims.ID()
 
-	createMessageParams := database.CreateMessageParams{
-		ChatID:     body.ChatID,
-		SenderID:   senderID,
-		CypherText: encrypted,
+	var body struct {
+		ChatID    int64  `param:"id"`
+		MessageID int64  `param:"message_id"`
+		Scope     string `query:"scope"`
+	}
+	if err := c.Bind(&body); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid input").SetInternal(err)
 	}
 
-	messageId, err := qtx.CreateMessage(ctx, createMessageParams)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "message creation failed").SetInternal(err)
+	switch body.Scope {
+	case "":
+		body.Scope = deleteForMe
+	case deleteForMe, deleteForEveryone:
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "scope must be me or everyone")
 	}
 
-	err = qtx.UpdateChatLastMessage(ctx, database.UpdateChatLastMessageParams{ChatID: body.ChatID, LastMessageID: &messageId})
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "update last message failed").SetInternal(err)
+	var (
+		notify   []int64
+		blobKeys []string // files and thumbnails of a message deleted for everyone
+	)
+
+	//-- Begin tx --//
+	ctx := c.Request().Context()
+	if err := withTx(ctx, message.store, func(qtx database.Querier) error {
+		role, err := requireChatPermission(ctx, qtx, body.ChatID, uid, permReadMessages)
+		if err != nil {
+			return err
+		}
+
+		//-- Delete for me: hide it from the caller's history --//
+		if body.Scope == deleteForMe {
+			_, err := qtx.GetMessage(ctx, database.GetMessageParams{ChatID: body.ChatID, MessageID: body.MessageID})
+			if errors.Is(err, pgx.ErrNoRows) {
+				return echo.NewHTTPError(http.StatusNotFound, "message not found")
+			}
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "message query failed").SetInternal(err)
+			}
+
+			if err := qtx.HideMessage(ctx, database.HideMessageParams{UserID: uid, MessageID: body.MessageID}); err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "message deletion failed").SetInternal(err)
+			}
+
+			// Only the caller's other devices need to know
+			notify = []int64{uid}
+			return nil
+		}
+
+		//-- Delete for everyone: leave a tombstone --//
+		current, err := qtx.GetMessageForUpdate(ctx, database.GetMessageForUpdateParams{ChatID: body.ChatID, MessageID: body.MessageID})
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusNotFound, "message not found")
+		}
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "message query failed").SetInternal(err)
+		}
+
+		if current.SenderID != uid && !roleCan(role, permDeleteAnyMessage) {
+			return echo.NewHTTPError(http.StatusForbidden, "only the author or an admin can delete this message for everyone")
+		}
+		if current.DeletedAt.Valid {
+			return nil
+		}
+
+		if _, err := qtx.DeleteMessageForEveryone(ctx, database.DeleteMessageForEveryoneParams{MessageID: body.MessageID}); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "message deletion failed").SetInternal(err)
+		}
+		if err := qtx.DeleteMessageRevisions(ctx, database.DeleteMessageRevisionsParams{MessageID: body.MessageID}); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "message deletion failed").SetInternal(err)
+		}
+		if err := qtx.DeleteMessageReactions(ctx, database.DeleteMessageReactionsParams{MessageID: body.MessageID}); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "message deletion failed").SetInternal(err)
+		}
+		if err := qtx.DeleteMessageSearchTokens(ctx, database.DeleteMessageSearchTokensParams{MessageID: body.MessageID}); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "message deletion failed").SetInternal(err)
+		}
+		files, err := qtx.DeleteMessageAttachments(ctx, database.DeleteMessageAttachmentsParams{MessageID: body.MessageID})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "message deletion failed").SetInternal(err)
+		}
+		for _, f := range files {
+			blobKeys = append(blobKeys, f.BlobKey)
+			if f.ThumbnailKey != nil {
+				blobKeys = append(blobKeys, *f.ThumbnailKey)
+			}
+		}
+
+		// The chat's last message may have been this one
+		if err := qtx.RefreshChatLastMessage(ctx, database.RefreshChatLastMessageParams{ChatID: body.ChatID}); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "update last message failed").SetInternal(err)
+		}
+
+		notify, err = qtx.ListChatParticipantIDs(ctx, database.ListChatParticipantIDsParams{ChatID: body.ChatID})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "participants query failed").SetInternal(err)
+		}
+
+		return nil
+	}); err != nil {
+		return err
 	}
 
-	if err := tx.Commit(ctx); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "commit failed")
+	// Only once the rows are gone, so a failed delete never leaves a message
+	// pointing at a missing file
+	for _, key := range blobKeys {
+		if err := message.blobs.Delete(ctx, key); err != nil {
+			c.Logger().Errorf("orphaned attachment blob %s: %v", key, err)
+		}
 	}
 
-	return c.JSON(http.StatusCreated, messageId)
+	//-- Notify participants --//
+	if len(notify) > 0 {
+		message.hub.Publish(realtime.Event{
+			Type:   realtime.EventMessageDeleted,
+			ChatID: body.ChatID,
+			Data:   MessageDeletedEvent{MessageID: body.MessageID, Scope: body.Scope},
+		}, notify...)
+	}
+
+	return c.NoContent(http.StatusNoContent)
 }
@@ -0,0 +1,157 @@
+package route
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestMessagePages(t *testing.T) {
+	s := newTestServer(t)
+	ada, bob, cy := s.register("ada"), s.register("bob"), s.register("cy")
+	s.befriend(ada, bob)
+	chatID := s.group(ada, bob)
+
+	var ids []int64
+	for i := range 5 {
+		ids = append(ids, s.send(ada, chatID, fmt.Sprintf("message %d", i)))
+	}
+	base := fmt.Sprintf("/v1/chats/%d/messages", chatID)
+
+	contents := func(p MessagePage) []string {
+		var out []string
+		for _, m := range p.Messages {
+			out = append(out, m.Content)
+		}
+		return out
+	}
+
+	// Newest first, walking back with next_cursor
+	var page MessagePage
+	s.expect(http.StatusOK, http.MethodGet, base+"?limit=2", bob.AccessToken, nil, &page)
+	if got := fmt.Sprint(contents(page)); got != "[message 4 message 3]" {
+		t.Errorf("first page = %s", got)
+	}
+	if page.NextCursor == nil || *page.NextCursor != ids[3] {
+		t.Fatalf("next_cursor = %v, want %d", page.NextCursor, ids[3])
+	}
+	s.expect(http.StatusOK, http.MethodGet, fmt.Sprintf("%s?limit=2&before=%d", base, *page.NextCursor), bob.AccessToken, nil, &page)
+	if got := fmt.Sprint(contents(page)); got != "[message 2 message 1]" {
+		t.Errorf("second page = %s", got)
+	}
+	if page.PrevCursor == nil || *page.PrevCursor != ids[2] {
+		t.Errorf("prev_cursor = %v, want %d", page.PrevCursor, ids[2])
+	}
+
+	// Oldest first from a cursor
+	s.expect(http.StatusOK, http.MethodGet, fmt.Sprintf("%s?order=asc&after=%d", base, ids[1]), bob.AccessToken, nil, &page)
+	if got := fmt.Sprint(contents(page)); got != "[message 2 message 3 message 4]" {
+		t.Errorf("asc page = %s", got)
+	}
+	if page.NextCursor != nil {
+		t.Errorf("next_cursor = %d on the last page", *page.NextCursor)
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		query  string
+		status int
+	}{
+		{"non-member", cy.AccessToken, "", http.StatusForbidden},
+		{"both cursors", bob.AccessToken, "?before=3&after=1", http.StatusBadRequest},
+		{"limit", bob.AccessToken, "?limit=101", http.StatusBadRequest},
+		{"order", bob.AccessToken, "?order=random", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if rec := s.do(http.MethodGet, base+tt.query, tt.token, nil); rec.Code != tt.status {
+			t.Errorf("%s: list = %d %s, want %d", tt.name, rec.Code, rec.Body, tt.status)
+		}
+	}
+	s.expect(http.StatusForbidden, http.MethodPost, base, cy.AccessToken, map[string]any{"content": "hi"}, nil)
+}
+
+func TestDeleteMessage(t *testing.T) {
+	s := newTestServer(t)
+	ada, bob := s.register("ada"), s.register("bob")
+	s.befriend(ada, bob)
+	chatID := s.group(ada, bob)
+	mine := s.send(ada, chatID, "for me")
+	everyone := s.send(ada, chatID, "for everyone")
+	base := fmt.Sprintf("/v1/chats/%d/messages", chatID)
+
+	s.expect(http.StatusNoContent, http.MethodDelete, fmt.Sprintf("%s/%d", base, mine), bob.AccessToken, nil, nil)
+	s.expect(http.StatusNoContent, http.MethodDelete, fmt.Sprintf("%s/%d?scope=everyone", base, everyone), ada.AccessToken, nil, nil)
+
+	var page MessagePage
+	s.expect(http.StatusOK, http.MethodGet, base, bob.AccessToken, nil, &page)
+	if len(page.Messages) != 1 || page.Messages[0].MessageID != everyone {
+		t.Fatalf("bob sees %+v", page.Messages)
+	}
+	if m := page.Messages[0]; m.DeletedAt == nil || m.Content != "" {
+		t.Errorf("message deleted for everyone = %+v", m)
+	}
+
+	s.expect(http.StatusOK, http.MethodGet, base, ada.AccessToken, nil, &page)
+	if len(page.Messages) != 2 || page.Messages[1].Content != "for me" {
+		t.Errorf("ada sees %+v", page.Messages)
+	}
+}
+
+func TestEditMessage(t *testing.T) {
+	s := newTestServer(t)
+	ada, bob, cy := s.register("ada"), s.register("bob"), s.register("cy")
+	s.befriend(ada, bob)
+	chatID := s.group(ada, bob)
+	id := s.send(ada, chatID, "first")
+	path := fmt.Sprintf("/v1/chats/%d/messages/%d", chatID, id)
+
+	var edited MessageResponse
+	s.expect(http.StatusOK, http.MethodPatch, path, ada.AccessToken, map[string]any{"content": "second"}, &edited)
+	if edited.Content != "second" || edited.EditedAt == nil {
+		t.Errorf("edited = %+v", edited)
+	}
+	s.expect(http.StatusOK, http.MethodPatch, path, ada.AccessToken, map[string]any{"content": "third"}, nil)
+
+	// Only the author edits, and only messages that exist
+	s.expect(http.StatusForbidden, http.MethodPatch, path, bob.AccessToken, map[string]any{"content": "mine now"}, nil)
+	s.expect(http.StatusForbidden, http.MethodPatch, path, cy.AccessToken, map[string]any{"content": "mine now"}, nil)
+	s.expect(http.StatusNotFound, http.MethodPatch, fmt.Sprintf("/v1/chats/%d/messages/%d", chatID, id+100), ada.AccessToken, map[string]any{"content": "x"}, nil)
+
+	var page MessagePage
+	s.expect(http.StatusOK, http.MethodGet, fmt.Sprintf("/v1/chats/%d/messages", chatID), bob.AccessToken, nil, &page)
+	if len(page.Messages) != 1 || page.Messages[0].Content != "third" || page.Messages[0].EditedAt == nil {
+		t.Errorf("history after edits = %+v", page.Messages)
+	}
+
+	// Earlier bodies come back oldest first, to participants only
+	var revisions []MessageRevision
+	s.expect(http.StatusOK, http.MethodGet, path+"/revisions", bob.AccessToken, nil, &revisions)
+	if len(revisions) != 2 || revisions[0].Content != "first" || revisions[1].Content != "second" {
+		t.Errorf("revisions = %+v", revisions)
+	}
+	s.expect(http.StatusForbidden, http.MethodGet, path+"/revisions", cy.AccessToken, nil, nil)
+
+	other := s.send(bob, chatID, "never edited")
+	s.expect(http.StatusOK, http.MethodGet, fmt.Sprintf("/v1/chats/%d/messages/%d/revisions", chatID, other), ada.AccessToken, nil, &revisions)
+	if len(revisions) != 0 {
+		t.Errorf("unedited message has revisions %+v", revisions)
+	}
+}
+
+func TestEditWindow(t *testing.T) {
+	s := newTestServer(t)
+	ada, bob := s.register("ada"), s.register("bob")
+	s.befriend(ada, bob)
+	chatID := s.group(ada, bob)
+
+	// A message sent before the window opened can no longer be edited
+	s.store.SetClock(func() time.Time { return time.Now().Add(-time.Hour) })
+	old := s.send(ada, chatID, "an hour ago")
+	s.store.SetClock(time.Now)
+	recent := s.send(ada, chatID, "just now")
+
+	s.expect(http.StatusForbidden, http.MethodPatch, fmt.Sprintf("/v1/chats/%d/messages/%d", chatID, old), ada.AccessToken, map[string]any{"content": "too late"}, nil)
+	s.expect(http.StatusOK, http.MethodPatch, fmt.Sprintf("/v1/chats/%d/messages/%d", chatID, recent), ada.AccessToken, map[string]any{"content": "in time"}, nil)
+}
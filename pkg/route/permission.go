@@ -0,0 +1,79 @@
+package route
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/astrokkidd/flick/pkg/database"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	roleOwner  = "owner"
+	roleAdmin  = "admin"
+	roleMember = "member"
+)
+
+type chatPermission int
+
+const (
+	permReadMessages chatPermission = iota
+	permSendMessages
+	permRenameChat
+	permAddMembers
+	permRemoveMembers
+	permDeleteAnyMessage
+	permManageRoles
+)
+
+// minimumRole is the lowest role allowed to exercise each permission.
+var minimumRole = map[chatPermission]string{
+	permReadMessages:     roleMember,
+	permSendMessages:     roleMember,
+	permRenameChat:       roleAdmin,
+	permAddMembers:       roleAdmin,
+	permRemoveMembers:    roleAdmin,
+	permDeleteAnyMessage: roleAdmin,
+	permManageRoles:      roleOwner,
+}
+
+func roleRank(role string) int {
+	switch role {
+	case roleOwner:
+		return 3
+	case roleAdmin:
+		return 2
+	case roleMember:
+		return 1
+	}
+	return 0
+}
+
+func roleCan(role string, perm chatPermission) bool {
+	min, ok := minimumRole[perm]
+	return ok && roleRank(role) >= roleRank(min)
+}
+
+// outranks reports whether actor may act on target, e.g. kick them or
+// change their role. Nobody outranks an equal.
+func outranks(actor, target string) bool {
+	return roleRank(actor) > roleRank(target)
+}
+
+// requireChatPermission resolves the caller's role in a chat and fails with
+// 403 when they are not a participant or their role is too low.
+func requireChatPermission(ctx context.Context, qtx database.Querier, chatID, uid int64, perm chatPermission) (string, error) {
+	role, err := qtx.GetParticipantRole(ctx, database.GetParticipantRoleParams{ChatID: chatID, UserID: uid})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", echo.NewHTTPError(http.StatusForbidden, "not a participant in this chat")
+	}
+	if err != nil {
+		return "", echo.NewHTTPError(http.StatusInternalServerError, "could not verify participant")
+	}
+	if !roleCan(role, perm) {
+		return role, echo.NewHTTPError(http.StatusForbidden, "insufficient role in this chat")
+	}
+	return role, nil
+}
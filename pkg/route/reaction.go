@@ -0,0 +1,204 @@
+package route
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/astrokkidd/flick/pkg/database"
+	"github.com/astrokkidd/flick/pkg/identity"
+	"github.com/astrokkidd/flick/pkg/realtime"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// ReactionSummary is one emoji on a message: how many participants put it
+// there and whether the caller is one of them.
+type ReactionSummary struct {
+	Emoji       string `json:"emoji"`
+	Count       int64  `json:"count"`
+	ReactedByMe bool   `json:"reacted_by_me"`
+}
+
+type ReactionEvent struct {
+	MessageID int64  `json:"message_id"`
+	UserID    int64  `json:"user_id"`
+	Emoji     string `json:"emoji"`
+}
+
+// maxEmojiBytes fits the longest ZWJ sequences with room to spare.
+const maxEmojiBytes = 64
+
+// validEmoji accepts a single emoji, including skin tones, ZWJ sequences,
+// flags and keycaps, and turns away anything with letters or spaces in it.
+func validEmoji(s string) bool {
+	if s == "" || len(s) > maxEmojiBytes || !utf8.ValidString(s) {
+		return false
+	}
+
+	keycap := strings.ContainsRune(s, '⃣')
+	symbol := false
+	for _, r := range s {
+		switch {
+		case unicode.Is(unicode.So, r):
+			symbol = true
+		case keycap && (r == '#' || r == '*' || unicode.IsDigit(r)):
+			symbol = true
+		case unicode.In(r, unicode.Sk, unicode.Mn, unicode.Me, unicode.Cf):
+			// modifiers, variation selectors, ZWJ and tags
+		default:
+			return false
+		}
+	}
+	return symbol
+}
+
+type reactionRequest struct {
+	ChatID    int64  `param:"id"`
+	MessageID int64  `param:"message_id"`
+	Emoji     string `json:"emoji" query:"emoji"`
+}
+
+// AddReaction puts an emoji on a message. Reacting twice with the same
+// emoji is a no-op.
+func (message *Message) AddReaction(c echo.Context) error {
+	claims, err := identity.GetUserClaims(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+	uid := claims.ID()
+
+	var body reactionRequest
+	if err := c.Bind(&body); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid json").SetInternal(err)
+	}
+	if !validEmoji(body.Emoji) {
+		return echo.NewHTTPError(http.StatusBadRequest, "emoji must be a single emoji")
+	}
+
+	var (
+		added        bool
+		participants []int64
+	)
+
+	//-- Begin tx --//
+	ctx := c.Request().Context()
+	if err := withTx(ctx, message.store, func(qtx database.Querier) error {
+		if _, err := requireChatPermission(ctx, qtx, body.ChatID, uid, permSendMessages); err != nil {
+			return err
+		}
+
+		// Locking the message serializes reactions to it, which keeps the
+		// cap below honest
+		m, err := qtx.GetMessageForUpdate(ctx, database.GetMessageForUpdateParams{ChatID: body.ChatID, MessageID: body.MessageID})
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusNotFound, "message not found")
+		}
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "message query failed").SetInternal(err)
+		}
+		if m.DeletedAt.Valid {
+			return echo.NewHTTPError(http.StatusConflict, "message was deleted")
+		}
+
+		others, err := qtx.CountOtherReactionEmojis(ctx, database.CountOtherReactionEmojisParams{MessageID: body.MessageID, Emoji: body.Emoji})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "reactions query failed").SetInternal(err)
+		}
+		if others >= message.maxReactions {
+			return echo.NewHTTPError(http.StatusConflict, "message has too many different reactions")
+		}
+
+		n, err := qtx.AddReaction(ctx, database.AddReactionParams{MessageID: body.MessageID, UserID: uid, Emoji: body.Emoji})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "reaction failed").SetInternal(err)
+		}
+		if added = n > 0; !added {
+			return nil
+		}
+
+		participants, err = qtx.ListChatParticipantIDs(ctx, database.ListChatParticipantIDsParams{ChatID: body.ChatID})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "participants query failed").SetInternal(err)
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	//-- Notify participants --//
+	if added {
+		message.hub.Publish(realtime.Event{
+			Type:   realtime.EventReactionAdded,
+			ChatID: body.ChatID,
+			Data:   ReactionEvent{MessageID: body.MessageID, UserID: uid, Emoji: body.Emoji},
+		}, participants...)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// RemoveReaction takes the caller's emoji, given as ?emoji=, off a message.
+func (message *Message) RemoveReaction(c echo.Context) error {
+	claims, err := identity.GetUserClaims(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+	uid := claims.ID()
+
+	var body reactionRequest
+	if err := c.Bind(&body); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid input").SetInternal(err)
+	}
+	if body.Emoji == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "missing emoji")
+	}
+
+	var participants []int64
+
+	//-- Begin tx --//
+	ctx := c.Request().Context()
+	if err := withTx(ctx, message.store, func(qtx database.Querier) error {
+		if _, err := requireChatPermission(ctx, qtx, body.ChatID, uid, permReadMessages); err != nil {
+			return err
+		}
+
+		// Make sure the message belongs to the chat the permission was checked in
+		if _, err := qtx.GetMessage(ctx, database.GetMessageParams{ChatID: body.ChatID, MessageID: body.MessageID}); errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusNotFound, "message not found")
+		} else if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "message query failed").SetInternal(err)
+		}
+
+		n, err := qtx.RemoveReaction(ctx, database.RemoveReactionParams{MessageID: body.MessageID, UserID: uid, Emoji: body.Emoji})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "reaction removal failed").SetInternal(err)
+		}
+		if n == 0 {
+			return nil
+		}
+
+		participants, err = qtx.ListChatParticipantIDs(ctx, database.ListChatParticipantIDsParams{ChatID: body.ChatID})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "participants query failed").SetInternal(err)
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	//-- Notify participants --//
+	if len(participants) > 0 {
+		message.hub.Publish(realtime.Event{
+			Type:   realtime.EventReactionRemoved,
+			ChatID: body.ChatID,
+			Data:   ReactionEvent{MessageID: body.MessageID, UserID: uid, Emoji: body.Emoji},
+		}, participants...)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
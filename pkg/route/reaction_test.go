@@ -0,0 +1,99 @@
+package route
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestValidEmoji(t *testing.T) {
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{"👍", true},
+		{"👍🏽", true},  // skin tone
+		{"👩‍💻", true}, // ZWJ sequence
+		{"🇳🇿", true},  // flag
+		{"#️⃣", true}, // keycap
+		{"❤️", true},  // variation selector
+		{"", false},
+		{"a", false},
+		{"👍 ", false},
+		{"ok👍", false},
+		{"#", false},
+		{string([]byte{0xff}), false},
+	}
+	for _, tt := range tests {
+		if got := validEmoji(tt.in); got != tt.want {
+			t.Errorf("validEmoji(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestReactions(t *testing.T) {
+	s := newTestServer(t)
+	ada, bob, cy := s.register("ada"), s.register("bob"), s.register("cy")
+	s.befriend(ada, bob)
+	chatID := s.group(ada, bob)
+	id := s.send(ada, chatID, "hello")
+	path := fmt.Sprintf("/v1/chats/%d/messages/%d/reactions", chatID, id)
+
+	react := func(u testUser, emoji string) int {
+		return s.do(http.MethodPost, path, u.AccessToken, map[string]string{"emoji": emoji}).Code
+	}
+	reactions := func(u testUser) []ReactionSummary {
+		var page MessagePage
+		s.expect(http.StatusOK, http.MethodGet, fmt.Sprintf("/v1/chats/%d/messages", chatID), u.AccessToken, nil, &page)
+		return page.Messages[0].Reactions
+	}
+
+	// Reacting twice with the same emoji counts once
+	for _, u := range []testUser{ada, bob, bob} {
+		if code := react(u, "👍"); code != http.StatusNoContent {
+			t.Fatalf("react = %d, want 204", code)
+		}
+	}
+	if got := reactions(ada); len(got) != 1 || got[0] != (ReactionSummary{Emoji: "👍", Count: 2, ReactedByMe: true}) {
+		t.Errorf("reactions = %+v", got)
+	}
+
+	if code := react(bob, "thumbs up"); code != http.StatusBadRequest {
+		t.Errorf("react with text = %d, want 400", code)
+	}
+	if code := react(cy, "👍"); code != http.StatusForbidden {
+		t.Errorf("react as non-member = %d, want 403", code)
+	}
+	if code := s.do(http.MethodPost, fmt.Sprintf("/v1/chats/%d/messages/%d/reactions", chatID, id+100), ada.AccessToken, map[string]string{"emoji": "👍"}).Code; code != http.StatusNotFound {
+		t.Errorf("react to a missing message = %d, want 404", code)
+	}
+
+	s.expect(http.StatusNoContent, http.MethodDelete, path+"?emoji="+url.QueryEscape("👍"), ada.AccessToken, nil, nil)
+	if got := reactions(ada); len(got) != 1 || got[0] != (ReactionSummary{Emoji: "👍", Count: 1}) {
+		t.Errorf("reactions after removal = %+v", got)
+	}
+	// Removing one that isn't there is not an error
+	s.expect(http.StatusNoContent, http.MethodDelete, path+"?emoji="+url.QueryEscape("🎉"), ada.AccessToken, nil, nil)
+	s.expect(http.StatusBadRequest, http.MethodDelete, path, ada.AccessToken, nil, nil)
+}
+
+func TestReactionCap(t *testing.T) {
+	s := newTestServer(t)
+	ada, bob := s.register("ada"), s.register("bob")
+	s.befriend(ada, bob)
+	chatID := s.group(ada, bob)
+	id := s.send(ada, chatID, "hello")
+	path := fmt.Sprintf("/v1/chats/%d/messages/%d/reactions", chatID, id)
+
+	// The test server allows 20 different emoji on a message
+	for i := range 20 {
+		s.expect(http.StatusNoContent, http.MethodPost, path, ada.AccessToken, map[string]string{"emoji": string(rune(0x1F600 + i))}, nil)
+	}
+	if rec := s.do(http.MethodPost, path, bob.AccessToken, map[string]string{"emoji": "🎉"}); rec.Code != http.StatusConflict {
+		t.Errorf("21st emoji = %d, want 409", rec.Code)
+	}
+
+	// Joining an emoji already there adds nothing new, so it is allowed
+	s.expect(http.StatusNoContent, http.MethodPost, path, bob.AccessToken, map[string]string{"emoji": string(rune(0x1F600))}, nil)
+}
@@ -10,13 +10,12 @@ import (
 
 	"github.com/astrokkidd/flick/pkg/database"
 	"github.com/astrokkidd/flick/pkg/identity"
-	"github.com/jackc/pgx/v5"
+	"github.com/astrokkidd/flick/pkg/store"
 	"github.com/labstack/echo/v4"
 )
 
 type Request struct {
-	queries      *database.Queries
-	conn         *pgx.Conn
+	store        store.Store
 	tokenHandler *identity.TokenHandler
 }
 
@@ -29,8 +28,8 @@ type FriendResponse struct {
 	FriendshipTs string `json:"friendship_ts"` // string for React
 }
 
-func NewRequestHandler(queries *database.Queries, conn *pgx.Conn, tokenHandler *identity.TokenHandler) Request {
-	return Request{queries, conn, tokenHandler}
+func NewRequestHandler(store store.Store, tokenHandler *identity.TokenHandler) Request {
+	return Request{store, tokenHandler}
 }
 
 func (r *Request) SendRequest(c echo.Context) error {
@@ -53,58 +52,62 @@ func (r *Request) SendRequest(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "display_name is required")
 	}
 
+	var fr database.FriendRequest
+
 	//-- Begin tx --//
 	ctx := c.Request().Context()
-	tx, err := r.conn.Begin(ctx)
-	if err != nil {
-		return echo.ErrInternalServerError
-	}
-	defer tx.Rollback(ctx)
-
-	qtx := r.queries.WithTx(tx)
+	if err := withTx(ctx, r.store, func(qtx database.Querier) error {
+		//-- Get the user id from payload display name --//
+		receiver, err := qtx.FindUserByDisplayName(ctx, database.FindUserByDisplayNameParams{DisplayName: dn})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, "user not found").SetInternal(err)
+		}
+		if receiver.UserID == uid {
+			return echo.NewHTTPError(http.StatusBadRequest, "cannot send request to yourself")
+		}
 
-	//-- Get the user id from payload display name --//
-	receiver, err := qtx.FindUserByDisplayName(ctx, database.FindUserByDisplayNameParams{DisplayName: dn})
-	if err != nil {
-		return echo.NewHTTPError(http.StatusNotFound, "user not found").SetInternal(err)
-	}
-	if receiver.UserID == uid {
-		return echo.NewHTTPError(http.StatusBadRequest, "cannot send request to yourself")
-	}
+		//-- Blocked users don't exist to each other --//
+		blocked, err := qtx.IsBlockedBetween(ctx, database.IsBlockedBetweenParams{UserID: uid, UserID2: receiver.UserID})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "block check failed").SetInternal(err)
+		}
+		if blocked {
+			return echo.NewHTTPError(http.StatusNotFound, "user not found")
+		}
 
-	// -- See if friend request already exists --//
-	alreadyExists, err := qtx.DoesFriendRequestExist(ctx, database.DoesFriendRequestExistParams{
-		SenderID:   uid,
-		ReceiverID: receiver.UserID,
-	})
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "friend request existence check failed").SetInternal(err)
-	}
-	if alreadyExists {
-		return echo.NewHTTPError(http.StatusConflict, "friend request already exists")
-	}
+		// -- See if friend request already exists --//
+		alreadyExists, err := qtx.DoesFriendRequestExist(ctx, database.DoesFriendRequestExistParams{
+			SenderID:   uid,
+			ReceiverID: receiver.UserID,
+		})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "friend request existence check failed").SetInternal(err)
+		}
+		if alreadyExists {
+			return echo.NewHTTPError(http.StatusConflict, "friend request already exists")
+		}
 
-	//-- See if users are already friends --//
-	alreadyFriends, err := qtx.AreUsersFriends(ctx, database.AreUsersFriendsParams{UserID: uid, UserID_2: receiver.UserID})
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "friend existence check failed").SetInternal(err)
-	}
-	if alreadyFriends {
-		return echo.NewHTTPError(http.StatusConflict, "users already friends")
-	}
+		//-- See if users are already friends --//
+		alreadyFriends, err := qtx.AreUsersFriends(ctx, database.AreUsersFriendsParams{UserID: uid, UserID_2: receiver.UserID})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "friend existence check failed").SetInternal(err)
+		}
+		if alreadyFriends {
+			return echo.NewHTTPError(http.StatusConflict, "users already friends")
+		}
 
-	//-- Create friend request --//
-	fr, err := qtx.CreateFriendRequest(ctx, database.CreateFriendRequestParams{
-		SenderID:   uid,
-		ReceiverID: receiver.UserID,
-	})
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "insert failed").SetInternal(err)
-	}
+		//-- Create friend request --//
+		fr, err = qtx.CreateFriendRequest(ctx, database.CreateFriendRequestParams{
+			SenderID:   uid,
+			ReceiverID: receiver.UserID,
+		})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "insert failed").SetInternal(err)
+		}
 
-	//-- Commit all queries --//
-	if err := tx.Commit(ctx); err != nil {
-		return echo.ErrInternalServerError
+		return nil
+	}); err != nil {
+		return err
 	}
 
 	return c.JSON(http.StatusCreated, fr)
@@ -119,7 +122,7 @@ func (request *Request) GetReceivedRequests(c echo.Context) error {
 	uid := claims.ID()
 
 	//-- List all friend requests to user --//
-	result, err := request.queries.ListReceivedFriendRequestsWithUser(c.Request().Context(), database.ListReceivedFriendRequestsWithUserParams{ReceiverID: uid})
+	result, err := request.store.ListReceivedFriendRequestsWithUser(c.Request().Context(), database.ListReceivedFriendRequestsWithUserParams{ReceiverID: uid})
 	if err != nil {
 		return echo.ErrInternalServerError.WithInternal(err)
 	}
@@ -139,7 +142,7 @@ func (request *Request) GetSentRequests(c echo.Context) error {
 	uid := claims.ID()
 
 	//-- List all friend requests to user --//
-	result, err := request.queries.ListSentFriendRequestsWithUser(c.Request().Context(), database.ListSentFriendRequestsWithUserParams{SenderID: uid})
+	result, err := request.store.ListSentFriendRequestsWithUser(c.Request().Context(), database.ListSentFriendRequestsWithUserParams{SenderID: uid})
 	if err != nil {
 		return echo.ErrInternalServerError.WithInternal(err)
 	}
@@ -159,7 +162,7 @@ func (request *Request) GetFriends(c echo.Context) error {
 	uid := claims.ID()
 
 	//-- List all friends of user --//
-	result, err := request.queries.ListAllFriends(c.Request().Context(), database.ListAllFriendsParams{UserID: uid})
+	result, err := request.store.ListAllFriends(c.Request().Context(), database.ListAllFriendsParams{UserID: uid})
 	if err != nil {
 		return echo.ErrInternalServerError.WithInternal(err)
 	}
@@ -196,37 +199,32 @@ func (request *Request) AcceptRequest(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "invalid request id")
 	}
 
+	var res int64
+
 	//-- Begin tx --//
 	ctx := c.Request().Context()
-	tx, err := request.conn.Begin(ctx)
-	if err != nil {
-		return echo.ErrInternalServerError
-	}
-	defer tx.Rollback(ctx)
-
-	qtx := request.queries.WithTx(tx)
-
-	//-- Get friend request sender --//
-	fid, err := qtx.GetUserByRequestID(ctx, database.GetUserByRequestIDParams{RequestID: rid})
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "query failed")
-	}
+	if err := withTx(ctx, request.store, func(qtx database.Querier) error {
+		//-- Get friend request sender --//
+		fid, err := qtx.GetUserByRequestID(ctx, database.GetUserByRequestIDParams{RequestID: rid})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "query failed")
+		}
 
-	//-- Create friendship --//
-	err = qtx.CreateFriendship(ctx, database.CreateFriendshipParams{UserID: uid, FriendID: fid})
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "friendship insert failed")
-	}
+		//-- Create friendship --//
+		err = qtx.CreateFriendship(ctx, database.CreateFriendshipParams{UserID: uid, FriendID: fid})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "friendship insert failed")
+		}
 
-	//-- Delete deprecated friend request --//
-	res, err := qtx.DeleteFriendRequest(ctx, database.DeleteFriendRequestParams{SenderID: fid, RequestID: rid})
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "request delete failed")
-	}
+		//-- Delete deprecated friend request --//
+		res, err = qtx.DeleteFriendRequest(ctx, database.DeleteFriendRequestParams{SenderID: fid, RequestID: rid})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "request delete failed")
+		}
 
-	//-- Commit queries --//
-	if err := tx.Commit(ctx); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "commit failed")
+		return nil
+	}); err != nil {
+		return err
 	}
 
 	return c.JSON(http.StatusOK, res)
@@ -251,36 +249,31 @@ func (request *Request) DeleteRequest(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "invalid request id")
 	}
 
+	var res int64
+
 	//-- Begin tx --//
 	ctx := c.Request().Context()
-	tx, err := request.conn.Begin(ctx)
-	if err != nil {
-		return echo.ErrInternalServerError
-	}
-	defer tx.Rollback(ctx)
-
-	qtx := request.queries.WithTx(tx)
-
-	//-- Get friend request sender --//
-	fid, err := qtx.GetUserByRequestID(ctx, database.GetUserByRequestIDParams{
-		RequestID: rid,
-	})
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "query failed")
-	}
+	if err := withTx(ctx, request.store, func(qtx database.Querier) error {
+		//-- Get friend request sender --//
+		fid, err := qtx.GetUserByRequestID(ctx, database.GetUserByRequestIDParams{
+			RequestID: rid,
+		})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "query failed")
+		}
 
-	//-- Delete deprecated friend request --//
-	res, err := qtx.DeleteFriendRequest(ctx, database.DeleteFriendRequestParams{
-		SenderID:  fid,
-		RequestID: rid,
-	})
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "request delete failed")
-	}
+		//-- Delete deprecated friend request --//
+		res, err = qtx.DeleteFriendRequest(ctx, database.DeleteFriendRequestParams{
+			SenderID:  fid,
+			RequestID: rid,
+		})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "request delete failed")
+		}
 
-	//-- Commit queries --//
-	if err := tx.Commit(ctx); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "commit failed")
+		return nil
+	}); err != nil {
+		return err
 	}
 
 	return c.JSON(http.StatusOK, res)
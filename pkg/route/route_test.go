@@ -0,0 +1,233 @@
+package route
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/astrokkidd/flick/pkg/blob"
+	"github.com/astrokkidd/flick/pkg/crypto"
+	"github.com/astrokkidd/flick/pkg/identity"
+	"github.com/astrokkidd/flick/pkg/realtime"
+	"github.com/astrokkidd/flick/pkg/store"
+	"github.com/labstack/echo/v4"
+)
+
+// testPassword passes the default policy and is on no common list.
+const testPassword = "plum-river-lantern"
+
+// testServer routes requests the way cmd/flick does, against the in-memory
+// store and a blob directory that lives as long as the test.
+type testServer struct {
+	t       *testing.T
+	e       *echo.Echo
+	store   *store.Memory
+	blobDir string
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	keyring, err := crypto.NewKeyring("test", map[string]string{"test": base64.StdEncoding.EncodeToString(key)}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cipher := crypto.NewAESGCM(keyring, crypto.NewEnvKeyProvider(keyring))
+
+	blobDir := t.TempDir()
+	blobs, err := blob.NewLocal(blobDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	passwords, err := identity.NewPasswordPolicy(12, 128, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashing, err := identity.NewArgon2Params(8*1024, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	st := store.NewMemory()
+	tokenHandler := identity.NewTokenHandler([]byte("test secret"), time.Minute, time.Hour)
+	sessions := identity.NewSessionCache(NewSessionLookup(st), time.Minute)
+	auth := identity.Authenticate(&tokenHandler, sessions)
+	hub := realtime.NewHub()
+	t.Cleanup(hub.Close)
+	sessions.OnRevoke(hub.DisconnectSession)
+
+	e := echo.New()
+	api := e.Group("/v1")
+
+	authHandler := NewAuthHandler(st, &tokenHandler, sessions, passwords, hashing, cipher)
+	api.POST("/auth/register", authHandler.Register)
+	api.POST("/auth/login", authHandler.Login)
+	api.POST("/auth/refresh", authHandler.Refresh)
+	api.POST("/auth/login/totp", authHandler.LoginTOTP)
+	api.POST("/auth/recover", authHandler.Recover)
+	session := api.Group("/auth", auth)
+	session.POST("/logout", authHandler.Logout)
+	session.GET("/sessions", authHandler.ListSessions)
+	session.DELETE("/sessions/:id", authHandler.RevokeSession)
+	session.POST("/totp/enroll", authHandler.EnrollTOTP)
+	session.POST("/totp/confirm", authHandler.ConfirmTOTP)
+
+	userHandler := NewUserHandler(st, &tokenHandler, sessions, passwords, hashing, blobs, 1<<20)
+	users := api.Group("/users", auth)
+	users.PUT("/password", userHandler.UpdatePassword)
+	users.GET("/blocks", userHandler.ListBlockedUsers)
+	users.GET("/:id", userHandler.GetUserProfile)
+	users.PUT("/:id/block", userHandler.BlockUser)
+	users.DELETE("/:id/block", userHandler.UnblockUser)
+
+	requestHandler := NewRequestHandler(st, &tokenHandler)
+	friends := api.Group("/friends", auth)
+	friends.GET("", requestHandler.GetFriends)
+	friends.POST("/requests/send", requestHandler.SendRequest)
+	friends.POST("/requests/:id/accept", requestHandler.AcceptRequest)
+
+	chatHandler := NewChatHandler(st, &tokenHandler, hub, cipher, blobs)
+	chat := api.Group("/chats", auth)
+	chat.POST("", chatHandler.CreateChat)
+	chat.GET("", chatHandler.GetChats)
+	chat.POST("/:id/read", chatHandler.SetLastReadMessage)
+	chat.PUT("/:id/title", chatHandler.RenameChat)
+	chat.POST("/:id/members", chatHandler.AddMembers)
+	chat.DELETE("/:id/members/:user_id", chatHandler.RemoveMember)
+	chat.PUT("/:id/members/:user_id/role", chatHandler.SetMemberRole)
+	chat.POST("/:id/leave", chatHandler.LeaveChat)
+
+	messageHandler := NewMessageHandler(st, &tokenHandler, hub, cipher, blobs, 15*time.Minute, 20)
+	chat.POST("/:id/messages", messageHandler.CreateMessage)
+	chat.GET("/:id/messages", messageHandler.GetMessages)
+	chat.PATCH("/:id/messages/:message_id", messageHandler.EditMessage)
+	chat.DELETE("/:id/messages/:message_id", messageHandler.DeleteMessage)
+	chat.GET("/:id/messages/:message_id/revisions", messageHandler.GetRevisions)
+	chat.POST("/:id/messages/:message_id/reactions", messageHandler.AddReaction)
+	chat.DELETE("/:id/messages/:message_id/reactions", messageHandler.RemoveReaction)
+	chat.GET("/:id/messages/:message_id/thread", messageHandler.GetThread)
+	chat.POST("/:id/messages/:message_id/thread/read", messageHandler.SetThreadLastRead)
+
+	attachmentHandler := NewAttachmentHandler(st, &tokenHandler, cipher, blobs, 1<<20, 4<<20)
+	chat.POST("/:id/attachments", attachmentHandler.Upload)
+	chat.GET("/:id/attachments/:attachment_id", attachmentHandler.Download)
+	chat.GET("/:id/attachments/:attachment_id/thumbnail", attachmentHandler.Thumbnail)
+
+	searches := api.Group("/search", auth)
+	searches.GET("/messages", messageHandler.SearchMessages)
+	searches.GET("/users", userHandler.SearchUsers)
+
+	return &testServer{t: t, e: e, store: st, blobDir: blobDir}
+}
+
+// do sends a request, JSON encoding body unless it is already a reader.
+func (s *testServer) do(method, path, token string, body any) *httptest.ResponseRecorder {
+	s.t.Helper()
+
+	var r io.Reader
+	contentType := echo.MIMEApplicationJSON
+	switch b := body.(type) {
+	case nil:
+	case *multipartBody:
+		r, contentType = b.buf, b.contentType
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		r = bytes.NewReader(data)
+	}
+
+	req := httptest.NewRequest(method, path, r)
+	if r != nil {
+		req.Header.Set(echo.HeaderContentType, contentType)
+	}
+	if token != "" {
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	s.e.ServeHTTP(rec, req)
+	return rec
+}
+
+// expect sends a request and fails the test unless it gets status, decoding
+// the response into out when set.
+func (s *testServer) expect(status int, method, path, token string, body, out any) {
+	s.t.Helper()
+	rec := s.do(method, path, token, body)
+	if rec.Code != status {
+		s.t.Fatalf("%s %s = %d %s, want %d", method, path, rec.Code, strings.TrimSpace(rec.Body.String()), status)
+	}
+	if out != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			s.t.Fatalf("%s %s: decoding %q: %v", method, path, rec.Body.String(), err)
+		}
+	}
+}
+
+type testUser struct {
+	ID           int64  `json:"user_id"`
+	DisplayName  string `json:"display_name"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+func (s *testServer) register(name string) testUser {
+	s.t.Helper()
+	var u testUser
+	s.expect(http.StatusCreated, http.MethodPost, "/v1/auth/register", "", map[string]string{
+		"display_name": name,
+		"password":     testPassword,
+		"first_name":   name,
+		"last_name":    "Test",
+	}, &u)
+	return u
+}
+
+func (s *testServer) befriend(a, b testUser) {
+	s.t.Helper()
+	var req struct {
+		RequestID int64 `json:"request_id"`
+	}
+	s.expect(http.StatusCreated, http.MethodPost, "/v1/friends/requests/send", a.AccessToken, map[string]string{"display_name": b.DisplayName}, &req)
+	s.expect(http.StatusOK, http.MethodPost, fmt.Sprintf("/v1/friends/requests/%d/accept", req.RequestID), b.AccessToken, nil, nil)
+}
+
+// group creates a group chat owned by owner with the given members, who
+// must already be owner's friends.
+func (s *testServer) group(owner testUser, members ...testUser) int64 {
+	s.t.Helper()
+	ids := []int64{}
+	for _, m := range members {
+		ids = append(ids, m.ID)
+	}
+	var created struct {
+		ChatID int64 `json:"chat_id"`
+	}
+	s.expect(http.StatusCreated, http.MethodPost, "/v1/chats", owner.AccessToken, map[string]any{
+		"kind":            "group",
+		"title":           "Test group",
+		"participant_ids": ids,
+	}, &created)
+	return created.ChatID
+}
+
+func (s *testServer) send(from testUser, chatID int64, content string) int64 {
+	s.t.Helper()
+	var id int64
+	s.expect(http.StatusCreated, http.MethodPost, fmt.Sprintf("/v1/chats/%d/messages", chatID), from.AccessToken, map[string]any{"content": content}, &id)
+	return id
+}
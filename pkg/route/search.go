@@ -0,0 +1,262 @@
+package route
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/astrokkidd/flick/pkg/crypto"
+	"github.com/astrokkidd/flick/pkg/database"
+	"github.com/astrokkidd/flick/pkg/identity"
+	"github.com/astrokkidd/flick/pkg/search"
+	"github.com/labstack/echo/v4"
+)
+
+// SearchResult is a message matching a search, with a snippet of its body
+// around the first matching word.
+type SearchResult struct {
+	MessageID    int64   `json:"message_id"`
+	ChatID       int64   `json:"chat_id"`
+	SenderID     int64   `json:"sender_id"`
+	Snippet      string  `json:"snippet"`
+	CreatedAt    string  `json:"created_at"`
+	EditedAt     *string `json:"edited_at"`
+	ThreadRootID *int64  `json:"thread_root_id,omitempty"` // replies in a thread only
+}
+
+// SearchPage is one page of search results, newest first. Pass next_cursor
+// back as before for the next page.
+type SearchPage struct {
+	Results    []SearchResult `json:"results"`
+	NextCursor *int64         `json:"next_cursor"`
+}
+
+// UserResult is a user matching a directory search. Real names follow the
+// same rule as PublicProfile.
+type UserResult struct {
+	UserID      int64   `json:"user_id"`
+	DisplayName string  `json:"display_name"`
+	FirstName   *string `json:"first_name,omitempty"`
+	LastName    *string `json:"last_name,omitempty"`
+	PfpURL      *string `json:"pfp_url"`
+	IsFriend    bool    `json:"is_friend"`
+}
+
+const (
+	defaultSearchPageSize int32 = 20
+	maxSearchPageSize     int32 = 50
+
+	defaultUserSearchSize int32 = 10
+	maxUserSearchSize     int32 = 25
+
+	// Shorter queries match most of the directory
+	minUserQueryRunes = 2
+	maxUserQueryRunes = 64
+
+	// Each word is digested once per chat searched
+	maxSearchTerms = 8
+
+	snippetWidth = 120 // runes
+)
+
+// indexMessage adds a message body to the chat's blind index.
+func indexMessage(ctx context.Context, qtx database.Querier, key crypto.DataKey, messageID int64, content string) error {
+	tokens, err := search.Index(key, content)
+	if err != nil || len(tokens) == 0 {
+		return err
+	}
+	return qtx.CreateMessageSearchTokens(ctx, database.CreateMessageSearchTokensParams{MessageID: messageID, Tokens: tokens})
+}
+
+// SearchMessages finds messages containing every word of q across the
+// caller's chats, or in chat_id alone. Bodies are sealed, so matching goes
+// through the blind index and only the matches are decrypted, to cut their
+// snippets. End-to-end chats are never indexed and can't be searched here.
+func (message *Message) SearchMessages(c echo.Context) error {
+	claims, err := identity.GetUserClaims(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+	uid := claims.ID()
+
+	var body struct {
+		Query    string `query:"q"`
+		ChatID   *int64 `query:"chat_id"`
+		BeforeID *int64 `query:"before"`
+		Limit    int32  `query:"limit"`
+	}
+	if err := c.Bind(&body); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid input").SetInternal(err)
+	}
+
+	terms := search.Tokens(body.Query)
+	if len(terms) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("q must contain a word of at least %d letters or digits", search.MinWordRunes))
+	}
+	if len(terms) > maxSearchTerms {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("q can have at most %d words", maxSearchTerms))
+	}
+
+	if body.Limit == 0 {
+		body.Limit = defaultSearchPageSize
+	}
+	if body.Limit < 0 || body.Limit > maxSearchPageSize {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxSearchPageSize))
+	}
+
+	page := SearchPage{Results: []SearchResult{}}
+
+	//-- Begin tx --//
+	ctx := c.Request().Context()
+	if err := withTx(ctx, message.store, func(qtx database.Querier) error {
+		if body.ChatID != nil {
+			if _, err := requireChatPermission(ctx, qtx, *body.ChatID, uid, permReadMessages); err != nil {
+				return err
+			}
+
+			endToEnd, err := qtx.IsChatEndToEnd(ctx, database.IsChatEndToEndParams{ChatID: *body.ChatID})
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "chat query failed")
+			}
+			if endToEnd {
+				return echo.NewHTTPError(http.StatusBadRequest, "end-to-end chats can only be searched on your devices")
+			}
+		}
+
+		chats, err := qtx.ListSearchableChats(ctx, database.ListSearchableChatsParams{UserID: uid, ChatID: body.ChatID})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "chats query failed").SetInternal(err)
+		}
+		if len(chats) == 0 {
+			return nil
+		}
+
+		//-- Digest the words under each chat's key --//
+		keys := make(map[int64]crypto.DataKey, len(chats))
+		chatIDs := make([]int64, 0, len(chats))
+		var tokens [][]byte
+		for _, chat := range chats {
+			key, err := message.cipher.OpenDataKey(ctx, chat.WrappedKey, crypto.ChatKeyAAD(chat.ChatID))
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "chat key unavailable").SetInternal(err)
+			}
+			digests, err := search.Digests(key, terms)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "chat key unavailable").SetInternal(err)
+			}
+
+			keys[chat.ChatID] = key
+			chatIDs = append(chatIDs, chat.ChatID)
+			tokens = append(tokens, digests...)
+		}
+
+		// One extra row tells whether there is another page
+		rows, err := qtx.SearchMessages(ctx, database.SearchMessagesParams{
+			ChatIds:   chatIDs,
+			BeforeID:  body.BeforeID,
+			Tokens:    tokens,
+			TermCount: int64(len(terms)),
+			UserID:    uid,
+			PageSize:  body.Limit + 1,
+		})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "search failed").SetInternal(err)
+		}
+		if int32(len(rows)) > body.Limit {
+			rows = rows[:body.Limit]
+			next := rows[len(rows)-1].MessageID
+			page.NextCursor = &next
+		}
+
+		for _, r := range rows {
+			plaintext, err := keys[r.ChatID].Decrypt(r.CypherText, crypto.MessageAAD(r.ChatID, r.SenderID, r.MessageID))
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "decryption failed")
+			}
+
+			page.Results = append(page.Results, SearchResult{
+				MessageID:    r.MessageID,
+				ChatID:       r.ChatID,
+				SenderID:     r.SenderID,
+				Snippet:      search.Snippet(string(plaintext), terms, snippetWidth),
+				CreatedAt:    r.CreatedAt.Format(time.RFC3339),
+				EditedAt:     optionalTime(r.EditedAt),
+				ThreadRootID: r.ThreadRootID,
+			})
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, page)
+}
+
+// likeEscaper escapes the LIKE wildcards, with backslash as the escape
+// character.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// SearchUsers looks q up in the user directory: display names by prefix,
+// real names anywhere in them, and both fuzzily where the database has
+// trigram matching. Real names only match for users allowed to see them,
+// so they can't be confirmed by searching. The closest matches come first;
+// there is no paging. Users on either side of a block never show up.
+func (user *User) SearchUsers(c echo.Context) error {
+	claims, err := identity.GetUserClaims(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+	uid := claims.ID()
+
+	var body struct {
+		Query string `query:"q"`
+		Limit int32  `query:"limit"`
+	}
+	if err := c.Bind(&body); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid input").SetInternal(err)
+	}
+
+	query := strings.ToLower(strings.Join(strings.Fields(body.Query), " "))
+	if n := utf8.RuneCountInString(query); n < minUserQueryRunes || n > maxUserQueryRunes {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("q must be between %d and %d characters", minUserQueryRunes, maxUserQueryRunes))
+	}
+
+	if body.Limit == 0 {
+		body.Limit = defaultUserSearchSize
+	}
+	if body.Limit < 0 || body.Limit > maxUserSearchSize {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxUserSearchSize))
+	}
+
+	rows, err := user.store.SearchUsers(c.Request().Context(), database.SearchUsersParams{
+		UserID:   uid,
+		Query:    query,
+		Pattern:  likeEscaper.Replace(query),
+		PageSize: body.Limit,
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "search failed").SetInternal(err)
+	}
+
+	results := make([]UserResult, 0, len(rows))
+	for _, r := range rows {
+		result := UserResult{
+			UserID:      r.UserID,
+			DisplayName: r.DisplayName,
+			PfpURL:      r.PfpUrl,
+			IsFriend:    r.IsFriend,
+		}
+		if r.IsFriend || r.SharesChat {
+			result.FirstName, result.LastName = &r.FirstName, &r.LastName
+		}
+		results = append(results, result)
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"users": results,
+	})
+}
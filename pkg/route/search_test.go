@@ -0,0 +1,64 @@
+package route
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestSearchMessages(t *testing.T) {
+	s := newTestServer(t)
+	ada, bob, cy := s.register("ada"), s.register("bob"), s.register("cy")
+	s.befriend(ada, bob)
+	s.befriend(ada, cy)
+	withBob, withCy := s.group(ada, bob), s.group(ada, cy)
+
+	fox := s.send(ada, withBob, "The quick brown Fox")
+	s.send(bob, withBob, "a lazy dog")
+	gone := s.send(bob, withBob, "another fox")
+	other := s.send(cy, withCy, "FOX sightings")
+	s.expect(http.StatusNoContent, http.MethodDelete, fmt.Sprintf("/v1/chats/%d/messages/%d?scope=everyone", withBob, gone), bob.AccessToken, nil, nil)
+
+	search := func(u testUser, query url.Values) []int64 {
+		t.Helper()
+		var page SearchPage
+		s.expect(http.StatusOK, http.MethodGet, "/v1/search/messages?"+query.Encode(), u.AccessToken, nil, &page)
+		var ids []int64
+		for _, r := range page.Results {
+			ids = append(ids, r.MessageID)
+			if r.Snippet == "" {
+				t.Errorf("result %d has no snippet", r.MessageID)
+			}
+		}
+		return ids
+	}
+
+	if got, want := fmt.Sprint(search(ada, url.Values{"q": {"fox"}})), fmt.Sprint([]int64{other, fox}); got != want {
+		t.Errorf("ada's fox = %s, want %s", got, want)
+	}
+	if got, want := fmt.Sprint(search(bob, url.Values{"q": {"fox"}})), fmt.Sprint([]int64{fox}); got != want {
+		t.Errorf("bob's fox = %s, want %s", got, want)
+	}
+	if got, want := fmt.Sprint(search(ada, url.Values{"q": {"fox"}, "chat_id": {fmt.Sprint(withBob)}})), fmt.Sprint([]int64{fox}); got != want {
+		t.Errorf("fox in one chat = %s, want %s", got, want)
+	}
+	if got := search(ada, url.Values{"q": {"quick dog"}}); len(got) != 0 {
+		t.Errorf("every word must match, got %v", got)
+	}
+
+	tests := []struct {
+		name   string
+		query  url.Values
+		status int
+	}{
+		{"empty", url.Values{"q": {"  "}}, http.StatusBadRequest},
+		{"other chat", url.Values{"q": {"fox"}, "chat_id": {fmt.Sprint(withCy)}}, http.StatusForbidden},
+		{"limit", url.Values{"q": {"fox"}, "limit": {"51"}}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if rec := s.do(http.MethodGet, "/v1/search/messages?"+tt.query.Encode(), bob.AccessToken, nil); rec.Code != tt.status {
+			t.Errorf("%s: search = %d %s, want %d", tt.name, rec.Code, rec.Body, tt.status)
+		}
+	}
+}
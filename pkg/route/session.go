@@ -0,0 +1,160 @@
+package route
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/astrokkidd/flick/pkg/database"
+	"github.com/astrokkidd/flick/pkg/identity"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+)
+
+type SessionResponse struct {
+	SessionID  string `json:"session_id"`
+	UserAgent  string `json:"user_agent"`
+	IPAddress  string `json:"ip_address"`
+	CreatedAt  string `json:"created_at"`
+	LastSeenAt string `json:"last_seen_at"`
+	Current    bool   `json:"current"`
+}
+
+// NewSessionLookup backs identity.SessionCache with the sessions table.
+func NewSessionLookup(queries database.Querier) identity.SessionLookup {
+	return func(ctx context.Context, sessionID, ip string) (bool, error) {
+		id, ok := parseSessionID(sessionID)
+		if !ok {
+			return false, nil
+		}
+
+		touched, err := queries.TouchSession(ctx, database.TouchSessionParams{SessionID: id, IpAddress: ip})
+		if err != nil {
+			return false, err
+		}
+		return touched > 0, nil
+	}
+}
+
+func (auth *Auth) Logout(c echo.Context) error {
+	claims, err := identity.GetUserClaims(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "unauthenticated")
+	}
+
+	return auth.endSession(c, claims.SessionID(), claims.ID())
+}
+
+func (auth *Auth) ListSessions(c echo.Context) error {
+	claims, err := identity.GetUserClaims(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "unauthenticated")
+	}
+
+	rows, err := auth.store.ListUserSessions(c.Request().Context(), database.ListUserSessionsParams{UserID: claims.ID()})
+	if err != nil {
+		return echo.ErrInternalServerError.WithInternal(err)
+	}
+
+	sessions := make([]SessionResponse, len(rows))
+	for i, r := range rows {
+		sessions[i] = SessionResponse{
+			SessionID:  r.SessionID.String(),
+			UserAgent:  r.UserAgent,
+			IPAddress:  r.IpAddress,
+			CreatedAt:  r.CreatedAt.Format(time.RFC3339),
+			LastSeenAt: r.LastSeenAt.Format(time.RFC3339),
+			Current:    r.SessionID.String() == claims.SessionID(),
+		}
+	}
+
+	return c.JSON(http.StatusOK, sessions)
+}
+
+func (auth *Auth) RevokeSession(c echo.Context) error {
+	claims, err := identity.GetUserClaims(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "unauthenticated")
+	}
+
+	return auth.endSession(c, c.Param("id"), claims.ID())
+}
+
+// endSession revokes one of the user's sessions along with its refresh tokens.
+func (auth *Auth) endSession(c echo.Context, sessionID string, uid int64) error {
+	id, ok := parseSessionID(sessionID)
+	if !ok {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid session id")
+	}
+
+	//-- Begin tx --//
+	ctx := c.Request().Context()
+	if err := withTx(ctx, auth.store, func(qtx database.Querier) error {
+		if err := revokeSession(ctx, qtx, id, uid); errors.Is(err, errSessionNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "session not found")
+		} else if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "could not revoke session").SetInternal(err)
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	auth.sessions.MarkRevoked(id.String())
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// startSession records a new signed-in device for the user.
+func startSession(ctx context.Context, q database.Querier, c echo.Context, uid int64) (pgtype.UUID, error) {
+	return q.CreateSession(ctx, database.CreateSessionParams{
+		UserID:    uid,
+		UserAgent: c.Request().UserAgent(),
+		IpAddress: c.RealIP(),
+	})
+}
+
+var errSessionNotFound = errors.New("session not found")
+
+// revokeSession ends the session and the refresh token family tied to it.
+func revokeSession(ctx context.Context, q database.Querier, session pgtype.UUID, uid int64) error {
+	revoked, err := q.RevokeSession(ctx, database.RevokeSessionParams{SessionID: session, UserID: uid})
+	if err != nil {
+		return err
+	}
+
+	if _, err := q.RevokeRefreshTokenFamily(ctx, database.RevokeRefreshTokenFamilyParams{FamilyID: session}); err != nil {
+		return err
+	}
+
+	if revoked == 0 {
+		return errSessionNotFound
+	}
+	return nil
+}
+
+// revokeAllSessions signs the user out everywhere except keep, which may be
+// the zero UUID. The ended sessions are returned so the cache can forget them
+// once the transaction commits.
+func revokeAllSessions(ctx context.Context, q database.Querier, uid int64, keep pgtype.UUID) ([]pgtype.UUID, error) {
+	revoked, err := q.RevokeAllUserSessions(ctx, database.RevokeAllUserSessionsParams{UserID: uid, KeepSessionID: keep})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := q.RevokeAllUserRefreshTokens(ctx, database.RevokeAllUserRefreshTokensParams{UserID: uid, KeepSessionID: keep}); err != nil {
+		return nil, err
+	}
+
+	return revoked, nil
+}
+
+func parseSessionID(s string) (pgtype.UUID, bool) {
+	var id pgtype.UUID
+	if err := id.Scan(s); err != nil || !id.Valid {
+		return id, false
+	}
+	return id, true
+}
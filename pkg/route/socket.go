@@ -0,0 +1,131 @@
+package route
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/astrokkidd/flick/pkg/identity"
+	"github.com/astrokkidd/flick/pkg/realtime"
+	"github.com/labstack/echo/v4"
+	"nhooyr.io/websocket"
+	"nhooyr.io/websocket/wsjson"
+)
+
+const socketWriteTimeout = 10 * time.Second
+
+// socketSessionCheck is how often an open connection checks its session is
+// still active, which catches revocations made through other instances.
+const socketSessionCheck = time.Minute
+
+// Browsers cannot set headers on a WebSocket handshake, so they offer the
+// access token as a subprotocol, socketTokenPrefix followed by the token,
+// alongside socketProtocol. Only socketProtocol is ever echoed back.
+const (
+	socketProtocol    = "flick.v1"
+	socketTokenPrefix = "bearer."
+)
+
+type Socket struct {
+	hub          *realtime.Hub
+	tokenHandler *identity.TokenHandler
+	sessions     *identity.SessionCache
+	origins      []string
+}
+
+// NewSocketHandler serves the gateway. origins are the host patterns of web
+// clients allowed to connect from another origin.
+func NewSocketHandler(hub *realtime.Hub, tokenHandler *identity.TokenHandler, sessions *identity.SessionCache, origins []string) Socket {
+	return Socket{hub, tokenHandler, sessions, origins}
+}
+
+func (socket *Socket) Connect(c echo.Context) error {
+	//-- Verify token before upgrading --//
+	claims, err := socket.tokenHandler.Verify(socketToken(c.Request()))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "unauthenticated").SetInternal(err)
+	}
+	active, err := socket.sessions.Active(c.Request().Context(), claims.SessionID(), c.RealIP())
+	if err != nil {
+		return echo.ErrInternalServerError.WithInternal(err)
+	}
+	if !active {
+		return echo.NewHTTPError(http.StatusUnauthorized, "session revoked")
+	}
+	uid := claims.ID()
+
+	conn, err := websocket.Accept(c.Response(), c.Request(), &websocket.AcceptOptions{
+		Subprotocols:   []string{socketProtocol},
+		OriginPatterns: socket.origins,
+	})
+	if err != nil {
+		// Accept has already written the error response
+		slog.Warn("websocket accept failed", "error", err)
+		return nil
+	}
+	defer conn.Close(websocket.StatusInternalError, "")
+
+	client := socket.hub.Register(uid, claims.SessionID())
+	defer socket.hub.Unregister(client)
+
+	// The gateway is push-only; CloseRead handles pings and close frames and
+	// cancels ctx once the peer goes away.
+	ctx := conn.CloseRead(c.Request().Context())
+
+	check := time.NewTicker(socketSessionCheck)
+	defer check.Stop()
+
+	for {
+		select {
+		case ev, ok := <-client.Events():
+			if !ok {
+				if errors.Is(client.Err(), realtime.ErrSessionRevoked) {
+					conn.Close(websocket.StatusPolicyViolation, "session revoked")
+					return nil
+				}
+				conn.Close(websocket.StatusTryAgainLater, "connection dropped")
+				return nil
+			}
+			if err := writeEvent(ctx, conn, ev); err != nil {
+				return nil
+			}
+		case <-check.C:
+			// A failed lookup keeps the connection; the next check retries.
+			active, err := socket.sessions.Active(ctx, claims.SessionID(), c.RealIP())
+			if err == nil && !active {
+				conn.Close(websocket.StatusPolicyViolation, "session revoked")
+				return nil
+			}
+		case <-ctx.Done():
+			conn.Close(websocket.StatusNormalClosure, "")
+			return nil
+		}
+	}
+}
+
+// socketToken finds the access token in the Authorization header, or among
+// the subprotocols a browser offered.
+func socketToken(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+
+	for _, header := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, proto := range strings.Split(header, ",") {
+			if token, ok := strings.CutPrefix(strings.TrimSpace(proto), socketTokenPrefix); ok {
+				return token
+			}
+		}
+	}
+	return ""
+}
+
+func writeEvent(ctx context.Context, conn *websocket.Conn, ev realtime.Event) error {
+	ctx, cancel := context.WithTimeout(ctx, socketWriteTimeout)
+	defer cancel()
+
+	return wsjson.Write(ctx, conn, ev)
+}
@@ -0,0 +1,261 @@
+package route
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/astrokkidd/flick/pkg/crypto"
+	"github.com/astrokkidd/flick/pkg/database"
+	"github.com/astrokkidd/flick/pkg/identity"
+	"github.com/astrokkidd/flick/pkg/realtime"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// MessagePreview is a message shown inside another one: the message a reply
+// quotes, or the latest reply in a thread.
+type MessagePreview struct {
+	MessageID  int64   `json:"message_id"`
+	SenderID   int64   `json:"sender_id"`
+	Content    string  `json:"content"`
+	Ciphertext []byte  `json:"ciphertext,omitempty"` // end-to-end chats only
+	CreatedAt  string  `json:"created_at"`
+	DeletedAt  *string `json:"deleted_at"`
+}
+
+// ThreadSummary sits on a thread root in the chat history. Replies the
+// caller hid or that were deleted for everyone are not counted.
+type ThreadSummary struct {
+	ReplyCount  int64           `json:"reply_count"`
+	UnreadCount int64           `json:"unread_count"`
+	LatestReply *MessagePreview `json:"latest_reply"`
+}
+
+type ThreadReadEvent struct {
+	ThreadRootID int64 `json:"thread_root_id"`
+	UserID       int64 `json:"user_id"`
+	MessageID    int64 `json:"message_id"`
+}
+
+// requireThreadRoot loads a message in the chat that can hold a thread.
+// Threads are one level deep, so replies in a thread cannot.
+func requireThreadRoot(ctx context.Context, qtx database.Querier, chatID, rootID int64) (database.GetMessageRow, error) {
+	root, err := qtx.GetMessage(ctx, database.GetMessageParams{ChatID: chatID, MessageID: rootID})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return root, echo.NewHTTPError(http.StatusNotFound, "message not found")
+	}
+	if err != nil {
+		return root, echo.NewHTTPError(http.StatusInternalServerError, "message query failed").SetInternal(err)
+	}
+	if root.ThreadRootID != nil {
+		return root, echo.NewHTTPError(http.StatusBadRequest, "replies in a thread cannot start threads")
+	}
+	return root, nil
+}
+
+// checkReplyTargets validates what a new message answers. A thread root
+// must not be deleted; neither must a quoted message, which also has to be
+// in the same conversation as the reply: the chat history or that thread.
+func checkReplyTargets(ctx context.Context, qtx database.Querier, chatID int64, replyToID, threadRootID *int64) error {
+	if threadRootID != nil {
+		root, err := requireThreadRoot(ctx, qtx, chatID, *threadRootID)
+		if err != nil {
+			return err
+		}
+		if root.DeletedAt.Valid {
+			return echo.NewHTTPError(http.StatusConflict, "message was deleted")
+		}
+	}
+
+	if replyToID == nil {
+		return nil
+	}
+
+	quoted, err := qtx.GetMessage(ctx, database.GetMessageParams{ChatID: chatID, MessageID: *replyToID})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return echo.NewHTTPError(http.StatusNotFound, "replied to message not found")
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "message query failed").SetInternal(err)
+	}
+	if quoted.DeletedAt.Valid {
+		return echo.NewHTTPError(http.StatusConflict, "message was deleted")
+	}
+
+	var sameConversation bool
+	switch {
+	case threadRootID == nil:
+		sameConversation = quoted.ThreadRootID == nil
+	case *replyToID == *threadRootID:
+		sameConversation = true
+	default:
+		sameConversation = quoted.ThreadRootID != nil && *quoted.ThreadRootID == *threadRootID
+	}
+	if !sameConversation {
+		return echo.NewHTTPError(http.StatusBadRequest, "replied to message is in another conversation")
+	}
+
+	return nil
+}
+
+// attachReplies fills in the message each reply quotes and, with threads,
+// the summary of every thread rooted in the page.
+func (message *Message) attachReplies(ctx context.Context, qtx database.Querier, key crypto.DataKey, endToEnd bool, chatID, userID int64, messages []MessageResponse, threads bool) error {
+	var (
+		previewIDs []int64
+		rootIDs    []int64
+	)
+	for _, m := range messages {
+		if m.ReplyToID != nil {
+			previewIDs = append(previewIDs, *m.ReplyToID)
+		}
+		rootIDs = append(rootIDs, m.MessageID)
+	}
+
+	summaries := map[int64]database.ListThreadSummariesRow{}
+	if threads && len(rootIDs) > 0 {
+		rows, err := qtx.ListThreadSummaries(ctx, database.ListThreadSummariesParams{UserID: userID, RootIds: rootIDs})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "threads query failed").SetInternal(err)
+		}
+		for _, r := range rows {
+			summaries[r.ThreadRootID] = r
+			previewIDs = append(previewIDs, r.LatestReplyID)
+		}
+	}
+
+	if len(previewIDs) == 0 {
+		return nil
+	}
+
+	rows, err := qtx.ListMessagesByID(ctx, database.ListMessagesByIDParams{ChatID: chatID, MessageIds: previewIDs})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "get messages failed").SetInternal(err)
+	}
+
+	previews := make(map[int64]*MessagePreview, len(rows))
+	for _, r := range rows {
+		preview := &MessagePreview{
+			MessageID: r.MessageID,
+			SenderID:  r.SenderID,
+			CreatedAt: r.CreatedAt.Format(time.RFC3339),
+			DeletedAt: optionalTime(r.DeletedAt),
+		}
+
+		switch {
+		case r.DeletedAt.Valid:
+		case endToEnd:
+			preview.Ciphertext = r.CypherText
+		default:
+			plaintext, err := key.Decrypt(r.CypherText, crypto.MessageAAD(chatID, r.SenderID, r.MessageID))
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "decryption failed")
+			}
+			preview.Content = string(plaintext)
+		}
+
+		previews[r.MessageID] = preview
+	}
+
+	for i := range messages {
+		m := &messages[i]
+		if m.ReplyToID != nil {
+			m.ReplyTo = previews[*m.ReplyToID]
+		}
+		if s, ok := summaries[m.MessageID]; ok {
+			m.Thread = &ThreadSummary{
+				ReplyCount:  s.ReplyCount,
+				UnreadCount: s.UnreadCount,
+				LatestReply: previews[s.LatestReplyID],
+			}
+		}
+	}
+
+	return nil
+}
+
+// GetThread pages through the replies to a message, with the same cursors
+// and order as the chat history.
+func (message *Message) GetThread(c echo.Context) error {
+	return message.listMessages(c, true)
+}
+
+// SetThreadLastRead moves the caller's read marker in a thread forward to
+// one of its replies. It is kept apart from the chat's own marker.
+func (message *Message) SetThreadLastRead(c echo.Context) error {
+	claims, err := identity.GetUserClaims(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+	uid := claims.ID()
+
+	var body struct {
+		ChatID    int64 `param:"id"`
+		RootID    int64 `param:"message_id"`
+		MessageID int64 `json:"message_id"`
+	}
+	if err := c.Bind(&body); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid json").SetInternal(err)
+	}
+
+	var (
+		moved        int64
+		participants []int64
+	)
+
+	//-- Begin tx --//
+	ctx := c.Request().Context()
+	if err := withTx(ctx, message.store, func(qtx database.Querier) error {
+		if _, err := requireChatPermission(ctx, qtx, body.ChatID, uid, permReadMessages); err != nil {
+			return err
+		}
+
+		reply, err := qtx.GetMessage(ctx, database.GetMessageParams{ChatID: body.ChatID, MessageID: body.MessageID})
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusNotFound, "message not found")
+		}
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "message query failed").SetInternal(err)
+		}
+		if reply.ThreadRootID == nil || *reply.ThreadRootID != body.RootID {
+			return echo.NewHTTPError(http.StatusBadRequest, "message is not a reply in this thread")
+		}
+
+		moved, err = qtx.SetThreadLastRead(ctx, database.SetThreadLastReadParams{
+			ThreadRootID:      body.RootID,
+			UserID:            uid,
+			LastReadMessageID: body.MessageID,
+		})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "could not set last read message").SetInternal(err)
+		}
+
+		// Marker only moves forward, nothing to announce if it stayed put
+		if moved == 0 {
+			return nil
+		}
+
+		participants, err = qtx.ListChatParticipantIDs(ctx, database.ListChatParticipantIDsParams{ChatID: body.ChatID})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "participants query failed").SetInternal(err)
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if moved == 0 {
+		return c.NoContent(http.StatusNoContent)
+	}
+
+	message.hub.Publish(realtime.Event{
+		Type:   realtime.EventThreadReadUpdated,
+		ChatID: body.ChatID,
+		Data:   ThreadReadEvent{ThreadRootID: body.RootID, UserID: uid, MessageID: body.MessageID},
+	}, participants...)
+
+	return c.NoContent(http.StatusNoContent)
+}
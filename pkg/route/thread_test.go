@@ -0,0 +1,104 @@
+package route
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestReplyTargets(t *testing.T) {
+	s := newTestServer(t)
+	ada, bob := s.register("ada"), s.register("bob")
+	s.befriend(ada, bob)
+	chatID := s.group(ada, bob)
+	path := fmt.Sprintf("/v1/chats/%d/messages", chatID)
+
+	root := s.send(ada, chatID, "root")
+	other := s.send(ada, chatID, "other")
+	gone := s.send(ada, chatID, "gone")
+	s.expect(http.StatusNoContent, http.MethodDelete, fmt.Sprintf("%s/%d?scope=everyone", path, gone), ada.AccessToken, nil, nil)
+
+	var reply int64
+	s.expect(http.StatusCreated, http.MethodPost, path, bob.AccessToken, map[string]any{"content": "in thread", "thread_root_id": root}, &reply)
+	// Quoting the root from inside its thread is fine
+	s.expect(http.StatusCreated, http.MethodPost, path, bob.AccessToken, map[string]any{"content": "quoting root", "thread_root_id": root, "reply_to_id": root}, nil)
+
+	tests := []struct {
+		name string
+		body map[string]any
+		want int
+	}{
+		{"missing quote", map[string]any{"reply_to_id": other + 100}, http.StatusNotFound},
+		{"missing root", map[string]any{"thread_root_id": other + 100}, http.StatusNotFound},
+		{"deleted quote", map[string]any{"reply_to_id": gone}, http.StatusConflict},
+		{"deleted root", map[string]any{"thread_root_id": gone}, http.StatusConflict},
+		{"reply as root", map[string]any{"thread_root_id": reply}, http.StatusBadRequest},
+		{"thread reply quoted in history", map[string]any{"reply_to_id": reply}, http.StatusBadRequest},
+		{"history quoted in thread", map[string]any{"thread_root_id": root, "reply_to_id": other}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		tt.body["content"] = tt.name
+		if code := s.do(http.MethodPost, path, ada.AccessToken, tt.body).Code; code != tt.want {
+			t.Errorf("%s = %d, want %d", tt.name, code, tt.want)
+		}
+	}
+}
+
+func TestThreads(t *testing.T) {
+	s := newTestServer(t)
+	ada, bob, cy := s.register("ada"), s.register("bob"), s.register("cy")
+	s.befriend(ada, bob)
+	chatID := s.group(ada, bob)
+
+	root := s.send(ada, chatID, "root")
+	var first, second int64
+	s.expect(http.StatusCreated, http.MethodPost, fmt.Sprintf("/v1/chats/%d/messages", chatID), bob.AccessToken, map[string]any{"content": "first", "thread_root_id": root}, &first)
+	s.expect(http.StatusCreated, http.MethodPost, fmt.Sprintf("/v1/chats/%d/messages", chatID), bob.AccessToken, map[string]any{"content": "second", "thread_root_id": root, "reply_to_id": first}, &second)
+
+	thread := func() *ThreadSummary {
+		t.Helper()
+		var page MessagePage
+		s.expect(http.StatusOK, http.MethodGet, fmt.Sprintf("/v1/chats/%d/messages", chatID), ada.AccessToken, nil, &page)
+		if len(page.Messages) != 1 || page.Messages[0].MessageID != root {
+			t.Fatalf("history = %+v, want only the root", page.Messages)
+		}
+		return page.Messages[0].Thread
+	}
+
+	got := thread()
+	if got == nil || got.ReplyCount != 2 || got.UnreadCount != 2 || got.LatestReply == nil || got.LatestReply.Content != "second" {
+		t.Fatalf("thread = %+v", got)
+	}
+
+	var page MessagePage
+	s.expect(http.StatusOK, http.MethodGet, fmt.Sprintf("/v1/chats/%d/messages/%d/thread", chatID, root), ada.AccessToken, nil, &page)
+	if len(page.Messages) != 2 {
+		t.Fatalf("thread replies = %+v", page.Messages)
+	}
+	for _, m := range page.Messages {
+		if m.ThreadRootID == nil || *m.ThreadRootID != root {
+			t.Errorf("reply %d has thread root %v", m.MessageID, m.ThreadRootID)
+		}
+		if m.MessageID == second && (m.ReplyTo == nil || m.ReplyTo.Content != "first") {
+			t.Errorf("reply %d quotes %+v", m.MessageID, m.ReplyTo)
+		}
+	}
+	s.expect(http.StatusBadRequest, http.MethodGet, fmt.Sprintf("/v1/chats/%d/messages/%d/thread", chatID, first), ada.AccessToken, nil, nil)
+	s.expect(http.StatusForbidden, http.MethodGet, fmt.Sprintf("/v1/chats/%d/messages/%d/thread", chatID, root), cy.AccessToken, nil, nil)
+
+	read := fmt.Sprintf("/v1/chats/%d/messages/%d/thread/read", chatID, root)
+	s.expect(http.StatusBadRequest, http.MethodPost, read, ada.AccessToken, map[string]int64{"message_id": root}, nil)
+	s.expect(http.StatusNotFound, http.MethodPost, read, ada.AccessToken, map[string]int64{"message_id": second + 100}, nil)
+	s.expect(http.StatusForbidden, http.MethodPost, read, cy.AccessToken, map[string]int64{"message_id": first}, nil)
+
+	s.expect(http.StatusNoContent, http.MethodPost, read, ada.AccessToken, map[string]int64{"message_id": first}, nil)
+	if got := thread(); got.UnreadCount != 1 {
+		t.Errorf("unread after reading the first reply = %d, want 1", got.UnreadCount)
+	}
+	s.expect(http.StatusNoContent, http.MethodPost, read, ada.AccessToken, map[string]int64{"message_id": second}, nil)
+	// The marker only moves forward
+	s.expect(http.StatusNoContent, http.MethodPost, read, ada.AccessToken, map[string]int64{"message_id": first}, nil)
+	if got := thread(); got.UnreadCount != 0 {
+		t.Errorf("unread after reading the thread = %d, want 0", got.UnreadCount)
+	}
+}
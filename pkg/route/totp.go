@@ -0,0 +1,315 @@
+package route
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/astrokkidd/flick/pkg/crypto"
+	"github.com/astrokkidd/flick/pkg/database"
+	"github.com/astrokkidd/flick/pkg/identity"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+const totpIssuer = "Flick"
+
+// Wrong codes are counted per user across challenges. Once maxMFAAttempts
+// have failed in a row, every further try waits out mfaLockout first.
+const (
+	maxMFAAttempts = 5
+	mfaLockout     = 15 * time.Minute
+)
+
+var (
+	errTOTPNotEnrolled = errors.New("totp not enrolled")
+	errMFALocked       = echo.NewHTTPError(http.StatusTooManyRequests, "too many failed attempts, try again later")
+)
+
+// EnrollTOTP starts two-factor setup by generating a secret for the user's
+// authenticator. Nothing is enforced until ConfirmTOTP sees a valid code.
+func (auth *Auth) EnrollTOTP(c echo.Context) error {
+	claims, err := identity.GetUserClaims(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid token").SetInternal(err)
+	}
+	uid := claims.ID()
+
+	secret, err := identity.NewTOTPSecret()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "could not generate secret").SetInternal(err)
+	}
+
+	sealed, err := auth.cipher.Encrypt(secret, crypto.UserSecretAAD(uid))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "could not seal secret").SetInternal(err)
+	}
+
+	var user database.FindUserByIDRow
+
+	//-- Begin tx --//
+	ctx := c.Request().Context()
+	if err := withTx(ctx, auth.store, func(qtx database.Querier) error {
+		state, err := qtx.GetUserTOTPForUpdate(ctx, database.GetUserTOTPForUpdateParams{UserID: uid})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "could not load two-factor state").SetInternal(err)
+		}
+		if state.TotpEnabled {
+			return echo.NewHTTPError(http.StatusConflict, "two-factor authentication is already enabled")
+		}
+
+		user, err = qtx.FindUserByID(ctx, database.FindUserByIDParams{UserID: uid})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "user lookup failed").SetInternal(err)
+		}
+
+		if err := qtx.SetPendingTOTPSecret(ctx, database.SetPendingTOTPSecretParams{TotpSecret: sealed, UserID: uid}); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "could not store secret").SetInternal(err)
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"secret":      identity.EncodeTOTPSecret(secret),
+		"otpauth_uri": identity.TOTPURI(secret, totpIssuer, user.DisplayName),
+	})
+}
+
+// ConfirmTOTP turns two-factor on once the user proves their authenticator
+// produces matching codes, and hands back a fresh set of recovery codes.
+func (auth *Auth) ConfirmTOTP(c echo.Context) error {
+	claims, err := identity.GetUserClaims(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid token").SetInternal(err)
+	}
+	uid := claims.ID()
+
+	var body struct {
+		Code string `json:"code"`
+	}
+	if err := c.Bind(&body); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	codes, hashes, err := identity.NewRecoveryCodes()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "could not generate recovery codes").SetInternal(err)
+	}
+
+	//-- Begin tx --//
+	ctx := c.Request().Context()
+	if err := withTx(ctx, auth.store, func(qtx database.Querier) error {
+		state, err := qtx.GetUserTOTPForUpdate(ctx, database.GetUserTOTPForUpdateParams{UserID: uid})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "could not load two-factor state").SetInternal(err)
+		}
+		if state.TotpEnabled {
+			return echo.NewHTTPError(http.StatusConflict, "two-factor authentication is already enabled")
+		}
+
+		step, err := auth.checkTOTP(state, uid, body.Code)
+		if errors.Is(err, errTOTPNotEnrolled) {
+			return echo.NewHTTPError(http.StatusBadRequest, "two-factor enrollment has not been started")
+		}
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "could not open secret").SetInternal(err)
+		}
+		if step == 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid code")
+		}
+
+		if err := qtx.EnableTOTP(ctx, database.EnableTOTPParams{TotpLastStep: &step, UserID: uid}); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "could not enable two-factor").SetInternal(err)
+		}
+
+		if err := storeRecoveryCodes(ctx, qtx, uid, hashes); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "could not store recovery codes").SetInternal(err)
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"recovery_codes": codes,
+	})
+}
+
+// LoginTOTP completes a login that Login answered with a challenge. Either a
+// current authenticator code or an unused recovery code is accepted.
+func (auth *Auth) LoginTOTP(c echo.Context) error {
+	var body struct {
+		MFAToken     string `json:"mfa_token"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if err := c.Bind(&body); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	body.Code = strings.TrimSpace(body.Code)
+	if body.Code == "" && body.RecoveryCode == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "code or recovery_code is required")
+	}
+
+	challenge, err := auth.tokenHandler.VerifyChallenge(body.MFAToken)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid or expired login challenge")
+	}
+	uid := challenge.UserID
+
+	var (
+		user   database.FindUserByIDRow
+		tokens TokenResponse
+		failed error
+	)
+
+	//-- Begin tx --//
+	ctx := c.Request().Context()
+	if err := withTx(ctx, auth.store, func(qtx database.Querier) error {
+		state, err := qtx.GetUserTOTPForUpdate(ctx, database.GetUserTOTPForUpdateParams{UserID: uid})
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusUnauthorized, "invalid or expired login challenge")
+		}
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "could not load two-factor state").SetInternal(err)
+		}
+		if !state.TotpEnabled {
+			return echo.NewHTTPError(http.StatusUnauthorized, "invalid or expired login challenge")
+		}
+		if mfaLocked(state) {
+			return errMFALocked
+		}
+
+		// A challenge is spent by its first try, right or wrong, so each
+		// guess costs the caller the password step again.
+		if err := qtx.DeleteExpiredMFAChallenges(ctx); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "could not check login challenge").SetInternal(err)
+		}
+		fresh, err := qtx.UseMFAChallenge(ctx, database.UseMFAChallengeParams{
+			ChallengeID: challenge.ID,
+			UserID:      uid,
+			ExpiresAt:   challenge.ExpiresAt,
+		})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "could not check login challenge").SetInternal(err)
+		}
+		if fresh == 0 {
+			return echo.NewHTTPError(http.StatusUnauthorized, "invalid or expired login challenge")
+		}
+
+		if body.Code != "" {
+			step, err := auth.checkTOTP(state, uid, body.Code)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "could not open secret").SetInternal(err)
+			}
+			if step == 0 {
+				failed = echo.NewHTTPError(http.StatusUnauthorized, "invalid code")
+				return recordMFAFailure(ctx, qtx, uid)
+			}
+			if err := qtx.SetTOTPLastStep(ctx, database.SetTOTPLastStepParams{TotpLastStep: &step, UserID: uid}); err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "could not record code").SetInternal(err)
+			}
+		} else {
+			used, err := qtx.UseRecoveryCode(ctx, database.UseRecoveryCodeParams{
+				UserID:   uid,
+				CodeHash: identity.HashRecoveryCode(body.RecoveryCode),
+			})
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "recovery code lookup failed").SetInternal(err)
+			}
+			if used == 0 {
+				failed = echo.NewHTTPError(http.StatusUnauthorized, "invalid recovery code")
+				return recordMFAFailure(ctx, qtx, uid)
+			}
+		}
+
+		if err := qtx.ResetMFAFailures(ctx, database.ResetMFAFailuresParams{UserID: uid}); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "could not record code").SetInternal(err)
+		}
+
+		user, err = qtx.FindUserByID(ctx, database.FindUserByIDParams{UserID: uid})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusUnauthorized, "user no longer exists")
+		}
+
+		tokens, err = auth.openSession(ctx, qtx, c, newUserClaims(uid, user.FirstName, user.LastName, derefString(user.PfpUrl)))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "could not create session").SetInternal(err)
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+	if failed != nil {
+		return failed
+	}
+
+	return c.JSON(http.StatusOK, loginResponse(tokens, uid, user.DisplayName, user.PfpUrl))
+}
+
+// checkTOTP verifies a code against the user's sealed secret, returning the
+// matched step or 0 when the code is wrong or already used.
+func (auth *Auth) checkTOTP(state database.GetUserTOTPForUpdateRow, uid int64, code string) (int64, error) {
+	if state.TotpSecret == nil {
+		return 0, errTOTPNotEnrolled
+	}
+
+	secret, err := auth.cipher.Decrypt(state.TotpSecret, crypto.UserSecretAAD(uid))
+	if err != nil {
+		return 0, err
+	}
+
+	var lastStep int64
+	if state.TotpLastStep != nil {
+		lastStep = *state.TotpLastStep
+	}
+
+	step, ok := identity.VerifyTOTP(secret, strings.TrimSpace(code), time.Now(), lastStep)
+	if !ok {
+		return 0, nil
+	}
+	return step, nil
+}
+
+// mfaLocked reports whether too many wrong codes in a row mean the user has
+// to wait before trying another.
+func mfaLocked(state database.GetUserTOTPForUpdateRow) bool {
+	return state.MfaLockedUntil.Valid && time.Now().Before(state.MfaLockedUntil.Time)
+}
+
+// recordMFAFailure counts a wrong code or recovery code against the user.
+// The caller commits it and answers with its own error afterwards, since
+// failing the transaction would roll the count back.
+func recordMFAFailure(ctx context.Context, q database.Querier, uid int64) error {
+	err := q.RecordMFAFailure(ctx, database.RecordMFAFailureParams{
+		MaxAttempts: maxMFAAttempts,
+		LockedUntil: time.Now().Add(mfaLockout),
+		UserID:      uid,
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "could not record attempt").SetInternal(err)
+	}
+	return nil
+}
+
+// storeRecoveryCodes replaces any codes the user had with the given hashes.
+func storeRecoveryCodes(ctx context.Context, q database.Querier, uid int64, hashes [][]byte) error {
+	if err := q.DeleteRecoveryCodes(ctx, database.DeleteRecoveryCodesParams{UserID: uid}); err != nil {
+		return err
+	}
+
+	for _, hash := range hashes {
+		if err := q.CreateRecoveryCode(ctx, database.CreateRecoveryCodeParams{UserID: uid, CodeHash: hash}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package route
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/astrokkidd/flick/pkg/database"
+	"github.com/astrokkidd/flick/pkg/store"
+	"github.com/labstack/echo/v4"
+)
+
+// withTx runs fn in a transaction, committing when it returns nil. Errors
+// from fn are returned as they are so handlers keep their own status codes.
+func withTx(ctx context.Context, s store.Store, fn func(qtx database.Querier) error) error {
+	var fnErr error
+	err := s.Tx(ctx, func(q database.Querier) error {
+		fnErr = fn(q)
+		return fnErr
+	})
+
+	switch {
+	case err == nil:
+		return nil
+	case fnErr != nil:
+		return fnErr
+	case errors.Is(err, store.ErrUnavailable):
+		return echo.NewHTTPError(http.StatusServiceUnavailable, "database unavailable").SetInternal(err)
+	default:
+		return echo.NewHTTPError(http.StatusInternalServerError, "commit failed").SetInternal(err)
+	}
+}
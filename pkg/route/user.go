@@ -1,26 +1,64 @@
 package route
 
 import (
-	"fmt"
+	"errors"
 	"net/http"
+	"time"
 
+	"github.com/astrokkidd/flick/pkg/blob"
 	"github.com/astrokkidd/flick/pkg/database"
 	"github.com/astrokkidd/flick/pkg/identity"
+	"github.com/astrokkidd/flick/pkg/store"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/labstack/echo/v4"
 )
 
 type User struct {
-	queries      *database.Queries
-	conn         *pgx.Conn
-	tokenHandler *identity.TokenHandler
+	store         store.Store
+	tokenHandler  *identity.TokenHandler
+	sessions      *identity.SessionCache
+	passwords     *identity.PasswordPolicy
+	hashing       identity.Argon2Params
+	blobs         blob.Store
+	avatarMaxSize int64 // bytes per upload
 }
 
-func NewUserHandler(queries *database.Queries, conn *pgx.Conn, tokenHandler *identity.TokenHandler) User {
-	return User{queries, conn, tokenHandler}
+// Profile is the caller's own account.
+type Profile struct {
+	UserID      int64   `json:"user_id"`
+	DisplayName string  `json:"display_name"`
+	FirstName   string  `json:"first_name"`
+	LastName    string  `json:"last_name"`
+	PfpURL      *string `json:"pfp_url"`
+	CreatedAt   string  `json:"created_at"`
+	TotpEnabled bool    `json:"totp_enabled"`
 }
 
-func (user *User) UpdateProfilePicture(c echo.Context) error {
+// PublicProfile is another user as the caller may see them. Real names are
+// only shared with friends and people in a chat with them, who see them on
+// the participant list anyway.
+type PublicProfile struct {
+	UserID       int64   `json:"user_id"`
+	DisplayName  string  `json:"display_name"`
+	FirstName    *string `json:"first_name,omitempty"`
+	LastName     *string `json:"last_name,omitempty"`
+	PfpURL       *string `json:"pfp_url"`
+	CreatedAt    string  `json:"created_at"`
+	Relationship string  `json:"relationship"` // self, friend, request_sent, request_received or none
+}
+
+func NewUserHandler(store store.Store, tokenHandler *identity.TokenHandler, sessions *identity.SessionCache, passwords *identity.PasswordPolicy, hashing identity.Argon2Params, blobs blob.Store, avatarMaxSize int64) User {
+	return User{store, tokenHandler, sessions, passwords, hashing, blobs, avatarMaxSize}
+}
+
+func (user *User) UpdateDisplayName(c echo.Context) error {
+	return echo.NewHTTPError(501, "not implemented")
+}
+
+// UpdatePassword changes the caller's password after checking the current
+// one. Every other session is signed out; the one making the change stays.
+func (user *User) UpdatePassword(c echo.Context) error {
 	claims, err := identity.GetUserClaims(c)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusUnauthorized, "unauthenticated")
@@ -28,71 +66,137 @@ func (user *User) UpdateProfilePicture(c echo.Context) error {
 	uid := claims.ID()
 
 	var body struct {
-		ProfilePictureURL string `json:"profile_picture_url"`
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
 	}
-	if err := c.Bind(&body); err != nil || body.ProfilePictureURL == "" {
-		return echo.NewHTTPError(http.StatusBadRequest, "missing or invalid profile_picture_url")
+	if err := c.Bind(&body); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+	if body.CurrentPassword == "" || body.NewPassword == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "current_password and new_password are required")
 	}
 
-	err = user.queries.UpdateUserPfp(c.Request().Context(), database.UpdateUserPfpParams{
-		UserID: uid,
-		PfpUrl: &body.ProfilePictureURL,
-	})
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update profile picture")
+	current, ok := parseSessionID(claims.SessionID())
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid session")
 	}
 
-	return echo.NewHTTPError(http.StatusOK, nil)
+	var revoked []pgtype.UUID
+
+	//-- Begin tx --//
+	ctx := c.Request().Context()
+	if err := withTx(ctx, user.store, func(qtx database.Querier) error {
+		u, err := qtx.GetUserPasswordForUpdate(ctx, database.GetUserPasswordForUpdateParams{UserID: uid})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch user").SetInternal(err)
+		}
+
+		validated, err := identity.Password(body.CurrentPassword).ValidatePassword(u.PasswordHash)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to verify password").SetInternal(err)
+		}
+		if !validated {
+			return echo.NewHTTPError(http.StatusForbidden, "current password is incorrect")
+		}
+
+		if err := checkPassword(user.passwords, body.NewPassword, u.DisplayName); err != nil {
+			return err
+		}
+
+		hash, err := identity.Password(body.NewPassword).GenerateHash(user.hashing)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to hash password").SetInternal(err)
+		}
+
+		if err := qtx.UpdateUserPassword(ctx, database.UpdateUserPasswordParams{PasswordHash: hash, UserID: uid}); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to update password").SetInternal(err)
+		}
+
+		revoked, err = revokeAllSessions(ctx, qtx, uid, current)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to revoke sessions").SetInternal(err)
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	for _, id := range revoked {
+		user.sessions.MarkRevoked(id.String())
+	}
+
+	return c.NoContent(http.StatusNoContent)
 }
 
-func (user *User) RemoveProfilePicture(c echo.Context) error {
+// GetProfile returns the caller's own account.
+func (user *User) GetProfile(c echo.Context) error {
 	claims, err := identity.GetUserClaims(c)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusUnauthorized, "unauthenticated")
 	}
-	uid := claims.ID()
 
-	//-- Begin tx --//
-	ctx := c.Request().Context()
-	tx, err := user.conn.Begin(ctx)
+	u, err := user.store.GetOwnProfile(c.Request().Context(), database.GetOwnProfileParams{UserID: claims.ID()})
 	if err != nil {
-		return echo.ErrInternalServerError
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch profile").SetInternal(err)
 	}
-	defer tx.Rollback(ctx)
 
-	qtx := user.queries.WithTx(tx)
+	return c.JSON(http.StatusOK, Profile{
+		UserID:      u.UserID,
+		DisplayName: u.DisplayName,
+		FirstName:   u.FirstName,
+		LastName:    u.LastName,
+		PfpURL:      u.PfpUrl,
+		CreatedAt:   u.CreatedAt.Format(time.RFC3339),
+		TotpEnabled: u.TotpEnabled,
+	})
+}
 
-	u, err := qtx.FindUserByID(ctx, database.FindUserByIDParams{UserID: uid})
+// GetUserProfile returns another user's public profile and where the caller
+// stands with them. Users on either side of a block don't exist to each
+// other.
+func (user *User) GetUserProfile(c echo.Context) error {
+	claims, err := identity.GetUserClaims(c)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch user")
+		return echo.NewHTTPError(http.StatusUnauthorized, "unauthenticated")
 	}
+	uid := claims.ID()
 
-	defaultPfp := fmt.Sprintf("https://api.dicebear.com/7.x/notionists-neutral/png?seed=%s", u.DisplayName)
+	var body struct {
+		UserID int64 `param:"id"`
+	}
+	if err := c.Bind(&body); err != nil || body.UserID <= 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid user id")
+	}
 
-	err = qtx.UpdateUserPfp(c.Request().Context(), database.UpdateUserPfpParams{
-		UserID: uid,
-		PfpUrl: &defaultPfp,
-	})
+	u, err := user.store.GetUserProfile(c.Request().Context(), database.GetUserProfileParams{ViewerID: uid, UserID: body.UserID})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return echo.NewHTTPError(http.StatusNotFound, "user not found")
+	}
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update profile picture")
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch profile").SetInternal(err)
 	}
 
-	//-- Commit queries --//
-	if err := tx.Commit(ctx); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "commit failed")
+	profile := PublicProfile{
+		UserID:       u.UserID,
+		DisplayName:  u.DisplayName,
+		PfpURL:       u.PfpUrl,
+		CreatedAt:    u.CreatedAt.Format(time.RFC3339),
+		Relationship: "none",
+	}
+	switch {
+	case u.UserID == uid:
+		profile.Relationship = "self"
+	case u.IsFriend:
+		profile.Relationship = "friend"
+	case u.RequestSent:
+		profile.Relationship = "request_sent"
+	case u.RequestReceived:
+		profile.Relationship = "request_received"
+	}
+	if u.UserID == uid || u.IsFriend || u.SharesChat {
+		profile.FirstName, profile.LastName = &u.FirstName, &u.LastName
 	}
 
-	return c.JSON(http.StatusOK, nil)
-}
-
-func (user *User) UpdateDisplayName(c echo.Context) error {
-	return echo.NewHTTPError(501, "not implemented")
-}
-
-func (user *User) UpdatePassword(c echo.Context) error {
-	return echo.NewHTTPError(501, "not implemented")
-}
-
-func (user *User) GetProfile(c echo.Context) error {
-	return echo.NewHTTPError(501, "not implemented")
+	return c.JSON(http.StatusOK, profile)
 }
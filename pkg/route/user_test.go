@@ -0,0 +1,200 @@
+package route
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestUpdatePassword(t *testing.T) {
+	s := newTestServer(t)
+	ada := s.register("ada")
+
+	var other testUser
+	s.expect(http.StatusOK, http.MethodPost, "/v1/auth/login", "", map[string]string{"display_name": "ada", "password": testPassword}, &other)
+
+	tests := []struct {
+		name   string
+		body   map[string]string
+		status int
+	}{
+		{"missing", map[string]string{"new_password": "new-plum-river-lantern"}, http.StatusBadRequest},
+		{"wrong current", map[string]string{"current_password": "wrong-password-here", "new_password": "new-plum-river-lantern"}, http.StatusForbidden},
+		{"short", map[string]string{"current_password": testPassword, "new_password": "short"}, http.StatusBadRequest},
+		{"common", map[string]string{"current_password": testPassword, "new_password": "password1234"}, http.StatusBadRequest},
+		{"display name", map[string]string{"current_password": testPassword, "new_password": "ada-river-lantern"}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if rec := s.do(http.MethodPut, "/v1/users/password", ada.AccessToken, tt.body); rec.Code != tt.status {
+			t.Errorf("%s: update = %d %s, want %d", tt.name, rec.Code, rec.Body, tt.status)
+		}
+	}
+
+	// A refused change leaves every session alone
+	s.expect(http.StatusOK, http.MethodGet, "/v1/auth/sessions", other.AccessToken, nil, nil)
+
+	s.expect(http.StatusNoContent, http.MethodPut, "/v1/users/password", ada.AccessToken, map[string]string{
+		"current_password": testPassword,
+		"new_password":     "new-plum-river-lantern",
+	}, nil)
+
+	// The session that changed it stays signed in, every other one ends
+	var sessions []SessionResponse
+	s.expect(http.StatusOK, http.MethodGet, "/v1/auth/sessions", ada.AccessToken, nil, &sessions)
+	if len(sessions) != 1 || !sessions[0].Current {
+		t.Errorf("sessions after the change = %+v", sessions)
+	}
+	if rec := s.do(http.MethodGet, "/v1/auth/sessions", other.AccessToken, nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("other access token after the change = %d, want 401", rec.Code)
+	}
+	if rec := s.do(http.MethodPost, "/v1/auth/refresh", "", map[string]string{"refresh_token": other.RefreshToken}); rec.Code != http.StatusUnauthorized {
+		t.Errorf("other refresh token after the change = %d, want 401", rec.Code)
+	}
+
+	if rec := s.do(http.MethodPost, "/v1/auth/login", "", map[string]string{"display_name": "ada", "password": testPassword}); rec.Code != http.StatusUnauthorized {
+		t.Errorf("old password after the change = %d, want 401", rec.Code)
+	}
+	s.expect(http.StatusOK, http.MethodPost, "/v1/auth/login", "", map[string]string{"display_name": "ada", "password": "new-plum-river-lantern"}, nil)
+}
+
+func TestUserProfile(t *testing.T) {
+	s := newTestServer(t)
+	ada, bob, cy, dee := s.register("ada"), s.register("bob"), s.register("cy"), s.register("dee")
+	s.befriend(ada, bob)
+	s.befriend(ada, dee)
+	s.group(ada, bob, dee)
+	s.expect(http.StatusCreated, http.MethodPost, "/v1/friends/requests/send", cy.AccessToken, map[string]string{"display_name": "dee"}, nil)
+
+	profile := func(viewer, u testUser) PublicProfile {
+		t.Helper()
+		var p PublicProfile
+		s.expect(http.StatusOK, http.MethodGet, fmt.Sprintf("/v1/users/%d", u.ID), viewer.AccessToken, nil, &p)
+		return p
+	}
+
+	// Real names are for friends and people sharing a chat
+	tests := []struct {
+		name         string
+		viewer, user testUser
+		relationship string
+		realName     bool
+	}{
+		{"self", ada, ada, "self", true},
+		{"friend", ada, bob, "friend", true},
+		{"shared chat", bob, dee, "none", true},
+		{"stranger", cy, ada, "none", false},
+		{"request sent", cy, dee, "request_sent", false},
+		{"request received", dee, cy, "request_received", false},
+	}
+	for _, tt := range tests {
+		p := profile(tt.viewer, tt.user)
+		if p.UserID != tt.user.ID || p.DisplayName != tt.user.DisplayName || p.Relationship != tt.relationship {
+			t.Errorf("%s: profile = %+v, want %s", tt.name, p, tt.relationship)
+		}
+		if got := p.FirstName != nil && *p.FirstName == tt.user.DisplayName; got != tt.realName {
+			t.Errorf("%s: real name shown = %v, want %v", tt.name, got, tt.realName)
+		}
+	}
+
+	s.expect(http.StatusNotFound, http.MethodGet, fmt.Sprintf("/v1/users/%d", dee.ID+100), ada.AccessToken, nil, nil)
+	s.expect(http.StatusBadRequest, http.MethodGet, "/v1/users/ada", ada.AccessToken, nil, nil)
+}
+
+func TestBlockUser(t *testing.T) {
+	s := newTestServer(t)
+	ada, bob := s.register("ada"), s.register("bob")
+	s.befriend(ada, bob)
+	block := fmt.Sprintf("/v1/users/%d/block", bob.ID)
+
+	s.expect(http.StatusNoContent, http.MethodPut, block, ada.AccessToken, nil, nil)
+	// Blocking twice is not an error
+	s.expect(http.StatusNoContent, http.MethodPut, block, ada.AccessToken, nil, nil)
+	s.expect(http.StatusBadRequest, http.MethodPut, fmt.Sprintf("/v1/users/%d/block", ada.ID), ada.AccessToken, nil, nil)
+	s.expect(http.StatusNotFound, http.MethodPut, fmt.Sprintf("/v1/users/%d/block", bob.ID+100), ada.AccessToken, nil, nil)
+
+	var blocks struct {
+		Users []BlockedUser `json:"users"`
+	}
+	s.expect(http.StatusOK, http.MethodGet, "/v1/users/blocks", ada.AccessToken, nil, &blocks)
+	if len(blocks.Users) != 1 || blocks.Users[0].UserID != bob.ID {
+		t.Errorf("blocks = %+v", blocks.Users)
+	}
+
+	var friends []FriendResponse
+	s.expect(http.StatusOK, http.MethodGet, "/v1/friends", ada.AccessToken, nil, &friends)
+	if len(friends) != 0 {
+		t.Errorf("friends after blocking = %+v", friends)
+	}
+
+	// Neither side exists to the other
+	s.expect(http.StatusNotFound, http.MethodGet, fmt.Sprintf("/v1/users/%d", bob.ID), ada.AccessToken, nil, nil)
+	s.expect(http.StatusNotFound, http.MethodGet, fmt.Sprintf("/v1/users/%d", ada.ID), bob.AccessToken, nil, nil)
+	s.expect(http.StatusNotFound, http.MethodPost, "/v1/friends/requests/send", bob.AccessToken, map[string]string{"display_name": "ada"}, nil)
+
+	s.expect(http.StatusNoContent, http.MethodDelete, block, ada.AccessToken, nil, nil)
+	s.expect(http.StatusNotFound, http.MethodDelete, block, ada.AccessToken, nil, nil)
+
+	// The friendship the block ended stays ended
+	var p PublicProfile
+	s.expect(http.StatusOK, http.MethodGet, fmt.Sprintf("/v1/users/%d", bob.ID), ada.AccessToken, nil, &p)
+	if p.Relationship != "none" {
+		t.Errorf("relationship after unblocking = %s, want none", p.Relationship)
+	}
+}
+
+func TestSearchUsers(t *testing.T) {
+	s := newTestServer(t)
+	ada, bob := s.register("ada"), s.register("bob")
+	var zed testUser
+	s.expect(http.StatusCreated, http.MethodPost, "/v1/auth/register", "", map[string]string{
+		"display_name": "zed",
+		"password":     testPassword,
+		"first_name":   "Marguerite",
+		"last_name":    "Okonkwo",
+	}, &zed)
+
+	search := func(u testUser, q string) []UserResult {
+		t.Helper()
+		var page struct {
+			Users []UserResult `json:"users"`
+		}
+		s.expect(http.StatusOK, http.MethodGet, "/v1/search/users?"+url.Values{"q": {q}}.Encode(), u.AccessToken, nil, &page)
+		return page.Users
+	}
+
+	// A stranger's real name neither matches nor shows
+	if got := search(ada, "marguerite"); len(got) != 0 {
+		t.Errorf("stranger by real name = %+v, want nothing", got)
+	}
+	if got := search(ada, "ze"); len(got) != 1 || got[0].UserID != zed.ID || got[0].FirstName != nil || got[0].IsFriend {
+		t.Errorf("stranger by display name = %+v", got)
+	}
+
+	s.befriend(ada, zed)
+	if got := search(ada, "Okonkwo"); len(got) != 1 || got[0].FirstName == nil || *got[0].FirstName != "Marguerite" || !got[0].IsFriend {
+		t.Errorf("friend by real name = %+v", got)
+	}
+
+	// Either side of a block hides both
+	s.expect(http.StatusNoContent, http.MethodPut, fmt.Sprintf("/v1/users/%d/block", zed.ID), bob.AccessToken, nil, nil)
+	if got := search(bob, "zed"); len(got) != 0 {
+		t.Errorf("blocked user = %+v, want nothing", got)
+	}
+	if got := search(zed, "bob"); len(got) != 0 {
+		t.Errorf("blocker = %+v, want nothing", got)
+	}
+
+	tests := []struct {
+		name  string
+		query url.Values
+	}{
+		{"short", url.Values{"q": {" a "}}},
+		{"limit", url.Values{"q": {"ada"}, "limit": {"26"}}},
+	}
+	for _, tt := range tests {
+		if rec := s.do(http.MethodGet, "/v1/search/users?"+tt.query.Encode(), ada.AccessToken, nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: search = %d %s, want 400", tt.name, rec.Code, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package search
+
+import "github.com/astrokkidd/flick/pkg/crypto"
+
+// Index returns the blind index of a message body: the digest of each of
+// its Tokens under the chat's data key.
+func Index(key crypto.DataKey, text string) ([][]byte, error) {
+	return Digests(key, Tokens(text))
+}
+
+// Digests digests tokens under the chat's data key, in order.
+func Digests(key crypto.DataKey, tokens []string) ([][]byte, error) {
+	digests := make([][]byte, len(tokens))
+	for i, token := range tokens {
+		d, err := key.BlindIndex(token)
+		if err != nil {
+			return nil, err
+		}
+		digests[i] = d
+	}
+	return digests, nil
+}
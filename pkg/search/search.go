@@ -0,0 +1,150 @@
+// Package search turns message text into the words the blind index is built
+// from, and cuts result snippets around them.
+//
+// A word is a run of letters and digits. Words are folded before indexing:
+// compatibility forms are decomposed, accents dropped and case folded, so
+// "Café", "cafe" and "CAFÉ" are the same word, as are "Straße" and
+// "STRASSE". Search matches whole words; scripts written without spaces
+// index a run as a single word.
+package search
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// MinWordRunes leaves out single letters, which match nearly everything.
+	MinWordRunes = 2
+	// MaxWordBytes truncates longer words, such as URLs, on both sides of
+	// the index so they still match.
+	MaxWordBytes = 64
+	// MaxTokens bounds the index rows a single message can add. Words past
+	// it are not searchable.
+	MaxTokens = 256
+)
+
+// word is a word of the original text along with its folded form.
+type word struct {
+	start, end int // byte offsets into the text
+	token      string
+}
+
+// Tokens returns the distinct folded words of text in the order they first
+// appear, at most MaxTokens of them.
+func Tokens(text string) []string {
+	seen := map[string]bool{}
+	tokens := []string{}
+	for _, w := range words(text) {
+		if seen[w.token] {
+			continue
+		}
+		seen[w.token] = true
+		tokens = append(tokens, w.token)
+		if len(tokens) == MaxTokens {
+			break
+		}
+	}
+	return tokens
+}
+
+// Snippet returns about width runes of text around the first word matching
+// one of the tokens, with runs of whitespace collapsed and an ellipsis where
+// the text was cut. Text with no match is cut from the start.
+func Snippet(text string, tokens []string, width int) string {
+	wanted := map[string]bool{}
+	for _, t := range tokens {
+		wanted[t] = true
+	}
+
+	at := 0
+	for _, w := range words(text) {
+		if wanted[w.token] {
+			at = utf8.RuneCountInString(text[:w.start])
+			break
+		}
+	}
+
+	r := []rune(text)
+	// Lead in with a little context, then fill the width
+	start := max(0, at-width/4)
+	end := min(len(r), start+width)
+	start = max(0, end-width)
+
+	snippet := strings.Join(strings.Fields(string(r[start:end])), " ")
+	if start > 0 {
+		snippet = "…" + snippet
+	}
+	if end < len(r) {
+		snippet += "…"
+	}
+	return snippet
+}
+
+func words(text string) []word {
+	var out []word
+	start := -1
+	for i, c := range text {
+		if inWord(c) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			out = appendWord(out, text, start, i)
+			start = -1
+		}
+	}
+	if start >= 0 {
+		out = appendWord(out, text, start, len(text))
+	}
+	return out
+}
+
+func appendWord(out []word, text string, start, end int) []word {
+	token := fold(text[start:end])
+	if utf8.RuneCountInString(token) < MinWordRunes {
+		return out
+	}
+	return append(out, word{start, end, truncate(token)})
+}
+
+// Combining marks are part of a word so decomposed accents don't split it.
+func inWord(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsNumber(c) || unicode.Is(unicode.Mn, c)
+}
+
+func fold(s string) string {
+	t := transform.Chain(norm.NFKD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	folded, _, err := transform.String(t, s)
+	if err != nil {
+		folded = s
+	}
+
+	// Decomposition can bring in punctuation, as in "½"
+	folded = strings.Map(func(c rune) rune {
+		if unicode.IsLetter(c) || unicode.IsNumber(c) {
+			return c
+		}
+		return -1
+	}, folded)
+	return cases.Fold().String(folded)
+}
+
+func truncate(token string) string {
+	if len(token) <= MaxWordBytes {
+		return token
+	}
+	end := MaxWordBytes
+	for end > 0 && !utf8.RuneStart(token[end]) {
+		end--
+	}
+	return token[:end]
+}
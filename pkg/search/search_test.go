@@ -0,0 +1,82 @@
+package search
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestTokensFold(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"Café cafe CAFÉ", []string{"cafe"}},
+		{"Straße STRASSE", []string{"strasse"}},
+		{"ﬁne ﬁsh", []string{"fine", "fish"}},   // compatibility ligature
+		{"½ a cup", []string{"12", "cup"}},      // decomposed to 1⁄2, single letters dropped
+		{"don't stop", []string{"don", "stop"}}, // apostrophes split words
+		{"e\u0301te\u0301", []string{"ete"}},    // combining accents stay in the word
+		{"東京タワー tower", []string{"東京タワー", "tower"}},
+		{"  \t\n", []string{}},
+	}
+
+	for _, tt := range tests {
+		if got := Tokens(tt.text); !slices.Equal(got, tt.want) {
+			t.Errorf("Tokens(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestTokensLimits(t *testing.T) {
+	long := strings.Repeat("é", MaxWordBytes)
+	tokens := Tokens(long)
+	if len(tokens) != 1 || len(tokens[0]) > MaxWordBytes {
+		t.Fatalf("Tokens of a long word = %q, want one token of at most %d bytes", tokens, MaxWordBytes)
+	}
+	if tokens[0] != strings.Repeat("e", MaxWordBytes) {
+		t.Errorf("long word folded to %q", tokens[0])
+	}
+
+	// Truncation must not split a multi-byte rune
+	cjk := strings.Repeat("字", MaxWordBytes)
+	if got := Tokens(cjk)[0]; len(got) != MaxWordBytes/3*3 {
+		t.Errorf("truncated %d bytes of CJK, want %d", len(got), MaxWordBytes/3*3)
+	}
+
+	var many []string
+	for i := range MaxTokens + 10 {
+		many = append(many, "w"+strings.Repeat("x", i%50)+string(rune('a'+i/50)))
+	}
+	if got := Tokens(strings.Join(many, " ")); len(got) != MaxTokens {
+		t.Errorf("got %d tokens, want at most %d", len(got), MaxTokens)
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	text := "the quick brown fox jumps over the lazy dog and keeps running far away"
+
+	tests := []struct {
+		tokens []string
+		width  int
+		want   string
+	}{
+		{[]string{"lazy"}, 20, "…the lazy dog and ke…"},
+		{[]string{"missing"}, 10, "the quick…"},
+		{[]string{"the"}, 200, text},
+		{[]string{"away"}, 12, "…ing far away"},
+	}
+
+	for _, tt := range tests {
+		if got := Snippet(text, tt.tokens, tt.width); got != tt.want {
+			t.Errorf("Snippet(%q, %d) = %q, want %q", tt.tokens, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestSnippetCollapsesWhitespace(t *testing.T) {
+	got := Snippet("hello\n\n\tthere   world", []string{"there"}, 100)
+	if got != "hello there world" {
+		t.Errorf("Snippet = %q", got)
+	}
+}
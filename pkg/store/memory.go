@@ -0,0 +1,286 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"maps"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/astrokkidd/flick/pkg/database"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Memory is a Store that keeps every table in process, for tests that
+// should not need Postgres.
+//
+// Transactions run one at a time against a copy of the tables that replaces
+// them on commit, so they are serializable and a failed one leaves nothing
+// behind. Queries outside a transaction commit on their own. As in Postgres,
+// sequences are not rolled back.
+type Memory struct {
+	mu   sync.Mutex
+	data *tables
+	seq  sequences
+	now  func() time.Time
+
+	memQueries
+}
+
+var _ Store = (*Memory)(nil)
+
+func NewMemory() *Memory {
+	m := &Memory{data: newTables(), now: now}
+	m.memQueries = memQueries{m: m}
+	return m
+}
+
+// SetClock replaces the clock stamping created_at and similar columns.
+func (m *Memory) SetClock(now func() time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.now = now
+}
+
+func (m *Memory) Tx(ctx context.Context, fn func(q database.Querier) error) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	work := m.data.clone()
+	if err := fn(&memQueries{m: m, tx: work}); err != nil {
+		return err
+	}
+
+	m.data = work
+	return nil
+}
+
+// memQueries implements database.Querier over the tables, either those of
+// an open transaction or the committed ones.
+type memQueries struct {
+	m  *Memory
+	tx *tables
+}
+
+// open returns the tables to work on. Outside a transaction it locks the
+// committed tables until done is called, so each query must check everything
+// before it writes anything.
+func (q *memQueries) open() (t *tables, done func()) {
+	if q.tx != nil {
+		return q.tx, func() {}
+	}
+	q.m.mu.Lock()
+	return q.m.data, q.m.mu.Unlock
+}
+
+type participantKey struct{ chatID, userID int64 }
+
+type friendshipKey struct{ userID, friendID int64 }
+
+type blockKey struct{ blockerID, blockedID int64 }
+
+type hiddenKey struct{ userID, messageID int64 }
+
+type threadReadKey struct{ threadRootID, userID int64 }
+
+type searchTokenKey struct {
+	token     string
+	messageID int64
+}
+
+type reactionKey struct {
+	messageID, userID int64
+	emoji             string
+}
+
+type tables struct {
+	users          map[int64]database.User
+	refreshTokens  map[int64]database.RefreshToken
+	sessions       map[[16]byte]database.Session
+	recoveryCodes  map[int64]database.RecoveryCode
+	mfaChallenges  map[string]database.UsedMfaChallenge
+	userKeys       map[int64]database.UserKey
+	chats          map[int64]database.Chat
+	participants   map[participantKey]database.ChatParticipant
+	messages       map[int64]database.Message
+	revisions      map[int64]database.MessageRevision
+	hidden         map[hiddenKey]database.HiddenMessage
+	reactions      map[reactionKey]database.MessageReaction
+	threadReads    map[threadReadKey]database.ThreadRead
+	searchTokens   map[searchTokenKey]database.MessageSearchToken
+	attachments    map[int64]database.Attachment
+	chatKeys       map[int64]database.ChatKey
+	friendships    map[friendshipKey]database.UserFriendship
+	friendRequests map[int64]database.FriendRequest
+	blocks         map[blockKey]database.UserBlock
+}
+
+func newTables() *tables {
+	return &tables{
+		users:          map[int64]database.User{},
+		refreshTokens:  map[int64]database.RefreshToken{},
+		sessions:       map[[16]byte]database.Session{},
+		recoveryCodes:  map[int64]database.RecoveryCode{},
+		mfaChallenges:  map[string]database.UsedMfaChallenge{},
+		userKeys:       map[int64]database.UserKey{},
+		chats:          map[int64]database.Chat{},
+		participants:   map[participantKey]database.ChatParticipant{},
+		messages:       map[int64]database.Message{},
+		revisions:      map[int64]database.MessageRevision{},
+		hidden:         map[hiddenKey]database.HiddenMessage{},
+		reactions:      map[reactionKey]database.MessageReaction{},
+		threadReads:    map[threadReadKey]database.ThreadRead{},
+		searchTokens:   map[searchTokenKey]database.MessageSearchToken{},
+		attachments:    map[int64]database.Attachment{},
+		chatKeys:       map[int64]database.ChatKey{},
+		friendships:    map[friendshipKey]database.UserFriendship{},
+		friendRequests: map[int64]database.FriendRequest{},
+		blocks:         map[blockKey]database.UserBlock{},
+	}
+}
+
+// clone copies the tables. Rows are values and byte slices are never written
+// in place, so a shallow copy of each map is enough.
+func (t *tables) clone() *tables {
+	return &tables{
+		users:          maps.Clone(t.users),
+		refreshTokens:  maps.Clone(t.refreshTokens),
+		sessions:       maps.Clone(t.sessions),
+		recoveryCodes:  maps.Clone(t.recoveryCodes),
+		mfaChallenges:  maps.Clone(t.mfaChallenges),
+		userKeys:       maps.Clone(t.userKeys),
+		chats:          maps.Clone(t.chats),
+		participants:   maps.Clone(t.participants),
+		messages:       maps.Clone(t.messages),
+		revisions:      maps.Clone(t.revisions),
+		hidden:         maps.Clone(t.hidden),
+		reactions:      maps.Clone(t.reactions),
+		threadReads:    maps.Clone(t.threadReads),
+		searchTokens:   maps.Clone(t.searchTokens),
+		attachments:    maps.Clone(t.attachments),
+		chatKeys:       maps.Clone(t.chatKeys),
+		friendships:    maps.Clone(t.friendships),
+		friendRequests: maps.Clone(t.friendRequests),
+		blocks:         maps.Clone(t.blocks),
+	}
+}
+
+// sequences stand in for the bigserial columns.
+type sequences struct {
+	users          atomic.Int64
+	refreshTokens  atomic.Int64
+	recoveryCodes  atomic.Int64
+	chats          atomic.Int64
+	messages       atomic.Int64
+	revisions      atomic.Int64
+	attachments    atomic.Int64
+	friendRequests atomic.Int64
+}
+
+// now matches the microsecond precision of timestamptz.
+func now() time.Time {
+	return time.Now().Truncate(time.Microsecond)
+}
+
+func (q *memQueries) now() time.Time {
+	return q.m.now()
+}
+
+func (q *memQueries) timestamp() pgtype.Timestamptz {
+	return pgtype.Timestamptz{Time: q.now(), Valid: true}
+}
+
+// newUUID stands in for gen_random_uuid().
+func newUUID() pgtype.UUID {
+	var id pgtype.UUID
+	rand.Read(id.Bytes[:])
+	id.Bytes[6] = id.Bytes[6]&0x0f | 0x40
+	id.Bytes[8] = id.Bytes[8]&0x3f | 0x80
+	id.Valid = true
+	return id
+}
+
+// limit applies LIMIT and OFFSET to already ordered rows.
+func limit[T any](rows []T, n, offset int32) []T {
+	if int(offset) >= len(rows) {
+		return rows[:0]
+	}
+	rows = rows[offset:]
+	if n >= 0 && int(n) < len(rows) {
+		rows = rows[:n]
+	}
+	return rows
+}
+
+// descNullsLast compares as ORDER BY x DESC NULLS LAST.
+func descNullsLast[T cmp.Ordered](a, b *T) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+	return cmp.Compare(*b, *a)
+}
+
+// desc compares as ORDER BY x DESC, which puts NULLs first.
+func desc[T cmp.Ordered](a, b *T) int {
+	if a == nil || b == nil {
+		return -descNullsLast(a, b)
+	}
+	return cmp.Compare(*b, *a)
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// The errors below mirror what Postgres reports, so handlers matching on
+// codes or messages behave the same against either store.
+
+func uniqueViolation(constraint string) error {
+	return &pgconn.PgError{
+		Severity:       "ERROR",
+		Code:           "23505",
+		Message:        fmt.Sprintf("duplicate key value violates unique constraint %q", constraint),
+		ConstraintName: constraint,
+	}
+}
+
+func foreignKeyViolation(table, constraint string) error {
+	return &pgconn.PgError{
+		Severity:       "ERROR",
+		Code:           "23503",
+		Message:        fmt.Sprintf("insert or update on table %q violates foreign key constraint %q", table, constraint),
+		TableName:      table,
+		ConstraintName: constraint,
+	}
+}
+
+func checkViolation(table, constraint string) error {
+	return &pgconn.PgError{
+		Severity:       "ERROR",
+		Code:           "23514",
+		Message:        fmt.Sprintf("new row for relation %q violates check constraint %q", table, constraint),
+		TableName:      table,
+		ConstraintName: constraint,
+	}
+}
+
+func stringTooLong(n int) error {
+	return &pgconn.PgError{
+		Severity: "ERROR",
+		Code:     "22001",
+		Message:  fmt.Sprintf("value too long for type character varying(%d)", n),
+	}
+}
@@ -0,0 +1,151 @@
+package store
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"slices"
+
+	"github.com/astrokkidd/flick/pkg/database"
+	"github.com/jackc/pgx/v5"
+)
+
+func (q *memQueries) NextAttachmentID(ctx context.Context) (int64, error) {
+	return q.m.seq.attachments.Add(1), nil
+}
+
+func (q *memQueries) CreateAttachment(ctx context.Context, arg database.CreateAttachmentParams) error {
+	t, done := q.open()
+	defer done()
+
+	if _, ok := t.attachments[arg.AttachmentID]; ok {
+		return uniqueViolation("attachments_pkey")
+	}
+	for _, a := range t.attachments {
+		if a.BlobKey == arg.BlobKey {
+			return uniqueViolation("attachments_blob_key_key")
+		}
+		if a.ThumbnailKey != nil && arg.ThumbnailKey != nil && *a.ThumbnailKey == *arg.ThumbnailKey {
+			return uniqueViolation("attachments_thumbnail_key_key")
+		}
+	}
+	if _, ok := t.chats[arg.ChatID]; !ok {
+		return foreignKeyViolation("attachments", "attachments_chat_id_fkey")
+	}
+	if _, ok := t.users[arg.UploaderID]; !ok {
+		return foreignKeyViolation("attachments", "attachments_uploader_id_fkey")
+	}
+
+	t.attachments[arg.AttachmentID] = database.Attachment{
+		AttachmentID: arg.AttachmentID,
+		ChatID:       arg.ChatID,
+		UploaderID:   arg.UploaderID,
+		BlobKey:      arg.BlobKey,
+		FileName:     arg.FileName,
+		MimeType:     arg.MimeType,
+		SizeBytes:    arg.SizeBytes,
+		Sha256:       bytes.Clone(arg.Sha256),
+		Width:        arg.Width,
+		Height:       arg.Height,
+		ThumbnailKey: arg.ThumbnailKey,
+		CreatedAt:    q.now(),
+	}
+	return nil
+}
+
+func (q *memQueries) GetAttachmentUsage(ctx context.Context, arg database.GetAttachmentUsageParams) (int64, error) {
+	t, done := q.open()
+	defer done()
+
+	var total int64
+	for _, a := range t.attachments {
+		if a.UploaderID == arg.UploaderID {
+			total += a.SizeBytes
+		}
+	}
+	return total, nil
+}
+
+func (q *memQueries) GetAttachment(ctx context.Context, arg database.GetAttachmentParams) (database.Attachment, error) {
+	t, done := q.open()
+	defer done()
+
+	a, ok := t.attachments[arg.AttachmentID]
+	if !ok || a.ChatID != arg.ChatID {
+		return database.Attachment{}, pgx.ErrNoRows
+	}
+	return a, nil
+}
+
+func (q *memQueries) AttachToMessage(ctx context.Context, arg database.AttachToMessageParams) ([]database.Attachment, error) {
+	t, done := q.open()
+	defer done()
+
+	if _, ok := t.messages[arg.MessageID]; !ok {
+		return nil, foreignKeyViolation("attachments", "attachments_message_id_fkey")
+	}
+
+	rows := []database.Attachment{}
+	for _, id := range slices.Compact(slices.Sorted(slices.Values(arg.AttachmentIds))) {
+		a, ok := t.attachments[id]
+		if !ok || a.ChatID != arg.ChatID || a.UploaderID != arg.UploaderID || a.MessageID != nil {
+			continue
+		}
+		a.MessageID = ptr(arg.MessageID)
+		t.attachments[id] = a
+		rows = append(rows, a)
+	}
+	return rows, nil
+}
+
+func (q *memQueries) ListMessageAttachments(ctx context.Context, arg database.ListMessageAttachmentsParams) ([]database.Attachment, error) {
+	t, done := q.open()
+	defer done()
+
+	rows := []database.Attachment{}
+	for _, a := range t.attachments {
+		if a.MessageID == nil || !slices.Contains(arg.MessageIds, *a.MessageID) {
+			continue
+		}
+		rows = append(rows, a)
+	}
+	slices.SortFunc(rows, func(a, b database.Attachment) int {
+		return cmp.Compare(a.AttachmentID, b.AttachmentID)
+	})
+	return rows, nil
+}
+
+func (q *memQueries) ListChatAttachmentBlobs(ctx context.Context, arg database.ListChatAttachmentBlobsParams) ([]database.ListChatAttachmentBlobsRow, error) {
+	t, done := q.open()
+	defer done()
+
+	rows := []database.Attachment{}
+	for _, a := range t.attachments {
+		if a.ChatID == arg.ChatID {
+			rows = append(rows, a)
+		}
+	}
+	slices.SortFunc(rows, func(a, b database.Attachment) int {
+		return cmp.Compare(a.AttachmentID, b.AttachmentID)
+	})
+
+	keys := []database.ListChatAttachmentBlobsRow{}
+	for _, a := range rows {
+		keys = append(keys, database.ListChatAttachmentBlobsRow{BlobKey: a.BlobKey, ThumbnailKey: a.ThumbnailKey})
+	}
+	return keys, nil
+}
+
+func (q *memQueries) DeleteMessageAttachments(ctx context.Context, arg database.DeleteMessageAttachmentsParams) ([]database.DeleteMessageAttachmentsRow, error) {
+	t, done := q.open()
+	defer done()
+
+	rows := []database.DeleteMessageAttachmentsRow{}
+	for id, a := range t.attachments {
+		if a.MessageID != nil && *a.MessageID == arg.MessageID {
+			rows = append(rows, database.DeleteMessageAttachmentsRow{BlobKey: a.BlobKey, ThumbnailKey: a.ThumbnailKey})
+			delete(t.attachments, id)
+		}
+	}
+	return rows, nil
+}
@@ -0,0 +1,386 @@
+package store
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"slices"
+
+	"github.com/astrokkidd/flick/pkg/database"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func (q *memQueries) CreateRefreshToken(ctx context.Context, arg database.CreateRefreshTokenParams) (database.CreateRefreshTokenRow, error) {
+	t, done := q.open()
+	defer done()
+
+	if _, ok := t.users[arg.UserID]; !ok {
+		return database.CreateRefreshTokenRow{}, foreignKeyViolation("refresh_tokens", "refresh_tokens_user_id_fkey")
+	}
+	for _, rt := range t.refreshTokens {
+		if bytes.Equal(rt.TokenHash, arg.TokenHash) {
+			return database.CreateRefreshTokenRow{}, uniqueViolation("refresh_tokens_token_hash_key")
+		}
+	}
+
+	family := arg.FamilyID
+	if !family.Valid {
+		family = newUUID()
+	}
+
+	rt := database.RefreshToken{
+		TokenID:   q.m.seq.refreshTokens.Add(1),
+		UserID:    arg.UserID,
+		FamilyID:  family,
+		TokenHash: bytes.Clone(arg.TokenHash),
+		CreatedAt: q.now(),
+		ExpiresAt: arg.ExpiresAt,
+	}
+	t.refreshTokens[rt.TokenID] = rt
+
+	return database.CreateRefreshTokenRow{TokenID: rt.TokenID, FamilyID: rt.FamilyID}, nil
+}
+
+func (q *memQueries) GetRefreshTokenForUpdate(ctx context.Context, arg database.GetRefreshTokenForUpdateParams) (database.GetRefreshTokenForUpdateRow, error) {
+	t, done := q.open()
+	defer done()
+
+	for _, rt := range t.refreshTokens {
+		if bytes.Equal(rt.TokenHash, arg.TokenHash) {
+			return database.GetRefreshTokenForUpdateRow{
+				TokenID:   rt.TokenID,
+				UserID:    rt.UserID,
+				FamilyID:  rt.FamilyID,
+				ExpiresAt: rt.ExpiresAt,
+				UsedAt:    rt.UsedAt,
+				RevokedAt: rt.RevokedAt,
+			}, nil
+		}
+	}
+	return database.GetRefreshTokenForUpdateRow{}, pgx.ErrNoRows
+}
+
+func (q *memQueries) MarkRefreshTokenUsed(ctx context.Context, arg database.MarkRefreshTokenUsedParams) error {
+	t, done := q.open()
+	defer done()
+
+	if rt, ok := t.refreshTokens[arg.TokenID]; ok {
+		rt.UsedAt = q.timestamp()
+		t.refreshTokens[rt.TokenID] = rt
+	}
+	return nil
+}
+
+func (q *memQueries) RevokeRefreshTokenFamily(ctx context.Context, arg database.RevokeRefreshTokenFamilyParams) (int64, error) {
+	t, done := q.open()
+	defer done()
+
+	var n int64
+	for id, rt := range t.refreshTokens {
+		if rt.FamilyID == arg.FamilyID && !rt.RevokedAt.Valid {
+			rt.RevokedAt = q.timestamp()
+			t.refreshTokens[id] = rt
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (q *memQueries) RevokeAllUserRefreshTokens(ctx context.Context, arg database.RevokeAllUserRefreshTokensParams) error {
+	t, done := q.open()
+	defer done()
+
+	for id, rt := range t.refreshTokens {
+		if rt.UserID != arg.UserID || rt.RevokedAt.Valid {
+			continue
+		}
+		if arg.KeepSessionID.Valid && rt.FamilyID == arg.KeepSessionID {
+			continue
+		}
+		rt.RevokedAt = q.timestamp()
+		t.refreshTokens[id] = rt
+	}
+	return nil
+}
+
+func (q *memQueries) CreateSession(ctx context.Context, arg database.CreateSessionParams) (pgtype.UUID, error) {
+	t, done := q.open()
+	defer done()
+
+	if _, ok := t.users[arg.UserID]; !ok {
+		return pgtype.UUID{}, foreignKeyViolation("sessions", "sessions_user_id_fkey")
+	}
+
+	at := q.now()
+	s := database.Session{
+		SessionID:  newUUID(),
+		UserID:     arg.UserID,
+		UserAgent:  arg.UserAgent,
+		IpAddress:  arg.IpAddress,
+		CreatedAt:  at,
+		LastSeenAt: at,
+	}
+	t.sessions[s.SessionID.Bytes] = s
+
+	return s.SessionID, nil
+}
+
+func (q *memQueries) TouchSession(ctx context.Context, arg database.TouchSessionParams) (int64, error) {
+	t, done := q.open()
+	defer done()
+
+	s, ok := t.sessions[arg.SessionID.Bytes]
+	if !ok || !arg.SessionID.Valid || s.RevokedAt.Valid {
+		return 0, nil
+	}
+	s.LastSeenAt = q.now()
+	s.IpAddress = arg.IpAddress
+	t.sessions[s.SessionID.Bytes] = s
+	return 1, nil
+}
+
+func (q *memQueries) ListUserSessions(ctx context.Context, arg database.ListUserSessionsParams) ([]database.ListUserSessionsRow, error) {
+	t, done := q.open()
+	defer done()
+
+	rows := []database.ListUserSessionsRow{}
+	for _, s := range t.sessions {
+		if s.UserID == arg.UserID && !s.RevokedAt.Valid {
+			rows = append(rows, database.ListUserSessionsRow{
+				SessionID:  s.SessionID,
+				UserAgent:  s.UserAgent,
+				IpAddress:  s.IpAddress,
+				CreatedAt:  s.CreatedAt,
+				LastSeenAt: s.LastSeenAt,
+			})
+		}
+	}
+	slices.SortFunc(rows, func(a, b database.ListUserSessionsRow) int { return b.LastSeenAt.Compare(a.LastSeenAt) })
+	return rows, nil
+}
+
+func (q *memQueries) RevokeSession(ctx context.Context, arg database.RevokeSessionParams) (int64, error) {
+	t, done := q.open()
+	defer done()
+
+	s, ok := t.sessions[arg.SessionID.Bytes]
+	if !ok || !arg.SessionID.Valid || s.UserID != arg.UserID || s.RevokedAt.Valid {
+		return 0, nil
+	}
+	s.RevokedAt = q.timestamp()
+	t.sessions[s.SessionID.Bytes] = s
+	return 1, nil
+}
+
+func (q *memQueries) RevokeAllUserSessions(ctx context.Context, arg database.RevokeAllUserSessionsParams) ([]pgtype.UUID, error) {
+	t, done := q.open()
+	defer done()
+
+	var revoked []database.Session
+	for _, s := range t.sessions {
+		if s.UserID != arg.UserID || s.RevokedAt.Valid {
+			continue
+		}
+		if arg.KeepSessionID.Valid && s.SessionID == arg.KeepSessionID {
+			continue
+		}
+		s.RevokedAt = q.timestamp()
+		t.sessions[s.SessionID.Bytes] = s
+		revoked = append(revoked, s)
+	}
+	slices.SortFunc(revoked, func(a, b database.Session) int { return a.CreatedAt.Compare(b.CreatedAt) })
+
+	ids := []pgtype.UUID{}
+	for _, s := range revoked {
+		ids = append(ids, s.SessionID)
+	}
+	return ids, nil
+}
+
+func (q *memQueries) GetUserTOTPForUpdate(ctx context.Context, arg database.GetUserTOTPForUpdateParams) (database.GetUserTOTPForUpdateRow, error) {
+	t, done := q.open()
+	defer done()
+
+	u, ok := t.users[arg.UserID]
+	if !ok {
+		return database.GetUserTOTPForUpdateRow{}, pgx.ErrNoRows
+	}
+	return database.GetUserTOTPForUpdateRow{
+		TotpSecret:     u.TotpSecret,
+		TotpEnabled:    u.TotpEnabled,
+		TotpLastStep:   u.TotpLastStep,
+		MfaLockedUntil: u.MfaLockedUntil,
+	}, nil
+}
+
+func (q *memQueries) SetPendingTOTPSecret(ctx context.Context, arg database.SetPendingTOTPSecretParams) error {
+	t, done := q.open()
+	defer done()
+
+	if u, ok := t.users[arg.UserID]; ok {
+		u.TotpSecret = bytes.Clone(arg.TotpSecret)
+		u.TotpEnabled = false
+		u.TotpLastStep = nil
+		t.users[u.UserID] = u
+	}
+	return nil
+}
+
+func (q *memQueries) EnableTOTP(ctx context.Context, arg database.EnableTOTPParams) error {
+	t, done := q.open()
+	defer done()
+
+	if u, ok := t.users[arg.UserID]; ok {
+		u.TotpEnabled = true
+		u.TotpLastStep = arg.TotpLastStep
+		t.users[u.UserID] = u
+	}
+	return nil
+}
+
+func (q *memQueries) SetTOTPLastStep(ctx context.Context, arg database.SetTOTPLastStepParams) error {
+	t, done := q.open()
+	defer done()
+
+	if u, ok := t.users[arg.UserID]; ok {
+		u.TotpLastStep = arg.TotpLastStep
+		t.users[u.UserID] = u
+	}
+	return nil
+}
+
+func (q *memQueries) RecordMFAFailure(ctx context.Context, arg database.RecordMFAFailureParams) error {
+	t, done := q.open()
+	defer done()
+
+	if u, ok := t.users[arg.UserID]; ok {
+		u.MfaFailedAttempts++
+		if u.MfaFailedAttempts >= arg.MaxAttempts {
+			u.MfaLockedUntil = pgtype.Timestamptz{Time: arg.LockedUntil, Valid: true}
+		}
+		t.users[u.UserID] = u
+	}
+	return nil
+}
+
+func (q *memQueries) ResetMFAFailures(ctx context.Context, arg database.ResetMFAFailuresParams) error {
+	t, done := q.open()
+	defer done()
+
+	if u, ok := t.users[arg.UserID]; ok {
+		u.MfaFailedAttempts = 0
+		u.MfaLockedUntil = pgtype.Timestamptz{}
+		t.users[u.UserID] = u
+	}
+	return nil
+}
+
+func (q *memQueries) UseMFAChallenge(ctx context.Context, arg database.UseMFAChallengeParams) (int64, error) {
+	t, done := q.open()
+	defer done()
+
+	if _, ok := t.users[arg.UserID]; !ok {
+		return 0, foreignKeyViolation("used_mfa_challenges", "used_mfa_challenges_user_id_fkey")
+	}
+	if _, ok := t.mfaChallenges[arg.ChallengeID]; ok {
+		return 0, nil
+	}
+
+	t.mfaChallenges[arg.ChallengeID] = database.UsedMfaChallenge{
+		ChallengeID: arg.ChallengeID,
+		UserID:      arg.UserID,
+		ExpiresAt:   arg.ExpiresAt,
+	}
+	return 1, nil
+}
+
+func (q *memQueries) DeleteExpiredMFAChallenges(ctx context.Context) error {
+	t, done := q.open()
+	defer done()
+
+	now := q.now()
+	for id, c := range t.mfaChallenges {
+		if c.ExpiresAt.Before(now) {
+			delete(t.mfaChallenges, id)
+		}
+	}
+	return nil
+}
+
+func (q *memQueries) ListTOTPSecrets(ctx context.Context, arg database.ListTOTPSecretsParams) ([]database.ListTOTPSecretsRow, error) {
+	t, done := q.open()
+	defer done()
+
+	rows := []database.ListTOTPSecretsRow{}
+	for _, u := range t.users {
+		if u.TotpSecret != nil && u.UserID > arg.AfterID {
+			rows = append(rows, database.ListTOTPSecretsRow{UserID: u.UserID, TotpSecret: u.TotpSecret})
+		}
+	}
+	slices.SortFunc(rows, func(a, b database.ListTOTPSecretsRow) int { return cmp.Compare(a.UserID, b.UserID) })
+	return limit(rows, arg.BatchSize, 0), nil
+}
+
+func (q *memQueries) ReplaceTOTPSecret(ctx context.Context, arg database.ReplaceTOTPSecretParams) (int64, error) {
+	t, done := q.open()
+	defer done()
+
+	u, ok := t.users[arg.UserID]
+	if !ok || u.TotpSecret == nil || !bytes.Equal(u.TotpSecret, arg.OldTotpSecret) {
+		return 0, nil
+	}
+	u.TotpSecret = bytes.Clone(arg.NewTotpSecret)
+	t.users[u.UserID] = u
+	return 1, nil
+}
+
+func (q *memQueries) DeleteRecoveryCodes(ctx context.Context, arg database.DeleteRecoveryCodesParams) error {
+	t, done := q.open()
+	defer done()
+
+	for id, rc := range t.recoveryCodes {
+		if rc.UserID == arg.UserID {
+			delete(t.recoveryCodes, id)
+		}
+	}
+	return nil
+}
+
+func (q *memQueries) CreateRecoveryCode(ctx context.Context, arg database.CreateRecoveryCodeParams) error {
+	t, done := q.open()
+	defer done()
+
+	if _, ok := t.users[arg.UserID]; !ok {
+		return foreignKeyViolation("recovery_codes", "recovery_codes_user_id_fkey")
+	}
+	for _, rc := range t.recoveryCodes {
+		if bytes.Equal(rc.CodeHash, arg.CodeHash) {
+			return uniqueViolation("recovery_codes_code_hash_key")
+		}
+	}
+
+	rc := database.RecoveryCode{
+		CodeID:    q.m.seq.recoveryCodes.Add(1),
+		UserID:    arg.UserID,
+		CodeHash:  bytes.Clone(arg.CodeHash),
+		CreatedAt: q.now(),
+	}
+	t.recoveryCodes[rc.CodeID] = rc
+	return nil
+}
+
+func (q *memQueries) UseRecoveryCode(ctx context.Context, arg database.UseRecoveryCodeParams) (int64, error) {
+	t, done := q.open()
+	defer done()
+
+	var n int64
+	for id, rc := range t.recoveryCodes {
+		if rc.UserID == arg.UserID && bytes.Equal(rc.CodeHash, arg.CodeHash) && !rc.UsedAt.Valid {
+			rc.UsedAt = q.timestamp()
+			t.recoveryCodes[id] = rc
+			n++
+		}
+	}
+	return n, nil
+}
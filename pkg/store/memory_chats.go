@@ -0,0 +1,559 @@
+package store
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"slices"
+
+	"github.com/astrokkidd/flick/pkg/database"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	chatKindDirect = "direct"
+	chatKindGroup  = "group"
+)
+
+func validRole(role string) bool {
+	return role == "owner" || role == "admin" || role == "member"
+}
+
+// chatParticipants returns the participants of a chat in the order they
+// joined.
+func chatParticipants(t *tables, chatID int64) []database.ChatParticipant {
+	var rows []database.ChatParticipant
+	for _, p := range t.participants {
+		if p.ChatID == chatID {
+			rows = append(rows, p)
+		}
+	}
+	slices.SortFunc(rows, func(a, b database.ChatParticipant) int {
+		return cmp.Or(a.JoinedAt.Compare(b.JoinedAt), cmp.Compare(a.UserID, b.UserID))
+	})
+	return rows
+}
+
+// lastMessage resolves the LEFT JOIN from a chat to its last message.
+func lastMessage(t *tables, c database.Chat) (database.Message, bool) {
+	if c.LastMessageID == nil {
+		return database.Message{}, false
+	}
+	m, ok := t.messages[*c.LastMessageID]
+	return m, ok
+}
+
+// lastVisibleMessage is the newest message in a chat's history that was not
+// deleted for everyone or hidden by the user.
+func lastVisibleMessage(t *tables, chatID, userID int64) (database.Message, bool) {
+	var last database.Message
+	found := false
+	for _, m := range t.messages {
+		if m.ChatID != chatID || m.ThreadRootID != nil || m.DeletedAt.Valid || isHidden(t, userID, m.MessageID) {
+			continue
+		}
+		if !found || compareMessages(m, last) > 0 {
+			last, found = m, true
+		}
+	}
+	return last, found
+}
+
+func (q *memQueries) GetChatByID(ctx context.Context, arg database.GetChatByIDParams) (database.GetChatByIDRow, error) {
+	t, done := q.open()
+	defer done()
+
+	c, ok := t.chats[arg.ChatID]
+	if !ok {
+		return database.GetChatByIDRow{}, pgx.ErrNoRows
+	}
+	return database.GetChatByIDRow{ChatID: c.ChatID, Kind: c.Kind, Title: c.Title, LastMessageID: c.LastMessageID}, nil
+}
+
+func (q *memQueries) CreateEmptyChat(ctx context.Context, arg database.CreateEmptyChatParams) (database.CreateEmptyChatRow, error) {
+	t, done := q.open()
+	defer done()
+
+	c := database.Chat{
+		ChatID:    q.m.seq.chats.Add(1),
+		Kind:      chatKindDirect,
+		CreatedAt: q.now(),
+		EndToEnd:  arg.EndToEnd,
+	}
+	t.chats[c.ChatID] = c
+	return database.CreateEmptyChatRow{ChatID: c.ChatID}, nil
+}
+
+func (q *memQueries) CreateGroupChat(ctx context.Context, arg database.CreateGroupChatParams) (int64, error) {
+	t, done := q.open()
+	defer done()
+
+	c := database.Chat{
+		ChatID:    q.m.seq.chats.Add(1),
+		Kind:      chatKindGroup,
+		Title:     arg.Title,
+		CreatedAt: q.now(),
+		EndToEnd:  arg.EndToEnd,
+	}
+	t.chats[c.ChatID] = c
+	return c.ChatID, nil
+}
+
+func (q *memQueries) IsChatEndToEnd(ctx context.Context, arg database.IsChatEndToEndParams) (bool, error) {
+	t, done := q.open()
+	defer done()
+
+	c, ok := t.chats[arg.ChatID]
+	if !ok {
+		return false, pgx.ErrNoRows
+	}
+	return c.EndToEnd, nil
+}
+
+func (q *memQueries) UpdateChatTitle(ctx context.Context, arg database.UpdateChatTitleParams) (int64, error) {
+	t, done := q.open()
+	defer done()
+
+	c, ok := t.chats[arg.ChatID]
+	if !ok || c.Kind != chatKindGroup {
+		return 0, nil
+	}
+	c.Title = arg.Title
+	t.chats[c.ChatID] = c
+	return 1, nil
+}
+
+func (q *memQueries) UpdateChatLastMessage(ctx context.Context, arg database.UpdateChatLastMessageParams) error {
+	t, done := q.open()
+	defer done()
+
+	if c, ok := t.chats[arg.ChatID]; ok {
+		c.LastMessageID = arg.LastMessageID
+		t.chats[c.ChatID] = c
+	}
+	return nil
+}
+
+func (q *memQueries) DeleteChat(ctx context.Context, arg database.DeleteChatParams) (int64, error) {
+	t, done := q.open()
+	defer done()
+
+	if _, ok := t.chats[arg.ChatID]; !ok {
+		return 0, nil
+	}
+
+	// ON DELETE CASCADE
+	delete(t.chats, arg.ChatID)
+	delete(t.chatKeys, arg.ChatID)
+	for k := range t.participants {
+		if k.chatID == arg.ChatID {
+			delete(t.participants, k)
+		}
+	}
+	for id, m := range t.messages {
+		if m.ChatID == arg.ChatID {
+			deleteMessage(t, id)
+		}
+	}
+	for id, a := range t.attachments {
+		if a.ChatID == arg.ChatID {
+			delete(t.attachments, id)
+		}
+	}
+	return 1, nil
+}
+
+func (q *memQueries) CountChatParticipants(ctx context.Context, arg database.CountChatParticipantsParams) (int64, error) {
+	t, done := q.open()
+	defer done()
+
+	return int64(len(chatParticipants(t, arg.ChatID))), nil
+}
+
+func (q *memQueries) AddParticipant(ctx context.Context, arg database.AddParticipantParams) error {
+	t, done := q.open()
+	defer done()
+
+	if !validRole(arg.Role) {
+		return checkViolation("chat_participants", "chat_participants_role_check")
+	}
+	if _, ok := t.chats[arg.ChatID]; !ok {
+		return foreignKeyViolation("chat_participants", "chat_participants_chat_id_fkey")
+	}
+	if _, ok := t.users[arg.UserID]; !ok {
+		return foreignKeyViolation("chat_participants", "chat_participants_user_id_fkey")
+	}
+
+	k := participantKey{arg.ChatID, arg.UserID}
+	if _, ok := t.participants[k]; ok {
+		return nil
+	}
+	t.participants[k] = database.ChatParticipant{
+		ChatID:   arg.ChatID,
+		UserID:   arg.UserID,
+		Role:     arg.Role,
+		JoinedAt: q.now(),
+	}
+	return nil
+}
+
+func (q *memQueries) RemoveParticipant(ctx context.Context, arg database.RemoveParticipantParams) (int64, error) {
+	t, done := q.open()
+	defer done()
+
+	k := participantKey{arg.ChatID, arg.UserID}
+	if _, ok := t.participants[k]; !ok {
+		return 0, nil
+	}
+	delete(t.participants, k)
+	return 1, nil
+}
+
+func (q *memQueries) GetParticipantRole(ctx context.Context, arg database.GetParticipantRoleParams) (string, error) {
+	t, done := q.open()
+	defer done()
+
+	p, ok := t.participants[participantKey{arg.ChatID, arg.UserID}]
+	if !ok {
+		return "", pgx.ErrNoRows
+	}
+	return p.Role, nil
+}
+
+func (q *memQueries) SetParticipantRole(ctx context.Context, arg database.SetParticipantRoleParams) (int64, error) {
+	t, done := q.open()
+	defer done()
+
+	k := participantKey{arg.ChatID, arg.UserID}
+	p, ok := t.participants[k]
+	if !ok {
+		return 0, nil
+	}
+	if !validRole(arg.Role) {
+		return 0, checkViolation("chat_participants", "chat_participants_role_check")
+	}
+	p.Role = arg.Role
+	t.participants[k] = p
+	return 1, nil
+}
+
+func (q *memQueries) FindSuccessorOwner(ctx context.Context, arg database.FindSuccessorOwnerParams) (int64, error) {
+	t, done := q.open()
+	defer done()
+
+	// Admins first, then by seniority
+	rows := chatParticipants(t, arg.ChatID)
+	slices.SortStableFunc(rows, func(a, b database.ChatParticipant) int {
+		return cmp.Compare(roleOrder(a.Role), roleOrder(b.Role))
+	})
+	if len(rows) == 0 {
+		return 0, pgx.ErrNoRows
+	}
+	return rows[0].UserID, nil
+}
+
+func roleOrder(role string) int {
+	if role == "admin" {
+		return 0
+	}
+	return 1
+}
+
+func (q *memQueries) FindDirectChatBetween(ctx context.Context, arg database.FindDirectChatBetweenParams) (int64, error) {
+	t, done := q.open()
+	defer done()
+
+	var ids []int64
+	for _, c := range t.chats {
+		if c.Kind != chatKindDirect || c.EndToEnd != arg.EndToEnd {
+			continue
+		}
+		var hasA, hasB, others bool
+		for _, p := range chatParticipants(t, c.ChatID) {
+			switch p.UserID {
+			case arg.UserID:
+				hasA = true
+			case arg.UserID_2:
+				hasB = true
+			default:
+				others = true
+			}
+		}
+		if hasA && hasB && !others {
+			ids = append(ids, c.ChatID)
+		}
+	}
+	if len(ids) == 0 {
+		return 0, pgx.ErrNoRows
+	}
+	return slices.Min(ids), nil
+}
+
+func (q *memQueries) ListChatsWithParticipant(ctx context.Context, arg database.ListChatsWithParticipantParams) ([]database.ListChatsWithParticipantRow, error) {
+	t, done := q.open()
+	defer done()
+
+	type row struct {
+		database.ListChatsWithParticipantRow
+		sentAt *int64
+	}
+	var rows []row
+	for _, c := range t.chats {
+		if _, ok := t.participants[participantKey{c.ChatID, arg.UserID}]; !ok {
+			continue
+		}
+
+		r := row{ListChatsWithParticipantRow: database.ListChatsWithParticipantRow{
+			ChatID:        c.ChatID,
+			LastMessageID: c.LastMessageID,
+			CreatedAt:     c.CreatedAt,
+		}}
+		if m, ok := lastMessage(t, c); ok {
+			r.MessageID = ptr(m.MessageID)
+			r.SenderID = ptr(m.SenderID)
+			r.sentAt = ptr(m.CreatedAt.UnixMicro())
+		}
+
+		// ARRAY_AGG over no rows is NULL, and pgx decodes an int8[] into any
+		// as []any
+		var others []any
+		for _, p := range chatParticipants(t, c.ChatID) {
+			if p.UserID != arg.UserID {
+				others = append(others, p.UserID)
+			}
+		}
+		slices.SortFunc(others, func(a, b any) int { return cmp.Compare(a.(int64), b.(int64)) })
+		if others != nil {
+			r.OtherParticipantIds = others
+		}
+
+		rows = append(rows, r)
+	}
+	slices.SortFunc(rows, func(a, b row) int { return descNullsLast(a.sentAt, b.sentAt) })
+
+	out := []database.ListChatsWithParticipantRow{}
+	for _, r := range rows {
+		out = append(out, r.ListChatsWithParticipantRow)
+	}
+	return out, nil
+}
+
+func (q *memQueries) ListChatsWithUser(ctx context.Context, arg database.ListChatsWithUserParams) ([]database.ListChatsWithUserRow, error) {
+	t, done := q.open()
+	defer done()
+
+	var chats []database.Chat
+	for _, c := range t.chats {
+		if _, ok := t.participants[participantKey{c.ChatID, arg.UserID}]; ok {
+			chats = append(chats, c)
+		}
+	}
+
+	// m.created_at DESC NULLS LAST, c.last_message_id DESC, c.chat_id DESC
+	sentAt := func(c database.Chat) *int64 {
+		if m, ok := lastVisibleMessage(t, c.ChatID, arg.UserID); ok {
+			return ptr(m.CreatedAt.UnixMicro())
+		}
+		return nil
+	}
+	slices.SortFunc(chats, func(a, b database.Chat) int {
+		return cmp.Or(
+			descNullsLast(sentAt(a), sentAt(b)),
+			desc(a.LastMessageID, b.LastMessageID),
+			cmp.Compare(b.ChatID, a.ChatID),
+		)
+	})
+
+	rows := []database.ListChatsWithUserRow{}
+	for _, c := range chats {
+		r := database.ListChatsWithUserRow{
+			ChatID:   c.ChatID,
+			Kind:     c.Kind,
+			Title:    c.Title,
+			EndToEnd: c.EndToEnd,
+		}
+		if m, ok := lastVisibleMessage(t, c.ChatID, arg.UserID); ok {
+			r.MessageID = ptr(m.MessageID)
+			r.SenderID = ptr(m.SenderID)
+			r.CreatedAt = pgtype.Timestamptz{Time: m.CreatedAt, Valid: true}
+			r.CypherText = m.CypherText
+		}
+		if k, ok := t.chatKeys[c.ChatID]; ok {
+			r.WrappedKey = k.WrappedKey
+		}
+		rows = append(rows, r)
+	}
+	return rows, nil
+}
+
+func (q *memQueries) ListChatParticipants(ctx context.Context, arg database.ListChatParticipantsParams) ([]database.ListChatParticipantsRow, error) {
+	t, done := q.open()
+	defer done()
+
+	rows := []database.ListChatParticipantsRow{}
+	for _, chatID := range slices.Compact(slices.Sorted(slices.Values(arg.Column1))) {
+		for _, p := range chatParticipants(t, chatID) {
+			u := t.users[p.UserID]
+			rows = append(rows, database.ListChatParticipantsRow{
+				ChatID:    p.ChatID,
+				Role:      p.Role,
+				PfpUrl:    u.PfpUrl,
+				UserID:    u.UserID,
+				FirstName: u.FirstName,
+				LastName:  u.LastName,
+			})
+		}
+	}
+	return rows, nil
+}
+
+func (q *memQueries) ListChatParticipantIDs(ctx context.Context, arg database.ListChatParticipantIDsParams) ([]int64, error) {
+	t, done := q.open()
+	defer done()
+
+	ids := []int64{}
+	for _, p := range chatParticipants(t, arg.ChatID) {
+		ids = append(ids, p.UserID)
+	}
+	return ids, nil
+}
+
+func (q *memQueries) RefreshChatLastMessage(ctx context.Context, arg database.RefreshChatLastMessageParams) error {
+	t, done := q.open()
+	defer done()
+
+	c, ok := t.chats[arg.ChatID]
+	if !ok {
+		return nil
+	}
+
+	var last database.Message
+	c.LastMessageID = nil
+	for _, m := range t.messages {
+		if m.ChatID == arg.ChatID && m.ThreadRootID == nil && !m.DeletedAt.Valid && (c.LastMessageID == nil || compareMessages(m, last) > 0) {
+			last = m
+			c.LastMessageID = ptr(m.MessageID)
+		}
+	}
+	t.chats[c.ChatID] = c
+	return nil
+}
+
+func (q *memQueries) SetTypingStatus(ctx context.Context, arg database.SetTypingStatusParams) (int64, error) {
+	t, done := q.open()
+	defer done()
+
+	k := participantKey{arg.ChatID, arg.UserID}
+	p, ok := t.participants[k]
+	if !ok {
+		return 0, nil
+	}
+	p.IsTyping = arg.IsTyping
+	t.participants[k] = p
+	return 1, nil
+}
+
+func (q *memQueries) SetLastReadMessage(ctx context.Context, arg database.SetLastReadMessageParams) (int64, error) {
+	t, done := q.open()
+	defer done()
+
+	k := participantKey{arg.ChatID, arg.UserID}
+	p, ok := t.participants[k]
+	if !ok {
+		return 0, nil
+	}
+	// Only moves forward; comparing against NULL matches nothing
+	if p.LastReadMessageID != nil && (arg.LastReadMessageID == nil || *p.LastReadMessageID >= *arg.LastReadMessageID) {
+		return 0, nil
+	}
+	p.LastReadMessageID = arg.LastReadMessageID
+	p.LastReadAt = q.timestamp()
+	t.participants[k] = p
+	return 1, nil
+}
+
+func (q *memQueries) IsUserInChat(ctx context.Context, arg database.IsUserInChatParams) (bool, error) {
+	t, done := q.open()
+	defer done()
+
+	_, ok := t.participants[participantKey{arg.ChatID, arg.UserID}]
+	return ok, nil
+}
+
+func (q *memQueries) GetNumberUnreadMessages(ctx context.Context, arg database.GetNumberUnreadMessagesParams) (int64, error) {
+	t, done := q.open()
+	defer done()
+
+	p, ok := t.participants[participantKey{arg.ChatID, arg.UserID}]
+	if !ok {
+		return 0, nil
+	}
+
+	var n int64
+	for _, m := range t.messages {
+		if m.ChatID != arg.ChatID || m.ThreadRootID != nil || m.DeletedAt.Valid || isHidden(t, arg.UserID, m.MessageID) {
+			continue
+		}
+		if p.LastReadMessageID == nil || m.MessageID > *p.LastReadMessageID {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (q *memQueries) GetChatKey(ctx context.Context, arg database.GetChatKeyParams) ([]byte, error) {
+	t, done := q.open()
+	defer done()
+
+	k, ok := t.chatKeys[arg.ChatID]
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+	return k.WrappedKey, nil
+}
+
+func (q *memQueries) CreateChatKey(ctx context.Context, arg database.CreateChatKeyParams) error {
+	t, done := q.open()
+	defer done()
+
+	if _, ok := t.chats[arg.ChatID]; !ok {
+		return foreignKeyViolation("chat_keys", "chat_keys_chat_id_fkey")
+	}
+	if _, ok := t.chatKeys[arg.ChatID]; ok {
+		return nil
+	}
+	t.chatKeys[arg.ChatID] = database.ChatKey{
+		ChatID:     arg.ChatID,
+		WrappedKey: bytes.Clone(arg.WrappedKey),
+		CreatedAt:  q.now(),
+	}
+	return nil
+}
+
+func (q *memQueries) ListChatKeys(ctx context.Context, arg database.ListChatKeysParams) ([]database.ListChatKeysRow, error) {
+	t, done := q.open()
+	defer done()
+
+	rows := []database.ListChatKeysRow{}
+	for _, k := range t.chatKeys {
+		if k.ChatID > arg.AfterID {
+			rows = append(rows, database.ListChatKeysRow{ChatID: k.ChatID, WrappedKey: k.WrappedKey})
+		}
+	}
+	slices.SortFunc(rows, func(a, b database.ListChatKeysRow) int { return cmp.Compare(a.ChatID, b.ChatID) })
+	return limit(rows, arg.BatchSize, 0), nil
+}
+
+func (q *memQueries) ReplaceChatKey(ctx context.Context, arg database.ReplaceChatKeyParams) (int64, error) {
+	t, done := q.open()
+	defer done()
+
+	k, ok := t.chatKeys[arg.ChatID]
+	if !ok || !bytes.Equal(k.WrappedKey, arg.OldWrappedKey) {
+		return 0, nil
+	}
+	k.WrappedKey = bytes.Clone(arg.NewWrappedKey)
+	t.chatKeys[k.ChatID] = k
+	return 1, nil
+}
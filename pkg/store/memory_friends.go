@@ -0,0 +1,314 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"slices"
+
+	"github.com/astrokkidd/flick/pkg/database"
+	"github.com/jackc/pgx/v5"
+)
+
+// sortedRequests returns the friend requests matching keep by request id.
+func sortedRequests(t *tables, keep func(database.FriendRequest) bool) []database.FriendRequest {
+	rows := []database.FriendRequest{}
+	for _, fr := range t.friendRequests {
+		if keep(fr) {
+			rows = append(rows, fr)
+		}
+	}
+	slices.SortFunc(rows, func(a, b database.FriendRequest) int { return cmp.Compare(a.RequestID, b.RequestID) })
+	return rows
+}
+
+func samePair(fr database.FriendRequest, a, b int64) bool {
+	return (fr.SenderID == a && fr.ReceiverID == b) || (fr.SenderID == b && fr.ReceiverID == a)
+}
+
+func (q *memQueries) CreateFriendRequest(ctx context.Context, arg database.CreateFriendRequestParams) (database.FriendRequest, error) {
+	t, done := q.open()
+	defer done()
+
+	if arg.SenderID == arg.ReceiverID {
+		return database.FriendRequest{}, checkViolation("friend_requests", "no_self_request")
+	}
+	if _, ok := t.users[arg.SenderID]; !ok {
+		return database.FriendRequest{}, foreignKeyViolation("friend_requests", "friend_requests_sender_id_fkey")
+	}
+	if _, ok := t.users[arg.ReceiverID]; !ok {
+		return database.FriendRequest{}, foreignKeyViolation("friend_requests", "friend_requests_receiver_id_fkey")
+	}
+
+	fr := database.FriendRequest{
+		RequestID:  q.m.seq.friendRequests.Add(1),
+		SenderID:   arg.SenderID,
+		ReceiverID: arg.ReceiverID,
+	}
+	t.friendRequests[fr.RequestID] = fr
+	return fr, nil
+}
+
+func (q *memQueries) GetFriendRequestByID(ctx context.Context, arg database.GetFriendRequestByIDParams) (database.FriendRequest, error) {
+	t, done := q.open()
+	defer done()
+
+	fr, ok := t.friendRequests[arg.RequestID]
+	if !ok {
+		return database.FriendRequest{}, pgx.ErrNoRows
+	}
+	return fr, nil
+}
+
+func (q *memQueries) GetPendingRequestBetween(ctx context.Context) (database.FriendRequest, error) {
+	t, done := q.open()
+	defer done()
+
+	rows := sortedRequests(t, func(database.FriendRequest) bool { return true })
+	if len(rows) == 0 {
+		return database.FriendRequest{}, pgx.ErrNoRows
+	}
+	return rows[0], nil
+}
+
+func (q *memQueries) ListIncomingFriendRequests(ctx context.Context, arg database.ListIncomingFriendRequestsParams) ([]database.FriendRequest, error) {
+	t, done := q.open()
+	defer done()
+
+	rows := sortedRequests(t, func(fr database.FriendRequest) bool { return fr.ReceiverID == arg.ReceiverID })
+	slices.Reverse(rows)
+	return limit(rows, arg.Limit, arg.Offset), nil
+}
+
+func (q *memQueries) ListOutgoingFriendRequests(ctx context.Context, arg database.ListOutgoingFriendRequestsParams) ([]database.FriendRequest, error) {
+	t, done := q.open()
+	defer done()
+
+	rows := sortedRequests(t, func(fr database.FriendRequest) bool { return fr.SenderID == arg.SenderID })
+	slices.Reverse(rows)
+	return limit(rows, arg.Limit, arg.Offset), nil
+}
+
+func (q *memQueries) DeleteFriendRequest(ctx context.Context, arg database.DeleteFriendRequestParams) (int64, error) {
+	t, done := q.open()
+	defer done()
+
+	fr, ok := t.friendRequests[arg.RequestID]
+	if !ok || fr.SenderID != arg.SenderID {
+		return 0, nil
+	}
+	delete(t.friendRequests, fr.RequestID)
+	return 1, nil
+}
+
+func (q *memQueries) AreUsersFriends(ctx context.Context, arg database.AreUsersFriendsParams) (bool, error) {
+	t, done := q.open()
+	defer done()
+
+	_, ok := t.friendships[friendshipKey{arg.UserID, arg.UserID_2}]
+	if !ok {
+		_, ok = t.friendships[friendshipKey{arg.UserID_2, arg.UserID}]
+	}
+	return ok, nil
+}
+
+func (q *memQueries) DoesFriendRequestExist(ctx context.Context, arg database.DoesFriendRequestExistParams) (bool, error) {
+	t, done := q.open()
+	defer done()
+
+	for _, fr := range t.friendRequests {
+		if samePair(fr, arg.SenderID, arg.ReceiverID) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (q *memQueries) CreateFriendship(ctx context.Context, arg database.CreateFriendshipParams) error {
+	t, done := q.open()
+	defer done()
+
+	if arg.UserID == arg.FriendID {
+		return checkViolation("user_friendships", "no_self_friend")
+	}
+	if _, ok := t.users[arg.UserID]; !ok {
+		return foreignKeyViolation("user_friendships", "user_friendships_user_id_fkey")
+	}
+	if _, ok := t.users[arg.FriendID]; !ok {
+		return foreignKeyViolation("user_friendships", "user_friendships_friend_id_fkey")
+	}
+
+	at := q.now()
+	for _, k := range []friendshipKey{{arg.UserID, arg.FriendID}, {arg.FriendID, arg.UserID}} {
+		if _, ok := t.friendships[k]; !ok {
+			t.friendships[k] = database.UserFriendship{UserID: k.userID, FriendID: k.friendID, FriendshipTs: at}
+		}
+	}
+	return nil
+}
+
+func (q *memQueries) ListAllFriends(ctx context.Context, arg database.ListAllFriendsParams) ([]database.ListAllFriendsRow, error) {
+	t, done := q.open()
+	defer done()
+
+	rows := []database.ListAllFriendsRow{}
+	for _, f := range t.friendships {
+		if f.UserID != arg.UserID {
+			continue
+		}
+		u := t.users[f.FriendID]
+		rows = append(rows, database.ListAllFriendsRow{
+			UserID:       u.UserID,
+			PfpUrl:       u.PfpUrl,
+			DisplayName:  u.DisplayName,
+			FirstName:    u.FirstName,
+			LastName:     u.LastName,
+			FriendshipTs: f.FriendshipTs,
+		})
+	}
+	slices.SortFunc(rows, func(a, b database.ListAllFriendsRow) int {
+		return cmp.Or(b.FriendshipTs.Compare(a.FriendshipTs), cmp.Compare(a.UserID, b.UserID))
+	})
+	return rows, nil
+}
+
+func (q *memQueries) ListReceivedFriendRequestsWithUser(ctx context.Context, arg database.ListReceivedFriendRequestsWithUserParams) ([]database.ListReceivedFriendRequestsWithUserRow, error) {
+	t, done := q.open()
+	defer done()
+
+	rows := []database.ListReceivedFriendRequestsWithUserRow{}
+	for _, fr := range sortedRequests(t, func(fr database.FriendRequest) bool { return fr.ReceiverID == arg.ReceiverID }) {
+		u := t.users[fr.SenderID]
+		rows = append(rows, database.ListReceivedFriendRequestsWithUserRow{
+			RequestID:   fr.RequestID,
+			SenderID:    fr.SenderID,
+			ReceiverID:  fr.ReceiverID,
+			UserID:      u.UserID,
+			DisplayName: u.DisplayName,
+			PfpUrl:      u.PfpUrl,
+		})
+	}
+	return rows, nil
+}
+
+func (q *memQueries) ListSentFriendRequestsWithUser(ctx context.Context, arg database.ListSentFriendRequestsWithUserParams) ([]database.ListSentFriendRequestsWithUserRow, error) {
+	t, done := q.open()
+	defer done()
+
+	rows := []database.ListSentFriendRequestsWithUserRow{}
+	for _, fr := range sortedRequests(t, func(fr database.FriendRequest) bool { return fr.SenderID == arg.SenderID }) {
+		u := t.users[fr.ReceiverID]
+		rows = append(rows, database.ListSentFriendRequestsWithUserRow{
+			RequestID:   fr.RequestID,
+			SenderID:    fr.SenderID,
+			ReceiverID:  fr.ReceiverID,
+			UserID:      u.UserID,
+			DisplayName: u.DisplayName,
+			PfpUrl:      u.PfpUrl,
+		})
+	}
+	return rows, nil
+}
+
+func (q *memQueries) GetUserByRequestID(ctx context.Context, arg database.GetUserByRequestIDParams) (int64, error) {
+	t, done := q.open()
+	defer done()
+
+	fr, ok := t.friendRequests[arg.RequestID]
+	if !ok {
+		return 0, pgx.ErrNoRows
+	}
+	return fr.SenderID, nil
+}
+
+func (q *memQueries) DeleteFriendship(ctx context.Context, arg database.DeleteFriendshipParams) error {
+	t, done := q.open()
+	defer done()
+
+	delete(t.friendships, friendshipKey{arg.UserID, arg.FriendID})
+	delete(t.friendships, friendshipKey{arg.FriendID, arg.UserID})
+	return nil
+}
+
+func (q *memQueries) DeleteFriendRequestsBetween(ctx context.Context, arg database.DeleteFriendRequestsBetweenParams) error {
+	t, done := q.open()
+	defer done()
+
+	for id, fr := range t.friendRequests {
+		if samePair(fr, arg.UserID, arg.UserID2) {
+			delete(t.friendRequests, id)
+		}
+	}
+	return nil
+}
+
+// blocked reports whether either user has blocked the other.
+func blocked(t *tables, a, b int64) bool {
+	_, ok := t.blocks[blockKey{a, b}]
+	if !ok {
+		_, ok = t.blocks[blockKey{b, a}]
+	}
+	return ok
+}
+
+func (q *memQueries) BlockUser(ctx context.Context, arg database.BlockUserParams) error {
+	t, done := q.open()
+	defer done()
+
+	if arg.BlockerID == arg.BlockedID {
+		return checkViolation("user_blocks", "no_self_block")
+	}
+	if _, ok := t.users[arg.BlockerID]; !ok {
+		return foreignKeyViolation("user_blocks", "user_blocks_blocker_id_fkey")
+	}
+	if _, ok := t.users[arg.BlockedID]; !ok {
+		return foreignKeyViolation("user_blocks", "user_blocks_blocked_id_fkey")
+	}
+
+	k := blockKey{arg.BlockerID, arg.BlockedID}
+	if _, ok := t.blocks[k]; !ok {
+		t.blocks[k] = database.UserBlock{BlockerID: arg.BlockerID, BlockedID: arg.BlockedID, CreatedAt: q.now()}
+	}
+	return nil
+}
+
+func (q *memQueries) UnblockUser(ctx context.Context, arg database.UnblockUserParams) (int64, error) {
+	t, done := q.open()
+	defer done()
+
+	k := blockKey{arg.BlockerID, arg.BlockedID}
+	if _, ok := t.blocks[k]; !ok {
+		return 0, nil
+	}
+	delete(t.blocks, k)
+	return 1, nil
+}
+
+func (q *memQueries) IsBlockedBetween(ctx context.Context, arg database.IsBlockedBetweenParams) (bool, error) {
+	t, done := q.open()
+	defer done()
+
+	return blocked(t, arg.UserID, arg.UserID2), nil
+}
+
+func (q *memQueries) ListBlockedUsers(ctx context.Context, arg database.ListBlockedUsersParams) ([]database.ListBlockedUsersRow, error) {
+	t, done := q.open()
+	defer done()
+
+	rows := []database.ListBlockedUsersRow{}
+	for _, b := range t.blocks {
+		if b.BlockerID != arg.BlockerID {
+			continue
+		}
+		u := t.users[b.BlockedID]
+		rows = append(rows, database.ListBlockedUsersRow{
+			UserID:      u.UserID,
+			DisplayName: u.DisplayName,
+			PfpUrl:      u.PfpUrl,
+			CreatedAt:   b.CreatedAt,
+		})
+	}
+	slices.SortFunc(rows, func(a, b database.ListBlockedUsersRow) int {
+		return cmp.Or(b.CreatedAt.Compare(a.CreatedAt), cmp.Compare(a.UserID, b.UserID))
+	})
+	return rows, nil
+}
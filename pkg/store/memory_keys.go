@@ -0,0 +1,71 @@
+package store
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/astrokkidd/flick/pkg/database"
+	"github.com/jackc/pgx/v5"
+)
+
+func (q *memQueries) UpsertIdentityKey(ctx context.Context, arg database.UpsertIdentityKeyParams) error {
+	t, done := q.open()
+	defer done()
+
+	if _, ok := t.users[arg.UserID]; !ok {
+		return foreignKeyViolation("user_keys", "user_keys_user_id_fkey")
+	}
+
+	t.userKeys[arg.UserID] = database.UserKey{
+		UserID:      arg.UserID,
+		IdentityKey: bytes.Clone(arg.IdentityKey),
+		UpdatedAt:   q.now(),
+	}
+	return nil
+}
+
+func (q *memQueries) UpsertUserKeys(ctx context.Context, arg database.UpsertUserKeysParams) error {
+	t, done := q.open()
+	defer done()
+
+	if _, ok := t.users[arg.UserID]; !ok {
+		return foreignKeyViolation("user_keys", "user_keys_user_id_fkey")
+	}
+	if (arg.SignedPrekeyID == nil) != (arg.SignedPrekey == nil) || (arg.SignedPrekey == nil) != (arg.SignedPrekeySignature == nil) {
+		return checkViolation("user_keys", "signed_prekey_complete")
+	}
+
+	t.userKeys[arg.UserID] = database.UserKey{
+		UserID:                arg.UserID,
+		IdentityKey:           bytes.Clone(arg.IdentityKey),
+		SignedPrekeyID:        arg.SignedPrekeyID,
+		SignedPrekey:          bytes.Clone(arg.SignedPrekey),
+		SignedPrekeySignature: bytes.Clone(arg.SignedPrekeySignature),
+		UpdatedAt:             q.now(),
+	}
+	return nil
+}
+
+func (q *memQueries) GetUserKeys(ctx context.Context, arg database.GetUserKeysParams) (database.UserKey, error) {
+	t, done := q.open()
+	defer done()
+
+	k, ok := t.userKeys[arg.UserID]
+	if !ok {
+		return database.UserKey{}, pgx.ErrNoRows
+	}
+	return k, nil
+}
+
+func (q *memQueries) ListUsersWithoutKeys(ctx context.Context, arg database.ListUsersWithoutKeysParams) ([]int64, error) {
+	t, done := q.open()
+	defer done()
+
+	ids := []int64{}
+	for _, id := range arg.UserIds {
+		if k, ok := t.userKeys[id]; !ok || k.SignedPrekey == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
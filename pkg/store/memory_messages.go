@@ -0,0 +1,556 @@
+package store
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/astrokkidd/flick/pkg/database"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// compareMessages orders by (created_at, message_id), the history cursor.
+func compareMessages(a, b database.Message) int {
+	return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), cmp.Compare(a.MessageID, b.MessageID))
+}
+
+// chatHistory returns a chat's messages oldest first, leaving out those the
+// user hid and starting after (or before) the cursor message when one is
+// given. A cursor that is not in the chat matches nothing, as the row
+// comparison against NULL does. Without a thread root it is the chat's own
+// history, with one the replies in that thread.
+func chatHistory(t *tables, chatID, userID int64, threadRootID, cursor *int64, before bool) []database.Message {
+	var pivot database.Message
+	if cursor != nil {
+		m, ok := t.messages[*cursor]
+		if !ok || m.ChatID != chatID {
+			return nil
+		}
+		pivot = m
+	}
+
+	var rows []database.Message
+	for _, m := range t.messages {
+		if m.ChatID != chatID || !inThread(m, threadRootID) || isHidden(t, userID, m.MessageID) {
+			continue
+		}
+		if cursor != nil {
+			if c := compareMessages(m, pivot); (before && c >= 0) || (!before && c <= 0) {
+				continue
+			}
+		}
+		rows = append(rows, m)
+	}
+	slices.SortFunc(rows, compareMessages)
+	return rows
+}
+
+// inThread reports whether a message is a reply in the thread, or in the
+// chat history itself when threadRootID is nil.
+func inThread(m database.Message, threadRootID *int64) bool {
+	if threadRootID == nil {
+		return m.ThreadRootID == nil
+	}
+	return m.ThreadRootID != nil && *m.ThreadRootID == *threadRootID
+}
+
+func isHidden(t *tables, userID, messageID int64) bool {
+	_, ok := t.hidden[hiddenKey{userID, messageID}]
+	return ok
+}
+
+// deleteMessage removes a message along with the rows that cascade from it.
+func deleteMessage(t *tables, messageID int64) {
+	delete(t.messages, messageID)
+	for id, r := range t.revisions {
+		if r.MessageID == messageID {
+			delete(t.revisions, id)
+		}
+	}
+	for k := range t.hidden {
+		if k.messageID == messageID {
+			delete(t.hidden, k)
+		}
+	}
+	for k := range t.reactions {
+		if k.messageID == messageID {
+			delete(t.reactions, k)
+		}
+	}
+	for k := range t.threadReads {
+		if k.threadRootID == messageID {
+			delete(t.threadReads, k)
+		}
+	}
+	deleteSearchTokens(t, messageID)
+	for id, a := range t.attachments {
+		if a.MessageID != nil && *a.MessageID == messageID {
+			delete(t.attachments, id)
+		}
+	}
+	for id, m := range t.messages {
+		switch {
+		case m.ThreadRootID != nil && *m.ThreadRootID == messageID:
+			deleteMessage(t, id)
+		case m.ReplyToID != nil && *m.ReplyToID == messageID:
+			m.ReplyToID = nil
+			t.messages[id] = m
+		}
+	}
+}
+
+func (q *memQueries) NextMessageID(ctx context.Context) (int64, error) {
+	return q.m.seq.messages.Add(1), nil
+}
+
+func (q *memQueries) CreateMessage(ctx context.Context, arg database.CreateMessageParams) (time.Time, error) {
+	t, done := q.open()
+	defer done()
+
+	if _, ok := t.messages[arg.MessageID]; ok {
+		return time.Time{}, uniqueViolation("messages_pkey")
+	}
+	if _, ok := t.chats[arg.ChatID]; !ok {
+		return time.Time{}, foreignKeyViolation("messages", "messages_chat_id_fkey")
+	}
+	if _, ok := t.users[arg.SenderID]; !ok {
+		return time.Time{}, foreignKeyViolation("messages", "messages_sender_id_fkey")
+	}
+	if arg.ReplyToID != nil {
+		if _, ok := t.messages[*arg.ReplyToID]; !ok {
+			return time.Time{}, foreignKeyViolation("messages", "messages_reply_to_id_fkey")
+		}
+	}
+	if arg.ThreadRootID != nil {
+		if _, ok := t.messages[*arg.ThreadRootID]; !ok {
+			return time.Time{}, foreignKeyViolation("messages", "messages_thread_root_id_fkey")
+		}
+	}
+
+	m := database.Message{
+		MessageID:    arg.MessageID,
+		SenderID:     arg.SenderID,
+		ChatID:       arg.ChatID,
+		CreatedAt:    q.now(),
+		CypherText:   bytes.Clone(arg.CypherText),
+		ReplyToID:    arg.ReplyToID,
+		ThreadRootID: arg.ThreadRootID,
+	}
+	t.messages[m.MessageID] = m
+	return m.CreatedAt, nil
+}
+
+func (q *memQueries) ListMessagesBefore(ctx context.Context, arg database.ListMessagesBeforeParams) ([]database.ListMessagesBeforeRow, error) {
+	t, done := q.open()
+	defer done()
+
+	history := chatHistory(t, arg.ChatID, arg.UserID, arg.ThreadRootID, arg.BeforeID, true)
+	slices.Reverse(history)
+
+	rows := []database.ListMessagesBeforeRow{}
+	for _, m := range limit(history, arg.PageSize, 0) {
+		rows = append(rows, database.ListMessagesBeforeRow{
+			MessageID:  m.MessageID,
+			SenderID:   m.SenderID,
+			CypherText: m.CypherText,
+			CreatedAt:  m.CreatedAt,
+			EditedAt:   m.EditedAt,
+			DeletedAt:  m.DeletedAt,
+			ReplyToID:  m.ReplyToID,
+		})
+	}
+	return rows, nil
+}
+
+func (q *memQueries) ListMessagesAfter(ctx context.Context, arg database.ListMessagesAfterParams) ([]database.ListMessagesAfterRow, error) {
+	t, done := q.open()
+	defer done()
+
+	rows := []database.ListMessagesAfterRow{}
+	for _, m := range limit(chatHistory(t, arg.ChatID, arg.UserID, arg.ThreadRootID, arg.AfterID, false), arg.PageSize, 0) {
+		rows = append(rows, database.ListMessagesAfterRow{
+			MessageID:  m.MessageID,
+			SenderID:   m.SenderID,
+			CypherText: m.CypherText,
+			CreatedAt:  m.CreatedAt,
+			EditedAt:   m.EditedAt,
+			DeletedAt:  m.DeletedAt,
+			ReplyToID:  m.ReplyToID,
+		})
+	}
+	return rows, nil
+}
+
+func (q *memQueries) GetMessage(ctx context.Context, arg database.GetMessageParams) (database.GetMessageRow, error) {
+	t, done := q.open()
+	defer done()
+
+	m, ok := t.messages[arg.MessageID]
+	if !ok || m.ChatID != arg.ChatID {
+		return database.GetMessageRow{}, pgx.ErrNoRows
+	}
+	return database.GetMessageRow{
+		SenderID:     m.SenderID,
+		CypherText:   m.CypherText,
+		CreatedAt:    m.CreatedAt,
+		EditedAt:     m.EditedAt,
+		DeletedAt:    m.DeletedAt,
+		ReplyToID:    m.ReplyToID,
+		ThreadRootID: m.ThreadRootID,
+	}, nil
+}
+
+func (q *memQueries) GetMessageForUpdate(ctx context.Context, arg database.GetMessageForUpdateParams) (database.GetMessageForUpdateRow, error) {
+	row, err := q.GetMessage(ctx, database.GetMessageParams(arg))
+	return database.GetMessageForUpdateRow(row), err
+}
+
+func (q *memQueries) ListMessagesByID(ctx context.Context, arg database.ListMessagesByIDParams) ([]database.ListMessagesByIDRow, error) {
+	t, done := q.open()
+	defer done()
+
+	rows := []database.ListMessagesByIDRow{}
+	for _, id := range slices.Compact(slices.Sorted(slices.Values(arg.MessageIds))) {
+		m, ok := t.messages[id]
+		if !ok || m.ChatID != arg.ChatID {
+			continue
+		}
+		rows = append(rows, database.ListMessagesByIDRow{
+			MessageID:  m.MessageID,
+			SenderID:   m.SenderID,
+			CypherText: m.CypherText,
+			CreatedAt:  m.CreatedAt,
+			EditedAt:   m.EditedAt,
+			DeletedAt:  m.DeletedAt,
+		})
+	}
+	return rows, nil
+}
+
+func (q *memQueries) EditMessage(ctx context.Context, arg database.EditMessageParams) (pgtype.Timestamptz, error) {
+	t, done := q.open()
+	defer done()
+
+	m, ok := t.messages[arg.MessageID]
+	if !ok {
+		return pgtype.Timestamptz{}, pgx.ErrNoRows
+	}
+	m.CypherText = bytes.Clone(arg.CypherText)
+	m.EditedAt = q.timestamp()
+	t.messages[m.MessageID] = m
+	return m.EditedAt, nil
+}
+
+func (q *memQueries) DeleteMessageForEveryone(ctx context.Context, arg database.DeleteMessageForEveryoneParams) (pgtype.Timestamptz, error) {
+	t, done := q.open()
+	defer done()
+
+	m, ok := t.messages[arg.MessageID]
+	if !ok {
+		return pgtype.Timestamptz{}, pgx.ErrNoRows
+	}
+	m.CypherText = []byte{}
+	m.DeletedAt = q.timestamp()
+	t.messages[m.MessageID] = m
+	return m.DeletedAt, nil
+}
+
+func (q *memQueries) HideMessage(ctx context.Context, arg database.HideMessageParams) error {
+	t, done := q.open()
+	defer done()
+
+	if _, ok := t.users[arg.UserID]; !ok {
+		return foreignKeyViolation("hidden_messages", "hidden_messages_user_id_fkey")
+	}
+	if _, ok := t.messages[arg.MessageID]; !ok {
+		return foreignKeyViolation("hidden_messages", "hidden_messages_message_id_fkey")
+	}
+
+	key := hiddenKey{arg.UserID, arg.MessageID}
+	if _, ok := t.hidden[key]; !ok {
+		t.hidden[key] = database.HiddenMessage{UserID: arg.UserID, MessageID: arg.MessageID, HiddenAt: q.now()}
+	}
+	return nil
+}
+
+func (q *memQueries) CreateMessageRevision(ctx context.Context, arg database.CreateMessageRevisionParams) error {
+	t, done := q.open()
+	defer done()
+
+	if _, ok := t.messages[arg.MessageID]; !ok {
+		return foreignKeyViolation("message_revisions", "message_revisions_message_id_fkey")
+	}
+
+	r := database.MessageRevision{
+		RevisionID: q.m.seq.revisions.Add(1),
+		MessageID:  arg.MessageID,
+		CypherText: bytes.Clone(arg.CypherText),
+		WrittenAt:  arg.WrittenAt,
+		ReplacedAt: q.now(),
+	}
+	t.revisions[r.RevisionID] = r
+	return nil
+}
+
+func (q *memQueries) DeleteMessageRevisions(ctx context.Context, arg database.DeleteMessageRevisionsParams) error {
+	t, done := q.open()
+	defer done()
+
+	for id, r := range t.revisions {
+		if r.MessageID == arg.MessageID {
+			delete(t.revisions, id)
+		}
+	}
+	return nil
+}
+
+func (q *memQueries) ListMessageRevisions(ctx context.Context, arg database.ListMessageRevisionsParams) ([]database.ListMessageRevisionsRow, error) {
+	t, done := q.open()
+	defer done()
+
+	rows := []database.ListMessageRevisionsRow{}
+	for _, r := range t.revisions {
+		if r.MessageID == arg.MessageID {
+			rows = append(rows, database.ListMessageRevisionsRow{
+				RevisionID: r.RevisionID,
+				CypherText: r.CypherText,
+				WrittenAt:  r.WrittenAt,
+				ReplacedAt: r.ReplacedAt,
+			})
+		}
+	}
+	slices.SortFunc(rows, func(a, b database.ListMessageRevisionsRow) int { return cmp.Compare(a.RevisionID, b.RevisionID) })
+	return rows, nil
+}
+
+func (q *memQueries) ListMessageCiphertexts(ctx context.Context, arg database.ListMessageCiphertextsParams) ([]database.ListMessageCiphertextsRow, error) {
+	t, done := q.open()
+	defer done()
+
+	rows := []database.ListMessageCiphertextsRow{}
+	for _, m := range t.messages {
+		if m.MessageID > arg.AfterID && !t.chats[m.ChatID].EndToEnd && !m.DeletedAt.Valid {
+			rows = append(rows, database.ListMessageCiphertextsRow{
+				MessageID:  m.MessageID,
+				ChatID:     m.ChatID,
+				SenderID:   m.SenderID,
+				CypherText: m.CypherText,
+			})
+		}
+	}
+	slices.SortFunc(rows, func(a, b database.ListMessageCiphertextsRow) int { return cmp.Compare(a.MessageID, b.MessageID) })
+	return limit(rows, arg.BatchSize, 0), nil
+}
+
+func (q *memQueries) ReplaceMessageCiphertext(ctx context.Context, arg database.ReplaceMessageCiphertextParams) (int64, error) {
+	t, done := q.open()
+	defer done()
+
+	m, ok := t.messages[arg.MessageID]
+	if !ok || !bytes.Equal(m.CypherText, arg.OldCypherText) {
+		return 0, nil
+	}
+	m.CypherText = bytes.Clone(arg.NewCypherText)
+	t.messages[m.MessageID] = m
+	return 1, nil
+}
+
+func (q *memQueries) ListMessageRevisionCiphertexts(ctx context.Context, arg database.ListMessageRevisionCiphertextsParams) ([]database.ListMessageRevisionCiphertextsRow, error) {
+	t, done := q.open()
+	defer done()
+
+	rows := []database.ListMessageRevisionCiphertextsRow{}
+	for _, r := range t.revisions {
+		m := t.messages[r.MessageID]
+		if r.RevisionID > arg.AfterID && !t.chats[m.ChatID].EndToEnd {
+			rows = append(rows, database.ListMessageRevisionCiphertextsRow{
+				RevisionID: r.RevisionID,
+				MessageID:  r.MessageID,
+				ChatID:     m.ChatID,
+				SenderID:   m.SenderID,
+				CypherText: r.CypherText,
+			})
+		}
+	}
+	slices.SortFunc(rows, func(a, b database.ListMessageRevisionCiphertextsRow) int {
+		return cmp.Compare(a.RevisionID, b.RevisionID)
+	})
+	return limit(rows, arg.BatchSize, 0), nil
+}
+
+func (q *memQueries) ReplaceMessageRevisionCiphertext(ctx context.Context, arg database.ReplaceMessageRevisionCiphertextParams) (int64, error) {
+	t, done := q.open()
+	defer done()
+
+	r, ok := t.revisions[arg.RevisionID]
+	if !ok || !bytes.Equal(r.CypherText, arg.OldCypherText) {
+		return 0, nil
+	}
+	r.CypherText = bytes.Clone(arg.NewCypherText)
+	t.revisions[r.RevisionID] = r
+	return 1, nil
+}
+
+func (q *memQueries) AddReaction(ctx context.Context, arg database.AddReactionParams) (int64, error) {
+	t, done := q.open()
+	defer done()
+
+	if len([]rune(arg.Emoji)) > 64 {
+		return 0, stringTooLong(64)
+	}
+	if _, ok := t.messages[arg.MessageID]; !ok {
+		return 0, foreignKeyViolation("message_reactions", "message_reactions_message_id_fkey")
+	}
+	if _, ok := t.users[arg.UserID]; !ok {
+		return 0, foreignKeyViolation("message_reactions", "message_reactions_user_id_fkey")
+	}
+
+	key := reactionKey{arg.MessageID, arg.UserID, arg.Emoji}
+	if _, ok := t.reactions[key]; ok {
+		return 0, nil
+	}
+	t.reactions[key] = database.MessageReaction{
+		MessageID: arg.MessageID,
+		UserID:    arg.UserID,
+		Emoji:     arg.Emoji,
+		CreatedAt: q.now(),
+	}
+	return 1, nil
+}
+
+func (q *memQueries) RemoveReaction(ctx context.Context, arg database.RemoveReactionParams) (int64, error) {
+	t, done := q.open()
+	defer done()
+
+	key := reactionKey{arg.MessageID, arg.UserID, arg.Emoji}
+	if _, ok := t.reactions[key]; !ok {
+		return 0, nil
+	}
+	delete(t.reactions, key)
+	return 1, nil
+}
+
+func (q *memQueries) CountOtherReactionEmojis(ctx context.Context, arg database.CountOtherReactionEmojisParams) (int64, error) {
+	t, done := q.open()
+	defer done()
+
+	emojis := map[string]bool{}
+	for k := range t.reactions {
+		if k.messageID == arg.MessageID && k.emoji != arg.Emoji {
+			emojis[k.emoji] = true
+		}
+	}
+	return int64(len(emojis)), nil
+}
+
+func (q *memQueries) DeleteMessageReactions(ctx context.Context, arg database.DeleteMessageReactionsParams) error {
+	t, done := q.open()
+	defer done()
+
+	for k := range t.reactions {
+		if k.messageID == arg.MessageID {
+			delete(t.reactions, k)
+		}
+	}
+	return nil
+}
+
+func (q *memQueries) ListMessageReactions(ctx context.Context, arg database.ListMessageReactionsParams) ([]database.ListMessageReactionsRow, error) {
+	t, done := q.open()
+	defer done()
+
+	type group struct {
+		database.ListMessageReactionsRow
+		firstAt time.Time
+	}
+	groups := map[reactionKey]*group{}
+	for k, r := range t.reactions {
+		if !slices.Contains(arg.MessageIds, k.messageID) {
+			continue
+		}
+
+		gk := reactionKey{messageID: k.messageID, emoji: k.emoji}
+		g, ok := groups[gk]
+		if !ok {
+			g = &group{ListMessageReactionsRow: database.ListMessageReactionsRow{MessageID: k.messageID, Emoji: k.emoji}, firstAt: r.CreatedAt}
+			groups[gk] = g
+		}
+		g.Count++
+		g.ReactedByMe = g.ReactedByMe || k.userID == arg.UserID
+		if r.CreatedAt.Before(g.firstAt) {
+			g.firstAt = r.CreatedAt
+		}
+	}
+
+	// ORDER BY message_id, MIN(created_at), emoji
+	sorted := slices.SortedFunc(maps.Values(groups), func(a, b *group) int {
+		return cmp.Or(cmp.Compare(a.MessageID, b.MessageID), a.firstAt.Compare(b.firstAt), cmp.Compare(a.Emoji, b.Emoji))
+	})
+	rows := []database.ListMessageReactionsRow{}
+	for _, g := range sorted {
+		rows = append(rows, g.ListMessageReactionsRow)
+	}
+	return rows, nil
+}
+
+func (q *memQueries) ListThreadSummaries(ctx context.Context, arg database.ListThreadSummariesParams) ([]database.ListThreadSummariesRow, error) {
+	t, done := q.open()
+	defer done()
+
+	rows := []database.ListThreadSummariesRow{}
+	for _, rootID := range slices.Compact(slices.Sorted(slices.Values(arg.RootIds))) {
+		read, hasRead := t.threadReads[threadReadKey{rootID, arg.UserID}]
+
+		var (
+			row    = database.ListThreadSummariesRow{ThreadRootID: rootID}
+			latest database.Message
+		)
+		for _, m := range t.messages {
+			if !inThread(m, &rootID) || m.DeletedAt.Valid || isHidden(t, arg.UserID, m.MessageID) {
+				continue
+			}
+			row.ReplyCount++
+			if !hasRead || m.MessageID > read.LastReadMessageID {
+				row.UnreadCount++
+			}
+			if row.ReplyCount == 1 || compareMessages(m, latest) > 0 {
+				latest = m
+			}
+		}
+		if row.ReplyCount == 0 {
+			continue
+		}
+		row.LatestReplyID = latest.MessageID
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func (q *memQueries) SetThreadLastRead(ctx context.Context, arg database.SetThreadLastReadParams) (int64, error) {
+	t, done := q.open()
+	defer done()
+
+	if _, ok := t.messages[arg.ThreadRootID]; !ok {
+		return 0, foreignKeyViolation("thread_reads", "thread_reads_thread_root_id_fkey")
+	}
+	if _, ok := t.users[arg.UserID]; !ok {
+		return 0, foreignKeyViolation("thread_reads", "thread_reads_user_id_fkey")
+	}
+
+	k := threadReadKey{arg.ThreadRootID, arg.UserID}
+	// Only moves forward
+	if r, ok := t.threadReads[k]; ok && r.LastReadMessageID >= arg.LastReadMessageID {
+		return 0, nil
+	}
+	t.threadReads[k] = database.ThreadRead{
+		ThreadRootID:      arg.ThreadRootID,
+		UserID:            arg.UserID,
+		LastReadMessageID: arg.LastReadMessageID,
+		LastReadAt:        q.now(),
+	}
+	return 1, nil
+}
@@ -0,0 +1,100 @@
+package store
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"slices"
+
+	"github.com/astrokkidd/flick/pkg/database"
+)
+
+func deleteSearchTokens(t *tables, messageID int64) {
+	for k := range t.searchTokens {
+		if k.messageID == messageID {
+			delete(t.searchTokens, k)
+		}
+	}
+}
+
+func (q *memQueries) CreateMessageSearchTokens(ctx context.Context, arg database.CreateMessageSearchTokensParams) error {
+	t, done := q.open()
+	defer done()
+
+	if _, ok := t.messages[arg.MessageID]; !ok && len(arg.Tokens) > 0 {
+		return foreignKeyViolation("message_search_tokens", "message_search_tokens_message_id_fkey")
+	}
+
+	for _, token := range arg.Tokens {
+		t.searchTokens[searchTokenKey{string(token), arg.MessageID}] = database.MessageSearchToken{
+			Token:     bytes.Clone(token),
+			MessageID: arg.MessageID,
+		}
+	}
+	return nil
+}
+
+func (q *memQueries) DeleteMessageSearchTokens(ctx context.Context, arg database.DeleteMessageSearchTokensParams) error {
+	t, done := q.open()
+	defer done()
+
+	deleteSearchTokens(t, arg.MessageID)
+	return nil
+}
+
+func (q *memQueries) ListSearchableChats(ctx context.Context, arg database.ListSearchableChatsParams) ([]database.ListSearchableChatsRow, error) {
+	t, done := q.open()
+	defer done()
+
+	rows := []database.ListSearchableChatsRow{}
+	for k := range t.participants {
+		if k.userID != arg.UserID || (arg.ChatID != nil && k.chatID != *arg.ChatID) || t.chats[k.chatID].EndToEnd {
+			continue
+		}
+		if key, ok := t.chatKeys[k.chatID]; ok {
+			rows = append(rows, database.ListSearchableChatsRow{ChatID: k.chatID, WrappedKey: key.WrappedKey})
+		}
+	}
+	slices.SortFunc(rows, func(a, b database.ListSearchableChatsRow) int { return cmp.Compare(a.ChatID, b.ChatID) })
+	return rows, nil
+}
+
+func (q *memQueries) SearchMessages(ctx context.Context, arg database.SearchMessagesParams) ([]database.SearchMessagesRow, error) {
+	t, done := q.open()
+	defer done()
+
+	wanted := map[string]bool{}
+	for _, token := range arg.Tokens {
+		wanted[string(token)] = true
+	}
+
+	// GROUP BY message_id HAVING COUNT(*) = term_count
+	matches := map[int64]int64{}
+	for k := range t.searchTokens {
+		if wanted[k.token] {
+			matches[k.messageID]++
+		}
+	}
+
+	rows := []database.SearchMessagesRow{}
+	for id, n := range matches {
+		m := t.messages[id]
+		if n != arg.TermCount || !slices.Contains(arg.ChatIds, m.ChatID) || m.DeletedAt.Valid || isHidden(t, arg.UserID, id) {
+			continue
+		}
+		if arg.BeforeID != nil && id >= *arg.BeforeID {
+			continue
+		}
+		rows = append(rows, database.SearchMessagesRow{
+			MessageID:    m.MessageID,
+			ChatID:       m.ChatID,
+			SenderID:     m.SenderID,
+			CypherText:   m.CypherText,
+			CreatedAt:    m.CreatedAt,
+			EditedAt:     m.EditedAt,
+			ThreadRootID: m.ThreadRootID,
+		})
+	}
+	slices.SortFunc(rows, func(a, b database.SearchMessagesRow) int { return cmp.Compare(b.MessageID, a.MessageID) })
+	return limit(rows, arg.PageSize, 0), nil
+}
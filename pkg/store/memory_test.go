@@ -0,0 +1,69 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/astrokkidd/flick/pkg/database"
+)
+
+func TestMemoryTxIsolation(t *testing.T) {
+	m := NewMemory()
+	ctx := context.Background()
+
+	err := m.Tx(ctx, func(q database.Querier) error {
+		uid := createUser(t, q, "ada")
+
+		// Writes are visible inside the transaction that made them
+		if _, err := q.FindUserByID(ctx, database.FindUserByIDParams{UserID: uid}); err != nil {
+			t.Errorf("own write not visible: %v", err)
+		}
+		// but not in the committed tables until it commits
+		if _, ok := m.data.users[uid]; ok {
+			t.Error("uncommitted user already in the committed tables")
+		}
+		return errors.New("abort")
+	})
+	if err == nil {
+		t.Fatal("Tx swallowed the error")
+	}
+	if len(m.data.users) != 0 {
+		t.Errorf("rolled back transaction left %d users", len(m.data.users))
+	}
+
+	// As in Postgres, the sequence is not rolled back
+	if uid := createUser(t, m, "bob"); uid != 2 {
+		t.Errorf("user id after a rollback = %d, want 2", uid)
+	}
+}
+
+func TestMemoryTxCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	called := false
+	err := NewMemory().Tx(ctx, func(database.Querier) error {
+		called = true
+		return nil
+	})
+	if !errors.Is(err, ErrUnavailable) || !errors.Is(err, context.Canceled) || called {
+		t.Errorf("Tx on a canceled context = %v, fn called %v", err, called)
+	}
+}
+
+func TestMemoryClock(t *testing.T) {
+	m := NewMemory()
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	m.SetClock(func() time.Time { return at })
+
+	ada := createUser(t, m, "ada")
+	chatID := createChat(t, m, ada)
+	createMessage(t, m, chatID, ada)
+
+	rows, err := m.ListMessagesBefore(context.Background(), database.ListMessagesBeforeParams{ChatID: chatID, UserID: ada, PageSize: 1})
+	if err != nil || len(rows) != 1 || !rows[0].CreatedAt.Equal(at) {
+		t.Errorf("message stamped %v, %v, want %v", rows, err, at)
+	}
+}
@@ -0,0 +1,308 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/astrokkidd/flick/pkg/database"
+	"github.com/jackc/pgx/v5"
+)
+
+const maxDisplayNameLength = 32
+
+func (q *memQueries) ListUsers(ctx context.Context) ([]database.User, error) {
+	t, done := q.open()
+	defer done()
+
+	users := []database.User{}
+	for _, u := range t.users {
+		users = append(users, u)
+	}
+	slices.SortFunc(users, func(a, b database.User) int { return strings.Compare(a.DisplayName, b.DisplayName) })
+	return users, nil
+}
+
+func (q *memQueries) FindUserByDisplayName(ctx context.Context, arg database.FindUserByDisplayNameParams) (database.FindUserByDisplayNameRow, error) {
+	t, done := q.open()
+	defer done()
+
+	for _, u := range t.users {
+		if u.DisplayName == arg.DisplayName {
+			return database.FindUserByDisplayNameRow{
+				UserID:       u.UserID,
+				PasswordHash: u.PasswordHash,
+				FirstName:    u.FirstName,
+				LastName:     u.LastName,
+				PfpUrl:       u.PfpUrl,
+				TotpEnabled:  u.TotpEnabled,
+			}, nil
+		}
+	}
+	return database.FindUserByDisplayNameRow{}, pgx.ErrNoRows
+}
+
+func (q *memQueries) FindUserByID(ctx context.Context, arg database.FindUserByIDParams) (database.FindUserByIDRow, error) {
+	t, done := q.open()
+	defer done()
+
+	u, ok := t.users[arg.UserID]
+	if !ok {
+		return database.FindUserByIDRow{}, pgx.ErrNoRows
+	}
+	return database.FindUserByIDRow{
+		DisplayName: u.DisplayName,
+		FirstName:   u.FirstName,
+		LastName:    u.LastName,
+		PfpUrl:      u.PfpUrl,
+	}, nil
+}
+
+func (q *memQueries) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.CreateUserRow, error) {
+	t, done := q.open()
+	defer done()
+
+	if utf8.RuneCountInString(arg.DisplayName) > maxDisplayNameLength {
+		return database.CreateUserRow{}, stringTooLong(maxDisplayNameLength)
+	}
+	for _, u := range t.users {
+		if u.DisplayName == arg.DisplayName {
+			return database.CreateUserRow{}, uniqueViolation("users_display_name_key")
+		}
+		if strings.EqualFold(u.DisplayName, arg.DisplayName) {
+			return database.CreateUserRow{}, uniqueViolation("uq_users_display_name_ci")
+		}
+	}
+
+	u := database.User{
+		UserID:       q.m.seq.users.Add(1),
+		DisplayName:  arg.DisplayName,
+		PasswordHash: arg.PasswordHash,
+		FirstName:    arg.FirstName,
+		LastName:     arg.LastName,
+		PfpUrl:       arg.PfpUrl,
+		CreatedAt:    q.now(),
+	}
+	t.users[u.UserID] = u
+
+	return database.CreateUserRow{UserID: u.UserID, DisplayName: u.DisplayName, PfpUrl: u.PfpUrl}, nil
+}
+
+func (q *memQueries) UpdateUserPfp(ctx context.Context, arg database.UpdateUserPfpParams) error {
+	t, done := q.open()
+	defer done()
+
+	if u, ok := t.users[arg.UserID]; ok {
+		u.PfpUrl = arg.PfpUrl
+		u.AvatarID = arg.AvatarID
+		t.users[u.UserID] = u
+	}
+	return nil
+}
+
+func (q *memQueries) UpdateUserPassword(ctx context.Context, arg database.UpdateUserPasswordParams) error {
+	t, done := q.open()
+	defer done()
+
+	if u, ok := t.users[arg.UserID]; ok {
+		u.PasswordHash = arg.PasswordHash
+		t.users[u.UserID] = u
+	}
+	return nil
+}
+
+func (q *memQueries) GetUserAvatarForUpdate(ctx context.Context, arg database.GetUserAvatarForUpdateParams) (database.GetUserAvatarForUpdateRow, error) {
+	t, done := q.open()
+	defer done()
+
+	u, ok := t.users[arg.UserID]
+	if !ok {
+		return database.GetUserAvatarForUpdateRow{}, pgx.ErrNoRows
+	}
+	return database.GetUserAvatarForUpdateRow{DisplayName: u.DisplayName, AvatarID: u.AvatarID}, nil
+}
+
+func (q *memQueries) GetUserPasswordForUpdate(ctx context.Context, arg database.GetUserPasswordForUpdateParams) (database.GetUserPasswordForUpdateRow, error) {
+	t, done := q.open()
+	defer done()
+
+	u, ok := t.users[arg.UserID]
+	if !ok {
+		return database.GetUserPasswordForUpdateRow{}, pgx.ErrNoRows
+	}
+	return database.GetUserPasswordForUpdateRow{DisplayName: u.DisplayName, PasswordHash: u.PasswordHash}, nil
+}
+
+func (q *memQueries) RehashUserPassword(ctx context.Context, arg database.RehashUserPasswordParams) error {
+	t, done := q.open()
+	defer done()
+
+	if u, ok := t.users[arg.UserID]; ok && u.PasswordHash == arg.OldHash {
+		u.PasswordHash = arg.NewHash
+		t.users[u.UserID] = u
+	}
+	return nil
+}
+
+func (q *memQueries) GetOwnProfile(ctx context.Context, arg database.GetOwnProfileParams) (database.GetOwnProfileRow, error) {
+	t, done := q.open()
+	defer done()
+
+	u, ok := t.users[arg.UserID]
+	if !ok {
+		return database.GetOwnProfileRow{}, pgx.ErrNoRows
+	}
+	return database.GetOwnProfileRow{
+		UserID:      u.UserID,
+		DisplayName: u.DisplayName,
+		FirstName:   u.FirstName,
+		LastName:    u.LastName,
+		PfpUrl:      u.PfpUrl,
+		CreatedAt:   u.CreatedAt,
+		TotpEnabled: u.TotpEnabled,
+	}, nil
+}
+
+// sharesChat reports whether two users are in a chat together.
+func sharesChat(t *tables, a, b int64) bool {
+	for k := range t.participants {
+		if k.userID != a {
+			continue
+		}
+		if _, ok := t.participants[participantKey{k.chatID, b}]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (q *memQueries) GetUserProfile(ctx context.Context, arg database.GetUserProfileParams) (database.GetUserProfileRow, error) {
+	t, done := q.open()
+	defer done()
+
+	u, ok := t.users[arg.UserID]
+	if !ok || blocked(t, arg.ViewerID, arg.UserID) {
+		return database.GetUserProfileRow{}, pgx.ErrNoRows
+	}
+
+	row := database.GetUserProfileRow{
+		UserID:      u.UserID,
+		DisplayName: u.DisplayName,
+		FirstName:   u.FirstName,
+		LastName:    u.LastName,
+		PfpUrl:      u.PfpUrl,
+		CreatedAt:   u.CreatedAt,
+		SharesChat:  sharesChat(t, arg.ViewerID, u.UserID),
+	}
+	_, row.IsFriend = t.friendships[friendshipKey{arg.ViewerID, u.UserID}]
+	for _, fr := range t.friendRequests {
+		row.RequestSent = row.RequestSent || (fr.SenderID == arg.ViewerID && fr.ReceiverID == u.UserID)
+		row.RequestReceived = row.RequestReceived || (fr.SenderID == u.UserID && fr.ReceiverID == arg.ViewerID)
+	}
+	return row, nil
+}
+
+// similarityThreshold is pg_trgm's default for the % operator.
+const similarityThreshold = 0.3
+
+func (q *memQueries) SearchUsers(ctx context.Context, arg database.SearchUsersParams) ([]database.SearchUsersRow, error) {
+	t, done := q.open()
+	defer done()
+
+	type match struct {
+		row           database.SearchUsersRow
+		exact, prefix bool
+		similarity    float64
+	}
+
+	var matches []match
+	for _, u := range t.users {
+		if u.UserID == arg.UserID || blocked(t, arg.UserID, u.UserID) {
+			continue
+		}
+
+		_, isFriend := t.friendships[friendshipKey{arg.UserID, u.UserID}]
+		shared := sharesChat(t, arg.UserID, u.UserID)
+
+		// Only friends and chat partners can find someone by real name
+		name := strings.ToLower(u.DisplayName)
+		m := match{
+			exact:      name == arg.Query,
+			prefix:     strings.HasPrefix(name, arg.Query),
+			similarity: similarity(name, arg.Query),
+		}
+		byName := false
+		if isFriend || shared {
+			fullName := strings.ToLower(u.FirstName + " " + u.LastName)
+			m.similarity = max(m.similarity, similarity(fullName, arg.Query))
+			byName = strings.Contains(fullName, arg.Query)
+		}
+		if !m.prefix && !byName && m.similarity < similarityThreshold {
+			continue
+		}
+
+		m.row = database.SearchUsersRow{
+			UserID:      u.UserID,
+			DisplayName: u.DisplayName,
+			FirstName:   u.FirstName,
+			LastName:    u.LastName,
+			PfpUrl:      u.PfpUrl,
+			IsFriend:    isFriend,
+			SharesChat:  shared,
+		}
+		matches = append(matches, m)
+	}
+
+	rank := func(b bool) int {
+		if b {
+			return 0
+		}
+		return 1
+	}
+	slices.SortFunc(matches, func(a, b match) int {
+		return cmp.Or(
+			cmp.Compare(rank(a.exact), rank(b.exact)),
+			cmp.Compare(rank(a.prefix), rank(b.prefix)),
+			cmp.Compare(b.similarity, a.similarity),
+			strings.Compare(a.row.DisplayName, b.row.DisplayName),
+		)
+	})
+
+	rows := []database.SearchUsersRow{}
+	for _, m := range matches {
+		rows = append(rows, m.row)
+	}
+	return limit(rows, arg.PageSize, 0), nil
+}
+
+// similarity is pg_trgm's: the trigrams the two strings share over all the
+// trigrams in either.
+func similarity(a, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+	shared := 0
+	for g := range ta {
+		if tb[g] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(ta)+len(tb)-shared)
+}
+
+// trigrams splits s into words of letters and digits and returns the
+// trigrams of each, padded with two spaces in front and one behind.
+func trigrams(s string) map[string]bool {
+	set := map[string]bool{}
+	for _, w := range strings.FieldsFunc(s, func(c rune) bool { return !unicode.IsLetter(c) && !unicode.IsDigit(c) }) {
+		r := []rune("  " + w + " ")
+		for i := 0; i+3 <= len(r); i++ {
+			set[string(r[i:i+3])] = true
+		}
+	}
+	return set
+}
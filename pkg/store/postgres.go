@@ -0,0 +1,47 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/astrokkidd/flick/pkg/database"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Postgres is the Store backed by a pgx connection pool.
+type Postgres struct {
+	*database.Queries
+	pool           *pgxpool.Pool
+	acquireTimeout time.Duration
+}
+
+var _ Store = (*Postgres)(nil)
+
+// NewPostgres serves queries from pool. Waiting for a free connection to
+// start a transaction gives up after acquireTimeout; zero waits as long as
+// the context does.
+func NewPostgres(pool *pgxpool.Pool, acquireTimeout time.Duration) *Postgres {
+	return &Postgres{Queries: database.New(pool), pool: pool, acquireTimeout: acquireTimeout}
+}
+
+func (p *Postgres) Tx(ctx context.Context, fn func(q database.Querier) error) error {
+	acquireCtx := ctx
+	if p.acquireTimeout > 0 {
+		var cancel context.CancelFunc
+		acquireCtx, cancel = context.WithTimeout(ctx, p.acquireTimeout)
+		defer cancel()
+	}
+
+	tx, err := p.pool.Begin(acquireCtx)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := fn(p.Queries.WithTx(tx)); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
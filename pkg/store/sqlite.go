@@ -0,0 +1,173 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/astrokkidd/flick/pkg/database"
+	"github.com/astrokkidd/flick/pkg/database/sqlite"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	driver "modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// SQLite is the Store backed by a single SQLite file, for local development
+// and single-node deployments. Its schema is configs/schema/sqlite, applied
+// with atlas like the Postgres one.
+//
+// Transactions take the write lock when they begin, so they run one at a
+// time and never fail half way on a lock upgrade; readers outside a
+// transaction are not blocked.
+type SQLite struct {
+	db *sql.DB
+
+	sqliteQueries
+}
+
+var _ Store = (*SQLite)(nil)
+
+// OpenSQLite opens the database file at path. Waiting on another writer
+// gives up after busyTimeout.
+func OpenSQLite(ctx context.Context, path string, busyTimeout time.Duration) (*SQLite, error) {
+	params := url.Values{
+		"_pragma": {
+			fmt.Sprintf("busy_timeout(%d)", busyTimeout.Milliseconds()),
+			"foreign_keys(1)",
+			"journal_mode(WAL)",
+			"synchronous(NORMAL)",
+		},
+		"_txlock":      {"immediate"},
+		"_time_format": {"sqlite"},
+	}
+
+	db, err := sql.Open("sqlite", "file:"+path+"?"+params.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	// Fail at startup rather than on the first request if the schema was
+	// never applied
+	var tables int
+	err = db.QueryRowContext(ctx, "SELECT count(*) FROM sqlite_schema WHERE type = 'table' AND name = 'users'").Scan(&tables)
+	if err == nil && tables == 0 {
+		err = fmt.Errorf("%s has no schema, apply configs/schema/sqlite first", path)
+	}
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLite{db: db, sqliteQueries: sqliteQueries{sqlite.New(db)}}, nil
+}
+
+func (s *SQLite) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLite) Tx(ctx context.Context, fn func(q database.Querier) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(sqliteQueries{s.q.WithTx(tx)}); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// liteErr brings driver errors in line with the Store contract: no rows is
+// pgx.ErrNoRows and constraint violations carry the Postgres error code.
+func liteErr(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return pgx.ErrNoRows
+	}
+
+	var e *driver.Error
+	if !errors.As(err, &e) {
+		return err
+	}
+
+	var code string
+	switch e.Code() {
+	case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+		code = "23505"
+	case sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY:
+		code = "23503"
+	case sqlite3.SQLITE_CONSTRAINT_CHECK:
+		code = "23514"
+	case sqlite3.SQLITE_CONSTRAINT_NOTNULL:
+		code = "23502"
+	default:
+		return err
+	}
+
+	return &pgconn.PgError{
+		Severity:       "ERROR",
+		Code:           code,
+		Message:        e.Error(),
+		ConstraintName: constraintName(e.Error()),
+	}
+}
+
+// constraintName picks the constraint out of messages such as "CHECK
+// constraint failed: no_self_request" or "UNIQUE constraint failed: index
+// 'uq_users_display_name_ci'". Column lists ("users.display_name") and
+// foreign keys, which SQLite does not name, give "".
+func constraintName(msg string) string {
+	const marker = "constraint failed: "
+	i := strings.LastIndex(msg, marker)
+	if i < 0 {
+		return ""
+	}
+	name, _, _ := strings.Cut(msg[i+len(marker):], " (")
+	if index, ok := strings.CutPrefix(name, "index "); ok {
+		return strings.Trim(index, "'")
+	}
+	if strings.ContainsAny(name, ". ") {
+		return ""
+	}
+	return name
+}
+
+func convertRows[T, U any](rows []U, convert func(U) T) []T {
+	converted := make([]T, len(rows))
+	for i, r := range rows {
+		converted[i] = convert(r)
+	}
+	return converted
+}
+
+func timestamptz(t *time.Time) pgtype.Timestamptz {
+	if t == nil {
+		return pgtype.Timestamptz{}
+	}
+	return pgtype.Timestamptz{Time: *t, Valid: true}
+}
+
+// widen and narrow carry nullable INTEGER columns between Postgres' int32
+// and SQLite's int64.
+func widen(v *int32) *int64 {
+	if v == nil {
+		return nil
+	}
+	w := int64(*v)
+	return &w
+}
+
+func narrow(v *int64) *int32 {
+	if v == nil {
+		return nil
+	}
+	n := int32(*v)
+	return &n
+}
@@ -0,0 +1,888 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"time"
+
+	"github.com/astrokkidd/flick/pkg/database"
+	"github.com/astrokkidd/flick/pkg/database/sqlite"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// sqliteQueries implements database.Querier over the sqlc queries generated
+// from pkg/database/sqlite. Rows and params are the same shape on both sides
+// apart from column order, integer widths and nullable timestamps, so most
+// methods convert straight across.
+type sqliteQueries struct {
+	q *sqlite.Queries
+}
+
+// users.sql
+
+func (q sqliteQueries) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.CreateUserRow, error) {
+	row, err := q.q.CreateUser(ctx, sqlite.CreateUserParams(arg))
+	return database.CreateUserRow(row), liteErr(err)
+}
+
+func (q sqliteQueries) FindUserByDisplayName(ctx context.Context, arg database.FindUserByDisplayNameParams) (database.FindUserByDisplayNameRow, error) {
+	row, err := q.q.FindUserByDisplayName(ctx, sqlite.FindUserByDisplayNameParams(arg))
+	return database.FindUserByDisplayNameRow(row), liteErr(err)
+}
+
+func (q sqliteQueries) FindUserByID(ctx context.Context, arg database.FindUserByIDParams) (database.FindUserByIDRow, error) {
+	row, err := q.q.FindUserByID(ctx, sqlite.FindUserByIDParams(arg))
+	return database.FindUserByIDRow(row), liteErr(err)
+}
+
+func (q sqliteQueries) GetOwnProfile(ctx context.Context, arg database.GetOwnProfileParams) (database.GetOwnProfileRow, error) {
+	row, err := q.q.GetOwnProfile(ctx, sqlite.GetOwnProfileParams(arg))
+	return database.GetOwnProfileRow(row), liteErr(err)
+}
+
+func (q sqliteQueries) GetUserAvatarForUpdate(ctx context.Context, arg database.GetUserAvatarForUpdateParams) (database.GetUserAvatarForUpdateRow, error) {
+	row, err := q.q.GetUserAvatarForUpdate(ctx, sqlite.GetUserAvatarForUpdateParams(arg))
+	return database.GetUserAvatarForUpdateRow(row), liteErr(err)
+}
+
+func (q sqliteQueries) GetUserPasswordForUpdate(ctx context.Context, arg database.GetUserPasswordForUpdateParams) (database.GetUserPasswordForUpdateRow, error) {
+	row, err := q.q.GetUserPasswordForUpdate(ctx, sqlite.GetUserPasswordForUpdateParams(arg))
+	return database.GetUserPasswordForUpdateRow(row), liteErr(err)
+}
+
+func (q sqliteQueries) GetUserProfile(ctx context.Context, arg database.GetUserProfileParams) (database.GetUserProfileRow, error) {
+	row, err := q.q.GetUserProfile(ctx, sqlite.GetUserProfileParams(arg))
+	return database.GetUserProfileRow(row), liteErr(err)
+}
+
+func (q sqliteQueries) ListUsers(ctx context.Context) ([]database.User, error) {
+	rows, err := q.q.ListUsers(ctx)
+	return convertRows(rows, func(r sqlite.User) database.User {
+		return database.User{
+			UserID:            r.UserID,
+			DisplayName:       r.DisplayName,
+			PasswordHash:      r.PasswordHash,
+			FirstName:         r.FirstName,
+			PfpUrl:            r.PfpUrl,
+			AvatarID:          r.AvatarID,
+			LastName:          r.LastName,
+			CreatedAt:         r.CreatedAt,
+			TotpSecret:        r.TotpSecret,
+			TotpEnabled:       r.TotpEnabled,
+			TotpLastStep:      r.TotpLastStep,
+			MfaFailedAttempts: int32(r.MfaFailedAttempts),
+			MfaLockedUntil:    timestamptz(r.MfaLockedUntil),
+		}
+	}), liteErr(err)
+}
+
+func (q sqliteQueries) RehashUserPassword(ctx context.Context, arg database.RehashUserPasswordParams) error {
+	return liteErr(q.q.RehashUserPassword(ctx, sqlite.RehashUserPasswordParams(arg)))
+}
+
+func (q sqliteQueries) SearchUsers(ctx context.Context, arg database.SearchUsersParams) ([]database.SearchUsersRow, error) {
+	rows, err := q.q.SearchUsers(ctx, sqlite.SearchUsersParams{
+		UserID:   arg.UserID,
+		Query:    arg.Query,
+		Pattern:  arg.Pattern,
+		PageSize: int64(arg.PageSize),
+	})
+	return convertRows(rows, func(r sqlite.SearchUsersRow) database.SearchUsersRow {
+		return database.SearchUsersRow{
+			UserID:      r.UserID,
+			DisplayName: r.DisplayName,
+			FirstName:   r.FirstName,
+			LastName:    r.LastName,
+			PfpUrl:      r.PfpUrl,
+			IsFriend:    r.IsFriend,
+			SharesChat:  r.SharesChat,
+		}
+	}), liteErr(err)
+}
+
+func (q sqliteQueries) UpdateUserPassword(ctx context.Context, arg database.UpdateUserPasswordParams) error {
+	return liteErr(q.q.UpdateUserPassword(ctx, sqlite.UpdateUserPasswordParams(arg)))
+}
+
+func (q sqliteQueries) UpdateUserPfp(ctx context.Context, arg database.UpdateUserPfpParams) error {
+	return liteErr(q.q.UpdateUserPfp(ctx, sqlite.UpdateUserPfpParams(arg)))
+}
+
+// auth.sql
+
+func (q sqliteQueries) CreateRecoveryCode(ctx context.Context, arg database.CreateRecoveryCodeParams) error {
+	return liteErr(q.q.CreateRecoveryCode(ctx, sqlite.CreateRecoveryCodeParams(arg)))
+}
+
+func (q sqliteQueries) CreateRefreshToken(ctx context.Context, arg database.CreateRefreshTokenParams) (database.CreateRefreshTokenRow, error) {
+	row, err := q.q.CreateRefreshToken(ctx, sqlite.CreateRefreshTokenParams{
+		UserID:    arg.UserID,
+		FamilyID:  arg.FamilyID,
+		TokenHash: arg.TokenHash,
+		ExpiresAt: arg.ExpiresAt.UTC(),
+	})
+	return database.CreateRefreshTokenRow(row), liteErr(err)
+}
+
+func (q sqliteQueries) CreateSession(ctx context.Context, arg database.CreateSessionParams) (pgtype.UUID, error) {
+	v, err := q.q.CreateSession(ctx, sqlite.CreateSessionParams(arg))
+	return v, liteErr(err)
+}
+
+func (q sqliteQueries) DeleteExpiredMFAChallenges(ctx context.Context) error {
+	return liteErr(q.q.DeleteExpiredMFAChallenges(ctx))
+}
+
+func (q sqliteQueries) DeleteRecoveryCodes(ctx context.Context, arg database.DeleteRecoveryCodesParams) error {
+	return liteErr(q.q.DeleteRecoveryCodes(ctx, sqlite.DeleteRecoveryCodesParams(arg)))
+}
+
+func (q sqliteQueries) EnableTOTP(ctx context.Context, arg database.EnableTOTPParams) error {
+	return liteErr(q.q.EnableTOTP(ctx, sqlite.EnableTOTPParams(arg)))
+}
+
+func (q sqliteQueries) GetRefreshTokenForUpdate(ctx context.Context, arg database.GetRefreshTokenForUpdateParams) (database.GetRefreshTokenForUpdateRow, error) {
+	row, err := q.q.GetRefreshTokenForUpdate(ctx, sqlite.GetRefreshTokenForUpdateParams(arg))
+	return database.GetRefreshTokenForUpdateRow{
+		TokenID:   row.TokenID,
+		UserID:    row.UserID,
+		FamilyID:  row.FamilyID,
+		ExpiresAt: row.ExpiresAt,
+		UsedAt:    timestamptz(row.UsedAt),
+		RevokedAt: timestamptz(row.RevokedAt),
+	}, liteErr(err)
+}
+
+func (q sqliteQueries) GetUserTOTPForUpdate(ctx context.Context, arg database.GetUserTOTPForUpdateParams) (database.GetUserTOTPForUpdateRow, error) {
+	row, err := q.q.GetUserTOTPForUpdate(ctx, sqlite.GetUserTOTPForUpdateParams(arg))
+	return database.GetUserTOTPForUpdateRow{
+		TotpSecret:     row.TotpSecret,
+		TotpEnabled:    row.TotpEnabled,
+		TotpLastStep:   row.TotpLastStep,
+		MfaLockedUntil: timestamptz(row.MfaLockedUntil),
+	}, liteErr(err)
+}
+
+func (q sqliteQueries) ListTOTPSecrets(ctx context.Context, arg database.ListTOTPSecretsParams) ([]database.ListTOTPSecretsRow, error) {
+	rows, err := q.q.ListTOTPSecrets(ctx, sqlite.ListTOTPSecretsParams{AfterID: arg.AfterID, BatchSize: int64(arg.BatchSize)})
+	return convertRows(rows, func(r sqlite.ListTOTPSecretsRow) database.ListTOTPSecretsRow { return database.ListTOTPSecretsRow(r) }), liteErr(err)
+}
+
+func (q sqliteQueries) ListUserSessions(ctx context.Context, arg database.ListUserSessionsParams) ([]database.ListUserSessionsRow, error) {
+	rows, err := q.q.ListUserSessions(ctx, sqlite.ListUserSessionsParams(arg))
+	return convertRows(rows, func(r sqlite.ListUserSessionsRow) database.ListUserSessionsRow {
+		return database.ListUserSessionsRow(r)
+	}), liteErr(err)
+}
+
+func (q sqliteQueries) MarkRefreshTokenUsed(ctx context.Context, arg database.MarkRefreshTokenUsedParams) error {
+	return liteErr(q.q.MarkRefreshTokenUsed(ctx, sqlite.MarkRefreshTokenUsedParams(arg)))
+}
+
+func (q sqliteQueries) RecordMFAFailure(ctx context.Context, arg database.RecordMFAFailureParams) error {
+	lockedUntil := arg.LockedUntil.UTC()
+	return liteErr(q.q.RecordMFAFailure(ctx, sqlite.RecordMFAFailureParams{
+		MaxAttempts: int64(arg.MaxAttempts),
+		LockedUntil: &lockedUntil,
+		UserID:      arg.UserID,
+	}))
+}
+
+func (q sqliteQueries) ReplaceTOTPSecret(ctx context.Context, arg database.ReplaceTOTPSecretParams) (int64, error) {
+	v, err := q.q.ReplaceTOTPSecret(ctx, sqlite.ReplaceTOTPSecretParams(arg))
+	return v, liteErr(err)
+}
+
+func (q sqliteQueries) ResetMFAFailures(ctx context.Context, arg database.ResetMFAFailuresParams) error {
+	return liteErr(q.q.ResetMFAFailures(ctx, sqlite.ResetMFAFailuresParams(arg)))
+}
+
+func (q sqliteQueries) RevokeAllUserRefreshTokens(ctx context.Context, arg database.RevokeAllUserRefreshTokensParams) error {
+	return liteErr(q.q.RevokeAllUserRefreshTokens(ctx, sqlite.RevokeAllUserRefreshTokensParams{
+		UserID:        arg.UserID,
+		KeepSessionID: arg.KeepSessionID,
+	}))
+}
+
+func (q sqliteQueries) RevokeAllUserSessions(ctx context.Context, arg database.RevokeAllUserSessionsParams) ([]pgtype.UUID, error) {
+	ids, err := q.q.RevokeAllUserSessions(ctx, sqlite.RevokeAllUserSessionsParams{
+		UserID:        arg.UserID,
+		KeepSessionID: arg.KeepSessionID,
+	})
+	return ids, liteErr(err)
+}
+
+func (q sqliteQueries) RevokeRefreshTokenFamily(ctx context.Context, arg database.RevokeRefreshTokenFamilyParams) (int64, error) {
+	v, err := q.q.RevokeRefreshTokenFamily(ctx, sqlite.RevokeRefreshTokenFamilyParams(arg))
+	return v, liteErr(err)
+}
+
+func (q sqliteQueries) RevokeSession(ctx context.Context, arg database.RevokeSessionParams) (int64, error) {
+	v, err := q.q.RevokeSession(ctx, sqlite.RevokeSessionParams(arg))
+	return v, liteErr(err)
+}
+
+func (q sqliteQueries) SetPendingTOTPSecret(ctx context.Context, arg database.SetPendingTOTPSecretParams) error {
+	return liteErr(q.q.SetPendingTOTPSecret(ctx, sqlite.SetPendingTOTPSecretParams(arg)))
+}
+
+func (q sqliteQueries) SetTOTPLastStep(ctx context.Context, arg database.SetTOTPLastStepParams) error {
+	return liteErr(q.q.SetTOTPLastStep(ctx, sqlite.SetTOTPLastStepParams(arg)))
+}
+
+func (q sqliteQueries) TouchSession(ctx context.Context, arg database.TouchSessionParams) (int64, error) {
+	v, err := q.q.TouchSession(ctx, sqlite.TouchSessionParams{IpAddress: arg.IpAddress, SessionID: arg.SessionID})
+	return v, liteErr(err)
+}
+
+func (q sqliteQueries) UseMFAChallenge(ctx context.Context, arg database.UseMFAChallengeParams) (int64, error) {
+	v, err := q.q.UseMFAChallenge(ctx, sqlite.UseMFAChallengeParams{
+		ChallengeID: arg.ChallengeID,
+		UserID:      arg.UserID,
+		ExpiresAt:   arg.ExpiresAt.UTC(),
+	})
+	return v, liteErr(err)
+}
+
+func (q sqliteQueries) UseRecoveryCode(ctx context.Context, arg database.UseRecoveryCodeParams) (int64, error) {
+	v, err := q.q.UseRecoveryCode(ctx, sqlite.UseRecoveryCodeParams(arg))
+	return v, liteErr(err)
+}
+
+// keys.sql
+
+func (q sqliteQueries) GetUserKeys(ctx context.Context, arg database.GetUserKeysParams) (database.UserKey, error) {
+	row, err := q.q.GetUserKeys(ctx, sqlite.GetUserKeysParams(arg))
+	return database.UserKey(row), liteErr(err)
+}
+
+func (q sqliteQueries) ListUsersWithoutKeys(ctx context.Context, arg database.ListUsersWithoutKeysParams) ([]int64, error) {
+	withKeys, err := q.q.ListUsersWithKeys(ctx, sqlite.ListUsersWithKeysParams(arg))
+	if err != nil {
+		return nil, liteErr(err)
+	}
+
+	ids := []int64{}
+	for _, id := range arg.UserIds {
+		if !slices.Contains(withKeys, id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (q sqliteQueries) UpsertIdentityKey(ctx context.Context, arg database.UpsertIdentityKeyParams) error {
+	return liteErr(q.q.UpsertIdentityKey(ctx, sqlite.UpsertIdentityKeyParams(arg)))
+}
+
+func (q sqliteQueries) UpsertUserKeys(ctx context.Context, arg database.UpsertUserKeysParams) error {
+	return liteErr(q.q.UpsertUserKeys(ctx, sqlite.UpsertUserKeysParams(arg)))
+}
+
+// friends.sql
+
+func (q sqliteQueries) AreUsersFriends(ctx context.Context, arg database.AreUsersFriendsParams) (bool, error) {
+	v, err := q.q.AreUsersFriends(ctx, sqlite.AreUsersFriendsParams{UserID: arg.UserID, UserID2: arg.UserID_2})
+	return v, liteErr(err)
+}
+
+func (q sqliteQueries) BlockUser(ctx context.Context, arg database.BlockUserParams) error {
+	return liteErr(q.q.BlockUser(ctx, sqlite.BlockUserParams(arg)))
+}
+
+func (q sqliteQueries) CreateFriendRequest(ctx context.Context, arg database.CreateFriendRequestParams) (database.FriendRequest, error) {
+	row, err := q.q.CreateFriendRequest(ctx, sqlite.CreateFriendRequestParams(arg))
+	return database.FriendRequest(row), liteErr(err)
+}
+
+func (q sqliteQueries) CreateFriendship(ctx context.Context, arg database.CreateFriendshipParams) error {
+	return liteErr(q.q.CreateFriendship(ctx, sqlite.CreateFriendshipParams(arg)))
+}
+
+func (q sqliteQueries) DeleteFriendRequest(ctx context.Context, arg database.DeleteFriendRequestParams) (int64, error) {
+	v, err := q.q.DeleteFriendRequest(ctx, sqlite.DeleteFriendRequestParams(arg))
+	return v, liteErr(err)
+}
+
+func (q sqliteQueries) DeleteFriendRequestsBetween(ctx context.Context, arg database.DeleteFriendRequestsBetweenParams) error {
+	return liteErr(q.q.DeleteFriendRequestsBetween(ctx, sqlite.DeleteFriendRequestsBetweenParams(arg)))
+}
+
+func (q sqliteQueries) DeleteFriendship(ctx context.Context, arg database.DeleteFriendshipParams) error {
+	return liteErr(q.q.DeleteFriendship(ctx, sqlite.DeleteFriendshipParams(arg)))
+}
+
+func (q sqliteQueries) DoesFriendRequestExist(ctx context.Context, arg database.DoesFriendRequestExistParams) (bool, error) {
+	v, err := q.q.DoesFriendRequestExist(ctx, sqlite.DoesFriendRequestExistParams(arg))
+	return v, liteErr(err)
+}
+
+func (q sqliteQueries) GetFriendRequestByID(ctx context.Context, arg database.GetFriendRequestByIDParams) (database.FriendRequest, error) {
+	row, err := q.q.GetFriendRequestByID(ctx, sqlite.GetFriendRequestByIDParams(arg))
+	return database.FriendRequest(row), liteErr(err)
+}
+
+func (q sqliteQueries) GetPendingRequestBetween(ctx context.Context) (database.FriendRequest, error) {
+	row, err := q.q.GetPendingRequestBetween(ctx)
+	return database.FriendRequest(row), liteErr(err)
+}
+
+func (q sqliteQueries) GetUserByRequestID(ctx context.Context, arg database.GetUserByRequestIDParams) (int64, error) {
+	v, err := q.q.GetUserByRequestID(ctx, sqlite.GetUserByRequestIDParams(arg))
+	return v, liteErr(err)
+}
+
+func (q sqliteQueries) IsBlockedBetween(ctx context.Context, arg database.IsBlockedBetweenParams) (bool, error) {
+	v, err := q.q.IsBlockedBetween(ctx, sqlite.IsBlockedBetweenParams(arg))
+	return v, liteErr(err)
+}
+
+func (q sqliteQueries) ListAllFriends(ctx context.Context, arg database.ListAllFriendsParams) ([]database.ListAllFriendsRow, error) {
+	rows, err := q.q.ListAllFriends(ctx, sqlite.ListAllFriendsParams(arg))
+	return convertRows(rows, func(r sqlite.ListAllFriendsRow) database.ListAllFriendsRow { return database.ListAllFriendsRow(r) }), liteErr(err)
+}
+
+func (q sqliteQueries) ListBlockedUsers(ctx context.Context, arg database.ListBlockedUsersParams) ([]database.ListBlockedUsersRow, error) {
+	rows, err := q.q.ListBlockedUsers(ctx, sqlite.ListBlockedUsersParams(arg))
+	return convertRows(rows, func(r sqlite.ListBlockedUsersRow) database.ListBlockedUsersRow {
+		return database.ListBlockedUsersRow(r)
+	}), liteErr(err)
+}
+
+func (q sqliteQueries) ListIncomingFriendRequests(ctx context.Context, arg database.ListIncomingFriendRequestsParams) ([]database.FriendRequest, error) {
+	rows, err := q.q.ListIncomingFriendRequests(ctx, sqlite.ListIncomingFriendRequestsParams{
+		ReceiverID: arg.ReceiverID,
+		Offset:     int64(arg.Offset),
+		Limit:      int64(arg.Limit),
+	})
+	return convertRows(rows, func(r sqlite.FriendRequest) database.FriendRequest { return database.FriendRequest(r) }), liteErr(err)
+}
+
+func (q sqliteQueries) ListOutgoingFriendRequests(ctx context.Context, arg database.ListOutgoingFriendRequestsParams) ([]database.FriendRequest, error) {
+	rows, err := q.q.ListOutgoingFriendRequests(ctx, sqlite.ListOutgoingFriendRequestsParams{
+		SenderID: arg.SenderID,
+		Offset:   int64(arg.Offset),
+		Limit:    int64(arg.Limit),
+	})
+	return convertRows(rows, func(r sqlite.FriendRequest) database.FriendRequest { return database.FriendRequest(r) }), liteErr(err)
+}
+
+func (q sqliteQueries) ListReceivedFriendRequestsWithUser(ctx context.Context, arg database.ListReceivedFriendRequestsWithUserParams) ([]database.ListReceivedFriendRequestsWithUserRow, error) {
+	rows, err := q.q.ListReceivedFriendRequestsWithUser(ctx, sqlite.ListReceivedFriendRequestsWithUserParams(arg))
+	return convertRows(rows, func(r sqlite.ListReceivedFriendRequestsWithUserRow) database.ListReceivedFriendRequestsWithUserRow {
+		return database.ListReceivedFriendRequestsWithUserRow(r)
+	}), liteErr(err)
+}
+
+func (q sqliteQueries) ListSentFriendRequestsWithUser(ctx context.Context, arg database.ListSentFriendRequestsWithUserParams) ([]database.ListSentFriendRequestsWithUserRow, error) {
+	rows, err := q.q.ListSentFriendRequestsWithUser(ctx, sqlite.ListSentFriendRequestsWithUserParams(arg))
+	return convertRows(rows, func(r sqlite.ListSentFriendRequestsWithUserRow) database.ListSentFriendRequestsWithUserRow {
+		return database.ListSentFriendRequestsWithUserRow(r)
+	}), liteErr(err)
+}
+
+func (q sqliteQueries) UnblockUser(ctx context.Context, arg database.UnblockUserParams) (int64, error) {
+	v, err := q.q.UnblockUser(ctx, sqlite.UnblockUserParams(arg))
+	return v, liteErr(err)
+}
+
+// chat.sql
+
+func (q sqliteQueries) AddParticipant(ctx context.Context, arg database.AddParticipantParams) error {
+	return liteErr(q.q.AddParticipant(ctx, sqlite.AddParticipantParams(arg)))
+}
+
+func (q sqliteQueries) CountChatParticipants(ctx context.Context, arg database.CountChatParticipantsParams) (int64, error) {
+	v, err := q.q.CountChatParticipants(ctx, sqlite.CountChatParticipantsParams(arg))
+	return v, liteErr(err)
+}
+
+func (q sqliteQueries) CreateChatKey(ctx context.Context, arg database.CreateChatKeyParams) error {
+	return liteErr(q.q.CreateChatKey(ctx, sqlite.CreateChatKeyParams(arg)))
+}
+
+func (q sqliteQueries) CreateEmptyChat(ctx context.Context, arg database.CreateEmptyChatParams) (database.CreateEmptyChatRow, error) {
+	row, err := q.q.CreateEmptyChat(ctx, sqlite.CreateEmptyChatParams(arg))
+	return database.CreateEmptyChatRow(row), liteErr(err)
+}
+
+func (q sqliteQueries) CreateGroupChat(ctx context.Context, arg database.CreateGroupChatParams) (int64, error) {
+	v, err := q.q.CreateGroupChat(ctx, sqlite.CreateGroupChatParams(arg))
+	return v, liteErr(err)
+}
+
+func (q sqliteQueries) DeleteChat(ctx context.Context, arg database.DeleteChatParams) (int64, error) {
+	v, err := q.q.DeleteChat(ctx, sqlite.DeleteChatParams(arg))
+	return v, liteErr(err)
+}
+
+func (q sqliteQueries) FindDirectChatBetween(ctx context.Context, arg database.FindDirectChatBetweenParams) (int64, error) {
+	v, err := q.q.FindDirectChatBetween(ctx, sqlite.FindDirectChatBetweenParams{
+		UserID:   arg.UserID,
+		UserID2:  arg.UserID_2,
+		EndToEnd: arg.EndToEnd,
+	})
+	return v, liteErr(err)
+}
+
+func (q sqliteQueries) FindSuccessorOwner(ctx context.Context, arg database.FindSuccessorOwnerParams) (int64, error) {
+	v, err := q.q.FindSuccessorOwner(ctx, sqlite.FindSuccessorOwnerParams(arg))
+	return v, liteErr(err)
+}
+
+func (q sqliteQueries) GetChatByID(ctx context.Context, arg database.GetChatByIDParams) (database.GetChatByIDRow, error) {
+	row, err := q.q.GetChatByID(ctx, sqlite.GetChatByIDParams(arg))
+	return database.GetChatByIDRow(row), liteErr(err)
+}
+
+func (q sqliteQueries) GetChatKey(ctx context.Context, arg database.GetChatKeyParams) ([]byte, error) {
+	v, err := q.q.GetChatKey(ctx, sqlite.GetChatKeyParams(arg))
+	return v, liteErr(err)
+}
+
+func (q sqliteQueries) GetNumberUnreadMessages(ctx context.Context, arg database.GetNumberUnreadMessagesParams) (int64, error) {
+	v, err := q.q.GetNumberUnreadMessages(ctx, sqlite.GetNumberUnreadMessagesParams(arg))
+	return v, liteErr(err)
+}
+
+func (q sqliteQueries) GetParticipantRole(ctx context.Context, arg database.GetParticipantRoleParams) (string, error) {
+	v, err := q.q.GetParticipantRole(ctx, sqlite.GetParticipantRoleParams(arg))
+	return v, liteErr(err)
+}
+
+func (q sqliteQueries) IsChatEndToEnd(ctx context.Context, arg database.IsChatEndToEndParams) (bool, error) {
+	v, err := q.q.IsChatEndToEnd(ctx, sqlite.IsChatEndToEndParams(arg))
+	return v, liteErr(err)
+}
+
+func (q sqliteQueries) IsUserInChat(ctx context.Context, arg database.IsUserInChatParams) (bool, error) {
+	v, err := q.q.IsUserInChat(ctx, sqlite.IsUserInChatParams(arg))
+	return v, liteErr(err)
+}
+
+func (q sqliteQueries) ListChatKeys(ctx context.Context, arg database.ListChatKeysParams) ([]database.ListChatKeysRow, error) {
+	rows, err := q.q.ListChatKeys(ctx, sqlite.ListChatKeysParams{AfterID: arg.AfterID, BatchSize: int64(arg.BatchSize)})
+	return convertRows(rows, func(r sqlite.ListChatKeysRow) database.ListChatKeysRow { return database.ListChatKeysRow(r) }), liteErr(err)
+}
+
+func (q sqliteQueries) ListChatParticipantIDs(ctx context.Context, arg database.ListChatParticipantIDsParams) ([]int64, error) {
+	v, err := q.q.ListChatParticipantIDs(ctx, sqlite.ListChatParticipantIDsParams(arg))
+	return v, liteErr(err)
+}
+
+func (q sqliteQueries) ListChatParticipants(ctx context.Context, arg database.ListChatParticipantsParams) ([]database.ListChatParticipantsRow, error) {
+	rows, err := q.q.ListChatParticipants(ctx, sqlite.ListChatParticipantsParams{ChatIds: arg.Column1})
+	return convertRows(rows, func(r sqlite.ListChatParticipantsRow) database.ListChatParticipantsRow {
+		return database.ListChatParticipantsRow(r)
+	}), liteErr(err)
+}
+
+func (q sqliteQueries) ListChatsWithParticipant(ctx context.Context, arg database.ListChatsWithParticipantParams) ([]database.ListChatsWithParticipantRow, error) {
+	rows, err := q.q.ListChatsWithParticipant(ctx, sqlite.ListChatsWithParticipantParams(arg))
+	if err != nil {
+		return nil, liteErr(err)
+	}
+
+	chats := make([]database.ListChatsWithParticipantRow, len(rows))
+	for i, r := range rows {
+		chats[i] = database.ListChatsWithParticipantRow(r)
+		if chats[i].OtherParticipantIds, err = arrayAgg(r.OtherParticipantIds); err != nil {
+			return nil, err
+		}
+	}
+	return chats, nil
+}
+
+func (q sqliteQueries) ListChatsWithUser(ctx context.Context, arg database.ListChatsWithUserParams) ([]database.ListChatsWithUserRow, error) {
+	rows, err := q.q.ListChatsWithUser(ctx, sqlite.ListChatsWithUserParams(arg))
+	return convertRows(rows, func(r sqlite.ListChatsWithUserRow) database.ListChatsWithUserRow {
+		return database.ListChatsWithUserRow{
+			ChatID:     r.ChatID,
+			Kind:       r.Kind,
+			Title:      r.Title,
+			EndToEnd:   r.EndToEnd,
+			MessageID:  r.MessageID,
+			SenderID:   r.SenderID,
+			CreatedAt:  timestamptz(r.CreatedAt),
+			CypherText: r.CypherText,
+			WrappedKey: r.WrappedKey,
+		}
+	}), liteErr(err)
+}
+
+func (q sqliteQueries) RefreshChatLastMessage(ctx context.Context, arg database.RefreshChatLastMessageParams) error {
+	return liteErr(q.q.RefreshChatLastMessage(ctx, sqlite.RefreshChatLastMessageParams(arg)))
+}
+
+func (q sqliteQueries) RemoveParticipant(ctx context.Context, arg database.RemoveParticipantParams) (int64, error) {
+	v, err := q.q.RemoveParticipant(ctx, sqlite.RemoveParticipantParams(arg))
+	return v, liteErr(err)
+}
+
+func (q sqliteQueries) ReplaceChatKey(ctx context.Context, arg database.ReplaceChatKeyParams) (int64, error) {
+	v, err := q.q.ReplaceChatKey(ctx, sqlite.ReplaceChatKeyParams(arg))
+	return v, liteErr(err)
+}
+
+func (q sqliteQueries) SetLastReadMessage(ctx context.Context, arg database.SetLastReadMessageParams) (int64, error) {
+	v, err := q.q.SetLastReadMessage(ctx, sqlite.SetLastReadMessageParams(arg))
+	return v, liteErr(err)
+}
+
+func (q sqliteQueries) SetParticipantRole(ctx context.Context, arg database.SetParticipantRoleParams) (int64, error) {
+	v, err := q.q.SetParticipantRole(ctx, sqlite.SetParticipantRoleParams(arg))
+	return v, liteErr(err)
+}
+
+func (q sqliteQueries) SetTypingStatus(ctx context.Context, arg database.SetTypingStatusParams) (int64, error) {
+	v, err := q.q.SetTypingStatus(ctx, sqlite.SetTypingStatusParams(arg))
+	return v, liteErr(err)
+}
+
+func (q sqliteQueries) UpdateChatLastMessage(ctx context.Context, arg database.UpdateChatLastMessageParams) error {
+	return liteErr(q.q.UpdateChatLastMessage(ctx, sqlite.UpdateChatLastMessageParams(arg)))
+}
+
+func (q sqliteQueries) UpdateChatTitle(ctx context.Context, arg database.UpdateChatTitleParams) (int64, error) {
+	v, err := q.q.UpdateChatTitle(ctx, sqlite.UpdateChatTitleParams(arg))
+	return v, liteErr(err)
+}
+
+// message.sql
+
+func (q sqliteQueries) AddReaction(ctx context.Context, arg database.AddReactionParams) (int64, error) {
+	v, err := q.q.AddReaction(ctx, sqlite.AddReactionParams(arg))
+	return v, liteErr(err)
+}
+
+func (q sqliteQueries) CreateMessage(ctx context.Context, arg database.CreateMessageParams) (time.Time, error) {
+	v, err := q.q.CreateMessage(ctx, sqlite.CreateMessageParams(arg))
+	return v, liteErr(err)
+}
+
+func (q sqliteQueries) CreateMessageRevision(ctx context.Context, arg database.CreateMessageRevisionParams) error {
+	arg.WrittenAt = arg.WrittenAt.UTC()
+	return liteErr(q.q.CreateMessageRevision(ctx, sqlite.CreateMessageRevisionParams(arg)))
+}
+
+func (q sqliteQueries) CountOtherReactionEmojis(ctx context.Context, arg database.CountOtherReactionEmojisParams) (int64, error) {
+	v, err := q.q.CountOtherReactionEmojis(ctx, sqlite.CountOtherReactionEmojisParams(arg))
+	return v, liteErr(err)
+}
+
+func (q sqliteQueries) DeleteMessageForEveryone(ctx context.Context, arg database.DeleteMessageForEveryoneParams) (pgtype.Timestamptz, error) {
+	v, err := q.q.DeleteMessageForEveryone(ctx, sqlite.DeleteMessageForEveryoneParams(arg))
+	return timestamptz(v), liteErr(err)
+}
+
+func (q sqliteQueries) DeleteMessageReactions(ctx context.Context, arg database.DeleteMessageReactionsParams) error {
+	return liteErr(q.q.DeleteMessageReactions(ctx, sqlite.DeleteMessageReactionsParams(arg)))
+}
+
+func (q sqliteQueries) DeleteMessageRevisions(ctx context.Context, arg database.DeleteMessageRevisionsParams) error {
+	return liteErr(q.q.DeleteMessageRevisions(ctx, sqlite.DeleteMessageRevisionsParams(arg)))
+}
+
+func (q sqliteQueries) EditMessage(ctx context.Context, arg database.EditMessageParams) (pgtype.Timestamptz, error) {
+	v, err := q.q.EditMessage(ctx, sqlite.EditMessageParams(arg))
+	return timestamptz(v), liteErr(err)
+}
+
+func (q sqliteQueries) GetMessage(ctx context.Context, arg database.GetMessageParams) (database.GetMessageRow, error) {
+	row, err := q.q.GetMessage(ctx, sqlite.GetMessageParams(arg))
+	return database.GetMessageRow{
+		SenderID:     row.SenderID,
+		CypherText:   row.CypherText,
+		CreatedAt:    row.CreatedAt,
+		EditedAt:     timestamptz(row.EditedAt),
+		DeletedAt:    timestamptz(row.DeletedAt),
+		ReplyToID:    row.ReplyToID,
+		ThreadRootID: row.ThreadRootID,
+	}, liteErr(err)
+}
+
+func (q sqliteQueries) GetMessageForUpdate(ctx context.Context, arg database.GetMessageForUpdateParams) (database.GetMessageForUpdateRow, error) {
+	row, err := q.q.GetMessageForUpdate(ctx, sqlite.GetMessageForUpdateParams(arg))
+	return database.GetMessageForUpdateRow{
+		SenderID:     row.SenderID,
+		CypherText:   row.CypherText,
+		CreatedAt:    row.CreatedAt,
+		EditedAt:     timestamptz(row.EditedAt),
+		DeletedAt:    timestamptz(row.DeletedAt),
+		ReplyToID:    row.ReplyToID,
+		ThreadRootID: row.ThreadRootID,
+	}, liteErr(err)
+}
+
+func (q sqliteQueries) HideMessage(ctx context.Context, arg database.HideMessageParams) error {
+	return liteErr(q.q.HideMessage(ctx, sqlite.HideMessageParams(arg)))
+}
+
+func (q sqliteQueries) ListMessageCiphertexts(ctx context.Context, arg database.ListMessageCiphertextsParams) ([]database.ListMessageCiphertextsRow, error) {
+	rows, err := q.q.ListMessageCiphertexts(ctx, sqlite.ListMessageCiphertextsParams{AfterID: arg.AfterID, BatchSize: int64(arg.BatchSize)})
+	return convertRows(rows, func(r sqlite.ListMessageCiphertextsRow) database.ListMessageCiphertextsRow {
+		return database.ListMessageCiphertextsRow(r)
+	}), liteErr(err)
+}
+
+func (q sqliteQueries) ListMessageReactions(ctx context.Context, arg database.ListMessageReactionsParams) ([]database.ListMessageReactionsRow, error) {
+	rows, err := q.q.ListMessageReactions(ctx, sqlite.ListMessageReactionsParams(arg))
+	return convertRows(rows, func(r sqlite.ListMessageReactionsRow) database.ListMessageReactionsRow {
+		return database.ListMessageReactionsRow(r)
+	}), liteErr(err)
+}
+
+func (q sqliteQueries) ListMessageRevisionCiphertexts(ctx context.Context, arg database.ListMessageRevisionCiphertextsParams) ([]database.ListMessageRevisionCiphertextsRow, error) {
+	rows, err := q.q.ListMessageRevisionCiphertexts(ctx, sqlite.ListMessageRevisionCiphertextsParams{AfterID: arg.AfterID, BatchSize: int64(arg.BatchSize)})
+	return convertRows(rows, func(r sqlite.ListMessageRevisionCiphertextsRow) database.ListMessageRevisionCiphertextsRow {
+		return database.ListMessageRevisionCiphertextsRow(r)
+	}), liteErr(err)
+}
+
+func (q sqliteQueries) ListMessageRevisions(ctx context.Context, arg database.ListMessageRevisionsParams) ([]database.ListMessageRevisionsRow, error) {
+	rows, err := q.q.ListMessageRevisions(ctx, sqlite.ListMessageRevisionsParams(arg))
+	return convertRows(rows, func(r sqlite.ListMessageRevisionsRow) database.ListMessageRevisionsRow {
+		return database.ListMessageRevisionsRow(r)
+	}), liteErr(err)
+}
+
+func (q sqliteQueries) ListMessagesAfter(ctx context.Context, arg database.ListMessagesAfterParams) ([]database.ListMessagesAfterRow, error) {
+	rows, err := q.q.ListMessagesAfter(ctx, sqlite.ListMessagesAfterParams{
+		ChatID:       arg.ChatID,
+		ThreadRootID: arg.ThreadRootID,
+		AfterID:      arg.AfterID,
+		UserID:       arg.UserID,
+		PageSize:     int64(arg.PageSize),
+	})
+	return convertRows(rows, func(r sqlite.ListMessagesAfterRow) database.ListMessagesAfterRow {
+		return database.ListMessagesAfterRow{
+			MessageID:  r.MessageID,
+			SenderID:   r.SenderID,
+			CypherText: r.CypherText,
+			CreatedAt:  r.CreatedAt,
+			EditedAt:   timestamptz(r.EditedAt),
+			DeletedAt:  timestamptz(r.DeletedAt),
+			ReplyToID:  r.ReplyToID,
+		}
+	}), liteErr(err)
+}
+
+func (q sqliteQueries) ListMessagesBefore(ctx context.Context, arg database.ListMessagesBeforeParams) ([]database.ListMessagesBeforeRow, error) {
+	rows, err := q.q.ListMessagesBefore(ctx, sqlite.ListMessagesBeforeParams{
+		ChatID:       arg.ChatID,
+		ThreadRootID: arg.ThreadRootID,
+		BeforeID:     arg.BeforeID,
+		UserID:       arg.UserID,
+		PageSize:     int64(arg.PageSize),
+	})
+	return convertRows(rows, func(r sqlite.ListMessagesBeforeRow) database.ListMessagesBeforeRow {
+		return database.ListMessagesBeforeRow{
+			MessageID:  r.MessageID,
+			SenderID:   r.SenderID,
+			CypherText: r.CypherText,
+			CreatedAt:  r.CreatedAt,
+			EditedAt:   timestamptz(r.EditedAt),
+			DeletedAt:  timestamptz(r.DeletedAt),
+			ReplyToID:  r.ReplyToID,
+		}
+	}), liteErr(err)
+}
+
+func (q sqliteQueries) ListMessagesByID(ctx context.Context, arg database.ListMessagesByIDParams) ([]database.ListMessagesByIDRow, error) {
+	rows, err := q.q.ListMessagesByID(ctx, sqlite.ListMessagesByIDParams(arg))
+	return convertRows(rows, func(r sqlite.ListMessagesByIDRow) database.ListMessagesByIDRow {
+		return database.ListMessagesByIDRow{
+			MessageID:  r.MessageID,
+			SenderID:   r.SenderID,
+			CypherText: r.CypherText,
+			CreatedAt:  r.CreatedAt,
+			EditedAt:   timestamptz(r.EditedAt),
+			DeletedAt:  timestamptz(r.DeletedAt),
+		}
+	}), liteErr(err)
+}
+
+func (q sqliteQueries) ListThreadSummaries(ctx context.Context, arg database.ListThreadSummariesParams) ([]database.ListThreadSummariesRow, error) {
+	rootIDs := make([]*int64, len(arg.RootIds))
+	for i := range arg.RootIds {
+		rootIDs[i] = &arg.RootIds[i]
+	}
+	rows, err := q.q.ListThreadSummaries(ctx, sqlite.ListThreadSummariesParams{UserID: arg.UserID, RootIds: rootIDs})
+	return convertRows(rows, func(r sqlite.ListThreadSummariesRow) database.ListThreadSummariesRow {
+		return database.ListThreadSummariesRow(r)
+	}), liteErr(err)
+}
+
+func (q sqliteQueries) NextMessageID(ctx context.Context) (int64, error) {
+	v, err := q.q.NextMessageID(ctx)
+	return v, liteErr(err)
+}
+
+func (q sqliteQueries) RemoveReaction(ctx context.Context, arg database.RemoveReactionParams) (int64, error) {
+	v, err := q.q.RemoveReaction(ctx, sqlite.RemoveReactionParams(arg))
+	return v, liteErr(err)
+}
+
+func (q sqliteQueries) ReplaceMessageCiphertext(ctx context.Context, arg database.ReplaceMessageCiphertextParams) (int64, error) {
+	v, err := q.q.ReplaceMessageCiphertext(ctx, sqlite.ReplaceMessageCiphertextParams(arg))
+	return v, liteErr(err)
+}
+
+func (q sqliteQueries) ReplaceMessageRevisionCiphertext(ctx context.Context, arg database.ReplaceMessageRevisionCiphertextParams) (int64, error) {
+	v, err := q.q.ReplaceMessageRevisionCiphertext(ctx, sqlite.ReplaceMessageRevisionCiphertextParams(arg))
+	return v, liteErr(err)
+}
+
+func (q sqliteQueries) SetThreadLastRead(ctx context.Context, arg database.SetThreadLastReadParams) (int64, error) {
+	v, err := q.q.SetThreadLastRead(ctx, sqlite.SetThreadLastReadParams(arg))
+	return v, liteErr(err)
+}
+
+// attachment.sql
+
+func (q sqliteQueries) AttachToMessage(ctx context.Context, arg database.AttachToMessageParams) ([]database.Attachment, error) {
+	rows, err := q.q.AttachToMessage(ctx, sqlite.AttachToMessageParams(arg))
+	return convertRows(rows, liteAttachment), liteErr(err)
+}
+
+func (q sqliteQueries) CreateAttachment(ctx context.Context, arg database.CreateAttachmentParams) error {
+	return liteErr(q.q.CreateAttachment(ctx, sqlite.CreateAttachmentParams{
+		AttachmentID: arg.AttachmentID,
+		ChatID:       arg.ChatID,
+		UploaderID:   arg.UploaderID,
+		BlobKey:      arg.BlobKey,
+		FileName:     arg.FileName,
+		MimeType:     arg.MimeType,
+		SizeBytes:    arg.SizeBytes,
+		Sha256:       arg.Sha256,
+		Width:        widen(arg.Width),
+		Height:       widen(arg.Height),
+		ThumbnailKey: arg.ThumbnailKey,
+	}))
+}
+
+func (q sqliteQueries) DeleteMessageAttachments(ctx context.Context, arg database.DeleteMessageAttachmentsParams) ([]database.DeleteMessageAttachmentsRow, error) {
+	rows, err := q.q.DeleteMessageAttachments(ctx, sqlite.DeleteMessageAttachmentsParams(arg))
+	return convertRows(rows, func(r sqlite.DeleteMessageAttachmentsRow) database.DeleteMessageAttachmentsRow {
+		return database.DeleteMessageAttachmentsRow(r)
+	}), liteErr(err)
+}
+
+func (q sqliteQueries) GetAttachment(ctx context.Context, arg database.GetAttachmentParams) (database.Attachment, error) {
+	r, err := q.q.GetAttachment(ctx, sqlite.GetAttachmentParams(arg))
+	return liteAttachment(r), liteErr(err)
+}
+
+func (q sqliteQueries) GetAttachmentUsage(ctx context.Context, arg database.GetAttachmentUsageParams) (int64, error) {
+	v, err := q.q.GetAttachmentUsage(ctx, sqlite.GetAttachmentUsageParams(arg))
+	return v, liteErr(err)
+}
+
+func (q sqliteQueries) ListChatAttachmentBlobs(ctx context.Context, arg database.ListChatAttachmentBlobsParams) ([]database.ListChatAttachmentBlobsRow, error) {
+	rows, err := q.q.ListChatAttachmentBlobs(ctx, sqlite.ListChatAttachmentBlobsParams(arg))
+	return convertRows(rows, func(r sqlite.ListChatAttachmentBlobsRow) database.ListChatAttachmentBlobsRow {
+		return database.ListChatAttachmentBlobsRow(r)
+	}), liteErr(err)
+}
+
+func (q sqliteQueries) ListMessageAttachments(ctx context.Context, arg database.ListMessageAttachmentsParams) ([]database.Attachment, error) {
+	messageIDs := make([]*int64, len(arg.MessageIds))
+	for i := range arg.MessageIds {
+		messageIDs[i] = &arg.MessageIds[i]
+	}
+	rows, err := q.q.ListMessageAttachments(ctx, sqlite.ListMessageAttachmentsParams{MessageIds: messageIDs})
+	return convertRows(rows, liteAttachment), liteErr(err)
+}
+
+func (q sqliteQueries) NextAttachmentID(ctx context.Context) (int64, error) {
+	v, err := q.q.NextAttachmentID(ctx)
+	return v, liteErr(err)
+}
+
+// search.sql
+
+func (q sqliteQueries) CreateMessageSearchTokens(ctx context.Context, arg database.CreateMessageSearchTokensParams) error {
+	for _, token := range arg.Tokens {
+		err := q.q.CreateMessageSearchToken(ctx, sqlite.CreateMessageSearchTokenParams{MessageID: arg.MessageID, Token: token})
+		if err != nil {
+			return liteErr(err)
+		}
+	}
+	return nil
+}
+
+func (q sqliteQueries) DeleteMessageSearchTokens(ctx context.Context, arg database.DeleteMessageSearchTokensParams) error {
+	return liteErr(q.q.DeleteMessageSearchTokens(ctx, sqlite.DeleteMessageSearchTokensParams(arg)))
+}
+
+func (q sqliteQueries) ListSearchableChats(ctx context.Context, arg database.ListSearchableChatsParams) ([]database.ListSearchableChatsRow, error) {
+	rows, err := q.q.ListSearchableChats(ctx, sqlite.ListSearchableChatsParams(arg))
+	return convertRows(rows, func(r sqlite.ListSearchableChatsRow) database.ListSearchableChatsRow {
+		return database.ListSearchableChatsRow(r)
+	}), liteErr(err)
+}
+
+func (q sqliteQueries) SearchMessages(ctx context.Context, arg database.SearchMessagesParams) ([]database.SearchMessagesRow, error) {
+	ids, err := q.q.SearchMessageIDs(ctx, sqlite.SearchMessageIDsParams{
+		BeforeID:  arg.BeforeID,
+		UserID:    arg.UserID,
+		TermCount: arg.TermCount,
+		Tokens:    arg.Tokens,
+		ChatIds:   arg.ChatIds,
+	})
+	if err != nil || len(ids) == 0 {
+		return []database.SearchMessagesRow{}, liteErr(err)
+	}
+
+	rows, err := q.q.ListSearchResults(ctx, sqlite.ListSearchResultsParams{MessageIds: limit(ids, arg.PageSize, 0)})
+	return convertRows(rows, func(r sqlite.ListSearchResultsRow) database.SearchMessagesRow {
+		return database.SearchMessagesRow{
+			MessageID:    r.MessageID,
+			ChatID:       r.ChatID,
+			SenderID:     r.SenderID,
+			CypherText:   r.CypherText,
+			CreatedAt:    r.CreatedAt,
+			EditedAt:     timestamptz(r.EditedAt),
+			ThreadRootID: r.ThreadRootID,
+		}
+	}), liteErr(err)
+}
+
+func liteAttachment(r sqlite.Attachment) database.Attachment {
+	return database.Attachment{
+		AttachmentID: r.AttachmentID,
+		ChatID:       r.ChatID,
+		UploaderID:   r.UploaderID,
+		MessageID:    r.MessageID,
+		BlobKey:      r.BlobKey,
+		FileName:     r.FileName,
+		MimeType:     r.MimeType,
+		SizeBytes:    r.SizeBytes,
+		Sha256:       r.Sha256,
+		Width:        narrow(r.Width),
+		Height:       narrow(r.Height),
+		CreatedAt:    r.CreatedAt,
+		ThumbnailKey: r.ThumbnailKey,
+	}
+}
+
+// arrayAgg turns the JSON array json_group_array builds into what pgx scans
+// for ARRAY_AGG: a []any of int64, or nil when there were no rows.
+func arrayAgg(v any) (any, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, nil
+	}
+
+	var ids []int64
+	if err := json.Unmarshal([]byte(s), &ids); err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	agg := make([]any, len(ids))
+	for i, id := range ids {
+		agg[i] = id
+	}
+	return agg, nil
+}
@@ -0,0 +1,28 @@
+// Package store is the storage the route handlers run against. The queries
+// themselves are the sqlc-generated database.Querier, grouped by the .sql
+// file they live in (users, friends, chats, messages, auth, keys); Postgres
+// serves them through pgx and Memory keeps everything in process for tests.
+package store
+
+import (
+	"context"
+	"errors"
+
+	"github.com/astrokkidd/flick/pkg/database"
+)
+
+// ErrUnavailable is returned by Tx when no transaction could be started.
+var ErrUnavailable = errors.New("store unavailable")
+
+// Store runs queries on their own or grouped in a transaction.
+//
+// Implementations follow the sqlc contract: :one queries that match nothing
+// return pgx.ErrNoRows, and constraint violations are *pgconn.PgError values
+// carrying the Postgres error code.
+type Store interface {
+	database.Querier
+
+	// Tx runs fn in a transaction, committing when it returns nil. Errors
+	// from fn are returned unchanged.
+	Tx(ctx context.Context, fn func(q database.Querier) error) error
+}
@@ -0,0 +1,390 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/astrokkidd/flick/pkg/database"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Every test in this file runs against each Store, so Memory and SQLite are
+// held to what Postgres does. Postgres only joins in when
+// FLICK_TEST_DATABASE_URL names a database the tests may create schemas in.
+func eachStore(t *testing.T, fn func(t *testing.T, s Store)) {
+	t.Run("memory", func(t *testing.T) { fn(t, NewMemory()) })
+	t.Run("sqlite", func(t *testing.T) { fn(t, openTestSQLite(t)) })
+	t.Run("postgres", func(t *testing.T) { fn(t, openTestPostgres(t)) })
+}
+
+func openTestSQLite(t *testing.T) *SQLite {
+	t.Helper()
+	schema, err := os.ReadFile("../../configs/schema/sqlite/schema.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "flick.db")
+	db, err := sql.Open("sqlite", "file:"+path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(string(schema))
+	db.Close()
+	if err != nil {
+		t.Fatalf("applying schema: %v", err)
+	}
+
+	s, err := OpenSQLite(context.Background(), path, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func openTestPostgres(t *testing.T) *Postgres {
+	t.Helper()
+	url := os.Getenv("FLICK_TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("FLICK_TEST_DATABASE_URL not set")
+	}
+	schema, err := os.ReadFile("../../configs/schema/schema.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// Each test gets a schema of its own, dropped afterwards
+	name := fmt.Sprintf("flick_test_%d", time.Now().UnixNano())
+	admin, err := pgx.Connect(ctx, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close(ctx)
+	if _, err := admin.Exec(ctx, "CREATE SCHEMA "+name); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if conn, err := pgx.Connect(context.Background(), url); err == nil {
+			conn.Exec(context.Background(), "DROP SCHEMA "+name+" CASCADE")
+			conn.Close(context.Background())
+		}
+	})
+
+	cfg, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.ConnConfig.RuntimeParams["search_path"] = name + ",public"
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+
+	if _, err := pool.Exec(ctx, string(schema)); err != nil {
+		t.Fatalf("applying schema: %v", err)
+	}
+	return NewPostgres(pool, 0)
+}
+
+func createUser(t *testing.T, q database.Querier, name string) int64 {
+	t.Helper()
+	row, err := q.CreateUser(context.Background(), database.CreateUserParams{
+		DisplayName:  name,
+		FirstName:    name,
+		LastName:     "Test",
+		PasswordHash: "hash",
+	})
+	if err != nil {
+		t.Fatalf("CreateUser(%s): %v", name, err)
+	}
+	return row.UserID
+}
+
+func createChat(t *testing.T, q database.Querier, members ...int64) int64 {
+	t.Helper()
+	ctx := context.Background()
+	chatID, err := q.CreateGroupChat(ctx, database.CreateGroupChatParams{Title: ptr("test")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, uid := range members {
+		if err := q.AddParticipant(ctx, database.AddParticipantParams{ChatID: chatID, UserID: uid, Role: "member"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return chatID
+}
+
+func createMessage(t *testing.T, q database.Querier, chatID, senderID int64) int64 {
+	t.Helper()
+	ctx := context.Background()
+	id, err := q.NextMessageID(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = q.CreateMessage(ctx, database.CreateMessageParams{
+		MessageID:  id,
+		ChatID:     chatID,
+		SenderID:   senderID,
+		CypherText: []byte("sealed"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func pgCode(err error) string {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code
+	}
+	return ""
+}
+
+func TestStoreErrors(t *testing.T) {
+	eachStore(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		createUser(t, s, "ada")
+
+		// SQLite checks the case-insensitive index first, so an exact
+		// duplicate may be reported against either constraint
+		_, err := s.CreateUser(ctx, database.CreateUserParams{DisplayName: "ada", PasswordHash: "hash"})
+		var pgErr *pgconn.PgError
+		if !errors.As(err, &pgErr) || pgErr.Code != "23505" ||
+			pgErr.ConstraintName != "users_display_name_key" && pgErr.ConstraintName != "uq_users_display_name_ci" {
+			t.Errorf("duplicate display name = %v, want a display name violation", err)
+		}
+
+		_, err = s.CreateUser(ctx, database.CreateUserParams{DisplayName: "ADA", PasswordHash: "hash"})
+		if !errors.As(err, &pgErr) || pgErr.Code != "23505" || pgErr.ConstraintName != "uq_users_display_name_ci" {
+			t.Errorf("display name differing in case = %v, want uq_users_display_name_ci violation", err)
+		}
+
+		if _, err := s.FindUserByDisplayName(ctx, database.FindUserByDisplayNameParams{DisplayName: "bob"}); !errors.Is(err, pgx.ErrNoRows) {
+			t.Errorf("missing user = %v, want pgx.ErrNoRows", err)
+		}
+
+		err = s.AddParticipant(ctx, database.AddParticipantParams{ChatID: 999, UserID: 1, Role: "member"})
+		if code := pgCode(err); code != "23503" {
+			t.Errorf("participant of a missing chat = %v, want a foreign key violation", err)
+		}
+	})
+}
+
+func TestStoreTx(t *testing.T) {
+	eachStore(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		errAbort := errors.New("abort")
+
+		// Everything a failed transaction did is undone, and its error comes
+		// back unchanged
+		err := s.Tx(ctx, func(q database.Querier) error {
+			uid := createUser(t, q, "ada")
+			createChat(t, q, uid)
+			return errAbort
+		})
+		if err != errAbort {
+			t.Errorf("Tx = %v, want the error fn returned", err)
+		}
+		if _, err := s.FindUserByDisplayName(ctx, database.FindUserByDisplayNameParams{DisplayName: "ada"}); !errors.Is(err, pgx.ErrNoRows) {
+			t.Errorf("user from a rolled back transaction: %v", err)
+		}
+
+		// A constraint violation part way fails the whole transaction too
+		err = s.Tx(ctx, func(q database.Querier) error {
+			createUser(t, q, "bob")
+			_, err := q.CreateUser(ctx, database.CreateUserParams{DisplayName: "bob", PasswordHash: "hash"})
+			return err
+		})
+		if pgCode(err) != "23505" {
+			t.Errorf("Tx = %v, want a unique violation", err)
+		}
+		if _, err := s.FindUserByDisplayName(ctx, database.FindUserByDisplayNameParams{DisplayName: "bob"}); !errors.Is(err, pgx.ErrNoRows) {
+			t.Errorf("user from a failed transaction: %v", err)
+		}
+
+		var uid int64
+		if err := s.Tx(ctx, func(q database.Querier) error {
+			uid = createUser(t, q, "cy")
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if user, err := s.FindUserByID(ctx, database.FindUserByIDParams{UserID: uid}); err != nil || user.DisplayName != "cy" {
+			t.Errorf("committed user = %+v, %v", user, err)
+		}
+	})
+}
+
+func TestStoreMessagePages(t *testing.T) {
+	eachStore(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		ada, bob := createUser(t, s, "ada"), createUser(t, s, "bob")
+		chatID := createChat(t, s, ada, bob)
+
+		var ids []int64
+		for range 5 {
+			ids = append(ids, createMessage(t, s, chatID, ada))
+		}
+
+		before := func(beforeID *int64, n int32) []int64 {
+			rows, err := s.ListMessagesBefore(ctx, database.ListMessagesBeforeParams{ChatID: chatID, BeforeID: beforeID, UserID: bob, PageSize: n})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []int64
+			for _, r := range rows {
+				got = append(got, r.MessageID)
+			}
+			return got
+		}
+		after := func(afterID *int64, n int32) []int64 {
+			rows, err := s.ListMessagesAfter(ctx, database.ListMessagesAfterParams{ChatID: chatID, AfterID: afterID, UserID: bob, PageSize: n})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []int64
+			for _, r := range rows {
+				got = append(got, r.MessageID)
+			}
+			return got
+		}
+
+		tests := []struct {
+			name string
+			got  []int64
+			want []int64
+		}{
+			{"latest", before(nil, 2), []int64{ids[4], ids[3]}},
+			{"before", before(&ids[3], 2), []int64{ids[2], ids[1]}},
+			{"before first", before(&ids[0], 2), nil},
+			{"earliest", after(nil, 2), []int64{ids[0], ids[1]}},
+			{"after", after(&ids[1], 10), []int64{ids[2], ids[3], ids[4]}},
+			{"after last", after(&ids[4], 10), nil},
+		}
+		for _, tt := range tests {
+			if !slices.Equal(tt.got, tt.want) {
+				t.Errorf("%s: got %v, want %v", tt.name, tt.got, tt.want)
+			}
+		}
+
+		// Hidden messages drop out for the user who hid them only
+		if err := s.HideMessage(ctx, database.HideMessageParams{UserID: bob, MessageID: ids[4]}); err != nil {
+			t.Fatal(err)
+		}
+		if got := before(nil, 1); !slices.Equal(got, []int64{ids[3]}) {
+			t.Errorf("after hiding, latest = %v", got)
+		}
+		rows, err := s.ListMessagesBefore(ctx, database.ListMessagesBeforeParams{ChatID: chatID, UserID: ada, PageSize: 1})
+		if err != nil || len(rows) != 1 || rows[0].MessageID != ids[4] {
+			t.Errorf("another user's hidden message vanished for the sender: %v, %v", rows, err)
+		}
+	})
+}
+
+func TestStoreSearch(t *testing.T) {
+	eachStore(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		ada := createUser(t, s, "ada")
+		chatID := createChat(t, s, ada)
+
+		var ids []int64
+		for _, words := range [][]string{{"red", "fox"}, {"red"}, {"red", "fox", "den"}} {
+			id := createMessage(t, s, chatID, ada)
+			var tokens [][]byte
+			for _, w := range words {
+				tokens = append(tokens, []byte(w))
+			}
+			if err := s.CreateMessageSearchTokens(ctx, database.CreateMessageSearchTokensParams{MessageID: id, Tokens: tokens}); err != nil {
+				t.Fatal(err)
+			}
+			ids = append(ids, id)
+		}
+
+		search := func(words ...string) []int64 {
+			var toks [][]byte
+			for _, w := range words {
+				toks = append(toks, []byte(w))
+			}
+			rows, err := s.SearchMessages(ctx, database.SearchMessagesParams{
+				ChatIds:   []int64{chatID},
+				Tokens:    toks,
+				TermCount: int64(len(toks)),
+				UserID:    ada,
+				PageSize:  10,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []int64
+			for _, r := range rows {
+				got = append(got, r.MessageID)
+			}
+			return got
+		}
+
+		if got := search("red"); !slices.Equal(got, []int64{ids[2], ids[1], ids[0]}) {
+			t.Errorf("red = %v, want newest first", got)
+		}
+		if got := search("red", "fox"); !slices.Equal(got, []int64{ids[2], ids[0]}) {
+			t.Errorf("red fox = %v, want messages holding every word", got)
+		}
+		if got := search("cat"); got != nil {
+			t.Errorf("cat = %v, want nothing", got)
+		}
+	})
+}
+
+func TestStoreMFA(t *testing.T) {
+	eachStore(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		uid := createUser(t, s, "ada")
+		expires := time.Now().Add(time.Minute)
+
+		for i, want := range []int64{1, 0} {
+			n, err := s.UseMFAChallenge(ctx, database.UseMFAChallengeParams{ChallengeID: "jti", UserID: uid, ExpiresAt: expires})
+			if err != nil || n != want {
+				t.Errorf("use %d of a challenge = %d, %v, want %d", i+1, n, err, want)
+			}
+		}
+
+		lockedUntil := time.Now().Add(time.Hour).Truncate(time.Second)
+		for i := range 2 {
+			err := s.RecordMFAFailure(ctx, database.RecordMFAFailureParams{MaxAttempts: 2, LockedUntil: lockedUntil, UserID: uid})
+			if err != nil {
+				t.Fatal(err)
+			}
+			state, err := s.GetUserTOTPForUpdate(ctx, database.GetUserTOTPForUpdateParams{UserID: uid})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if locked := state.MfaLockedUntil.Valid; locked != (i == 1) {
+				t.Errorf("after %d failures, locked = %v", i+1, locked)
+			}
+			if i == 1 && !state.MfaLockedUntil.Time.Equal(lockedUntil) {
+				t.Errorf("locked until %v, want %v", state.MfaLockedUntil.Time, lockedUntil)
+			}
+		}
+
+		if err := s.ResetMFAFailures(ctx, database.ResetMFAFailuresParams{UserID: uid}); err != nil {
+			t.Fatal(err)
+		}
+		state, err := s.GetUserTOTPForUpdate(ctx, database.GetUserTOTPForUpdateParams{UserID: uid})
+		if err != nil || state.MfaLockedUntil.Valid {
+			t.Errorf("after reset = %+v, %v", state.MfaLockedUntil, err)
+		}
+	})
+}